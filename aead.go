@@ -0,0 +1,190 @@
+package firebase
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// aeadCodecPrefix is the prefix of values encoded by AEADCodec.
+const aeadCodecPrefix = "aead:"
+
+// Keyring provides the keys for an AEADCodec.
+//
+// Keys are identified by an ID that is stored alongside the ciphertext,
+// allowing keys to be rotated: values are always encrypted with the current
+// key, and decrypted with the key that encrypted them. A Keyring backed by a
+// KMS (ie, holding data encryption keys decrypted by the KMS) can be used by
+// implementing this interface.
+type Keyring interface {
+	// CurrentKey returns the ID and the key used to encrypt values.
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the ID.
+	Key(id string) ([]byte, error)
+}
+
+// LocalKeyring is a Keyring of locally held keys.
+type LocalKeyring struct {
+	// Current is the ID of the key used to encrypt values.
+	Current string
+
+	// Keys are the AES keys (16, 24, or 32 bytes), keyed by ID. IDs cannot
+	// contain a colon.
+	Keys map[string][]byte
+}
+
+// CurrentKey satisfies the Keyring interface.
+func (kr *LocalKeyring) CurrentKey() (string, []byte, error) {
+	key, err := kr.Key(kr.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	return kr.Current, key, nil
+}
+
+// Key satisfies the Keyring interface.
+func (kr *LocalKeyring) Key(id string) ([]byte, error) {
+	key, ok := kr.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// AEADCodec is a value codec that encrypts values with AES-GCM, storing them
+// as strings containing the key ID and base64 encoded ciphertext, such that
+// sensitive values are stored as ciphertext (even to Firebase console
+// viewers).
+//
+// The key ID and the database path of the value are bound to the ciphertext
+// as associated data, such that a ciphertext cannot be moved to another field
+// or node and still decrypt.
+//
+// AEADCodec can be applied to database paths with PathCodec, or to tagged
+// struct fields with EncryptFields.
+type AEADCodec struct {
+	// Keyring provides the encryption keys.
+	Keyring Keyring
+
+	// AllowPlaintext allows values not encoded by the codec (ie, values
+	// written before the codec was applied) to be decoded as is. When false,
+	// decoding a non-null value not encoded by the codec returns an error.
+	AllowPlaintext bool
+}
+
+// aead returns the AES-GCM AEAD for the key.
+func (c AEADCodec) aead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encode satisfies the ValueCodec interface, encrypting the value without a
+// database path.
+func (c AEADCodec) Encode(buf []byte) ([]byte, error) {
+	return c.EncodePath("", buf)
+}
+
+// Decode satisfies the ValueCodec interface, decrypting a value encrypted
+// without a database path.
+func (c AEADCodec) Decode(buf []byte) ([]byte, error) {
+	return c.DecodePath("", buf)
+}
+
+// additionalData returns the associated data for the key ID and path.
+func (c AEADCodec) additionalData(id, path string) []byte {
+	return []byte(id + ":" + path)
+}
+
+// EncodePath satisfies the PathValueCodec interface.
+func (c AEADCodec) EncodePath(path string, buf []byte) ([]byte, error) {
+	id, key, err := c.Keyring.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(id, ":") {
+		return nil, fmt.Errorf("invalid key id %q", id)
+	}
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nonce, nonce, buf, c.additionalData(id, path))
+
+	return json.Marshal(aeadCodecPrefix + id + ":" + base64.StdEncoding.EncodeToString(ciphertext))
+}
+
+// DecodePath satisfies the PathValueCodec interface.
+func (c AEADCodec) DecodePath(path string, buf []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil || !strings.HasPrefix(s, aeadCodecPrefix) {
+		// not encoded by the codec
+		if c.AllowPlaintext || string(bytes.TrimSpace(buf)) == "null" {
+			return buf, nil
+		}
+		return nil, errors.New("value is not encrypted")
+	}
+
+	i := strings.IndexByte(s[len(aeadCodecPrefix):], ':')
+	if i == -1 {
+		return nil, errors.New("missing key id")
+	}
+	id, enc := s[len(aeadCodecPrefix):len(aeadCodecPrefix)+i], s[len(aeadCodecPrefix)+i+1:]
+
+	key, err := c.Keyring.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], c.additionalData(id, path))
+}
+
+// EncryptFields is an option that applies the value codec (typically an
+// AEADCodec) to struct fields tagged with `firebase:",encrypt"`, encoding the
+// fields of values written with Set, Push, and Update, and decoding the
+// fields of values read with Get.
+//
+// For example:
+//
+//	type User struct {
+//		Name  string `json:"name"`
+//		Email string `json:"email" firebase:",encrypt"`
+//	}
+//
+// Tagged fields are found in nested structs, and in the elements of maps and
+// slices (ie, map[string]User).
+func EncryptFields(codec ValueCodec) Option {
+	return func(r *DatabaseRef) error {
+		if codec == nil {
+			return errors.New("field codec cannot be nil")
+		}
+		r.setFieldCodec("encrypt", codec)
+		return nil
+	}
+}
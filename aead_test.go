@@ -0,0 +1,241 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncryptFields(t *testing.T) {
+	var stored []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write(stored)
+			return
+		}
+		stored, _ = ioutil.ReadAll(req.Body)
+		w.Write(stored)
+	}))
+	defer ts.Close()
+
+	keyring := &LocalKeyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef0123456789abcdef"),
+		},
+	}
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), EncryptFields(AEADCodec{Keyring: keyring}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email" firebase:",encrypt"`
+	}
+	if err = r.Ref("/users").Set(map[string]user{"a": {"alice", "alice@example.com"}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// check stored value is encrypted
+	var raw map[string]map[string]string
+	if err = json.Unmarshal(stored, &raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := raw["a"]["email"]; !strings.HasPrefix(s, aeadCodecPrefix+"k1:") {
+		t.Errorf("expected encrypted email, got: %q", s)
+	}
+	if s := raw["a"]["name"]; s != "alice" {
+		t.Errorf("expected name alice, got: %q", s)
+	}
+
+	// rotate key, and check decrypted
+	keyring.Keys["k2"], keyring.Current = []byte("fedcba9876543210"), "k2"
+	var v map[string]user
+	if err = r.Ref("/users").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v["a"].Email != "alice@example.com" {
+		t.Errorf("expected decrypted email, got: %q", v["a"].Email)
+	}
+
+	// tampered ciphertext
+	stored = []byte(strings.Replace(string(stored), aeadCodecPrefix+"k1:", aeadCodecPrefix+"k2:", 1))
+	if err = r.Ref("/users").Get(&v); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestEncryptFieldsMoved(t *testing.T) {
+	var stored []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write(stored)
+			return
+		}
+		stored, _ = ioutil.ReadAll(req.Body)
+		w.Write(stored)
+	}))
+	defer ts.Close()
+
+	keyring := &LocalKeyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef"),
+		},
+	}
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), EncryptFields(AEADCodec{Keyring: keyring}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email" firebase:",encrypt"`
+	}
+	if err = r.Ref("/users").Set(map[string]user{"a": {"alice", "alice@example.com"}, "b": {"bob", "bob@example.com"}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// move alice's ciphertext to bob
+	var raw map[string]map[string]string
+	if err = json.Unmarshal(stored, &raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	raw["b"]["email"] = raw["a"]["email"]
+	stored, _ = json.Marshal(raw)
+
+	var v map[string]user
+	if err = r.Ref("/users").Get(&v); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestEncryptFieldsWrites(t *testing.T) {
+	var mu sync.Mutex
+	stored := make(map[string][]byte)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Method == "GET" {
+			buf, ok := stored[req.URL.Path]
+			if !ok {
+				buf = []byte("null")
+			}
+			w.Header().Set("ETag", "etag")
+			w.Write(buf)
+			return
+		}
+		buf, _ := ioutil.ReadAll(req.Body)
+		stored[req.URL.Path] = buf
+		w.Write(buf)
+	}))
+	defer ts.Close()
+
+	keyring := &LocalKeyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef"),
+		},
+	}
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), EncryptFields(AEADCodec{Keyring: keyring}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email" firebase:",encrypt"`
+	}
+
+	// encrypted returns the stored email for the path, checking it is
+	// encrypted
+	encrypted := func(test, path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		var raw map[string]interface{}
+		if err := json.Unmarshal(stored[path], &raw); err != nil {
+			t.Fatalf("%s expected no error, got: %v", test, err)
+		}
+		if s, _ := raw["email"].(string); !strings.HasPrefix(s, aeadCodecPrefix+"k1:") {
+			t.Errorf("%s expected encrypted email, got: %q", test, s)
+		}
+	}
+
+	// set if match
+	if err = r.Ref("a").SetIfMatch("etag", user{"alice", "alice@example.com"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	encrypted("SetIfMatch", "/a.json")
+
+	var u user
+	if _, err = r.Ref("a").GetWithETag(&u); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if u.Email != "alice@example.com" {
+		t.Errorf("expected decrypted email, got: %q", u.Email)
+	}
+
+	// transaction
+	var email string
+	err = r.Ref("a").Transaction(func(current json.RawMessage) (interface{}, error) {
+		var u user
+		if err := json.Unmarshal(current, &u); err != nil {
+			return nil, err
+		}
+		email, u.Name = u.Email, "alice2"
+		return u, nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if email != "alice@example.com" {
+		t.Errorf("expected decrypted current email, got: %q", email)
+	}
+	encrypted("Transaction", "/a.json")
+
+	// meta ref
+	if err = NewMetaRef(r, "test").Ref("b").Set(user{"bob", "bob@example.com"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	encrypted("MetaRef.Set", "/b.json")
+	mu.Lock()
+	if !strings.Contains(string(stored["/b.json"]), `"_meta"`) {
+		t.Errorf("expected meta, got: %s", stored["/b.json"])
+	}
+	mu.Unlock()
+}
+
+func TestAEADCodecPlaintext(t *testing.T) {
+	keyring := &LocalKeyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef"),
+		},
+	}
+	tests := []struct {
+		allow bool
+		buf   string
+		ok    bool
+	}{
+		{false, `"alice@example.com"`, false},
+		{false, `1`, false},
+		{false, `null`, true},
+		{true, `"alice@example.com"`, true},
+		{true, `1`, true},
+	}
+	for i, test := range tests {
+		c := AEADCodec{Keyring: keyring, AllowPlaintext: test.allow}
+		buf, err := c.DecodePath("/users/a/email", []byte(test.buf))
+		if test.ok != (err == nil) {
+			t.Errorf("test %d expected ok %t, got: %v", i, test.ok, err)
+		}
+		if err == nil && string(buf) != test.buf {
+			t.Errorf("test %d expected %s, got: %s", i, test.buf, buf)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
+	"sync"
+)
+
+// affinityTransport wraps a http.RoundTripper, persisting the session cookies
+// and the values of the named response headers across requests.
+//
+// The persisted values are shared by all transports created by the same
+// SessionAffinity option.
+type affinityTransport struct {
+	transport http.RoundTripper
+	headers   []string
+	jar       http.CookieJar
+
+	mu     *sync.RWMutex
+	values map[string]string
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (at *affinityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trans := at.transport
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	// copy request
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+len(at.headers)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	// add persisted headers and cookies
+	at.mu.RLock()
+	for k, v := range at.values {
+		if r.Header.Get(k) == "" {
+			r.Header.Set(k, v)
+		}
+	}
+	at.mu.RUnlock()
+	for _, c := range at.jar.Cookies(r.URL) {
+		r.AddCookie(c)
+	}
+
+	res, err := trans.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// persist returned headers and cookies
+	at.mu.Lock()
+	for _, k := range at.headers {
+		if v := res.Header.Get(k); v != "" {
+			at.values[k] = v
+		}
+	}
+	at.mu.Unlock()
+	if cookies := res.Cookies(); len(cookies) != 0 {
+		at.jar.SetCookies(r.URL, cookies)
+	}
+
+	return res, nil
+}
+
+// SessionAffinity is an option that persists the session cookies set by the
+// server or an intermediary (ie, a load balancer fronting the database), and
+// the values of the named response headers (ie, "X-Backend-Server"), sending
+// them with subsequent requests made by the database ref and its children.
+//
+// This is needed for load-balanced REST proxies that pin a client to a
+// backend using a cookie or affinity header.
+func SessionAffinity(headers ...string) Option {
+	return func(r *DatabaseRef) error {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+
+		canonical := make([]string, len(headers))
+		for i, h := range headers {
+			canonical[i] = textproto.CanonicalMIMEHeaderKey(h)
+		}
+
+		mu, values := new(sync.RWMutex), make(map[string]string)
+		return wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &affinityTransport{
+				transport: transport,
+				headers:   canonical,
+				jar:       jar,
+				mu:        mu,
+				values:    values,
+			}
+		})(r)
+	}
+}
@@ -0,0 +1,43 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionAffinity(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if n == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			w.Header().Set("X-Backend", "b1")
+		} else {
+			if c, err := req.Cookie("session"); err != nil || c.Value != "s1" {
+				t.Errorf("expected session cookie s1, got: %v", c)
+			}
+			if s := req.Header.Get("X-Backend"); s != "b1" {
+				t.Errorf("expected X-Backend b1, got: %q", s)
+			}
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), SessionAffinity("x-backend"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var v interface{}
+	if err = r.Ref("a").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Ref("b/c").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 requests, got: %d", n)
+	}
+}
@@ -0,0 +1,363 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultBatchConcurrency is the default number of concurrent write
+	// requests issued by a BatchWriter.
+	DefaultBatchConcurrency = 8
+)
+
+// MultiError is the error returned by a batch write, mapping the keys (child
+// paths) of the failed writes to their errors.
+type MultiError map[string]error
+
+// Error satisfies the error interface.
+func (e MultiError) Error() string {
+	keys := e.Keys()
+	if len(keys) == 0 {
+		return "firebase: no errors"
+	}
+	s := strings.TrimPrefix(e[keys[0]].Error(), "firebase: ")
+	if len(keys) == 1 {
+		return fmt.Sprintf("firebase: write to %s failed: %s", keys[0], s)
+	}
+	return fmt.Sprintf("firebase: %d writes failed, including %s: %s", len(keys), keys[0], s)
+}
+
+// Keys returns the sorted keys of the failed writes.
+func (e MultiError) Keys() []string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// BatchWriter writes many values to the children of a Firebase database ref
+// using a bounded pool of concurrent requests.
+//
+// Values are either written as a batch (see Set and Push), or queued for
+// writing in the background (see Enqueue and Flush). A BatchWriter must not
+// be copied after first use.
+type BatchWriter struct {
+	// Concurrency is the maximum number of concurrent requests. If zero, then
+	// DefaultBatchConcurrency is used.
+	Concurrency int
+
+	// StrictOrdering, when set, serializes writes to overlapping paths (ie,
+	// "users/x" and "users/x/name"), such that they are applied in the order
+	// they were issued, and disables the coalescing of queued writes.
+	StrictOrdering bool
+
+	mu sync.Mutex
+
+	// sem bounds the concurrent queued writes.
+	sem chan struct{}
+
+	// latest is the most recently queued write for each path.
+	latest map[string]*batchWrite
+
+	// outstanding are the queued writes that have not completed.
+	outstanding map[*batchWrite]bool
+
+	// errs are the errors of the queued writes since the last flush.
+	errs MultiError
+}
+
+// batchWrite is a queued write.
+type batchWrite struct {
+	r    *DatabaseRef
+	v    interface{}
+	opts []QueryOption
+
+	host, path string
+
+	// deps are the writes that must complete prior to the write.
+	deps []chan struct{}
+
+	started bool
+	done    chan struct{}
+}
+
+// Set sets the values keyed by their child paths relative to Firebase
+// database ref r (ie, "users/x" or "y"), issuing the writes in key order.
+// When StrictOrdering is set, writes to overlapping paths are serialized in
+// key order (ie, "users/x" is written before "users/x/name").
+//
+// Each failed write is recorded in the returned MultiError. When the
+// context is done, the pending writes are not issued and are recorded in the
+// MultiError with the context's error.
+func (b *BatchWriter) Set(r *DatabaseRef, ctxt context.Context, values map[string]interface{}, opts ...QueryOption) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return b.write(ctxt, keys, b.StrictOrdering, func(k string) error {
+		return SetContext(r.Ref(k), ctxt, values[k], opts...)
+	})
+}
+
+// Push pushes the values to Firebase database ref r, returning the generated
+// keys in the same order as values.
+//
+// The keys are generated client side (see GeneratePushID) prior to writing,
+// such that the keys sort in the order of values regardless of the order the
+// writes complete. Failed writes are recorded in the returned MultiError, as
+// with Set.
+func (b *BatchWriter) Push(r *DatabaseRef, ctxt context.Context, values []interface{}, opts ...QueryOption) ([]string, error) {
+	keys := make([]string, len(values))
+	m := make(map[string]interface{}, len(values))
+	for i, v := range values {
+		keys[i] = GeneratePushID()
+		m[keys[i]] = v
+	}
+
+	return keys, b.write(ctxt, keys, false, func(k string) error {
+		return SetContext(r.Ref(k), ctxt, m[k], opts...)
+	})
+}
+
+// concurrency returns the maximum number of concurrent requests.
+func (b *BatchWriter) concurrency() int {
+	if b.Concurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return b.Concurrency
+}
+
+// write concurrently calls f for each of the keys, returning a MultiError
+// for the failed and pending keys. When ordered is true, the call for a key
+// waits for the calls for its ancestor keys to complete.
+func (b *BatchWriter) write(ctxt context.Context, keys []string, ordered bool, f func(string) error) error {
+	// determine ancestor dependencies
+	done := make([]chan struct{}, len(keys))
+	deps := make([][]chan struct{}, len(keys))
+	if ordered {
+		index := make(map[string]int, len(keys))
+		for i, k := range keys {
+			done[i] = make(chan struct{})
+			parts := splitPath(k)
+			for j := 0; j <= len(parts); j++ {
+				if n, ok := index[strings.Join(parts[:j], "/")]; ok {
+					deps[i] = append(deps[i], done[n])
+				}
+			}
+			index[strings.Join(parts, "/")] = i
+		}
+	}
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	errs := make(MultiError)
+
+	// start workers
+	var wg sync.WaitGroup
+	for i := 0; i < b.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				for _, d := range deps[i] {
+					<-d
+				}
+				if err := f(keys[i]); err != nil {
+					mu.Lock()
+					errs[keys[i]] = err
+					mu.Unlock()
+				}
+				if done[i] != nil {
+					close(done[i])
+				}
+			}
+		}()
+	}
+
+	// queue jobs
+	for i := range keys {
+		if ctxt.Err() == nil {
+			select {
+			case jobs <- i:
+				continue
+			case <-ctxt.Done():
+			}
+		}
+		mu.Lock()
+		for _, k := range keys[i:] {
+			errs[k] = ctxt.Err()
+		}
+		mu.Unlock()
+		break
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Enqueue queues the value to be set to Firebase database ref r in the
+// background, returning immediately. Errors are reported by Flush.
+//
+// Writes to the same path are applied in the order they were queued. A
+// queued write that has not yet started is replaced by a subsequently queued
+// write to the same path (ie, only the latest value is written), unless
+// StrictOrdering is set, in which case every write is issued, and writes to
+// overlapping paths are serialized.
+func (b *BatchWriter) Enqueue(r *DatabaseRef, v interface{}, opts ...QueryOption) {
+	u := r.URL()
+	w := &batchWrite{
+		r:    r,
+		v:    v,
+		opts: opts,
+		host: u.Host,
+		path: joinPath(u.Path, ""),
+		done: make(chan struct{}),
+	}
+	key := w.host + w.path
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.latest == nil {
+		b.sem = make(chan struct{}, b.concurrency())
+		b.latest = make(map[string]*batchWrite)
+		b.outstanding = make(map[*batchWrite]bool)
+	}
+
+	prev := b.latest[key]
+	switch {
+	case prev != nil && !prev.started && !b.StrictOrdering:
+		// coalesce with queued write
+		prev.r, prev.v, prev.opts = r, v, opts
+		return
+
+	case b.StrictOrdering:
+		for _, x := range b.latest {
+			if x.host == w.host && pathsOverlap(x.path, w.path) {
+				w.deps = append(w.deps, x.done)
+			}
+		}
+
+	case prev != nil:
+		w.deps = append(w.deps, prev.done)
+	}
+
+	b.latest[key] = w
+	b.outstanding[w] = true
+	go b.run(key, w)
+}
+
+// run runs the queued write after its dependencies have completed.
+func (b *BatchWriter) run(key string, w *batchWrite) {
+	for _, d := range w.deps {
+		<-d
+	}
+
+	b.sem <- struct{}{}
+	b.mu.Lock()
+	w.started = true
+	r, v, opts := w.r, w.v, w.opts
+	b.mu.Unlock()
+
+	err := Set(r, v, opts...)
+	<-b.sem
+
+	b.mu.Lock()
+	if err != nil {
+		if b.errs == nil {
+			b.errs = make(MultiError)
+		}
+		b.errs[w.path] = err
+	}
+	if b.latest[key] == w {
+		delete(b.latest, key)
+	}
+	delete(b.outstanding, w)
+	b.mu.Unlock()
+
+	close(w.done)
+}
+
+// Flush waits for all writes queued with Enqueue prior to the call to
+// complete, returning a MultiError (keyed by path) of the queued writes that
+// failed since the previous call to Flush. When the context is done, Flush
+// returns the context's error, and the queued writes continue in the
+// background.
+func (b *BatchWriter) Flush(ctxt context.Context) error {
+	b.mu.Lock()
+	done := make([]chan struct{}, 0, len(b.outstanding))
+	for w := range b.outstanding {
+		done = append(done, w.done)
+	}
+	b.mu.Unlock()
+
+	for _, d := range done {
+		select {
+		case <-d:
+		case <-ctxt.Done():
+			return ctxt.Err()
+		}
+	}
+
+	b.mu.Lock()
+	errs := b.errs
+	b.errs = nil
+	b.mu.Unlock()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// pathsOverlap determines if the absolute, normalized paths are the same, or
+// if one is an ancestor of the other.
+func pathsOverlap(a, b string) bool {
+	switch {
+	case a == b, a == "/", b == "/":
+		return true
+	}
+	return strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// BatchSet sets the values keyed by their child paths relative to Firebase
+// database ref r, using DefaultBatchConcurrency concurrent requests. See
+// BatchWriter.Set.
+func BatchSet(r *DatabaseRef, values map[string]interface{}, opts ...QueryOption) error {
+	return BatchSetContext(r, context.Background(), values, opts...)
+}
+
+// BatchSetContext sets the values keyed by their child paths relative to
+// Firebase database ref r, using DefaultBatchConcurrency concurrent requests,
+// canceling pending writes when the passed context is done. See
+// BatchWriter.Set.
+func BatchSetContext(r *DatabaseRef, ctxt context.Context, values map[string]interface{}, opts ...QueryOption) error {
+	return new(BatchWriter).Set(r, ctxt, values, opts...)
+}
+
+// BatchPush pushes the values to Firebase database ref r, using
+// DefaultBatchConcurrency concurrent requests, returning the generated keys.
+// See BatchWriter.Push.
+func BatchPush(r *DatabaseRef, values []interface{}, opts ...QueryOption) ([]string, error) {
+	return BatchPushContext(r, context.Background(), values, opts...)
+}
+
+// BatchPushContext pushes the values to Firebase database ref r, using
+// DefaultBatchConcurrency concurrent requests, returning the generated keys,
+// and canceling pending writes when the passed context is done. See
+// BatchWriter.Push.
+func BatchPushContext(r *DatabaseRef, ctxt context.Context, values []interface{}, opts ...QueryOption) ([]string, error) {
+	return new(BatchWriter).Push(r, ctxt, values, opts...)
+}
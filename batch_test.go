@@ -0,0 +1,219 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchSet(t *testing.T) {
+	var mu sync.Mutex
+	written := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PUT" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		if strings.HasPrefix(req.URL.Path, "/a/bad") {
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+			return
+		}
+		buf, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		written[req.URL.Path] = string(buf)
+		mu.Unlock()
+		w.Write(buf)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	a := r.Ref("a")
+
+	values := make(map[string]interface{})
+	for i := 0; i < 50; i++ {
+		values["k"+string(rune('A'+i))] = i
+	}
+	if err = a.BatchSet(values); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(written) != 50 || written["/a/kA.json"] != "0" || written["/a/x/y.json"] != "" {
+		t.Errorf("expected 50 writes, got: %v", written)
+	}
+
+	err = BatchSet(a, map[string]interface{}{"x/y": 1, "bad1": 2, "bad2": 3})
+	e, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected MultiError, got: %v", err)
+	}
+	if keys := e.Keys(); len(keys) != 2 || keys[0] != "bad1" || keys[1] != "bad2" {
+		t.Errorf("expected bad1 and bad2 to fail, got: %v", keys)
+	}
+	if e["bad1"].(*Error).StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code, got: %v", e["bad1"])
+	}
+	if written["/a/x/y.json"] != "1" {
+		t.Errorf("expected /a/x/y to be written")
+	}
+
+	// canceled
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = BatchSetContext(a, ctxt, map[string]interface{}{"c": 1, "d": 2})
+	if e, ok := err.(MultiError); !ok || len(e) != 2 || e["c"] != context.Canceled {
+		t.Errorf("expected canceled writes, got: %v", err)
+	}
+}
+
+func TestBatchPush(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PUT" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	b := &BatchWriter{Concurrency: 2}
+	keys, err := b.Push(r.Ref("list"), context.Background(), []interface{}{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(keys) != 5 || !sort.StringsAreSorted(keys) {
+		t.Errorf("expected 5 ordered keys, got: %v", keys)
+	}
+	if len(paths) != 5 {
+		t.Errorf("expected 5 writes, got: %v", paths)
+	}
+	for _, k := range keys {
+		found := false
+		for _, p := range paths {
+			found = found || p == "/list/"+k+".json"
+		}
+		if !found {
+			t.Errorf("expected write to %s", k)
+		}
+	}
+}
+
+func TestBatchWriterEnqueue(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	started, gate := make(chan struct{}, 16), make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		log = append(log, req.URL.Path+"="+string(buf))
+		g := gate
+		mu.Unlock()
+		switch req.URL.Path {
+		case "/fail.json":
+			http.Error(w, `{"error":"denied"}`, http.StatusBadRequest)
+			return
+		case "/gate.json", "/x.json":
+			started <- struct{}{}
+			<-g
+		}
+		w.Write(buf)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// reset resets the log and gate, returning the log prior to the reset
+	reset := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		s := fmt.Sprint(log)
+		log, gate = nil, make(chan struct{})
+		return s
+	}
+
+	tests := []struct {
+		strict bool
+		exp    string
+	}{
+		{false, "[/gate.json=1 /gate.json=3]"},
+		{true, "[/gate.json=1 /gate.json=2 /gate.json=3]"},
+	}
+	for i, test := range tests {
+		reset()
+		b := &BatchWriter{Concurrency: 4, StrictOrdering: test.strict}
+		b.Enqueue(r.Ref("gate"), 1)
+		<-started
+		b.Enqueue(r.Ref("gate"), 2)
+		b.Enqueue(r.Ref("gate"), 3)
+		close(gate)
+		if err = b.Flush(context.Background()); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := reset(); s != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, s)
+		}
+	}
+
+	// overlapping paths are serialized in strict mode
+	b := &BatchWriter{Concurrency: 4, StrictOrdering: true}
+	b.Enqueue(r.Ref("x"), 1)
+	<-started
+	b.Enqueue(r.Ref("x/y"), 2)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	n := len(log)
+	mu.Unlock()
+	if n != 1 {
+		t.Errorf("expected x/y to wait for x, got %d writes", n)
+	}
+	close(gate)
+	if err = b.Flush(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := reset(); s != "[/x.json=1 /x/y.json=2]" {
+		t.Errorf("expected ordered writes, got: %s", s)
+	}
+
+	// flush reports errors
+	b.Enqueue(r.Ref("fail"), 1)
+	err = b.Flush(context.Background())
+	if e, ok := err.(MultiError); !ok || len(e) != 1 || e["/fail"] == nil {
+		t.Errorf("expected failed write, got: %v", err)
+	}
+	if err = b.Flush(context.Background()); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	// flush canceled
+	reset()
+	b.Enqueue(r.Ref("gate"), 4)
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = b.Flush(ctxt); err != context.Canceled {
+		t.Errorf("expected context canceled, got: %v", err)
+	}
+	<-started
+	close(gate)
+	if err = b.Flush(context.Background()); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
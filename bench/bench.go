@@ -0,0 +1,284 @@
+// Package bench provides a load testing harness for Firebase database refs,
+// driving a configurable mix of reads, writes, and watches using the same
+// code paths as the firebase package.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+const (
+	// DefaultConcurrency is the default number of concurrent workers.
+	DefaultConcurrency = 10
+
+	// DefaultKeys is the default number of distinct child keys operated on.
+	DefaultKeys = 100
+
+	// DefaultPayloadSize is the default size (in bytes) of written values.
+	DefaultPayloadSize = 256
+)
+
+// Op is a benchmarked operation.
+type Op string
+
+const (
+	// OpRead reads a child.
+	OpRead Op = "read"
+
+	// OpWrite writes a child.
+	OpWrite Op = "write"
+
+	// OpWatch watches a child, until its initial value is received.
+	OpWatch Op = "watch"
+)
+
+// Config is the configuration for Run.
+type Config struct {
+	// Ref is the database ref to benchmark. Reads, writes, and watches are
+	// made against the children of the ref.
+	Ref *firebase.DatabaseRef
+
+	// Duration is the duration to run. If 0, then Run runs until the context
+	// is done.
+	Duration time.Duration
+
+	// Concurrency is the number of concurrent workers. If less than or equal
+	// to 0, then DefaultConcurrency is used.
+	Concurrency int
+
+	// Reads, Writes, and Watches are the relative weights of each operation in
+	// the mix. If all are 0, then only reads are made.
+	Reads, Writes, Watches int
+
+	// Keys is the number of distinct child keys operated on. If less than or
+	// equal to 0, then DefaultKeys is used.
+	Keys int
+
+	// PayloadSize is the size (in bytes) of written values. If less than or
+	// equal to 0, then DefaultPayloadSize is used.
+	PayloadSize int
+}
+
+// OpStats are the results of a single operation type.
+type OpStats struct {
+	// Latency is the latency histogram of successful operations.
+	Latency *Histogram `json:"-"`
+
+	// Count is the number of operations made.
+	Count int64 `json:"count"`
+
+	// Errors is the number of failed operations, keyed by error class (ie,
+	// the HTTP status text, "timeout", or "error").
+	Errors map[string]int64 `json:"errors,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (s *OpStats) MarshalJSON() ([]byte, error) {
+	type stats OpStats
+	return json.Marshal(struct {
+		*stats
+		Mean time.Duration `json:"mean"`
+		Min  time.Duration `json:"min"`
+		Max  time.Duration `json:"max"`
+		P50  time.Duration `json:"p50"`
+		P90  time.Duration `json:"p90"`
+		P99  time.Duration `json:"p99"`
+	}{
+		stats: (*stats)(s),
+		Mean:  s.Latency.Mean(),
+		Min:   s.Latency.Min(),
+		Max:   s.Latency.Max(),
+		P50:   s.Latency.Percentile(50),
+		P90:   s.Latency.Percentile(90),
+		P99:   s.Latency.Percentile(99),
+	})
+}
+
+// Result is the result of a benchmark run.
+type Result struct {
+	// Duration is the actual duration of the run.
+	Duration time.Duration `json:"duration"`
+
+	// Ops are the results for each operation type in the mix.
+	Ops map[Op]*OpStats `json:"ops"`
+}
+
+// Throughput returns the operations per second of op.
+func (res *Result) Throughput(op Op) float64 {
+	s, ok := res.Ops[op]
+	if !ok || res.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Count) / res.Duration.Seconds()
+}
+
+// Sorted returns the sorted operation types of the result.
+func (res *Result) Sorted() []Op {
+	ops := make([]Op, 0, len(res.Ops))
+	for op := range res.Ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i] < ops[j]
+	})
+	return ops
+}
+
+// Run runs a benchmark against the ref, until the configured duration has
+// elapsed or the context is done.
+func Run(ctxt context.Context, cfg *Config) (*Result, error) {
+	if cfg.Ref == nil {
+		return nil, &firebase.Error{
+			Err: "bench ref must be specified",
+		}
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	keys := cfg.Keys
+	if keys <= 0 {
+		keys = DefaultKeys
+	}
+	size := cfg.PayloadSize
+	if size <= 0 {
+		size = DefaultPayloadSize
+	}
+
+	// build mix
+	var mix []Op
+	for _, w := range []struct {
+		op     Op
+		weight int
+	}{{OpRead, cfg.Reads}, {OpWrite, cfg.Writes}, {OpWatch, cfg.Watches}} {
+		for i := 0; i < w.weight; i++ {
+			mix = append(mix, w.op)
+		}
+	}
+	if len(mix) == 0 {
+		mix = []Op{OpRead}
+	}
+
+	res := &Result{
+		Ops: make(map[Op]*OpStats),
+	}
+	for _, op := range mix {
+		res.Ops[op] = &OpStats{
+			Latency: NewHistogram(),
+			Errors:  make(map[string]int64),
+		}
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctxt, cancel = context.WithTimeout(ctxt, cfg.Duration)
+		defer cancel()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(seed))
+			payload := make([]byte, size)
+			for ctxt.Err() == nil {
+				op := mix[rnd.Intn(len(mix))]
+				ref := cfg.Ref.Ref(strconv.Itoa(rnd.Intn(keys)))
+
+				opStart := time.Now()
+				err := do(ctxt, op, ref, rnd, payload)
+				latency := time.Since(opStart)
+
+				// ignore operations interrupted by the end of the run
+				if err != nil && ctxt.Err() != nil {
+					return
+				}
+
+				s := res.Ops[op]
+				mu.Lock()
+				s.Count++
+				if err != nil {
+					s.Errors[classify(err)]++
+				}
+				mu.Unlock()
+				if err == nil {
+					s.Latency.Record(latency)
+				}
+			}
+		}(start.UnixNano() + int64(i))
+	}
+	wg.Wait()
+	res.Duration = time.Since(start)
+
+	return res, nil
+}
+
+// payloadChars are the characters used for written payloads.
+const payloadChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// do performs op against ref.
+func do(ctxt context.Context, op Op, ref *firebase.DatabaseRef, rnd *rand.Rand, payload []byte) error {
+	switch op {
+	case OpRead:
+		var v json.RawMessage
+		return ref.GetContext(ctxt, &v)
+
+	case OpWrite:
+		for i := range payload {
+			payload[i] = payloadChars[rnd.Intn(len(payloadChars))]
+		}
+		return ref.SetContext(ctxt, string(payload))
+
+	case OpWatch:
+		watchCtxt, cancel := context.WithCancel(ctxt)
+		defer cancel()
+		events, err := ref.Watch(watchCtxt)
+		if err != nil {
+			return err
+		}
+		for ev := range events {
+			switch ev.Type {
+			case firebase.EventTypePut:
+				return nil
+			case firebase.EventTypeKeepAlive:
+				continue
+			}
+			return &firebase.Error{
+				Err: fmt.Sprintf("watch received %s event", ev.Type),
+			}
+		}
+		return &firebase.Error{
+			Err: "watch closed",
+		}
+	}
+
+	return &firebase.Error{
+		Err: fmt.Sprintf("unknown op %s", op),
+	}
+}
+
+// classify returns the error class of err.
+func classify(err error) string {
+	if e, ok := err.(*firebase.Error); ok && e.StatusCode != 0 {
+		return http.StatusText(e.StatusCode)
+	}
+	if err == context.DeadlineExceeded {
+		return "timeout"
+	}
+	return "error"
+}
@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	if n := h.Count(); n != 100 {
+		t.Errorf("expected 100, got: %d", n)
+	}
+	if d := h.Min(); d != time.Millisecond {
+		t.Errorf("expected 1ms, got: %v", d)
+	}
+	if d := h.Max(); d != 100*time.Millisecond {
+		t.Errorf("expected 100ms, got: %v", d)
+	}
+	if d := h.Mean(); d != 50500*time.Microsecond {
+		t.Errorf("expected 50.5ms, got: %v", d)
+	}
+
+	// percentiles are bucket upper bounds, within ~19% of the actual value
+	for _, test := range []struct {
+		p   float64
+		exp time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+	} {
+		d := h.Percentile(test.p)
+		if d < test.exp || float64(d) > float64(test.exp)*1.19 {
+			t.Errorf("p%v: expected approximately %v, got: %v", test.p, test.exp, d)
+		}
+	}
+	if d := h.Percentile(100); d != 100*time.Millisecond {
+		t.Errorf("expected 100ms, got: %v", d)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Permission denied"}`))
+			return
+		}
+		w.Write([]byte(`"value"`))
+	}))
+	defer ts.Close()
+
+	r, err := firebase.NewDatabaseRef(firebase.URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	res, err := Run(context.Background(), &Config{
+		Ref:         r,
+		Duration:    100 * time.Millisecond,
+		Concurrency: 2,
+		Reads:       1,
+		Writes:      1,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	read, write := res.Ops[OpRead], res.Ops[OpWrite]
+	if read.Count == 0 || read.Latency.Count() != read.Count {
+		t.Errorf("expected successful reads, got: %d/%d", read.Latency.Count(), read.Count)
+	}
+	if write.Count == 0 || write.Errors["Unauthorized"] != write.Count {
+		t.Errorf("expected unauthorized writes, got: %v/%d", write.Errors, write.Count)
+	}
+}
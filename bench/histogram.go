@@ -0,0 +1,136 @@
+package bench
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// histogramMin is the upper bound of the first histogram bucket.
+	histogramMin = 100 * time.Microsecond
+
+	// histogramBucketsPerDoubling is the number of histogram buckets per
+	// doubling of latency.
+	histogramBucketsPerDoubling = 4
+
+	// histogramBuckets is the number of histogram buckets, covering latencies
+	// up to approximately 105 seconds.
+	histogramBuckets = 20*histogramBucketsPerDoubling + 1
+)
+
+// Histogram is a latency histogram with exponentially sized buckets, safe for
+// concurrent use.
+type Histogram struct {
+	mu sync.Mutex
+
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// NewHistogram creates a new latency histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]int64, histogramBuckets+1),
+	}
+}
+
+// bucket returns the bucket index for d.
+func bucket(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(float64(d)/float64(histogramMin)) * histogramBucketsPerDoubling))
+	if i > histogramBuckets {
+		return histogramBuckets
+	}
+	return i
+}
+
+// bound returns the upper bound of bucket i.
+func bound(i int) time.Duration {
+	return time.Duration(float64(histogramMin) * math.Pow(2, float64(i)/histogramBucketsPerDoubling))
+}
+
+// Record records the latency d.
+func (h *Histogram) Record(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[bucket(d)]++
+	h.count++
+	h.sum += d
+	if h.count == 1 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Count returns the number of recorded latencies.
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+// Mean returns the mean recorded latency.
+func (h *Histogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Min returns the minimum recorded latency.
+func (h *Histogram) Min() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.min
+}
+
+// Max returns the maximum recorded latency.
+func (h *Histogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.max
+}
+
+// Percentile returns the approximate latency at percentile p (0-100), as the
+// upper bound of the bucket containing p, capped at the maximum recorded
+// latency.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return 0
+	}
+
+	target := int64(math.Ceil(float64(h.count) * p / 100))
+	if target < 1 {
+		target = 1
+	}
+
+	var n int64
+	for i, c := range h.counts {
+		if n += c; n >= target {
+			if d := bound(i); d < h.max {
+				return d
+			}
+			break
+		}
+	}
+
+	return h.max
+}
@@ -0,0 +1,170 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/knq/jwt/gserviceaccount"
+)
+
+// BootstrapConfig is the configuration for Bootstrap.
+type BootstrapConfig struct {
+	// Credentials are the JSON encoded Google service account credentials.
+	Credentials []byte
+
+	// DatabaseID is the database instance ID. If empty, then the project's
+	// default database instance ID (<project>-default-rtdb) is used.
+	DatabaseID string
+
+	// Location is the location used when creating the database instance. If
+	// empty, then DefaultDatabaseLocation is used.
+	Location string
+
+	// Rules are the JSON encoded security rules to apply when the database's
+	// current security rules are the defaults.
+	Rules []byte
+
+	// Seed is the initial data to write, keyed by database path. Data is only
+	// written to paths that do not already contain data.
+	Seed map[string]interface{}
+
+	// ManagementClient is the http.Client used for the management API. If
+	// nil, then a client authorized using Credentials is used.
+	ManagementClient *http.Client
+
+	// Options are additional options applied to the returned database ref.
+	Options []Option
+}
+
+// Bootstrap performs a one-call setup of a Firebase Realtime Database for a
+// new environment, returning the database ref for the bootstrapped database.
+//
+// Bootstrap ensures the database instance exists (creating it via the
+// management API if needed), applies the configured rules if the current
+// rules are the default rules created by Firebase, and idempotently seeds the
+// initial data.
+func Bootstrap(ctxt context.Context, cfg *BootstrapConfig) (*DatabaseRef, error) {
+	var err error
+
+	// load credentials
+	gsa, err := gserviceaccount.FromJSON(cfg.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	if gsa.ProjectID == "" {
+		return nil, errors.New("google service account credentials missing project_id")
+	}
+
+	// management client
+	client := cfg.ManagementClient
+	if client == nil {
+		ts, err := gsa.TokenSource(nil, managementScopes...)
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{
+			Transport: &oauth2.Transport{
+				Source: oauth2.ReuseTokenSource(nil, ts),
+			},
+		}
+	}
+
+	// ensure database exists
+	databaseID := cfg.DatabaseID
+	typ := "USER_DATABASE"
+	if databaseID == "" {
+		databaseID, typ = gsa.ProjectID+"-default-rtdb", "DEFAULT_DATABASE"
+	}
+	inst, err := GetDatabaseInstance(ctxt, client, gsa.ProjectID, "", databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if inst == nil {
+		inst, err = CreateDatabaseInstance(ctxt, client, gsa.ProjectID, cfg.Location, databaseID, typ)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// create ref
+	opts := []Option{GoogleServiceAccountCredentialsJSON(cfg.Credentials)}
+	if inst.DatabaseURL != "" {
+		opts = append(opts, URL(inst.DatabaseURL))
+	}
+	r, err := NewDatabaseRef(append(opts, cfg.Options...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply rules
+	if cfg.Rules != nil {
+		current, err := r.GetRulesJSON()
+		if err != nil {
+			return nil, err
+		}
+		if isDefaultRules(current) {
+			err = r.SetRulesJSON(cfg.Rules)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// seed data in path order
+	paths := make([]string, 0, len(cfg.Seed))
+	for path := range cfg.Seed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var existing interface{}
+		err = r.Ref(path).Get(&existing, Shallow)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			continue
+		}
+
+		err = r.Ref(path).Set(cfg.Seed[path])
+		if err != nil {
+			return nil, fmt.Errorf("could not seed %s: %v", path, err)
+		}
+	}
+
+	return r, nil
+}
+
+// isDefaultRules determines if the JSON encoded rules are one of the default
+// rule sets created by Firebase (locked mode, test mode, or fully open),
+// containing only top-level .read and .write rules.
+func isDefaultRules(buf []byte) bool {
+	var v struct {
+		Rules map[string]interface{} `json:"rules"`
+	}
+	if err := json.Unmarshal(buf, &v); err != nil || len(v.Rules) != 2 {
+		return false
+	}
+
+	for _, k := range []string{".read", ".write"} {
+		switch x := v.Rules[k].(type) {
+		case bool:
+		case string:
+			x = strings.TrimSpace(x)
+			if x != "true" && x != "false" && !strings.HasPrefix(x, "now <") {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
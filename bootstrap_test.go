@@ -0,0 +1,178 @@
+package firebase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsDefaultRules(t *testing.T) {
+	tests := []struct {
+		rules string
+		exp   bool
+	}{
+		{`{"rules":{".read":false,".write":false}}`, true},
+		{`{"rules":{".read":true,".write":true}}`, true},
+		{`{"rules":{".read":"now < 1700000000000",".write":"now < 1700000000000"}}`, true},
+		{`{"rules":{".read":"auth != null",".write":"auth != null"}}`, false},
+		{`{"rules":{".read":true}}`, false},
+		{`{"rules":{".read":true,".write":true,"users":{".read":true}}}`, false},
+		{`{"rules":{".read":1,".write":true}}`, false},
+		{`invalid`, false},
+	}
+	for i, test := range tests {
+		if b := isDefaultRules([]byte(test.rules)); b != test.exp {
+			t.Errorf("test %d expected %t, got: %t", i, test.exp, b)
+		}
+	}
+}
+
+func TestDatabaseInstance(t *testing.T) {
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqs = append(reqs, req.Method+" "+req.URL.RequestURI())
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/instances/missing"):
+			http.Error(w, `{"error":{"message":"not found","status":"NOT_FOUND"}}`, http.StatusNotFound)
+		case strings.HasSuffix(req.URL.Path, "/instances/denied"):
+			http.Error(w, `{"error":{"message":"denied","status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+		case req.Method == "GET":
+			w.Write([]byte(`{"name":"projects/p/locations/europe-west1/instances/db","databaseUrl":"https://db.europe-west1.firebasedatabase.app","state":"ACTIVE"}`))
+		case req.Method == "POST":
+			var inst DatabaseInstance
+			if err := json.NewDecoder(req.Body).Decode(&inst); err != nil || inst.Type != "USER_DATABASE" {
+				http.Error(w, `{"error":{"message":"bad request","status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"name":"projects/p/locations/us-central1/instances/db2","type":"USER_DATABASE"}`))
+		}
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	client := &http.Client{Transport: &rewriteTransport{host: u.Host}}
+	ctxt := context.Background()
+
+	inst, err := GetDatabaseInstance(ctxt, client, "p", "", "db")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if inst.Location() != "europe-west1" || inst.DatabaseURL != "https://db.europe-west1.firebasedatabase.app" {
+		t.Errorf("unexpected instance: %+v", inst)
+	}
+	if inst, err = GetDatabaseInstance(ctxt, client, "p", "", "missing"); err != nil || inst != nil {
+		t.Errorf("expected no instance and no error, got: %v, %v", inst, err)
+	}
+	if _, err = GetDatabaseInstance(ctxt, client, "p", "", "denied"); err == nil || !strings.Contains(err.Error(), "PERMISSION_DENIED") {
+		t.Errorf("expected permission denied error, got: %v", err)
+	}
+
+	if inst, err = CreateDatabaseInstance(ctxt, client, "p", "", "db2", "USER_DATABASE"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if inst.Location() != DefaultDatabaseLocation {
+		t.Errorf("expected default location, got: %+v", inst)
+	}
+
+	exp := []string{
+		"GET /v1beta/projects/p/locations/-/instances/db",
+		"GET /v1beta/projects/p/locations/-/instances/missing",
+		"GET /v1beta/projects/p/locations/-/instances/denied",
+		"POST /v1beta/projects/p/locations/us-central1/instances?databaseId=db2",
+	}
+	if strings.Join(reqs, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("expected requests:\n%s\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(reqs, "\n"))
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	s := &memServer{}
+	s.set("/.settings/rules", map[string]interface{}{
+		"rules": map[string]interface{}{".read": false, ".write": false},
+	})
+	s.set("/existing", "keep")
+	var created bool
+	var dbURL string
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v1beta/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			created = true
+		}
+		if !created {
+			http.Error(w, `{"error":{"message":"not found","status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&DatabaseInstance{
+			Name:        "projects/p/locations/us-central1/instances/p-default-rtdb",
+			DatabaseURL: dbURL,
+		})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	dbURL = ts.URL
+
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "p",
+		"private_key_id": "kid",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "svc@p.iam.gserviceaccount.com",
+		"token_uri":      ts.URL + "/token",
+	})
+	u, _ := url.Parse(ts.URL)
+
+	cfg := &BootstrapConfig{
+		Credentials:      creds,
+		Rules:            []byte(`{"rules":{".read":"auth != null",".write":"auth != null"}}`),
+		Seed:             map[string]interface{}{"existing": "replaced", "config/version": 1},
+		ManagementClient: &http.Client{Transport: &rewriteTransport{host: u.Host}},
+	}
+	r, err := Bootstrap(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if r.URL().Host != u.Host {
+		t.Errorf("expected ref for created instance, got: %s", r.URL())
+	}
+	if !created {
+		t.Errorf("expected database instance to be created")
+	}
+	rules, _ := s.get("/.settings/rules/rules").(map[string]interface{})
+	if rules[".read"] != "auth != null" {
+		t.Errorf("expected rules to be applied, got: %v", rules)
+	}
+	if s.get("/existing") != "keep" || s.get("/config/version") != float64(1) {
+		t.Errorf("expected only missing data to be seeded, got: %v", s.root)
+	}
+
+	// bootstrapping again leaves customized rules
+	s.set("/.settings/rules/rules/.write", false)
+	if _, err = Bootstrap(context.Background(), cfg); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if rules, _ = s.get("/.settings/rules/rules").(map[string]interface{}); rules[".write"] != false {
+		t.Errorf("expected rules to be unchanged, got: %v", rules)
+	}
+
+	if _, err = Bootstrap(context.Background(), &BootstrapConfig{Credentials: []byte(`{}`)}); err == nil {
+		t.Errorf("expected error")
+	}
+}
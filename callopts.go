@@ -0,0 +1,129 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// CallOptions shape the requests made by an operation, such as Get, Set,
+// Update, etc. CallOptions can be set as defaults for reads and writes on a
+// database ref (see ReadOptions and WriteOptions), or for a single operation
+// (see WithCallOptions).
+type CallOptions struct {
+	// Timeout is the deadline for the operation, relative to its start and
+	// including all retries and reading the response. If 0, then no timeout
+	// is applied, other than the context's.
+	Timeout time.Duration
+
+	// Retry is the retry policy for the operation. If nil, then the ref's
+	// retry policy (see Retry) is used.
+	Retry *RetryPolicy
+
+	// Idempotent marks the operation as safe to retry, allowing Update and
+	// Push to be retried according to the retry policy (as when the written
+	// values are absolute, or a Push key has been pre-generated by the
+	// caller).
+	Idempotent bool
+}
+
+// merge merges o into the call options.
+func (co *CallOptions) merge(o *CallOptions) {
+	if o == nil {
+		return
+	}
+	if o.Timeout != 0 {
+		co.Timeout = o.Timeout
+	}
+	if o.Retry != nil {
+		co.Retry = o.Retry
+	}
+	co.Idempotent = co.Idempotent || o.Idempotent
+}
+
+// validate validates the call options.
+func (co *CallOptions) validate() error {
+	if co.Timeout < 0 {
+		return errors.New("call timeout cannot be negative")
+	}
+	if co.Retry != nil && (co.Retry.Backoff < 0 || co.Retry.MaxBackoff < 0) {
+		return errors.New("retry backoff cannot be negative")
+	}
+	return nil
+}
+
+// ReadOptions is an option that sets the default call options for read
+// operations (Get, GetRaw, etc) on the database ref (and its children).
+func ReadOptions(opts CallOptions) Option {
+	return func(r *DatabaseRef) error {
+		if err := opts.validate(); err != nil {
+			return err
+		}
+		r.readOpts = &opts
+		return nil
+	}
+}
+
+// WriteOptions is an option that sets the default call options for write
+// operations (Set, Push, Update, Remove, etc) on the database ref (and its
+// children).
+func WriteOptions(opts CallOptions) Option {
+	return func(r *DatabaseRef) error {
+		if err := opts.validate(); err != nil {
+			return err
+		}
+		r.writeOpts = &opts
+		return nil
+	}
+}
+
+// callOptionsKey is the context key for CallOptions.
+type callOptionsKey struct{}
+
+// WithCallOptions returns a copy of the context that applies the call
+// options to operations made with the context (ie, with GetContext,
+// SetContext, etc), overriding the ref's defaults for any fields set.
+func WithCallOptions(ctxt context.Context, opts *CallOptions) context.Context {
+	return context.WithValue(ctxt, callOptionsKey{}, opts)
+}
+
+// callOptions returns the call options in effect for a request with the
+// method and query options, combining the ref's timeout and retry policy,
+// the ref's read or write defaults, the context's call options, and the
+// query's timeout (in increasing order of precedence).
+func (r *DatabaseRef) callOptions(ctxt context.Context, method string, opts []QueryOption) CallOptions {
+	co := CallOptions{
+		Timeout: r.timeout,
+		Retry:   r.retryPolicy,
+	}
+	if OpType(method) == OpTypeGet {
+		co.merge(r.readOpts)
+	} else {
+		co.merge(r.writeOpts)
+	}
+	if o, ok := ctxt.Value(callOptionsKey{}).(*CallOptions); ok {
+		co.merge(o)
+	}
+	if q, err := buildQuery(r.queryOpts, opts); err == nil {
+		if d := queryTimeout(q); d != 0 {
+			co.Timeout = d
+		}
+	}
+	return co
+}
+
+// cancelBody wraps a response body, canceling the operation's timeout
+// context when closed.
+type cancelBody struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+// Close satisfies the io.Closer interface.
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
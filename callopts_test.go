@@ -0,0 +1,117 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallOptions(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow.json" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if atomic.AddInt32(&attempts, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	policy := &RetryPolicy{Backoff: time.Millisecond}
+	r, err := NewDatabaseRef(
+		URL(ts.URL+"/"),
+		ReadOptions(CallOptions{Retry: policy}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// reads are retried
+	var v interface{}
+	if err = r.Get(&v); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	// writes are not retried
+	atomic.StoreInt32(&attempts, 0)
+	if err = r.Set(map[string]interface{}{}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// idempotent updates are retried
+	atomic.StoreInt32(&attempts, 0)
+	ctxt := WithCallOptions(context.Background(), &CallOptions{
+		Retry:      policy,
+		Idempotent: true,
+	})
+	if err = r.UpdateContext(ctxt, map[string]interface{}{"a": 1}); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("expected 2 attempts, got: %d", n)
+	}
+
+	// timeout
+	atomic.StoreInt32(&attempts, 1)
+	ctxt = WithCallOptions(context.Background(), &CallOptions{
+		Timeout: 50 * time.Millisecond,
+	})
+	start := time.Now()
+	if err = r.Ref("/slow").GetContext(ctxt, &v); err == nil {
+		t.Errorf("expected error")
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("expected timeout, took: %v", d)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	queries := make(chan string, 1)
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/a.json" {
+			// block until the client gives up
+			select {
+			case <-req.Context().Done():
+			case <-done:
+			}
+			return
+		}
+		queries <- req.URL.Query().Get("timeout")
+		// outlast the ref timeout
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), Timeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// ref timeout
+	var v interface{}
+	if err = r.Ref("/a").Get(&v); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// query timeout overrides ref timeout
+	if err = r.Get(&v, QueryTimeout(time.Second+500*time.Microsecond)); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if query := <-queries; query != "1001ms" {
+		t.Errorf("expected 1001ms, got: %q", query)
+	}
+
+	// invalid query timeout
+	if err = r.Get(&v, QueryTimeout(MaxQueryTimeout+1)); err == nil {
+		t.Errorf("expected error")
+	}
+}
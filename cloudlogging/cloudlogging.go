@@ -0,0 +1,267 @@
+// Package cloudlogging provides an exporter that ships Firebase database
+// request errors, slow operations, and audit records to Google Cloud Logging
+// as structured log entries.
+//
+// The exporter is attached to a database ref using the Observe option:
+//
+//	exp := cloudlogging.New(client, "my-project")
+//	db, err := firebase.NewDatabaseRef(
+//	    firebase.GoogleServiceAccountCredentialsFile("credentials.json"),
+//	    firebase.Observe(exp.Observe),
+//	)
+//	...
+//	defer exp.Close(context.Background())
+package cloudlogging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+const (
+	// DefaultEndpoint is the default Cloud Logging API endpoint.
+	DefaultEndpoint = "https://logging.googleapis.com/v2"
+
+	// DefaultLogName is the default log name.
+	DefaultLogName = "firebase"
+
+	// DefaultSlowThreshold is the default duration after which an operation
+	// is considered slow.
+	DefaultSlowThreshold = 2 * time.Second
+
+	// DefaultFlushInterval is the default interval at which buffered entries
+	// are written.
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultBatchSize is the default maximum number of entries written per
+	// request.
+	DefaultBatchSize = 500
+)
+
+// Entry is a structured log entry payload.
+type Entry struct {
+	Kind       string `json:"kind"`
+	Op         string `json:"op"`
+	Path       string `json:"path"`
+	Auth       string `json:"auth,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// entry is a buffered log entry.
+type entry struct {
+	time     time.Time
+	severity string
+	payload  *Entry
+}
+
+// Exporter buffers and writes log entries for observed database operations
+// to Google Cloud Logging.
+//
+// Failed operations are logged with ERROR severity, and slow operations with
+// WARNING severity. When Audit is enabled, all write operations are logged
+// with NOTICE severity.
+type Exporter struct {
+	// Client is the http.Client used for requests, which must be authorized
+	// with the https://www.googleapis.com/auth/logging.write scope.
+	Client *http.Client
+
+	// Endpoint is the Cloud Logging API endpoint.
+	Endpoint string
+
+	// ProjectID is the project the log entries are written to.
+	ProjectID string
+
+	// LogName is the log name.
+	LogName string
+
+	// SlowThreshold is the duration after which an operation is logged as
+	// slow.
+	SlowThreshold time.Duration
+
+	// Audit toggles logging all write operations.
+	Audit bool
+
+	// BatchSize is the maximum number of entries written per request.
+	BatchSize int
+
+	// OnError, if set, is called when entries could not be written.
+	OnError func(err error)
+
+	mu      sync.Mutex
+	pending []*entry
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// New creates and starts a new exporter, writing buffered entries every
+// DefaultFlushInterval.
+func New(client *http.Client, projectID string) *Exporter {
+	exp := &Exporter{
+		Client:        client,
+		Endpoint:      DefaultEndpoint,
+		ProjectID:     projectID,
+		LogName:       DefaultLogName,
+		SlowThreshold: DefaultSlowThreshold,
+		BatchSize:     DefaultBatchSize,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go exp.run(DefaultFlushInterval)
+	return exp
+}
+
+// run periodically flushes the buffered entries until closed.
+func (exp *Exporter) run(interval time.Duration) {
+	defer close(exp.stopped)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := exp.Flush(context.Background()); err != nil && exp.OnError != nil {
+				exp.OnError(err)
+			}
+		case <-exp.done:
+			return
+		}
+	}
+}
+
+// Observe satisfies the firebase.Observer func signature, buffering a log
+// entry for the operation when it failed, was slow, or (when auditing) was a
+// write.
+func (exp *Exporter) Observe(info *firebase.OperationInfo) {
+	var kind, severity string
+	switch {
+	case info.Err != nil:
+		kind, severity = "error", "ERROR"
+	case info.Duration >= exp.SlowThreshold:
+		kind, severity = "slow", "WARNING"
+	case exp.Audit && info.Op != firebase.OpTypeGet:
+		kind, severity = "audit", "NOTICE"
+	default:
+		return
+	}
+
+	e := &entry{
+		time:     info.Start,
+		severity: severity,
+		payload: &Entry{
+			Kind:       kind,
+			Op:         string(info.Op),
+			Path:       info.Path,
+			Auth:       info.Auth,
+			DurationMS: int64(info.Duration / time.Millisecond),
+			StatusCode: info.StatusCode,
+		},
+	}
+	if info.Err != nil {
+		e.payload.Error = info.Err.Error()
+	}
+
+	exp.mu.Lock()
+	exp.pending = append(exp.pending, e)
+	exp.mu.Unlock()
+}
+
+// Flush writes all buffered entries.
+func (exp *Exporter) Flush(ctxt context.Context) error {
+	exp.mu.Lock()
+	pending := exp.pending
+	exp.pending = nil
+	exp.mu.Unlock()
+
+	for len(pending) != 0 {
+		n := len(pending)
+		if exp.BatchSize > 0 && n > exp.BatchSize {
+			n = exp.BatchSize
+		}
+		if err := exp.write(ctxt, pending[:n]); err != nil {
+			// requeue unwritten entries
+			exp.mu.Lock()
+			exp.pending = append(pending, exp.pending...)
+			exp.mu.Unlock()
+			return err
+		}
+		pending = pending[n:]
+	}
+
+	return nil
+}
+
+// Close stops the exporter and flushes all buffered entries.
+func (exp *Exporter) Close(ctxt context.Context) error {
+	select {
+	case <-exp.done:
+	default:
+		close(exp.done)
+	}
+	<-exp.stopped
+
+	return exp.Flush(ctxt)
+}
+
+// write writes the entries.
+func (exp *Exporter) write(ctxt context.Context, entries []*entry) error {
+	logName := "projects/" + exp.ProjectID + "/logs/" + url.PathEscape(exp.LogName)
+
+	list := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		list[i] = map[string]interface{}{
+			"logName":     logName,
+			"timestamp":   e.time.UTC().Format(time.RFC3339Nano),
+			"severity":    e.severity,
+			"jsonPayload": e.payload,
+		}
+	}
+
+	buf, err := json.Marshal(map[string]interface{}{
+		"resource": map[string]interface{}{
+			"type": "global",
+			"labels": map[string]string{
+				"project_id": exp.ProjectID,
+			},
+		},
+		"entries": list,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal json: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", exp.Endpoint+"/entries:write", bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := exp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req.WithContext(ctxt))
+	if err != nil {
+		return fmt.Errorf("could not execute request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("could not write log entries: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
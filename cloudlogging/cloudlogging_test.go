@@ -0,0 +1,187 @@
+package cloudlogging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+// logEntry is a written log entry.
+type logEntry struct {
+	LogName     string `json:"logName"`
+	Timestamp   string `json:"timestamp"`
+	Severity    string `json:"severity"`
+	JSONPayload Entry  `json:"jsonPayload"`
+}
+
+// loggingServer is a test Cloud Logging API server.
+type loggingServer struct {
+	sync.Mutex
+	fail    bool
+	writes  [][]logEntry
+	project string
+}
+
+func (s *loggingServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if req.Method != "POST" || req.URL.Path != "/entries:write" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if s.fail {
+		http.Error(w, `{"error":{"status":"UNAVAILABLE"}}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	var v struct {
+		Resource struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"resource"`
+		Entries []logEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.project = v.Resource.Labels["project_id"]
+	s.writes = append(s.writes, v.Entries)
+	w.Write([]byte(`{}`))
+}
+
+func TestExporter(t *testing.T) {
+	ls := &loggingServer{}
+	lts := httptest.NewServer(ls)
+	defer lts.Close()
+
+	dts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/denied.json" {
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer dts.Close()
+
+	exp := New(nil, "p")
+	exp.Endpoint = lts.URL
+	exp.Audit = true
+	exp.BatchSize = 2
+
+	r, err := firebase.NewDatabaseRef(firebase.URL(dts.URL+"/"), firebase.Observe(exp.Observe))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var v interface{}
+	if err = r.Ref("/a").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Ref("/a").Set(1, firebase.AuthUID("alice")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Ref("/denied").Get(&v); err == nil {
+		t.Fatalf("expected error")
+	}
+	exp.Observe(&firebase.OperationInfo{
+		Op:       firebase.OpTypeGet,
+		Path:     "/slow",
+		Start:    time.Now(),
+		Duration: 3 * time.Second,
+	})
+
+	// write failure requeues entries
+	ls.Lock()
+	ls.fail = true
+	ls.Unlock()
+	if err = exp.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected unavailable error, got: %v", err)
+	}
+	ls.Lock()
+	ls.fail = false
+	ls.Unlock()
+
+	if err = exp.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+	if ls.project != "p" {
+		t.Errorf("expected project label, got: %q", ls.project)
+	}
+	if len(ls.writes) != 2 || len(ls.writes[0]) != 2 || len(ls.writes[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1 entries, got: %v", ls.writes)
+	}
+	entries := append(ls.writes[0], ls.writes[1]...)
+	tests := []struct {
+		severity, kind, op, path string
+	}{
+		{"NOTICE", "audit", "PUT", "/a"},
+		{"ERROR", "error", "GET", "/denied"},
+		{"WARNING", "slow", "GET", "/slow"},
+	}
+	for i, test := range tests {
+		e := entries[i]
+		if e.LogName != "projects/p/logs/firebase" || e.Timestamp == "" {
+			t.Errorf("test %d unexpected entry: %+v", i, e)
+		}
+		if e.Severity != test.severity || e.JSONPayload.Kind != test.kind || e.JSONPayload.Op != test.op || e.JSONPayload.Path != test.path {
+			t.Errorf("test %d expected %s %s %s %s, got: %+v", i, test.severity, test.kind, test.op, test.path, e)
+		}
+	}
+	if e := entries[0].JSONPayload; e.Auth != `{"uid":"alice"}` || e.StatusCode != http.StatusOK {
+		t.Errorf("expected auth and status, got: %+v", e)
+	}
+	if e := entries[1].JSONPayload; e.StatusCode != http.StatusUnauthorized || !strings.Contains(e.Error, "Permission denied") {
+		t.Errorf("expected error and status, got: %+v", e)
+	}
+	if e := entries[2].JSONPayload; e.DurationMS != 3000 {
+		t.Errorf("expected duration 3000, got: %d", e.DurationMS)
+	}
+}
+
+func TestExporterOnError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	errs := make(chan error, 1)
+	exp := &Exporter{
+		Endpoint:  ts.URL,
+		ProjectID: "p",
+		LogName:   DefaultLogName,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	exp.Observe(&firebase.OperationInfo{Op: firebase.OpTypeSet, Path: "/a", Err: errors.New("failed")})
+	go exp.run(10 * time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "403") {
+			t.Errorf("expected forbidden error, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected periodic flush error")
+	}
+	if err := exp.Close(context.Background()); err == nil {
+		t.Errorf("expected error")
+	}
+}
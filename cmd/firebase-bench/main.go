@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/bench"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
+)
+
+var (
+	flagCredentials = flag.String("creds", "", "path to google service account credentials")
+	flagRef         = flag.String("ref", "/bench", "firebase ref to benchmark")
+	flagDuration    = flag.Duration("d", 30*time.Second, "duration to run")
+	flagConcurrency = flag.Int("c", bench.DefaultConcurrency, "number of concurrent workers")
+	flagReads       = flag.Int("reads", 1, "relative weight of reads")
+	flagWrites      = flag.Int("writes", 1, "relative weight of writes")
+	flagWatches     = flag.Int("watches", 0, "relative weight of watches")
+	flagKeys        = flag.Int("keys", bench.DefaultKeys, "number of distinct child keys")
+	flagSize        = flag.Int("size", bench.DefaultPayloadSize, "size of written values in bytes")
+	flagJSON        = flag.Bool("json", false, "output results as json")
+	flagVerbose     = flag.Bool("v", false, "verbose logging")
+)
+
+func main() {
+	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the benchmark.
+func run() error {
+	// check credentials
+	if *flagCredentials == "" {
+		return clierr.Usage("invalid credentials file")
+	}
+
+	// build firebase options
+	opts := []firebase.Option{
+		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
+	}
+	if *flagVerbose {
+		opts = append(opts, firebase.Log(log.Printf, log.Printf))
+	}
+
+	// create database ref
+	ref, err := firebase.NewDatabaseRef(opts...)
+	if err != nil {
+		return clierr.Auth(err)
+	}
+
+	// run
+	res, err := bench.Run(context.Background(), &bench.Config{
+		Ref:         ref.Ref(*flagRef),
+		Duration:    *flagDuration,
+		Concurrency: *flagConcurrency,
+		Reads:       *flagReads,
+		Writes:      *flagWrites,
+		Watches:     *flagWatches,
+		Keys:        *flagKeys,
+		PayloadSize: *flagSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	// output
+	if *flagJSON {
+		buf, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", string(buf))
+		return nil
+	}
+
+	fmt.Fprintf(os.Stdout, "duration: %v\n", res.Duration)
+	for _, op := range res.Sorted() {
+		s := res.Ops[op]
+		fmt.Fprintf(os.Stdout, "\n%s: %d ops (%.1f/s)\n", op, s.Count, res.Throughput(op))
+		fmt.Fprintf(os.Stdout, "  mean: %v min: %v max: %v\n", s.Latency.Mean(), s.Latency.Min(), s.Latency.Max())
+		fmt.Fprintf(os.Stdout, "  p50: %v p90: %v p99: %v\n", s.Latency.Percentile(50), s.Latency.Percentile(90), s.Latency.Percentile(99))
+
+		// error breakdown
+		classes := make([]string, 0, len(s.Errors))
+		for class := range s.Errors {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(os.Stdout, "  errors (%s): %d\n", class, s.Errors[class])
+		}
+	}
+	return nil
+}
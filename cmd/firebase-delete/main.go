@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"path"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
+)
+
+var (
+	flagCredentials = flag.String("creds", "", "path to google service account credentials")
+	flagRef         = flag.String("ref", "", "firebase ref to delete")
+	flagBatchSize   = flag.Int("batch-size", firebase.DefaultRemoveBatchSize, "number of children deleted per request")
+	flagRate        = flag.Float64("rate", 0, "maximum requests per second (0 for unlimited)")
+	flagRoot        = flag.Bool("root", false, "allow deleting the database root")
+	flagVerbose     = flag.Bool("v", false, "verbose logging")
+)
+
+func main() {
+	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the delete.
+//
+// Children are deleted in batches, so an interrupted delete can be resumed by
+// running the same command again.
+func run() error {
+	// check flags
+	ref := path.Join("/", *flagRef)
+	switch {
+	case *flagCredentials == "":
+		return clierr.Usage("invalid credentials file")
+	case *flagRef == "":
+		return clierr.Usage("ref not specified")
+	case ref == "/" && !*flagRoot:
+		return clierr.Usage("refusing to delete the database root without -root")
+	case *flagBatchSize <= 0:
+		return clierr.Usage("invalid batch size")
+	case *flagRate < 0:
+		return clierr.Usage("rate cannot be negative")
+	}
+
+	opts := []firebase.Option{
+		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
+	}
+	if *flagRate > 0 {
+		opts = append(opts, firebase.RateLimit(*flagRate, 1))
+	}
+	if *flagVerbose {
+		opts = append(opts, firebase.Log(log.Printf, log.Printf))
+	}
+
+	// create database ref
+	db, err := firebase.NewDatabaseRef(opts...)
+	if err != nil {
+		return clierr.Auth(err)
+	}
+
+	// delete
+	var total int
+	rr := &firebase.RecursiveRemover{
+		BatchSize: *flagBatchSize,
+		Progress: func(p string, n int) {
+			total += n
+			log.Printf("deleted %d children of %s (%d total)", n, p, total)
+		},
+	}
+	if err = rr.Remove(db.Ref(ref), context.Background()); err != nil {
+		return err
+	}
+
+	log.Printf("deleted %s", ref)
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/mask"
+	"github.com/knq/firebase/internal/version"
+)
+
+var (
+	flagCredentials = flag.String("creds", "", "path to google service account credentials")
+	flagRef         = flag.String("ref", "/", "firebase ref to export")
+	flagOut         = flag.String("out", "-", "output file, or directory when -dir is specified (- for stdout)")
+	flagDir         = flag.Bool("dir", false, "write each child of the ref to a separate file in the output directory")
+	flagGzip        = flag.Bool("gzip", false, "gzip output")
+	flagChunk       = flag.Int("chunk", 1000, "number of children retrieved per request")
+	flagDownload    = flag.String("download", "", "retrieve the ref in a single request as the download filename")
+	flagVerbose     = flag.Bool("v", false, "verbose logging")
+	flagMask        mask.Profile
+)
+
+func init() {
+	flag.Var(&flagMask, "mask", "mask values matching a path glob (glob=redact, glob=hash, or glob=truncate:n), may be repeated")
+}
+
+func main() {
+	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the export.
+func run() error {
+	// check credentials
+	if *flagCredentials == "" {
+		return clierr.Usage("invalid credentials file")
+	}
+	if *flagChunk <= 0 {
+		return clierr.Usage("invalid chunk size")
+	}
+	if *flagDir && (*flagOut == "-" || *flagDownload != "") {
+		return clierr.Usage("-dir requires an output directory, and cannot be used with -download")
+	}
+	if *flagDownload != "" && len(flagMask) != 0 {
+		return clierr.Usage("-mask cannot be used with -download")
+	}
+
+	// build firebase options
+	opts := []firebase.Option{
+		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
+	}
+	if *flagVerbose {
+		opts = append(opts, firebase.Log(log.Printf, log.Printf))
+	}
+
+	// create database ref
+	db, err := firebase.NewDatabaseRef(opts...)
+	if err != nil {
+		return clierr.Auth(err)
+	}
+	ref := db.Ref(*flagRef)
+
+	// single request download
+	if *flagDownload != "" {
+		body, err := ref.GetRaw(firebase.Download(*flagDownload))
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return write(*flagOut, func(w io.Writer) error {
+			_, err := io.Copy(w, body)
+			return err
+		})
+	}
+
+	// retrieve shallow keys
+	var shallow interface{}
+	if err = ref.Get(&shallow, firebase.Shallow); err != nil {
+		return err
+	}
+	m, ok := shallow.(map[string]interface{})
+	if !ok {
+		// ref is a primitive (or null) value
+		if *flagDir {
+			return fmt.Errorf("ref %s is not an object", *flagRef)
+		}
+		return write(*flagOut, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(flagMask.Apply("/", shallow))
+		})
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+
+	// export to directory
+	if *flagDir {
+		if err = os.MkdirAll(*flagOut, 0755); err != nil {
+			return err
+		}
+		return chunks(ref, keys, func(kv firebase.KeyValue) error {
+			name := filepath.Join(*flagOut, kv.Key+".json")
+			return write(name, func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "%s\n", kv.Value)
+				return err
+			})
+		})
+	}
+
+	// export to file
+	return write(*flagOut, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		first := true
+		err := chunks(ref, keys, func(kv firebase.KeyValue) error {
+			key, err := json.Marshal(kv.Key)
+			if err != nil {
+				return err
+			}
+			sep := ","
+			if first {
+				sep, first = "", false
+			}
+			_, err = fmt.Fprintf(w, "%s%s:%s", sep, key, kv.Value)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "}\n")
+		return err
+	})
+}
+
+// chunks retrieves the children of ref with the sorted keys, flagChunk
+// children per request, calling f for each child.
+func chunks(ref *firebase.DatabaseRef, keys []string, f func(firebase.KeyValue) error) error {
+	for i := 0; i < len(keys); i += *flagChunk {
+		end := i + *flagChunk
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if *flagVerbose {
+			log.Printf("retrieving %d-%d of %d children", i+1, end, len(keys))
+		}
+
+		s, err := ref.GetStream(
+			firebase.OrderBy("$key"),
+			firebase.StartAt(keys[i]),
+			firebase.EndAt(keys[end-1]),
+		)
+		if err != nil {
+			return err
+		}
+		for s.Next() {
+			kv := s.KeyValue()
+			if kv.Value, err = flagMask.ApplyJSON("/"+kv.Key, kv.Value); err != nil {
+				s.Close()
+				return err
+			}
+			if err = f(kv); err != nil {
+				s.Close()
+				return err
+			}
+		}
+		err = s.Err()
+		s.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write creates the named file (or stdout when name is -), gzipping the
+// output when -gzip is specified, and calling f to write the file contents.
+func write(name string, f func(io.Writer) error) error {
+	var w io.WriteCloser = os.Stdout
+	if name != "-" {
+		if *flagGzip && !strings.HasSuffix(name, ".gz") {
+			name += ".gz"
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+
+	var err error
+	if *flagGzip {
+		gz := gzip.NewWriter(w)
+		err = f(gz)
+		if cerr := gz.Close(); err == nil {
+			err = cerr
+		}
+	} else {
+		err = f(w)
+	}
+
+	if name != "-" {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// keyLess reports whether key a sorts before key b, using Firebase's key
+// ordering: keys parseable as 32-bit integers first (in numeric order), then
+// all other keys in lexicographic order.
+func keyLess(a, b string) bool {
+	ia, aerr := strconv.ParseInt(a, 10, 32)
+	ib, berr := strconv.ParseInt(b, 10, 32)
+	switch {
+	case aerr == nil && berr == nil:
+		return ia < ib
+	case aerr == nil || berr == nil:
+		return aerr == nil
+	}
+	return a < b
+}
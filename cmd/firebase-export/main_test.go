@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+)
+
+// setFlags sets the command line flags, returning a func that restores their
+// defaults.
+func setFlags(t *testing.T, args ...string) func() {
+	for i := 0; i < len(args); i += 2 {
+		if err := flag.Set(args[i], args[i+1]); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return func() {
+		for i := 0; i < len(args); i += 2 {
+			f := flag.Lookup(args[i])
+			if f.Name == "mask" {
+				flagMask = nil
+				continue
+			}
+			f.Value.Set(f.DefValue)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"creds", "c.json", "chunk", "0"},
+		{"creds", "c.json", "dir", "true"},
+		{"creds", "c.json", "dir", "true", "out", "dir", "download", "a.json"},
+		{"creds", "c.json", "download", "a.json", "mask", "users/*/email=redact"},
+	}
+	for i, test := range tests {
+		reset := setFlags(t, test...)
+		err := run()
+		reset()
+		if e, ok := err.(*clierr.Error); !ok || e.Code != clierr.ExitUsage {
+			t.Errorf("test %d expected usage error, got: %v", i, err)
+		}
+	}
+}
+
+func TestKeyLess(t *testing.T) {
+	keys := []string{"b", "10", "-1", "a", "2", "2147483648", "1"}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	if s := strings.Join(keys, " "); s != "-1 1 2 10 2147483648 a b" {
+		t.Errorf("unexpected order: %s", s)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	data := map[string]interface{}{
+		"1": 1, "2": "two", "10": map[string]interface{}{"email": "a@example.com"}, "a": true, "b": nil,
+	}
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		reqs = append(reqs, q.Get("startAt")+"-"+q.Get("endAt"))
+		var start, end string
+		json.Unmarshal([]byte(q.Get("startAt")), &start)
+		json.Unmarshal([]byte(q.Get("endAt")), &end)
+		m := make(map[string]interface{})
+		for k, v := range data {
+			if !keyLess(k, start) && !keyLess(end, k) && v != nil {
+				m[k] = v
+			}
+		}
+		json.NewEncoder(w).Encode(m)
+	}))
+	defer ts.Close()
+
+	r, err := firebase.NewDatabaseRef(firebase.URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer setFlags(t, "chunk", "2", "mask", "*/email=redact")()
+
+	var out []string
+	err = chunks(r, []string{"1", "2", "10", "a"}, func(kv firebase.KeyValue) error {
+		out = append(out, kv.Key+"="+string(kv.Value))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := strings.Join(reqs, " "); s != `"1"-"2" "10"-"a"` {
+		t.Errorf("unexpected requests: %s", s)
+	}
+	if s := strings.Join(out, " "); s != `1=1 2="two" 10={"email":"[redacted]"} a=true` {
+		t.Errorf("unexpected output: %s", s)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firebase-export")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		gzip bool
+		name string
+	}{
+		{false, "a.json"},
+		{true, "b.json.gz"},
+	}
+	for i, test := range tests {
+		*flagGzip = test.gzip
+		err := write(filepath.Join(dir, strings.TrimSuffix(test.name, ".gz")), func(w io.Writer) error {
+			_, err := io.WriteString(w, `{"a":1}`)
+			return err
+		})
+		*flagGzip = false
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		buf, err := ioutil.ReadFile(filepath.Join(dir, test.name))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if test.gzip {
+			gz, err := gzip.NewReader(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			if buf, err = ioutil.ReadAll(gz); err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+		}
+		if string(buf) != `{"a":1}` {
+			t.Errorf("test %d unexpected contents: %s", i, buf)
+		}
+	}
+}
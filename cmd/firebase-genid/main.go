@@ -1,12 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/version"
 )
 
 func main() {
+	flag.Parse()
+	version.Check()
+
 	fmt.Fprintf(os.Stdout, "%s\n", firebase.GeneratePushID())
 }
@@ -8,6 +8,9 @@ import (
 	"os"
 
 	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/mask"
+	"github.com/knq/firebase/internal/version"
 )
 
 var (
@@ -15,17 +18,40 @@ var (
 	flagRef         = flag.String("ref", "/", "firebase ref to retrieve")
 	flagVerbose     = flag.Bool("v", false, "verbose logging")
 	flagRules       = flag.Bool("rules", false, "retrieve rules")
+	flagOrderBy     = flag.String("order-by", "", "order children by $key, $value, $priority, or a child path")
+	flagStartAt     = flag.String("start-at", "", "start at value (json, or a string)")
+	flagEndAt       = flag.String("end-at", "", "end at value (json, or a string)")
+	flagEqualTo     = flag.String("equal-to", "", "equal to value (json, or a string)")
+	flagLimitFirst  = flag.Uint("limit-to-first", 0, "limit to the first n children")
+	flagLimitLast   = flag.Uint("limit-to-last", 0, "limit to the last n children")
+	flagMask        mask.Profile
 )
 
-func main() {
-	var err error
+func init() {
+	flag.Var(&flagMask, "mask", "mask values matching a path glob (glob=redact, glob=hash, or glob=truncate:n), may be repeated")
+}
+
+// keyValue is a child key and value of an ordered query result.
+type keyValue struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Priority interface{}     `json:"priority,omitempty"`
+}
 
+func main() {
 	flag.Parse()
+	version.Check()
 
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run retrieves the ref.
+func run() error {
 	// check credentials
 	if *flagCredentials == "" {
-		fmt.Fprintf(os.Stderr, "error: invalid credentials file\n")
-		os.Exit(1)
+		return clierr.Usage("invalid credentials file")
 	}
 
 	// build firebase options
@@ -39,33 +65,86 @@ func main() {
 	// create database ref
 	ref, err := firebase.NewDatabaseRef(opts...)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return clierr.Auth(err)
+	}
+
+	// build query
+	var query []firebase.QueryOption
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "start-at":
+			query = append(query, firebase.StartAt(queryValue(f.Value.String())))
+		case "end-at":
+			query = append(query, firebase.EndAt(queryValue(f.Value.String())))
+		case "equal-to":
+			query = append(query, firebase.EqualTo(queryValue(f.Value.String())))
+		case "limit-to-first":
+			query = append(query, firebase.LimitToFirst(*flagLimitFirst))
+		case "limit-to-last":
+			query = append(query, firebase.LimitToLast(*flagLimitLast))
+		}
+	})
+	if *flagOrderBy == "" && len(query) != 0 {
+		*flagOrderBy = "$key"
 	}
 
 	// retrieve ref
 	var buf []byte
-	if !*flagRules {
-		var v interface{}
-		err = ref.Ref(*flagRef).Get(&v)
+	switch {
+	case *flagOrderBy != "" && !*flagRules:
+		// retrieve as an ordered array, as the server's ordering is lost in
+		// a json object
+		kvs, err := ref.Ref(*flagRef).GetOrdered(append(query, firebase.OrderBy(*flagOrderBy))...)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		res := make([]keyValue, len(kvs))
+		for i, kv := range kvs {
+			val, err := flagMask.ApplyJSON("/"+kv.Key, kv.Value)
+			if err != nil {
+				return err
+			}
+			res[i] = keyValue{Key: kv.Key, Value: val, Priority: kv.Priority}
 		}
 
 		// pretty format
-		buf, err = json.MarshalIndent(v, "", "  ")
+		buf, err = json.MarshalIndent(res, "", "  ")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
-	} else {
+
+	case !*flagRules:
+		var v interface{}
+		if err = ref.Ref(*flagRef).Get(&v); err != nil {
+			return err
+		}
+		if v == nil {
+			return clierr.NotFound("%s not found", *flagRef)
+		}
+
+		// pretty format
+		buf, err = json.MarshalIndent(flagMask.Apply("/", v), "", "  ")
+		if err != nil {
+			return err
+		}
+
+	default:
 		buf, err = ref.Ref(*flagRef).GetRulesJSON()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 	}
 
 	fmt.Fprintf(os.Stdout, "%s\n", string(buf))
+	return nil
+}
+
+// queryValue returns the JSON decoded value of s, or s when s is not valid
+// JSON (ie, an unquoted string).
+func queryValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
 }
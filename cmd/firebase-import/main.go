@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
+)
+
+var (
+	flagCredentials = flag.String("creds", "", "path to google service account credentials")
+	flagRef         = flag.String("ref", "/", "firebase ref to import to")
+	flagFile        = flag.String("file", "", "json encoded file to import (optionally gzipped)")
+	flagMaxBytes    = flag.Int("max-bytes", 1<<20, "maximum size of each write request in bytes")
+	flagConcurrency = flag.Int("concurrency", 4, "number of concurrent write requests")
+	flagDryRun      = flag.Bool("dry-run", false, "show what would be written, without writing")
+	flagProgress    = flag.String("progress", "", "progress file for resuming an interrupted import (default: <file>.progress)")
+	flagVerbose     = flag.Bool("v", false, "verbose logging")
+)
+
+func main() {
+	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// write is a single path and value written by a batch.
+type write struct {
+	path string
+	val  interface{}
+	size int
+}
+
+// run runs the import.
+func run() error {
+	// check flags
+	switch {
+	case *flagCredentials == "" && !*flagDryRun:
+		return clierr.Usage("invalid credentials file")
+	case *flagFile == "":
+		return clierr.Usage("file not specified")
+	case *flagMaxBytes <= 0:
+		return clierr.Usage("invalid max bytes")
+	case *flagConcurrency <= 0:
+		return clierr.Usage("invalid concurrency")
+	}
+	if *flagProgress == "" {
+		*flagProgress = *flagFile + ".progress"
+	}
+
+	// decode file
+	v, fingerprint, err := decode(*flagFile)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return fmt.Errorf("%s does not contain a json object", *flagFile)
+	}
+
+	// split into batches
+	var writes []write
+	if err = split(path.Join("/", *flagRef), v, &writes); err != nil {
+		return err
+	}
+	batches := batch(writes)
+
+	// dry run
+	if *flagDryRun {
+		for i, b := range batches {
+			var size int
+			for _, w := range b {
+				size += w.size
+			}
+			fmt.Fprintf(os.Stdout, "batch %d: %d paths, %d bytes\n", i+1, len(b), size)
+			for _, w := range b {
+				fmt.Fprintf(os.Stdout, "  %s (%d bytes)\n", w.path, w.size)
+			}
+		}
+		return nil
+	}
+
+	// load progress
+	done, progress, err := loadProgress(*flagProgress, fingerprint)
+	if err != nil {
+		return err
+	}
+	defer progress.Close()
+	if len(done) != 0 {
+		log.Printf("resuming: %d of %d batches already written", len(done), len(batches))
+	}
+
+	// create firebase ref
+	opts := []firebase.Option{
+		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
+	}
+	if *flagVerbose {
+		opts = append(opts, firebase.Log(log.Printf, log.Printf))
+	}
+	db, err := firebase.NewDatabaseRef(opts...)
+	if err != nil {
+		return clierr.Auth(err)
+	}
+
+	// write batches
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	ch := make(chan int)
+	for i := 0; i < *flagConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				updates := make(map[string]interface{}, len(batches[i]))
+				for _, w := range batches[i] {
+					updates[w.path] = w.val
+				}
+				err := db.UpdateMulti(updates)
+
+				mu.Lock()
+				if err == nil {
+					_, err = fmt.Fprintf(progress, "%d\n", i)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("batch %d: %w", i+1, err)
+				}
+				if err == nil {
+					log.Printf("wrote batch %d of %d (%d paths)", i+1, len(batches), len(batches[i]))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range batches {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		if !done[i] {
+			ch <- i
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// remove progress on completion
+	progress.Close()
+	return os.Remove(*flagProgress)
+}
+
+// decode decodes the json file, returning the decoded value and the
+// fingerprint of the file and batch size, used to validate the progress file.
+func decode(name string) (interface{}, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:", *flagMaxBytes)
+
+	var r io.Reader = bufio.NewReader(io.TeeReader(f, h))
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, "", err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return nil, "", err
+	}
+	io.Copy(ioutil.Discard, r)
+
+	return v, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// split splits v into writes no larger than flagMaxBytes, descending into
+// objects that are too large to be written in a single request.
+func split(p string, v interface{}, writes *[]write) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// the root cannot be written by a multi-path update, so always descend
+	m, ok := v.(map[string]interface{})
+	if p != "/" && (len(buf) <= *flagMaxBytes || !ok || len(m) == 0) {
+		if len(buf) > *flagMaxBytes {
+			log.Printf("warning: %s (%d bytes) exceeds max bytes", p, len(buf))
+		}
+		*writes = append(*writes, write{path: p, val: v, size: len(buf) + len(p)})
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err = split(path.Join(p, k), m[k], writes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// batch groups the writes into batches no larger than flagMaxBytes.
+func batch(writes []write) [][]write {
+	var batches [][]write
+	var cur []write
+	var size int
+	for _, w := range writes {
+		if len(cur) != 0 && size+w.size > *flagMaxBytes {
+			batches = append(batches, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, w)
+		size += w.size
+	}
+	if len(cur) != 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// loadProgress loads the completed batches from the progress file, returning
+// the progress file opened for appending.
+//
+// The first line of the progress file is the fingerprint of the import file
+// and batch size, which must match the current import.
+func loadProgress(name, fingerprint string) (map[int]bool, *os.File, error) {
+	done := make(map[int]bool)
+
+	buf, err := ioutil.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err = fmt.Fprintf(f, "%s\n", fingerprint); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return done, f, nil
+	case err != nil:
+		return nil, nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if lines[0] != fingerprint {
+		return nil, nil, fmt.Errorf("progress file %s does not match the import file and max bytes (remove it to restart)", name)
+	}
+	for _, line := range lines[1:] {
+		i, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid progress file %s: %v", name, err)
+		}
+		done[i] = true
+	}
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return done, f, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase/internal/clierr"
+)
+
+// setFlags sets the command line flags, returning a func that restores their
+// defaults.
+func setFlags(t *testing.T, args ...string) func() {
+	for i := 0; i < len(args); i += 2 {
+		if err := flag.Set(args[i], args[i+1]); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return func() {
+		for i := 0; i < len(args); i += 2 {
+			f := flag.Lookup(args[i])
+			f.Value.Set(f.DefValue)
+		}
+		*flagProgress = ""
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tests := [][]string{
+		{"file", "a.json"},
+		{"dry-run", "true"},
+		{"creds", "c.json", "file", "a.json", "max-bytes", "0"},
+		{"creds", "c.json", "file", "a.json", "concurrency", "0"},
+	}
+	for i, test := range tests {
+		reset := setFlags(t, test...)
+		err := run()
+		reset()
+		if e, ok := err.(*clierr.Error); !ok || e.Code != clierr.ExitUsage {
+			t.Errorf("test %d expected usage error, got: %v", i, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	defer setFlags(t, "max-bytes", "20")()
+
+	v := map[string]interface{}{
+		"a": map[string]interface{}{"x": 1, "y": 2},
+		"b": "hello",
+		"c": map[string]interface{}{"big": strings.Repeat("x", 30)},
+		"d": map[string]interface{}{},
+	}
+	tests := []struct {
+		path    string
+		writes  string
+		batches int
+	}{
+		{"/", "/a:15 /b:9 /c/big:38 /d:4", 4},
+		{"/imp", "/imp/a:19 /imp/b:13 /imp/c/big:42 /imp/d:8", 4},
+	}
+	for i, test := range tests {
+		var writes []write
+		if err := split(test.path, v, &writes); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var s []string
+		for _, w := range writes {
+			s = append(s, fmt.Sprintf("%s:%d", w.path, w.size))
+		}
+		if strings.Join(s, " ") != test.writes {
+			t.Errorf("test %d expected writes %s, got: %s", i, test.writes, strings.Join(s, " "))
+		}
+		if batches := batch(writes); len(batches) != test.batches {
+			t.Errorf("test %d expected %d batches, got: %d", i, test.batches, len(batches))
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firebase-import")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "a.json")
+	if err = ioutil.WriteFile(plain, []byte(`{"n":12345678901234567890}`), 0644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	gzipped := filepath.Join(dir, "a.json.gz")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`{"n":12345678901234567890}`))
+	gz.Close()
+	f.Close()
+
+	v1, fp1, err := decode(plain)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	v2, fp2, err := decode(gzipped)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if fmt.Sprint(v1) != "map[n:12345678901234567890]" || fmt.Sprint(v2) != fmt.Sprint(v1) {
+		t.Errorf("expected numbers to be preserved, got: %v, %v", v1, v2)
+	}
+	if fp1 == fp2 {
+		t.Errorf("expected fingerprints to differ by file contents")
+	}
+
+	// fingerprint includes max bytes
+	reset := setFlags(t, "max-bytes", "10")
+	_, fp3, err := decode(plain)
+	reset()
+	if err != nil || fp3 == fp1 {
+		t.Errorf("expected fingerprint to change with max bytes, got: %s, %v", fp3, err)
+	}
+
+	if _, _, err = decode(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got: %v", err)
+	}
+}
+
+func TestLoadProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firebase-import")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a.json.progress")
+
+	// new
+	done, f, err := loadProgress(name, "fp")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(done) != 0 {
+		t.Errorf("expected no completed batches, got: %v", done)
+	}
+	fmt.Fprintf(f, "%d\n%d\n", 0, 2)
+	f.Close()
+
+	// resume
+	if done, f, err = loadProgress(name, "fp"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(done) != 2 || !done[0] || done[1] || !done[2] {
+		t.Errorf("expected batches 0 and 2 completed, got: %v", done)
+	}
+	fmt.Fprintf(f, "%d\n", 1)
+	f.Close()
+	if done, f, err = loadProgress(name, "fp"); err != nil || len(done) != 3 {
+		t.Errorf("expected 3 completed batches, got: %v, %v", done, err)
+	}
+	f.Close()
+
+	// mismatch
+	if _, _, err = loadProgress(name, "other"); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected mismatch error, got: %v", err)
+	}
+
+	// invalid
+	ioutil.WriteFile(name, []byte("fp\nx\n"), 0644)
+	if _, _, err = loadProgress(name, "fp"); err == nil || !strings.Contains(err.Error(), "invalid progress file") {
+		t.Errorf("expected invalid progress error, got: %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firebase-import")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "a.json")
+	if err = ioutil.WriteFile(name, []byte(`{"a":{"x":1,"y":2},"b":"hello"}`), 0644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer setFlags(t, "file", name, "ref", "imp", "max-bytes", "20", "dry-run", "true")()
+
+	// capture stdout
+	out, err := ioutil.TempFile(dir, "stdout")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	err = run()
+	os.Stdout = stdout
+	out.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := "batch 1: 1 paths, 19 bytes\n  /imp/a (19 bytes)\nbatch 2: 1 paths, 13 bytes\n  /imp/b (13 bytes)\n"
+	if string(buf) != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, buf)
+	}
+	if _, err = os.Stat(name + ".progress"); !os.IsNotExist(err) {
+		t.Errorf("expected no progress file, got: %v", err)
+	}
+
+	// not an object
+	ioutil.WriteFile(name, []byte(`[1,2]`), 0644)
+	if err = run(); err == nil || !strings.Contains(err.Error(), "does not contain a json object") {
+		t.Errorf("expected not an object error, got: %v", err)
+	}
+}
@@ -2,42 +2,66 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"io/ioutil"
 	"log"
 
 	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
 )
 
 var (
-	flagCreds = flag.String("creds", "", "google service account credentials file")
-	flagRef   = flag.String("ref", "/", "firebase database path ref to merge data to")
-	flagFile  = flag.String("file", "", "json encoded file")
+	flagCreds       = flag.String("creds", "", "google service account credentials file")
+	flagRef         = flag.String("ref", "/", "firebase database path ref to merge data to")
+	flagFile        = flag.String("file", "", "json encoded file")
+	flagRate        = flag.Float64("rate", 0, "maximum writes per second (0 for unlimited)")
+	flagConcurrency = flag.Int("concurrency", firebase.DefaultBatchConcurrency, "number of concurrent write requests")
 )
 
 func main() {
-	var err error
-
 	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the merge.
+func run() error {
+	var err error
 
 	// check flags
 	if *flagCreds == "" || *flagFile == "" {
-		log.Fatal("creds or file not specified")
+		return clierr.Usage("creds or file not specified")
+	}
+
+	if *flagRate < 0 {
+		return clierr.Usage("rate cannot be negative")
+	}
+	if *flagConcurrency <= 0 {
+		return clierr.Usage("invalid concurrency")
 	}
 
 	// create firebase ref
-	db, err := firebase.NewDatabaseRef(
+	opts := []firebase.Option{
 		firebase.GoogleServiceAccountCredentialsFile(*flagCreds),
-	)
+	}
+	if *flagRate > 0 {
+		opts = append(opts, firebase.RateLimit(*flagRate, 1))
+	}
+	db, err := firebase.NewDatabaseRef(opts...)
 	if err != nil {
-		log.Fatal(err)
+		return clierr.Auth(err)
 	}
 
 	// decode json file
 	buf, err := ioutil.ReadFile(*flagFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// create json decoder
@@ -48,18 +72,16 @@ func main() {
 	var d map[string]interface{}
 	err = dec.Decode(&d)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// get base ref
 	r := db.Ref(*flagRef)
 
 	// overwrite each node from data
-	for k, v := range d {
-		log.Printf("writing %s", k)
-		err = r.Ref("/" + k).Set(v)
-		if err != nil {
-			log.Fatal(err)
-		}
+	log.Printf("writing %d nodes", len(d))
+	b := &firebase.BatchWriter{
+		Concurrency: *flagConcurrency,
 	}
+	return b.Set(r, context.Background(), d)
 }
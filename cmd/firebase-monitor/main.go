@@ -4,28 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"log"
-	"os"
+	"net/http"
 	"strings"
 
 	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
 )
 
 var (
 	flagCredentials = flag.String("creds", "", "path to google service account credentials")
 	flagRef         = flag.String("ref", "/", "firebase ref to monitor")
+	flagMetricsAddr = flag.String("metrics-addr", "", "address to serve prometheus metrics on (ie, :9090)")
 )
 
 func main() {
-	var err error
-
 	flag.Parse()
+	version.Check()
 
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the monitor.
+func run() error {
 	// check credentials
 	if *flagCredentials == "" {
-		fmt.Fprintf(os.Stderr, "error: invalid credentials file\n")
-		os.Exit(1)
+		return clierr.Usage("invalid credentials file")
 	}
 
 	// create database ref
@@ -33,34 +40,56 @@ func main() {
 		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
 	)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return clierr.Auth(err)
 	}
 
-	// watch ref
-	ch, err := ref.Ref(*flagRef).Watch(context.Background())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	// serve metrics
+	m := newMetrics()
+	if *flagMetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m)
+		go func() {
+			log.Fatal(http.ListenAndServe(*flagMetricsAddr, mux))
+		}()
 	}
 
+	// listen to ref, reconnecting as necessary
+	ch := ref.Ref(*flagRef).Listen(context.Background(), []firebase.EventType{
+		firebase.EventTypePut,
+		firebase.EventTypePatch,
+		firebase.EventTypeKeepAlive,
+		firebase.EventTypeCancel,
+		firebase.EventTypeAuthRevoked,
+		firebase.EventTypeConnected,
+		firebase.EventTypeDisconnected,
+		firebase.EventTypeReconnecting,
+	})
+
 	// output events as received
 	for ev := range ch {
+		m.record(ev)
+
+		switch ev.Type {
+		case firebase.EventTypeConnected, firebase.EventTypeDisconnected, firebase.EventTypeReconnecting:
+			log.Printf("%s: %s", strings.ToUpper(string(ev.Type)), string(ev.Data))
+			continue
+		}
+
 		// unmarshal data
-		var v map[string]interface{}
+		var v interface{}
 		err = json.Unmarshal(ev.Data, &v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 
 		// pretty format
 		buf, err := json.MarshalIndent(v, "", "  ")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 
 		log.Printf("%s: %s", strings.ToUpper(string(ev.Type)), string(buf))
 	}
+
+	return nil
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+// metrics are the stream health metrics of the monitor, served in the
+// Prometheus text exposition format.
+type metrics struct {
+	sync.Mutex
+
+	events     map[firebase.EventType]int64
+	reconnects int64
+	connected  bool
+	lastEvent  time.Time
+
+	now func() time.Time
+}
+
+// newMetrics creates the monitor metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		events: make(map[firebase.EventType]int64),
+		now:    time.Now,
+	}
+}
+
+// record records the event.
+func (m *metrics) record(ev *firebase.Event) {
+	m.Lock()
+	defer m.Unlock()
+
+	switch ev.Type {
+	case firebase.EventTypeConnected:
+		m.connected = true
+	case firebase.EventTypeDisconnected:
+		m.connected = false
+	case firebase.EventTypeReconnecting:
+		m.reconnects++
+	default:
+		m.lastEvent = m.now()
+	}
+	m.events[ev.Type]++
+}
+
+// labelEscaper escapes label values for the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP satisfies the http.Handler interface.
+func (m *metrics) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	types := make([]string, 0, len(m.events))
+	for typ := range m.events {
+		types = append(types, string(typ))
+	}
+	sort.Strings(types)
+	fmt.Fprintln(res, "# HELP firebase_monitor_events_total Events received, by type.")
+	fmt.Fprintln(res, "# TYPE firebase_monitor_events_total counter")
+	for _, typ := range types {
+		fmt.Fprintf(res, "firebase_monitor_events_total{type=\"%s\"} %d\n", labelEscaper.Replace(typ), m.events[firebase.EventType(typ)])
+	}
+
+	fmt.Fprintln(res, "# HELP firebase_monitor_reconnects_total Stream reconnection attempts.")
+	fmt.Fprintln(res, "# TYPE firebase_monitor_reconnects_total counter")
+	fmt.Fprintf(res, "firebase_monitor_reconnects_total %d\n", m.reconnects)
+
+	var connected int
+	if m.connected {
+		connected = 1
+	}
+	fmt.Fprintln(res, "# HELP firebase_monitor_connected Whether the stream is connected.")
+	fmt.Fprintln(res, "# TYPE firebase_monitor_connected gauge")
+	fmt.Fprintf(res, "firebase_monitor_connected %d\n", connected)
+
+	if !m.lastEvent.IsZero() {
+		fmt.Fprintln(res, "# HELP firebase_monitor_last_event_timestamp_seconds Time the last server event (including keep-alives) was received.")
+		fmt.Fprintln(res, "# TYPE firebase_monitor_last_event_timestamp_seconds gauge")
+		fmt.Fprintf(res, "firebase_monitor_last_event_timestamp_seconds %.3f\n", float64(m.lastEvent.UnixNano())/1e9)
+
+		fmt.Fprintln(res, "# HELP firebase_monitor_lag_seconds Time since the last server event was received.")
+		fmt.Fprintln(res, "# TYPE firebase_monitor_lag_seconds gauge")
+		fmt.Fprintf(res, "firebase_monitor_lag_seconds %.3f\n", m.now().Sub(m.lastEvent).Seconds())
+	}
+}
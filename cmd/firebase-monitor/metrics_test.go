@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+func TestMetrics(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	m := newMetrics()
+	m.now = func() time.Time { return now }
+
+	for _, typ := range []firebase.EventType{
+		firebase.EventTypeConnected,
+		firebase.EventTypePut,
+		firebase.EventTypeKeepAlive,
+		firebase.EventTypeDisconnected,
+		firebase.EventTypeReconnecting,
+		firebase.EventTypeConnected,
+		firebase.EventTypePatch,
+		firebase.EventTypePatch,
+		firebase.EventType("a\"b\\c\nd"),
+	} {
+		m.record(&firebase.Event{Type: typ})
+	}
+	now = now.Add(2500 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if s := w.Header().Get("Content-Type"); s != "text/plain; version=0.0.4" {
+		t.Errorf("expected text exposition content type, got: %s", s)
+	}
+	exp := `# HELP firebase_monitor_events_total Events received, by type.
+# TYPE firebase_monitor_events_total counter
+firebase_monitor_events_total{type="a\"b\\c\nd"} 1
+firebase_monitor_events_total{type="connected"} 2
+firebase_monitor_events_total{type="disconnected"} 1
+firebase_monitor_events_total{type="keep-alive"} 1
+firebase_monitor_events_total{type="patch"} 2
+firebase_monitor_events_total{type="put"} 1
+firebase_monitor_events_total{type="reconnecting"} 1
+# HELP firebase_monitor_reconnects_total Stream reconnection attempts.
+# TYPE firebase_monitor_reconnects_total counter
+firebase_monitor_reconnects_total 1
+# HELP firebase_monitor_connected Whether the stream is connected.
+# TYPE firebase_monitor_connected gauge
+firebase_monitor_connected 1
+# HELP firebase_monitor_last_event_timestamp_seconds Time the last server event (including keep-alives) was received.
+# TYPE firebase_monitor_last_event_timestamp_seconds gauge
+firebase_monitor_last_event_timestamp_seconds 1500000000.000
+# HELP firebase_monitor_lag_seconds Time since the last server event was received.
+# TYPE firebase_monitor_lag_seconds gauge
+firebase_monitor_lag_seconds 2.500
+`
+	if s := w.Body.String(); s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+
+	// no last event
+	w = httptest.NewRecorder()
+	newMetrics().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	exp = `# HELP firebase_monitor_events_total Events received, by type.
+# TYPE firebase_monitor_events_total counter
+# HELP firebase_monitor_reconnects_total Stream reconnection attempts.
+# TYPE firebase_monitor_reconnects_total counter
+firebase_monitor_reconnects_total 0
+# HELP firebase_monitor_connected Whether the stream is connected.
+# TYPE firebase_monitor_connected gauge
+firebase_monitor_connected 0
+`
+	if s := w.Body.String(); s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/rules"
+)
+
+// edit downloads the existing rules into $EDITOR, validates the edited
+// rules, shows the differences, and writes the rules after confirmation.
+//
+// When the edited rules are invalid, the editor is reopened with the edited
+// rules until they are valid or the edit is abandoned.
+func edit(ref *firebase.DatabaseRef) error {
+	existing, err := ref.GetRulesJSON()
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file
+	f, err := ioutil.TempFile("", "firebase-rules-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(existing)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	var buf []byte
+	for {
+		if err = runEditor(f.Name()); err != nil {
+			return err
+		}
+		if buf, err = ioutil.ReadFile(f.Name()); err != nil {
+			return err
+		}
+
+		// validate locally, then with the server
+		if err = validate(buf); err == nil {
+			err = ref.SetRulesJSON(buf, firebase.DryRun)
+		}
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "invalid rules: %v\n", err)
+		if !confirm(stdin, "edit again?") {
+			return fmt.Errorf("edit abandoned: %w", err)
+		}
+	}
+
+	// show changes
+	a, err := canonical(existing)
+	if err != nil {
+		return fmt.Errorf("existing rules: %w", err)
+	}
+	b, err := canonical(buf)
+	if err != nil {
+		return err
+	}
+	if encode(a) == encode(b) {
+		fmt.Fprintln(os.Stdout, "no changes")
+		return nil
+	}
+	if err = diff(existing, buf); err != nil {
+		return err
+	}
+	if !confirm(stdin, "write rules?") {
+		fmt.Fprintln(os.Stdout, "rules not written")
+		return nil
+	}
+
+	// save existing rules
+	if !*flagNoSave {
+		err = ioutil.WriteFile(*flagRulesFile+"-old", existing, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ref.SetRulesJSON(buf)
+}
+
+// validate parses and validates the rules.
+func validate(buf []byte) error {
+	n, err := rules.Parse(buf)
+	if err != nil {
+		return err
+	}
+	return rules.Validate(n)
+}
+
+// runEditor opens name with $VISUAL or $EDITOR, defaulting to vi.
+func runEditor(name string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// editor may include arguments (ie, "code --wait")
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], name)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", editor, err)
+	}
+	return nil
+}
+
+// confirm prompts for a yes or no answer, defaulting to no.
+func confirm(r *bufio.Reader, prompt string) bool {
+	fmt.Fprintf(os.Stdout, "%s [y/N] ", prompt)
+	line, _ := r.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+// rulesServer is a test database server for the security rules.
+type rulesServer struct {
+	sync.Mutex
+	rules  string
+	dryRun int
+}
+
+func (s *rulesServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if req.URL.Path != "/.settings/rules.json" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	switch req.Method {
+	case "GET":
+		w.Write([]byte(s.rules))
+	case "PUT":
+		buf, _ := ioutil.ReadAll(req.Body)
+		if strings.Contains(string(buf), "reject") {
+			http.Error(w, `{"error":"Line 1: invalid expression"}`, http.StatusBadRequest)
+			return
+		}
+		if req.URL.Query().Get("dryRun") == "true" {
+			s.dryRun++
+		} else {
+			var b bytes.Buffer
+			json.Compact(&b, buf)
+			s.rules = b.String()
+		}
+		w.Write(buf)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firebase-rules")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"valid.json":    `{"rules":{".read":true,".write":false}}`,
+		"invalid.json":  `{"rules":`,
+		"rejected.json": `{"rules":{".read":"'reject' != ''"}}`,
+	}
+	for name, buf := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(buf), 0644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	defer os.Setenv("VISUAL", os.Getenv("VISUAL"))
+
+	// discard the invalid rules messages
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer devnull.Close()
+	defer setFlags(t, "rules", filepath.Join(dir, "rules.json"))()
+
+	existing := `{"rules":{".read":false,".write":false}}`
+	tests := []struct {
+		editor string
+		input  string
+		out    string
+		err    string
+		rules  string
+		dryRun int
+	}{
+		{"true", "", "no changes\n", "", existing, 1},
+		{"cp " + filepath.Join(dir, "valid.json"), "n\n", "- /.read: false\n+ /.read: true\nwrite rules? [y/N] rules not written\n", "", existing, 1},
+		{"cp " + filepath.Join(dir, "invalid.json"), "n\n", "edit again? [y/N] ", "edit abandoned: ", existing, 0},
+		{"cp " + filepath.Join(dir, "rejected.json"), "\n", "edit again? [y/N] ", "edit abandoned: ", existing, 0},
+		{"cp " + filepath.Join(dir, "valid.json"), "y\n", "- /.read: false\n+ /.read: true\nwrite rules? [y/N] ", "", files["valid.json"], 1},
+		{"false", "", "", "editor false: ", existing, 0},
+	}
+	for i, test := range tests {
+		s := &rulesServer{rules: existing}
+		ts := httptest.NewServer(s)
+		r, err := firebase.NewDatabaseRef(firebase.URL(ts.URL + "/"))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		os.Setenv("VISUAL", test.editor)
+		os.Remove(filepath.Join(dir, "rules.json-old"))
+
+		// answers
+		in, err := ioutil.TempFile(dir, "stdin")
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		in.WriteString(test.input)
+		in.Seek(0, 0)
+		stdin, stderr := os.Stdin, os.Stderr
+		os.Stdin, os.Stderr = in, devnull
+		out, err := capture(t, func() error {
+			return edit(r)
+		})
+		os.Stdin, os.Stderr = stdin, stderr
+		in.Close()
+		ts.Close()
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)):
+			t.Errorf("test %d expected error %q, got: %v", i, test.err, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d expected output:\n%s\ngot:\n%s", i, test.out, out)
+		}
+		if s.rules != test.rules {
+			t.Errorf("test %d expected rules %s, got: %s", i, test.rules, s.rules)
+		}
+		if s.dryRun != test.dryRun {
+			t.Errorf("test %d expected %d dry runs, got: %d", i, test.dryRun, s.dryRun)
+		}
+
+		// existing rules are saved before writing
+		buf, err := ioutil.ReadFile(filepath.Join(dir, "rules.json-old"))
+		switch {
+		case test.rules != existing && string(buf) != existing:
+			t.Errorf("test %d expected saved rules %s, got: %s, %v", i, existing, buf, err)
+		case test.rules == existing && !os.IsNotExist(err):
+			t.Errorf("test %d expected no saved rules, got: %v", i, err)
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/knq/firebase"
+	"github.com/knq/firebase/internal/clierr"
+	"github.com/knq/firebase/internal/version"
+	"github.com/knq/firebase/rules"
 )
 
 var (
@@ -15,26 +21,35 @@ var (
 	flagNoSave      = flag.Bool("nosave", false, "don't save existing rules before writing new rules")
 	flagClearRules  = flag.Bool("clear", false, "clear rules")
 	flagClearValue  = flag.String("val", "false", "clear rule value")
+	flagDiff        = flag.Bool("diff", false, "print the differences between the existing rules and the rules file, without writing")
+	flagDryRun      = flag.Bool("dry-run", false, "validate the rules with the server, without writing")
+	flagEdit        = flag.Bool("edit", false, "edit the existing rules with $EDITOR, writing them after confirmation")
 )
 
 func main() {
-	var err error
-
 	flag.Parse()
+	version.Check()
+
+	if err := run(); err != nil {
+		clierr.Exit(err)
+	}
+}
+
+// run runs the rules tool.
+func run() error {
+	var err error
 
 	// check credentials
 	if *flagCredentials == "" {
-		fmt.Fprintf(os.Stderr, "error: invalid credentials file\n")
-		os.Exit(1)
+		return clierr.Usage("invalid credentials file")
 	}
 
 	// load rules
 	buf := []byte(fmt.Sprintf(emptyRules, *flagClearValue, *flagClearValue))
-	if !*flagClearRules {
+	if !*flagClearRules && !*flagEdit {
 		buf, err = ioutil.ReadFile(*flagRulesFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 	}
 
@@ -43,31 +58,142 @@ func main() {
 		firebase.GoogleServiceAccountCredentialsFile(*flagCredentials),
 	)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return clierr.Auth(err)
+	}
+
+	// edit
+	if *flagEdit {
+		return edit(ref)
+	}
+
+	// diff
+	if *flagDiff {
+		existing, err := ref.GetRulesJSON()
+		if err != nil {
+			return err
+		}
+		if err = diff(existing, buf); err != nil {
+			return err
+		}
+	}
+
+	// validate
+	if *flagDryRun {
+		if err = ref.SetRulesJSON(buf, firebase.DryRun); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, "rules are valid")
+	}
+	if *flagDiff || *flagDryRun {
+		return nil
 	}
 
 	// save existing rules
 	if !*flagNoSave {
 		existing, err := ref.GetRulesJSON()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 
 		err = ioutil.WriteFile(*flagRulesFile+"-old", existing, 0644)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 	}
 
 	// set rules
-	err = ref.SetRulesJSON(buf)
+	return ref.SetRulesJSON(buf)
+}
+
+// diff prints the structural differences between the existing and new
+// rules.
+func diff(existing, buf []byte) error {
+	a, err := canonical(existing)
+	if err != nil {
+		return fmt.Errorf("existing rules: %w", err)
+	}
+	b, err := canonical(buf)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *flagRulesFile, err)
+	}
+
+	changes := make(map[string][2]interface{})
+	diffValues("", a, b, changes)
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stdout, "no changes")
+		return nil
+	}
+
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		c := changes[path]
+		if c[0] != nil {
+			fmt.Fprintf(os.Stdout, "- %s: %s\n", path, encode(c[0]))
+		}
+		if c[1] != nil {
+			fmt.Fprintf(os.Stdout, "+ %s: %s\n", path, encode(c[1]))
+		}
+	}
+
+	return nil
+}
+
+// canonical parses the rules, returning them as a generic JSON value with
+// normalized expressions and indexes.
+func canonical(buf []byte) (interface{}, error) {
+	n, err := rules.Parse(buf)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return nil, err
+	}
+	if buf, err = n.MarshalJSON(); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err = json.Unmarshal(buf, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diffValues adds the differences between a and b at path to changes, where
+// a nil value indicates the path was added or removed.
+func diffValues(path string, a, b interface{}, changes map[string][2]interface{}) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		for k, v := range am {
+			diffValues(path+"/"+k, v, bm[k], changes)
+		}
+		for k, v := range bm {
+			if _, ok := am[k]; !ok {
+				diffValues(path+"/"+k, nil, v, changes)
+			}
+		}
+		return
+	}
+
+	if encode(a) != encode(b) {
+		if path == "" {
+			path = "/"
+		}
+		changes[path] = [2]interface{}{a, b}
+	}
+}
+
+// encode json encodes v.
+func encode(v interface{}) string {
+	if v == nil {
+		return ""
 	}
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(sb.String(), "\n")
 }
 
 // emptyRules are the empty rule set for firebase (allow/disallow reads/writes).
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase/internal/clierr"
+)
+
+// setFlags sets the command line flags, returning a func that restores their
+// defaults.
+func setFlags(t *testing.T, args ...string) func() {
+	for i := 0; i < len(args); i += 2 {
+		if err := flag.Set(args[i], args[i+1]); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return func() {
+		for i := 0; i < len(args); i += 2 {
+			f := flag.Lookup(args[i])
+			f.Value.Set(f.DefValue)
+		}
+	}
+}
+
+// capture calls f, returning what it wrote to stdout.
+func capture(t *testing.T, f func() error) (string, error) {
+	out, err := ioutil.TempFile("", "firebase-rules")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.Remove(out.Name())
+
+	stdout := os.Stdout
+	os.Stdout = out
+	err = f()
+	os.Stdout = stdout
+	out.Close()
+
+	buf, rerr := ioutil.ReadFile(out.Name())
+	if rerr != nil {
+		t.Fatalf("expected no error, got: %v", rerr)
+	}
+	return string(buf), err
+}
+
+func TestFlags(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"diff", "true"},
+		{"dry-run", "true"},
+	}
+	for i, test := range tests {
+		reset := setFlags(t, test...)
+		err := run()
+		reset()
+		if e, ok := err.(*clierr.Error); !ok || e.Code != clierr.ExitUsage {
+			t.Errorf("test %d expected usage error, got: %v", i, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	existing := `{
+  // comment
+  "rules": {
+    ".read": "auth != null",
+    "users": {
+      "$uid": {
+        ".write": "auth.uid == $uid",
+        ".indexOn": ["b", "a"]
+      }
+    },
+    "old": {".read": true}
+  }
+}`
+	tests := []struct {
+		buf string
+		exp string
+	}{
+		{existing, "no changes\n"},
+		{
+			`{"rules":{".read":"auth != null","users":{"$uid":{".write":"auth.uid == $uid",".indexOn":["a","b"]}},"old":{".read":true}}}`,
+			"no changes\n",
+		},
+		{
+			`{"rules":{".read":"auth != null","users":{"$uid":{".write":"auth.uid === $uid",".indexOn":["a","b"]}},"new":{".read":"true"}}}`,
+			`+ /new: {".read":true}` + "\n" +
+				`- /old: {".read":true}` + "\n" +
+				`- /users/$uid/.write: "auth.uid == $uid"` + "\n" +
+				`+ /users/$uid/.write: "auth.uid === $uid"` + "\n",
+		},
+	}
+	for i, test := range tests {
+		s, err := capture(t, func() error {
+			return diff([]byte(existing), []byte(test.buf))
+		})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s != test.exp {
+			t.Errorf("test %d expected:\n%s\ngot:\n%s", i, test.exp, s)
+		}
+	}
+
+	// invalid rules
+	if _, err := capture(t, func() error {
+		return diff([]byte(existing), []byte(`{"rules":`))
+	}); err == nil || !strings.HasPrefix(err.Error(), "rules.json: ") {
+		t.Errorf("expected rules file error, got: %v", err)
+	}
+	if _, err := capture(t, func() error {
+		return diff([]byte(`[]`), []byte(existing))
+	}); err == nil || !strings.HasPrefix(err.Error(), "existing rules: ") {
+		t.Errorf("expected existing rules error, got: %v", err)
+	}
+}
@@ -0,0 +1,376 @@
+package firebase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValueCodec is a codec for values stored at designated database paths (see
+// PathCodec), such as for compressing large values.
+//
+// Encode is passed the JSON encoded value to be written, and returns the JSON
+// encoded value to store. Decode is passed the stored JSON encoded value, and
+// returns the original JSON encoded value. Decode should pass through values
+// that were not encoded by the codec, allowing codecs to be added to paths
+// with existing data.
+type ValueCodec interface {
+	Encode(buf []byte) ([]byte, error)
+	Decode(buf []byte) ([]byte, error)
+}
+
+// PathValueCodec is a ValueCodec that is passed the absolute database path of
+// the values it encodes and decodes (ie, "/users/alice/email"), such as for
+// binding encrypted values to their location.
+type PathValueCodec interface {
+	ValueCodec
+	EncodePath(path string, buf []byte) ([]byte, error)
+	DecodePath(path string, buf []byte) ([]byte, error)
+}
+
+// pathCodec is a value codec for a database path pattern.
+type pathCodec struct {
+	pattern []string
+	codec   ValueCodec
+}
+
+// PathCodec is an option that applies the value codec to values stored at the
+// database path (and its children), transparently encoding the values before
+// they are written with Set, Push, and Update, and decoding the values after
+// they are read with Get.
+//
+// The path is an absolute database path, where path components starting with
+// $ are wildcards (ie, "/documents/$id/body"). Values are encoded as a whole,
+// and as such, values beneath the path cannot be individually written or
+// read, and cannot be queried or watched.
+//
+// Values retrieved with GetRaw, GetStream, GetOrdered, or via Watch and
+// Listen are not decoded.
+//
+// As the path of a value must be known to encode it, Push generates the
+// pushed key client side (see GeneratePushID) for refs with path or field
+// codecs.
+func PathCodec(path string, codec ValueCodec) Option {
+	return func(r *DatabaseRef) error {
+		if codec == nil {
+			return errors.New("path codec cannot be nil")
+		}
+		r.codecs = append(r.codecs[:len(r.codecs):len(r.codecs)], pathCodec{
+			pattern: splitPath(path),
+			codec:   codec,
+		})
+		return nil
+	}
+}
+
+// matchPattern determines if the path components match the pattern
+// components, where pattern components starting with $ match any path
+// component.
+func matchPattern(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, p := range pattern {
+		if !strings.HasPrefix(p, "$") && p != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeValues encodes the JSON encoded body of a write operation of v using
+// the ref's path codecs and field codec.
+func (r *DatabaseRef) encodeValues(op OpType, v interface{}, buf []byte) ([]byte, error) {
+	if !r.hasCodecs() {
+		return buf, nil
+	}
+
+	tree, err := decodeJSON(buf)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	base := splitPath(r.URL().Path)
+	switch op {
+	case OpTypeUpdate:
+		// each key of an update is a relative path
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			return buf, nil
+		}
+		vals := reflect.Indirect(reflect.ValueOf(v))
+		for k, x := range m {
+			path := append(base[:len(base):len(base)], splitPath(k)...)
+			var typ reflect.Type
+			if vals.Kind() == reflect.Map && vals.Type().Key().Kind() == reflect.String {
+				if val := vals.MapIndex(reflect.ValueOf(k).Convert(vals.Type().Key())); val.IsValid() {
+					typ = val.Type()
+					if val.Kind() == reflect.Interface && !val.IsNil() {
+						typ = val.Elem().Type()
+					}
+				}
+			}
+			if m[k], err = transformValue(r.codecsFor(path, typ), path, x, false); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		// the pushed key is not known, and as such, only matches wildcards
+		path := base
+		if op == OpTypePush {
+			path = append(base[:len(base):len(base)], "")
+		}
+		var typ reflect.Type
+		if v != nil {
+			typ = reflect.TypeOf(v)
+		}
+		tree, err = transformValue(r.codecsFor(path, typ), path, tree, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(tree)
+}
+
+// decodeValues decodes the JSON encoded body of a read operation into d
+// using the ref's path codecs and field codec.
+func (r *DatabaseRef) decodeValues(d interface{}, buf []byte) ([]byte, error) {
+	if !r.hasCodecs() {
+		return buf, nil
+	}
+
+	tree, err := decodeJSON(buf)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	path := splitPath(r.URL().Path)
+	tree, err = transformValue(r.codecsFor(path, reflect.TypeOf(d)), path, tree, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(tree)
+}
+
+// hasCodecs determines if the ref has any path or field codecs.
+func (r *DatabaseRef) hasCodecs() bool {
+	return len(r.codecs) != 0 || len(r.fieldCodecs) != 0
+}
+
+// setFieldCodec sets the field codec for struct fields tagged with the
+// firebase struct tag option.
+func (r *DatabaseRef) setFieldCodec(option string, codec ValueCodec) {
+	// copy, as the field codecs are shared with cloned refs
+	m := make(map[string]ValueCodec, len(r.fieldCodecs)+1)
+	for k, v := range r.fieldCodecs {
+		m[k] = v
+	}
+	m[option] = codec
+	r.fieldCodecs = m
+}
+
+// codecsFor returns the ref's path codecs, and the field codecs for the
+// tagged fields of typ (when written to or read from path).
+func (r *DatabaseRef) codecsFor(path []string, typ reflect.Type) []pathCodec {
+	if len(r.fieldCodecs) == 0 || typ == nil {
+		return r.codecs
+	}
+
+	codecs := r.codecs[:len(r.codecs):len(r.codecs)]
+	for option, codec := range r.fieldCodecs {
+		for _, rel := range taggedFields(typ, option, nil, make(map[reflect.Type]bool)) {
+			codecs = append(codecs, pathCodec{
+				pattern: append(path[:len(path):len(path)], rel...),
+				codec:   codec,
+			})
+		}
+	}
+	return codecs
+}
+
+// transformValue encodes (or decodes) the value at the path components, and
+// all values beneath it, matching the codecs.
+func transformValue(codecs []pathCodec, path []string, v interface{}, decode bool) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var descend bool
+	for _, c := range codecs {
+		switch {
+		case len(c.pattern) < len(path) && matchPattern(c.pattern, path[:len(c.pattern)]):
+			return nil, &Error{
+				Err: fmt.Sprintf("cannot access /%s beneath encoded path /%s", strings.Join(path, "/"), strings.Join(c.pattern, "/")),
+			}
+
+		case len(c.pattern) == len(path) && matchPattern(c.pattern, path):
+			buf, err := json.Marshal(v)
+			if err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not marshal json: %v", err),
+				}
+			}
+			f, verb := c.codec.Encode, "encode"
+			if decode {
+				f, verb = c.codec.Decode, "decode"
+			}
+			if pc, ok := c.codec.(PathValueCodec); ok {
+				p := "/" + strings.Join(path, "/")
+				f = func(buf []byte) ([]byte, error) {
+					if decode {
+						return pc.DecodePath(p, buf)
+					}
+					return pc.EncodePath(p, buf)
+				}
+			}
+			if buf, err = f(buf); err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not %s /%s: %v", verb, strings.Join(path, "/"), err),
+				}
+			}
+			return decodeJSON(buf)
+
+		case len(c.pattern) > len(path) && matchPattern(c.pattern[:len(path)], path):
+			descend = true
+		}
+	}
+	if !descend {
+		return v, nil
+	}
+
+	var err error
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, y := range x {
+			if x[k], err = transformValue(codecs, append(path[:len(path):len(path)], k), y, decode); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, y := range x {
+			if x[i], err = transformValue(codecs, append(path[:len(path):len(path)], strconv.Itoa(i)), y, decode); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// taggedFields returns the relative path patterns of the struct fields of
+// typ tagged with the firebase struct tag option (ie, `firebase:",encrypt"`),
+// where map keys and slice indexes are wildcards.
+func taggedFields(typ reflect.Type, option string, prefix []string, seen map[reflect.Type]bool) [][]string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	var paths [][]string
+	switch typ.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return taggedFields(typ.Elem(), option, append(prefix[:len(prefix):len(prefix)], "$"), seen)
+
+	case reflect.Struct:
+		if seen[typ] {
+			return nil
+		}
+		seen[typ] = true
+		defer delete(seen, typ)
+
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+
+			// determine stored name
+			name, opts := parseFieldTag(f)
+			if name == "" {
+				continue
+			}
+
+			// tagged field
+			if sliceContains(opts, option) {
+				paths = append(paths, append(prefix[:len(prefix):len(prefix)], name))
+				continue
+			}
+
+			// embedded struct fields are promoted
+			if f.Anonymous && f.Tag.Get("json") == "" {
+				paths = append(paths, taggedFields(f.Type, option, prefix, seen)...)
+				continue
+			}
+			paths = append(paths, taggedFields(f.Type, option, append(prefix[:len(prefix):len(prefix)], name), seen)...)
+		}
+	}
+
+	return paths
+}
+
+// gzipCodecPrefix is the prefix of values encoded by GzipCodec.
+const gzipCodecPrefix = "gzip:"
+
+// GzipCodec is a value codec that compresses values using gzip, storing them
+// as base64 encoded strings.
+type GzipCodec struct {
+	// Level is the gzip compression level. If 0, then gzip.DefaultCompression
+	// is used.
+	Level int
+}
+
+// Encode satisfies the ValueCodec interface.
+func (c GzipCodec) Encode(buf []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(buf); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(gzipCodecPrefix + base64.StdEncoding.EncodeToString(b.Bytes()))
+}
+
+// Decode satisfies the ValueCodec interface.
+func (c GzipCodec) Decode(buf []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil || !strings.HasPrefix(s, gzipCodecPrefix) {
+		// not encoded by the codec
+		return buf, nil
+	}
+
+	z, err := base64.StdEncoding.DecodeString(s[len(gzipCodecPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	rdr, err := gzip.NewReader(bytes.NewReader(z))
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+
+	return ioutil.ReadAll(rdr)
+}
@@ -0,0 +1,65 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPathCodec(t *testing.T) {
+	var stored []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write(stored)
+			return
+		}
+		stored, _ = ioutil.ReadAll(req.Body)
+		w.Write(stored)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), PathCodec("/docs/$id/body", GzipCodec{}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	body := strings.Repeat("lorem ipsum ", 100)
+	err = r.Ref("/docs").Set(map[string]interface{}{
+		"a": map[string]interface{}{"title": "A", "body": body},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// check stored value is encoded
+	var raw map[string]map[string]string
+	if err = json.Unmarshal(stored, &raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := raw["a"]["body"]; !strings.HasPrefix(s, gzipCodecPrefix) || len(s) >= len(body) {
+		t.Errorf("expected compressed body, got: %q", s)
+	}
+	if s := raw["a"]["title"]; s != "A" {
+		t.Errorf("expected title A, got: %q", s)
+	}
+
+	// check decoded
+	var v map[string]struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if err = r.Ref("/docs").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v["a"].Title != "A" || v["a"].Body != body {
+		t.Errorf("expected decoded value, got: %v", v)
+	}
+
+	// cannot write beneath encoded path
+	if err = r.Ref("/docs/a/body/x").Set(1); err == nil {
+		t.Errorf("expected error")
+	}
+}
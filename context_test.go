@@ -0,0 +1,87 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContext(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []string
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+		select {
+		case <-req.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var d interface{}
+	tests := []func(context.Context) error{
+		func(ctxt context.Context) error { return r.GetContext(ctxt, &d) },
+		func(ctxt context.Context) error {
+			_, err := r.GetRawContext(ctxt)
+			return err
+		},
+		func(ctxt context.Context) error { return r.SetContext(ctxt, "v") },
+		func(ctxt context.Context) error {
+			_, err := r.PushContext(ctxt, "v")
+			return err
+		},
+		func(ctxt context.Context) error { return r.UpdateContext(ctxt, map[string]interface{}{"a": 1}) },
+		func(ctxt context.Context) error { return r.RemoveContext(ctxt) },
+		func(ctxt context.Context) error { return r.SetRulesJSONContext(ctxt, []byte(`{"rules":{}}`)) },
+		func(ctxt context.Context) error {
+			_, err := r.GetRulesJSONContext(ctxt)
+			return err
+		},
+	}
+
+	// canceled before the request is made
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, test := range tests {
+		if err := test(ctxt); err == nil || !strings.Contains(err.Error(), "context canceled") {
+			t.Errorf("test %d expected context canceled error, got: %v", i, err)
+		}
+	}
+	mu.Lock()
+	if len(reqs) != 0 {
+		t.Errorf("expected no requests, got: %v", reqs)
+	}
+	mu.Unlock()
+
+	// deadline exceeded while waiting on the server
+	for i, test := range tests {
+		ctxt, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := test(ctxt)
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+			t.Errorf("test %d expected context deadline exceeded error, got: %v", i, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("test %d expected request to be canceled, took: %v", i, d)
+		}
+	}
+	mu.Lock()
+	if len(reqs) != len(tests) {
+		t.Errorf("expected %d requests, got: %v", len(tests), reqs)
+	}
+	mu.Unlock()
+}
@@ -8,12 +8,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -45,84 +49,189 @@ const (
 // Do executes an HTTP operation on Firebase database ref r passing the
 // supplied value v as JSON marshaled data and decoding the response to d.
 func Do(op OpType, r *DatabaseRef, v, d interface{}, opts ...QueryOption) error {
-	var err error
-
-	// encode v
-	var body io.Reader
-	switch x := v.(type) {
-	case io.Reader:
-		body = x
+	return DoContext(op, r, context.Background(), v, d, opts...)
+}
 
-	case []byte:
-		body = bytes.NewReader(x)
-
-	default:
-		if v != nil {
-			buf, err := json.Marshal(v)
-			if err != nil {
-				return &Error{
-					Err: fmt.Sprintf("could not marshal json: %v", err),
-				}
-			}
-			body = bytes.NewReader(buf)
-		}
+// DoContext executes an HTTP operation on Firebase database ref r passing the
+// supplied value v as JSON marshaled data and decoding the response to d. The
+// operation is canceled when the passed context is done.
+func DoContext(op OpType, r *DatabaseRef, ctxt context.Context, v, d interface{}, opts ...QueryOption) error {
+	if v == Delete {
+		return errDeleteValue
 	}
 
-	// create client and request
-	client, req, err := r.clientAndRequest(string(op), body, opts...)
+	// encode v
+	body, err := r.encodeBody(op, v)
 	if err != nil {
 		return err
 	}
 
 	// execute
-	res, err := client.Do(req)
+	res, err := r.do(ctxt, string(op), body, nil, opts...)
 	if err != nil {
-		return &Error{
-			Err: fmt.Sprintf("could not execute request: %v", err),
-		}
+		return err
 	}
 	defer res.Body.Close()
 
-	// check for server error
-	err = checkServerError(res)
+	// decode body to d (a silent response has no body)
+	if d != nil && res.StatusCode != http.StatusNoContent {
+		return r.decodeBody(op, res.Body, d)
+	}
+
+	return nil
+}
+
+// encodeBody encodes v as the request body for the operation, using the
+// ref's JSON codec and applying the ref's path and field codecs. Readers are
+// passed through unchanged.
+func (r *DatabaseRef) encodeBody(op OpType, v interface{}) (io.Reader, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+
+	case io.Reader:
+		return x, nil
+
+	case []byte:
+		buf, err := r.encodeValues(op, nil, x)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf), nil
+	}
+
+	buf, err := r.marshal(v)
 	if err != nil {
-		return err
+		return nil, &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	if buf, err = r.encodeValues(op, v, buf); err != nil {
+		return nil, err
 	}
+	return bytes.NewReader(buf), nil
+}
 
-	// decode body to d
-	if d != nil {
-		dec := json.NewDecoder(res.Body)
-		dec.UseNumber()
-		err = dec.Decode(d)
+// decodeBody decodes the response body of the operation into d, applying the
+// ref's path and field codecs to read operations, and using the ref's JSON
+// codec.
+func (r *DatabaseRef) decodeBody(op OpType, rdr io.Reader, d interface{}) error {
+	if r.hasCodecs() && op == OpTypeGet {
+		buf, err := ioutil.ReadAll(rdr)
+		if err == nil {
+			buf, err = r.decodeValues(d, buf)
+		}
 		if err != nil {
+			return err
+		}
+		rdr = bytes.NewReader(buf)
+	}
+
+	if tagged := hasFirebaseTags(reflect.TypeOf(d), false); tagged || r.jsonUnmarshal != nil {
+		buf, err := ioutil.ReadAll(rdr)
+		if err == ErrResponseTooLarge {
+			return err
+		}
+		if err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not read response: %v", err),
+			}
+		}
+		if tagged {
+			return Unmarshal(buf, d)
+		}
+		if err = r.jsonUnmarshal(buf, d); err != nil {
 			return &Error{
 				Err: fmt.Sprintf("could not unmarshal json: %v", err),
 			}
 		}
+		return nil
+	}
+
+	dec := json.NewDecoder(rdr)
+	dec.UseNumber()
+	err := dec.Decode(d)
+	if err == ErrResponseTooLarge {
+		return err
+	}
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
 	}
 
 	return nil
 }
 
+// GetRaw retrieves the values stored at Firebase database ref r, returning the
+// unprocessed response body. The caller is responsible for closing the
+// returned io.ReadCloser.
+//
+// GetRaw is useful when combined with the Download query option, when
+// streaming large values directly to disk, or when the response should not be
+// decoded.
+func GetRaw(r *DatabaseRef, opts ...QueryOption) (io.ReadCloser, error) {
+	return GetRawContext(r, context.Background(), opts...)
+}
+
+// GetRawContext retrieves the values stored at Firebase database ref r,
+// returning the unprocessed response body. The caller is responsible for
+// closing the returned io.ReadCloser.
+//
+// Reading the returned body will fail once the passed context is done.
+func GetRawContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (io.ReadCloser, error) {
+	res, err := r.do(ctxt, string(OpTypeGet), nil, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
 // Get retrieves the values stored at Firebase database ref r and decodes them
 // into d.
 func Get(r *DatabaseRef, d interface{}, opts ...QueryOption) error {
-	return Do(OpTypeGet, r, nil, d, opts...)
+	return GetContext(r, context.Background(), d, opts...)
+}
+
+// GetContext retrieves the values stored at Firebase database ref r and
+// decodes them into d, canceling the operation when the passed context is
+// done.
+func GetContext(r *DatabaseRef, ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return DoContext(OpTypeGet, r, ctxt, nil, d, opts...)
 }
 
 // Set stores values v at Firebase database ref r.
 func Set(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
-	return Do(OpTypeSet, r, v, nil, opts...)
+	return SetContext(r, context.Background(), v, opts...)
+}
+
+// SetContext stores values v at Firebase database ref r, canceling the
+// operation when the passed context is done.
+func SetContext(r *DatabaseRef, ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return DoContext(OpTypeSet, r, ctxt, v, nil, opts...)
 }
 
 // Push pushes values v to Firebase database ref r, returning the name (ID) of
 // the pushed node.
 func Push(r *DatabaseRef, v interface{}, opts ...QueryOption) (string, error) {
+	return PushContext(r, context.Background(), v, opts...)
+}
+
+// PushContext pushes values v to Firebase database ref r, returning the name
+// (ID) of the pushed node, canceling the operation when the passed context is
+// done.
+func PushContext(r *DatabaseRef, ctxt context.Context, v interface{}, opts ...QueryOption) (string, error) {
+	if r.hasCodecs() {
+		// the value's path must be known to encode it (see PathCodec)
+		id := GeneratePushID()
+		return id, SetContext(r.Ref(id), ctxt, v, opts...)
+	}
+
 	var res struct {
 		Name string `json:"name"`
 	}
 
-	err := Do(OpTypePush, r, v, &res, opts...)
+	err := DoContext(OpTypePush, r, ctxt, v, &res, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -130,24 +239,103 @@ func Push(r *DatabaseRef, v interface{}, opts ...QueryOption) (string, error) {
 	return res.Name, nil
 }
 
+// PushIdempotent pushes values v to Firebase database ref r using the Push ID
+// derived from the idempotency key and creation time t (see
+// IdempotentPushID), returning the name (ID) of the pushed node.
+//
+// Unlike Push, retrying PushIdempotent with the same key and time (ie, after
+// a network timeout) writes the same child, and will not create a duplicate.
+func PushIdempotent(r *DatabaseRef, key string, t time.Time, v interface{}, opts ...QueryOption) (string, error) {
+	return PushIdempotentContext(r, context.Background(), key, t, v, opts...)
+}
+
+// PushIdempotentContext pushes values v to Firebase database ref r using the
+// Push ID derived from the idempotency key and creation time t, returning the
+// name (ID) of the pushed node. See PushIdempotent.
+func PushIdempotentContext(r *DatabaseRef, ctxt context.Context, key string, t time.Time, v interface{}, opts ...QueryOption) (string, error) {
+	if key == "" {
+		return "", &Error{
+			Err: "idempotency key cannot be empty",
+		}
+	}
+
+	id := IdempotentPushID(key, t)
+	err := r.Ref(id).SetContext(ctxt, v, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// PushAndGet pushes values v to Firebase database ref r, and reads back the
+// created node into d, returning the name (ID) of the pushed node. The value
+// read back includes the resolved server values (ie, ServerTimestamp).
+//
+// Unlike Push, the Push ID is generated client side and the node is written
+// with Set, such that both steps are retried per the ref's RetryPolicy
+// without creating duplicates. The query options are passed to both steps.
+func PushAndGet(r *DatabaseRef, v, d interface{}, opts ...QueryOption) (string, error) {
+	return PushAndGetContext(r, context.Background(), v, d, opts...)
+}
+
+// PushAndGetContext pushes values v to Firebase database ref r, and reads
+// back the created node into d, returning the name (ID) of the pushed node.
+// See PushAndGet.
+func PushAndGetContext(r *DatabaseRef, ctxt context.Context, v, d interface{}, opts ...QueryOption) (string, error) {
+	id := GeneratePushID()
+	c := r.Ref(id)
+	if err := c.SetContext(ctxt, v, opts...); err != nil {
+		return "", err
+	}
+	if err := c.GetContext(ctxt, d, opts...); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
 // Update updates the values stored at Firebase database ref r to v.
 func Update(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
-	return Do(OpTypeUpdate, r, v, nil, opts...)
+	return UpdateContext(r, context.Background(), v, opts...)
+}
+
+// UpdateContext updates the values stored at Firebase database ref r to v,
+// canceling the operation when the passed context is done.
+func UpdateContext(r *DatabaseRef, ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return DoContext(OpTypeUpdate, r, ctxt, v, nil, opts...)
 }
 
 // Remove removes the values stored at Firebase database ref r.
 func Remove(r *DatabaseRef, opts ...QueryOption) error {
-	return Do(OpTypeRemove, r, nil, nil, opts...)
+	return RemoveContext(r, context.Background(), opts...)
+}
+
+// RemoveContext removes the values stored at Firebase database ref r,
+// canceling the operation when the passed context is done.
+func RemoveContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) error {
+	return DoContext(OpTypeRemove, r, ctxt, nil, nil, opts...)
 }
 
 // SetRules sets the security rules for Firebase database ref r.
-func SetRules(r *DatabaseRef, v interface{}) error {
-	return Do(OpTypeSet, r.Ref("/.settings/rules"), v, nil)
+func SetRules(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
+	return SetRulesContext(r, context.Background(), v, opts...)
+}
+
+// SetRulesContext sets the security rules for Firebase database ref r,
+// canceling the operation when the passed context is done.
+func SetRulesContext(r *DatabaseRef, ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return DoContext(OpTypeSet, r.Ref("/.settings/rules"), ctxt, v, nil, opts...)
 }
 
 // SetRulesJSON sets the JSON-encoded security rules for Firebase database ref
 // r.
-func SetRulesJSON(r *DatabaseRef, buf []byte) error {
+func SetRulesJSON(r *DatabaseRef, buf []byte, opts ...QueryOption) error {
+	return SetRulesJSONContext(r, context.Background(), buf, opts...)
+}
+
+// SetRulesJSONContext sets the JSON-encoded security rules for Firebase
+// database ref r, canceling the operation when the passed context is done.
+func SetRulesJSONContext(r *DatabaseRef, ctxt context.Context, buf []byte, opts ...QueryOption) error {
 	var err error
 	var v interface{}
 
@@ -173,13 +361,19 @@ func SetRulesJSON(r *DatabaseRef, buf []byte) error {
 		}
 	}
 
-	return Do(OpTypeSet, r.Ref("/.settings/rules"), rules.Bytes(), nil)
+	return DoContext(OpTypeSet, r.Ref("/.settings/rules"), ctxt, rules.Bytes(), nil, opts...)
 }
 
 // GetRulesJSON retrieves the security rules for Firebase database ref r.
 func GetRulesJSON(r *DatabaseRef) ([]byte, error) {
+	return GetRulesJSONContext(r, context.Background())
+}
+
+// GetRulesJSONContext retrieves the security rules for Firebase database ref
+// r, canceling the operation when the passed context is done.
+func GetRulesJSONContext(r *DatabaseRef, ctxt context.Context) ([]byte, error) {
 	var d json.RawMessage
-	err := Do(OpTypeGet, r.Ref("/.settings/rules"), nil, &d)
+	err := DoContext(OpTypeGet, r.Ref("/.settings/rules"), ctxt, nil, &d)
 	if err != nil {
 		return nil, err
 	}
@@ -193,12 +387,57 @@ type DatabaseRef struct {
 	url       *url.URL
 	transport http.RoundTripper
 
+	// wrappers wrap transport (in the order the options were applied) when
+	// the client is built.
+	wrappers []func(http.RoundTripper) http.RoundTripper
+
 	// source is the oauth2 token source.
 	source oauth2.TokenSource
 
+	// client is the http.Client built from transport and source, shared by
+	// all refs derived from the ref, and reset when either is changed.
+	client *http.Client
+
 	queryOpts []QueryOption
 
-	watchBufLen int
+	watchBufLen      int
+	watchDiffs       bool
+	watchChangesOnly bool
+	listenHeartbeats bool
+	watchOverflow    OverflowPolicy
+	watchStale       time.Duration
+
+	watchCallbacks []WatchCallbacks
+
+	// watchPoll is the polling fallback for Watch, shared by all refs
+	// derived from the ref.
+	watchPoll *watchPoller
+
+	transactionRetries int
+
+	recorder *AccessRecorder
+
+	observers []Observer
+
+	retryPolicy *RetryPolicy
+
+	limiter *rate.Limiter
+
+	maxResponseBytes int64
+
+	codecs      []pathCodec
+	fieldCodecs map[string]ValueCodec
+
+	jsonMarshal   func(interface{}) ([]byte, error)
+	jsonUnmarshal func([]byte, interface{}) error
+
+	timeout   time.Duration
+	readOpts  *CallOptions
+	writeOpts *CallOptions
+
+	// life is the lifecycle shared by all refs derived from the same
+	// NewDatabaseRef call.
+	life *lifecycle
 }
 
 // NewDatabaseRef creates a new Firebase base database ref using the supplied
@@ -208,7 +447,9 @@ func NewDatabaseRef(opts ...Option) (*DatabaseRef, error) {
 
 	// create client
 	r := &DatabaseRef{
-		watchBufLen: DefaultWatchBuffer,
+		watchBufLen:        DefaultWatchBuffer,
+		transactionRetries: DefaultTransactionRetries,
+		life:               newLifecycle(),
 	}
 
 	// apply opts
@@ -224,15 +465,37 @@ func NewDatabaseRef(opts ...Option) (*DatabaseRef, error) {
 		return nil, errors.New("no firebase url specified")
 	}
 
+	// create the client shared by all child refs
+	r.client = r.newHTTPClient()
+
 	return r, nil
 }
 
-// httpClient returns a http.Client suitable for use with Firebase.
+// httpClient returns a http.Client suitable for use with Firebase, creating
+// it on first use.
 func (r *DatabaseRef) httpClient() (*http.Client, error) {
 	r.rw.RLock()
-	defer r.rw.RUnlock()
+	client := r.client
+	r.rw.RUnlock()
+	if client != nil {
+		return client, nil
+	}
 
+	r.rw.Lock()
+	defer r.rw.Unlock()
+	if r.client == nil {
+		r.client = r.newHTTPClient()
+	}
+	return r.client, nil
+}
+
+// newHTTPClient creates a http.Client from the ref's transport, transport
+// wrappers, and token source.
+func (r *DatabaseRef) newHTTPClient() *http.Client {
 	transport := r.transport
+	for _, f := range r.wrappers {
+		transport = f(transport)
+	}
 
 	// set oauth2 transport
 	if r.source != nil {
@@ -244,7 +507,7 @@ func (r *DatabaseRef) httpClient() (*http.Client, error) {
 
 	return &http.Client{
 		Transport: transport,
-	}, nil
+	}
 }
 
 // createRequest creates a http.Request for the Firebase database ref with
@@ -255,18 +518,14 @@ func (r *DatabaseRef) createRequest(method string, body io.Reader, opts ...Query
 	// build url
 	u := r.URL().String() + ".json"
 
-	if len(r.queryOpts) > 0 {
-		opts = append(r.queryOpts, opts...)
-	}
-
 	// build query params
-	if len(opts) > 0 {
-		v := make(url.Values)
-		for _, o := range opts {
-			err = o(v)
-			if err != nil {
-				return nil, err
-			}
+	if len(r.queryOpts) > 0 || len(opts) > 0 {
+		v, err := buildQuery(r.queryOpts, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err = validateQuery(v); err != nil {
+			return nil, err
 		}
 
 		if vstr := v.Encode(); vstr != "" {
@@ -279,6 +538,7 @@ func (r *DatabaseRef) createRequest(method string, body io.Reader, opts ...Query
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("User-Agent", UserAgent())
 
 	// substitute + on raw path
 	if strings.Contains(req.URL.Path, "+") {
@@ -312,6 +572,207 @@ func (r *DatabaseRef) clientAndRequest(method string, body io.Reader, opts ...Qu
 	return client, req, nil
 }
 
+// do creates and executes a http.Request for the Firebase database ref with
+// the context and additional request headers, returning the http.Response if
+// the server did not return an error.
+//
+// The request is shaped by the CallOptions in effect for the method, with
+// failed requests retried according to its RetryPolicy, if any. The size of
+// read responses is limited by the ref's MaxResponseBytes, if any.
+//
+// The caller is responsible for closing the returned response body.
+func (r *DatabaseRef) do(ctxt context.Context, method string, body io.Reader, header http.Header, opts ...QueryOption) (*http.Response, error) {
+	co := r.callOptions(ctxt, method, opts)
+
+	// apply timeout until the response body is closed
+	if co.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctxt, cancel = context.WithTimeout(ctxt, co.Timeout)
+		res, err := r.retry(ctxt, co, method, body, header, opts...)
+		if err == nil {
+			err = r.limitResponse(method, res)
+		}
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+		return res, nil
+	}
+
+	res, err := r.retry(ctxt, co, method, body, header, opts...)
+	if err == nil {
+		err = r.limitResponse(method, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// retry executes the request, retrying failed requests according to the call
+// options' RetryPolicy, if any.
+func (r *DatabaseRef) retry(ctxt context.Context, co CallOptions, method string, body io.Reader, header http.Header, opts ...QueryOption) (*http.Response, error) {
+	policy := co.Retry
+	if policy == nil || (!policy.retryMethod(method) && !co.Idempotent) {
+		return r.attempt(ctxt, 1, method, body, header, opts...)
+	}
+
+	// buffer body for retries
+	var buf []byte
+	if body != nil {
+		var err error
+		buf, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, &Error{
+				Err: fmt.Sprintf("could not read request body: %v", err),
+			}
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if buf != nil {
+			body = bytes.NewReader(buf)
+		}
+
+		res, err := r.attempt(ctxt, attempt, method, body, header, opts...)
+		if err == nil {
+			return res, nil
+		}
+
+		// check retryable
+		e, ok := err.(*Error)
+		if !ok || attempt >= policy.maxAttempts() || !policy.retryable(e) || ctxt.Err() != nil {
+			if ok && attempt > 1 {
+				return nil, e.withAttempts(attempt)
+			}
+			return nil, err
+		}
+
+		// wait
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-ctxt.Done():
+			return nil, e.withAttempts(attempt)
+		}
+	}
+}
+
+// attempt creates and executes a single http.Request (attempt n) for the
+// Firebase database ref, returning the http.Response if the server did not
+// return an error, and notifying the ref's observers.
+//
+// The caller is responsible for closing the returned response body.
+func (r *DatabaseRef) attempt(ctxt context.Context, n int, method string, body io.Reader, header http.Header, opts ...QueryOption) (*http.Response, error) {
+	if err := r.life.begin(ctxt); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := r.roundTrip(ctxt, method, body, header, opts...)
+
+	// capture response and check for server error
+	var resInfo *ResponseInfo
+	if err == nil {
+		resInfo = captureResponse(ctxt, res)
+		err = checkServerError(res)
+		if err != nil {
+			res.Body.Close()
+		}
+	}
+
+	// track in-flight request until the body is closed
+	if err == nil {
+		res.Body = &lifecycleBody{ReadCloser: res.Body, l: r.life}
+	} else {
+		r.life.end()
+	}
+
+	// notify observers
+	if len(r.observers) != 0 {
+		info := &OperationInfo{
+			Context:  ctxt,
+			Op:       OpType(method),
+			Path:     r.URL().Path,
+			Attempt:  n,
+			Start:    start,
+			Duration: time.Since(start),
+			Err:      err,
+			Response: resInfo,
+		}
+		if res != nil {
+			info.StatusCode = res.StatusCode
+		}
+		if q, qerr := buildQuery(r.queryOpts, opts); qerr == nil {
+			info.Auth = q.Get("auth_variable_override")
+		}
+		r.observe(info)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// roundTrip creates and executes a http.Request for the Firebase database ref
+// with the context and additional request headers, returning the
+// http.Response regardless of its status code.
+//
+// The caller is responsible for closing the returned response body.
+func (r *DatabaseRef) roundTrip(ctxt context.Context, method string, body io.Reader, header http.Header, opts ...QueryOption) (*http.Response, error) {
+	// create client and request
+	client, req, err := r.clientAndRequest(method, body, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for rate limiter
+	if err = r.wait(ctxt); err != nil {
+		return nil, err
+	}
+
+	// add headers
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	// count sent bytes
+	var sent *countingBody
+	if r.recorder != nil && req.Body != nil {
+		sent = &countingBody{ReadCloser: req.Body}
+		if r.recorder.logging() {
+			sent.capture = new(bytes.Buffer)
+		}
+		req.Body = sent
+	}
+
+	// execute
+	res, err := client.Do(req.WithContext(ctxt))
+	if err != nil {
+		return nil, &Error{
+			Err:     fmt.Sprintf("could not execute request: %v", err),
+			network: true,
+		}
+	}
+
+	// record access
+	if r.recorder != nil {
+		res.Body = &recordingBody{
+			ReadCloser: res.Body,
+			rec:        r.recorder,
+			op:         OpType(method),
+			path:       r.URL().Path,
+			auth:       req.URL.Query().Get("auth_variable_override"),
+			sent:       sent,
+		}
+	}
+
+	return res, nil
+}
+
 // Ref creates a new Firebase database child ref, locked to the specified path.
 //
 // NOTE: any Option passed returning an error will cause this func to panic.
@@ -322,30 +783,15 @@ func (r *DatabaseRef) clientAndRequest(method string, body io.Reader, opts ...Qu
 //     err := SomeOption(child)
 // 	   if err != nil { log.Fatal(err) }
 func (r *DatabaseRef) Ref(path string, opts ...Option) *DatabaseRef {
-	r.rw.RLock()
-	defer r.rw.RUnlock()
-
 	// create new path
-	curpath := r.url.Path
+	curpath := r.URL().Path
 	if !strings.HasSuffix(curpath, "/") {
 		curpath += "/"
 	}
 	path = strings.TrimPrefix(path, "/")
 
 	// create child ref
-	c := &DatabaseRef{
-		url: &url.URL{
-			Scheme: r.url.Scheme,
-			Opaque: r.url.Opaque,
-			User:   r.url.User,
-			Host:   r.url.Host,
-			Path:   curpath + path,
-		},
-		transport:   r.transport,
-		source:      r.source,
-		queryOpts:   r.queryOpts,
-		watchBufLen: r.watchBufLen,
-	}
+	c := r.clone(curpath + path)
 
 	// apply opts
 	for _, o := range opts {
@@ -359,6 +805,56 @@ func (r *DatabaseRef) Ref(path string, opts ...Option) *DatabaseRef {
 	return c
 }
 
+// clone creates a copy of the Firebase database ref, locked to the specified
+// absolute path.
+func (r *DatabaseRef) clone(path string) *DatabaseRef {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	return &DatabaseRef{
+		url: &url.URL{
+			Scheme: r.url.Scheme,
+			Opaque: r.url.Opaque,
+			User:   r.url.User,
+			Host:   r.url.Host,
+			Path:   path,
+		},
+		transport:        r.transport,
+		wrappers:         r.wrappers,
+		source:           r.source,
+		client:           r.client,
+		queryOpts:        r.queryOpts,
+		watchBufLen:      r.watchBufLen,
+		watchDiffs:       r.watchDiffs,
+		watchChangesOnly: r.watchChangesOnly,
+		listenHeartbeats: r.listenHeartbeats,
+		watchOverflow:    r.watchOverflow,
+		watchStale:       r.watchStale,
+		watchCallbacks:   r.watchCallbacks,
+		watchPoll:        r.watchPoll,
+		recorder:         r.recorder,
+		observers:        r.observers,
+		retryPolicy:      r.retryPolicy,
+		limiter:          r.limiter,
+		maxResponseBytes: r.maxResponseBytes,
+		codecs:           r.codecs,
+		fieldCodecs:      r.fieldCodecs,
+		jsonMarshal:      r.jsonMarshal,
+		jsonUnmarshal:    r.jsonUnmarshal,
+		timeout:          r.timeout,
+		readOpts:         r.readOpts,
+		writeOpts:        r.writeOpts,
+		life:             r.life,
+
+		transactionRetries: r.transactionRetries,
+	}
+}
+
+// root returns the root Firebase database ref for the ref.
+func (r *DatabaseRef) root() *DatabaseRef {
+	return r.clone("/")
+}
+
 // URL returns the URL for the Firebase database ref.
 func (r *DatabaseRef) URL() *url.URL {
 	return r.url
@@ -370,36 +866,363 @@ func (r *DatabaseRef) Get(d interface{}, opts ...QueryOption) error {
 	return Get(r, d, opts...)
 }
 
+// GetContext retrieves the values stored at the Firebase database ref and
+// decodes them into d, canceling the operation when the passed context is
+// done.
+func (r *DatabaseRef) GetContext(ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return GetContext(r, ctxt, d, opts...)
+}
+
+// GetRaw retrieves the values stored at the Firebase database ref, returning
+// the unprocessed response body. The caller is responsible for closing the
+// returned io.ReadCloser.
+func (r *DatabaseRef) GetRaw(opts ...QueryOption) (io.ReadCloser, error) {
+	return GetRaw(r, opts...)
+}
+
+// GetRawContext retrieves the values stored at the Firebase database ref,
+// returning the unprocessed response body. The caller is responsible for
+// closing the returned io.ReadCloser.
+func (r *DatabaseRef) GetRawContext(ctxt context.Context, opts ...QueryOption) (io.ReadCloser, error) {
+	return GetRawContext(r, ctxt, opts...)
+}
+
+// GetFields retrieves only the specified child fields of each child of the
+// Firebase database ref, decoding the assembled sparse result into d.
+func (r *DatabaseRef) GetFields(d interface{}, fields []string, opts ...QueryOption) error {
+	return GetFields(r, d, fields, opts...)
+}
+
+// GetOrdered retrieves the children of the Firebase database ref, returning
+// them as an ordered slice of key and raw value pairs.
+func (r *DatabaseRef) GetOrdered(opts ...QueryOption) ([]KeyValue, error) {
+	return GetOrdered(r, opts...)
+}
+
+// GetOrderedContext retrieves the children of the Firebase database ref,
+// returning them as an ordered slice of key and raw value pairs.
+func (r *DatabaseRef) GetOrderedContext(ctxt context.Context, opts ...QueryOption) ([]KeyValue, error) {
+	return GetOrderedContext(r, ctxt, opts...)
+}
+
+// GetStream retrieves the children of the Firebase database ref, returning
+// a Stream that decodes the children incrementally. The caller is
+// responsible for closing the returned Stream.
+func (r *DatabaseRef) GetStream(opts ...QueryOption) (*Stream, error) {
+	return GetStream(r, opts...)
+}
+
+// GetStreamContext retrieves the children of the Firebase database ref,
+// returning a Stream that decodes the children incrementally. The caller is
+// responsible for closing the returned Stream.
+func (r *DatabaseRef) GetStreamContext(ctxt context.Context, opts ...QueryOption) (*Stream, error) {
+	return GetStreamContext(r, ctxt, opts...)
+}
+
+// Iter returns an Iterator over the children of the Firebase database ref in
+// key order, retrieving the children lazily in pages.
+func (r *DatabaseRef) Iter(ctxt context.Context, opts ...QueryOption) *Iterator {
+	return Iter(r, ctxt, opts...)
+}
+
+// List expands the path glob (ie, "/users/*/sessions/*") relative to the
+// Firebase database ref, returning the sorted absolute paths of the matching
+// nodes.
+func (r *DatabaseRef) List(glob string) ([]string, error) {
+	return List(r, glob)
+}
+
+// ListContext expands the path glob relative to the Firebase database ref,
+// returning the sorted absolute paths of the matching nodes.
+func (r *DatabaseRef) ListContext(ctxt context.Context, glob string) ([]string, error) {
+	return ListContext(r, ctxt, glob)
+}
+
+// GetInto retrieves the children of the Firebase database ref, decoding
+// them into d (a pointer to a map or slice), and returning the child keys in
+// query order.
+func (r *DatabaseRef) GetInto(d interface{}, opts ...QueryOption) ([]string, error) {
+	return GetInto(r, d, opts...)
+}
+
+// GetIntoContext retrieves the children of the Firebase database ref,
+// decoding them into d (a pointer to a map or slice), and returning the child
+// keys in query order.
+func (r *DatabaseRef) GetIntoContext(ctxt context.Context, d interface{}, opts ...QueryOption) ([]string, error) {
+	return GetIntoContext(r, ctxt, d, opts...)
+}
+
+// GetWithETag retrieves the value stored at the Firebase database ref,
+// decoding it into d, and returning its ETag.
+func (r *DatabaseRef) GetWithETag(d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETag(r, d, opts...)
+}
+
+// GetWithETagContext retrieves the value stored at the Firebase database
+// ref, decoding it into d, and returning its ETag.
+func (r *DatabaseRef) GetWithETagContext(ctxt context.Context, d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETagContext(r, ctxt, d, opts...)
+}
+
 // Set stores values v at the Firebase database ref.
 func (r *DatabaseRef) Set(v interface{}, opts ...QueryOption) error {
 	return Set(r, v, opts...)
 }
 
+// SetContext stores values v at the Firebase database ref, canceling the
+// operation when the passed context is done.
+func (r *DatabaseRef) SetContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return SetContext(r, ctxt, v, opts...)
+}
+
+// SetIfMatch stores value v at the Firebase database ref, only if the ETag
+// of the currently stored value matches etag.
+func (r *DatabaseRef) SetIfMatch(etag string, v interface{}, opts ...QueryOption) error {
+	return SetIfMatch(r, etag, v, opts...)
+}
+
+// SetIfMatchContext stores value v at the Firebase database ref, only if the
+// ETag of the currently stored value matches etag.
+func (r *DatabaseRef) SetIfMatchContext(ctxt context.Context, etag string, v interface{}, opts ...QueryOption) error {
+	return SetIfMatchContext(r, ctxt, etag, v, opts...)
+}
+
+// SetWithPriority stores values v at the Firebase database ref with the
+// priority.
+func (r *DatabaseRef) SetWithPriority(v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriority(r, v, priority, opts...)
+}
+
+// SetWithPriorityContext stores values v at the Firebase database ref with
+// the priority, canceling the operation when the passed context is done.
+func (r *DatabaseRef) SetWithPriorityContext(ctxt context.Context, v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriorityContext(r, ctxt, v, priority, opts...)
+}
+
+// GetPriority retrieves the priority of the value stored at the Firebase
+// database ref.
+func (r *DatabaseRef) GetPriority(opts ...QueryOption) (interface{}, error) {
+	return GetPriority(r, opts...)
+}
+
+// GetPriorityContext retrieves the priority of the value stored at the
+// Firebase database ref, canceling the operation when the passed context is
+// done.
+func (r *DatabaseRef) GetPriorityContext(ctxt context.Context, opts ...QueryOption) (interface{}, error) {
+	return GetPriorityContext(r, ctxt, opts...)
+}
+
 // Push pushes values v to the Firebase database ref, returning the name (ID)
 // of the pushed node.
 func (r *DatabaseRef) Push(v interface{}, opts ...QueryOption) (string, error) {
 	return Push(r, v, opts...)
 }
 
+// PushContext pushes values v to the Firebase database ref, returning the name
+// (ID) of the pushed node, canceling the operation when the passed context is
+// done.
+func (r *DatabaseRef) PushContext(ctxt context.Context, v interface{}, opts ...QueryOption) (string, error) {
+	return PushContext(r, ctxt, v, opts...)
+}
+
+// PushIdempotent pushes values v to the Firebase database ref using the Push
+// ID derived from the idempotency key and creation time t, returning the name
+// (ID) of the pushed node.
+func (r *DatabaseRef) PushIdempotent(key string, t time.Time, v interface{}, opts ...QueryOption) (string, error) {
+	return PushIdempotent(r, key, t, v, opts...)
+}
+
+// PushIdempotentContext pushes values v to the Firebase database ref using
+// the Push ID derived from the idempotency key and creation time t,
+// returning the name (ID) of the pushed node.
+func (r *DatabaseRef) PushIdempotentContext(ctxt context.Context, key string, t time.Time, v interface{}, opts ...QueryOption) (string, error) {
+	return PushIdempotentContext(r, ctxt, key, t, v, opts...)
+}
+
+// PushAndGet pushes values v to the Firebase database ref, and reads back the
+// created node into d, returning the name (ID) of the pushed node.
+func (r *DatabaseRef) PushAndGet(v, d interface{}, opts ...QueryOption) (string, error) {
+	return PushAndGet(r, v, d, opts...)
+}
+
+// PushAndGetContext pushes values v to the Firebase database ref, and reads
+// back the created node into d, returning the name (ID) of the pushed node.
+func (r *DatabaseRef) PushAndGetContext(ctxt context.Context, v, d interface{}, opts ...QueryOption) (string, error) {
+	return PushAndGetContext(r, ctxt, v, d, opts...)
+}
+
+// BatchSet sets the values keyed by their child paths relative to the
+// Firebase database ref. See BatchWriter.Set.
+func (r *DatabaseRef) BatchSet(values map[string]interface{}, opts ...QueryOption) error {
+	return BatchSet(r, values, opts...)
+}
+
+// BatchSetContext sets the values keyed by their child paths relative to the
+// Firebase database ref, canceling pending writes when the passed context is
+// done. See BatchWriter.Set.
+func (r *DatabaseRef) BatchSetContext(ctxt context.Context, values map[string]interface{}, opts ...QueryOption) error {
+	return BatchSetContext(r, ctxt, values, opts...)
+}
+
+// BatchPush pushes the values to the Firebase database ref, returning the
+// generated keys. See BatchWriter.Push.
+func (r *DatabaseRef) BatchPush(values []interface{}, opts ...QueryOption) ([]string, error) {
+	return BatchPush(r, values, opts...)
+}
+
+// BatchPushContext pushes the values to the Firebase database ref, returning
+// the generated keys, and canceling pending writes when the passed context is
+// done. See BatchWriter.Push.
+func (r *DatabaseRef) BatchPushContext(ctxt context.Context, values []interface{}, opts ...QueryOption) ([]string, error) {
+	return BatchPushContext(r, ctxt, values, opts...)
+}
+
 // Update updates the values stored at the Firebase database ref to v.
 func (r *DatabaseRef) Update(v interface{}, opts ...QueryOption) error {
 	return Update(r, v, opts...)
 }
 
+// UpdateContext updates the values stored at the Firebase database ref to v,
+// canceling the operation when the passed context is done.
+func (r *DatabaseRef) UpdateContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return UpdateContext(r, ctxt, v, opts...)
+}
+
+// UpdateMulti atomically writes the values in updates, keyed by their full
+// database paths, using a single multi-path update against the root of the
+// Firebase database ref.
+func (r *DatabaseRef) UpdateMulti(updates map[string]interface{}, opts ...QueryOption) error {
+	return UpdateMulti(r, updates, opts...)
+}
+
+// UpdateMultiContext atomically writes the values in updates, keyed by their
+// full database paths, using a single multi-path update against the root of
+// the Firebase database ref.
+func (r *DatabaseRef) UpdateMultiContext(ctxt context.Context, updates map[string]interface{}, opts ...QueryOption) error {
+	return UpdateMultiContext(r, ctxt, updates, opts...)
+}
+
+// UpdateFields updates the fields of the Firebase database ref, keyed by
+// their relative paths, in a single request. Fields with the value Delete (or
+// nil) are removed.
+func (r *DatabaseRef) UpdateFields(fields map[string]interface{}, opts ...QueryOption) error {
+	return UpdateFields(r, fields, opts...)
+}
+
+// UpdateFieldsContext updates the fields of the Firebase database ref, keyed
+// by their relative paths, in a single request, canceling the operation when
+// the passed context is done.
+func (r *DatabaseRef) UpdateFieldsContext(ctxt context.Context, fields map[string]interface{}, opts ...QueryOption) error {
+	return UpdateFieldsContext(r, ctxt, fields, opts...)
+}
+
 // Remove removes the values stored at the Firebase database ref.
 func (r *DatabaseRef) Remove(opts ...QueryOption) error {
 	return Remove(r, opts...)
 }
 
+// RemoveContext removes the values stored at the Firebase database ref,
+// canceling the operation when the passed context is done.
+func (r *DatabaseRef) RemoveContext(ctxt context.Context, opts ...QueryOption) error {
+	return RemoveContext(r, ctxt, opts...)
+}
+
+// RemoveRecursive removes the Firebase database ref and all of its
+// descendants in batches. See RecursiveRemover.Remove.
+func (r *DatabaseRef) RemoveRecursive(opts ...QueryOption) error {
+	return RemoveRecursive(r, opts...)
+}
+
+// RemoveRecursiveContext removes the Firebase database ref and all of its
+// descendants in batches, canceling the operation when the passed context is
+// done. See RecursiveRemover.Remove.
+func (r *DatabaseRef) RemoveRecursiveContext(ctxt context.Context, opts ...QueryOption) error {
+	return RemoveRecursiveContext(r, ctxt, opts...)
+}
+
+// RemoveIfMatch removes the value stored at the Firebase database ref, only
+// if the ETag of the currently stored value matches etag.
+func (r *DatabaseRef) RemoveIfMatch(etag string, opts ...QueryOption) error {
+	return RemoveIfMatch(r, etag, opts...)
+}
+
+// RemoveIfMatchContext removes the value stored at the Firebase database ref,
+// only if the ETag of the currently stored value matches etag.
+func (r *DatabaseRef) RemoveIfMatchContext(ctxt context.Context, etag string, opts ...QueryOption) error {
+	return RemoveIfMatchContext(r, ctxt, etag, opts...)
+}
+
 // SetRules sets the security rules for the Firebase database ref.
-func (r *DatabaseRef) SetRules(v interface{}) error {
-	return SetRules(r, v)
+func (r *DatabaseRef) SetRules(v interface{}, opts ...QueryOption) error {
+	return SetRules(r, v, opts...)
+}
+
+// SetRulesContext sets the security rules for the Firebase database ref,
+// canceling the operation when the passed context is done.
+func (r *DatabaseRef) SetRulesContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return SetRulesContext(r, ctxt, v, opts...)
 }
 
 // SetRulesJSON sets the JSON-encoded security rules for the Firebase database
 // ref.
-func (r *DatabaseRef) SetRulesJSON(buf []byte) error {
-	return SetRulesJSON(r, buf)
+func (r *DatabaseRef) SetRulesJSON(buf []byte, opts ...QueryOption) error {
+	return SetRulesJSON(r, buf, opts...)
+}
+
+// SetRulesJSONContext sets the JSON-encoded security rules for the Firebase
+// database ref, canceling the operation when the passed context is done.
+func (r *DatabaseRef) SetRulesJSONContext(ctxt context.Context, buf []byte, opts ...QueryOption) error {
+	return SetRulesJSONContext(r, ctxt, buf, opts...)
+}
+
+// GetSetting retrieves the named database setting for the Firebase database
+// ref, decoding it into d.
+func (r *DatabaseRef) GetSetting(name string, d interface{}) error {
+	return GetSetting(r, name, d)
+}
+
+// GetSettingContext retrieves the named database setting for the Firebase
+// database ref, decoding it into d, canceling the operation when the passed
+// context is done.
+func (r *DatabaseRef) GetSettingContext(ctxt context.Context, name string, d interface{}) error {
+	return GetSettingContext(r, ctxt, name, d)
+}
+
+// SetSetting sets the named database setting for the Firebase database ref.
+func (r *DatabaseRef) SetSetting(name string, v interface{}) error {
+	return SetSetting(r, name, v)
+}
+
+// SetSettingContext sets the named database setting for the Firebase
+// database ref, canceling the operation when the passed context is done.
+func (r *DatabaseRef) SetSettingContext(ctxt context.Context, name string, v interface{}) error {
+	return SetSettingContext(r, ctxt, name, v)
+}
+
+// GetDefaultWriteSizeLimit retrieves the defaultWriteSizeLimit database
+// setting for the Firebase database ref.
+func (r *DatabaseRef) GetDefaultWriteSizeLimit() (string, error) {
+	return GetDefaultWriteSizeLimit(r)
+}
+
+// GetDefaultWriteSizeLimitContext retrieves the defaultWriteSizeLimit
+// database setting for the Firebase database ref, canceling the operation
+// when the passed context is done.
+func (r *DatabaseRef) GetDefaultWriteSizeLimitContext(ctxt context.Context) (string, error) {
+	return GetDefaultWriteSizeLimitContext(r, ctxt)
+}
+
+// SetDefaultWriteSizeLimit sets the defaultWriteSizeLimit database setting
+// for the Firebase database ref.
+func (r *DatabaseRef) SetDefaultWriteSizeLimit(size string) error {
+	return SetDefaultWriteSizeLimit(r, size)
+}
+
+// SetDefaultWriteSizeLimitContext sets the defaultWriteSizeLimit database
+// setting for the Firebase database ref, canceling the operation when the
+// passed context is done.
+func (r *DatabaseRef) SetDefaultWriteSizeLimitContext(ctxt context.Context, size string) error {
+	return SetDefaultWriteSizeLimitContext(r, ctxt, size)
 }
 
 // GetRulesJSON retrieves the security rules for the Firebase database ref.
@@ -407,6 +1230,51 @@ func (r *DatabaseRef) GetRulesJSON() ([]byte, error) {
 	return GetRulesJSON(r)
 }
 
+// GetRulesJSONContext retrieves the security rules for the Firebase database
+// ref, canceling the operation when the passed context is done.
+func (r *DatabaseRef) GetRulesJSONContext(ctxt context.Context) ([]byte, error) {
+	return GetRulesJSONContext(r, ctxt)
+}
+
+// EnsureIndex ensures the security rules for the database contain .indexOn
+// entries for the fields under the rules path. See EnsureIndexContext.
+func (r *DatabaseRef) EnsureIndex(path string, fields ...string) ([]string, error) {
+	return EnsureIndex(r, path, fields...)
+}
+
+// EnsureIndexContext ensures the security rules for the database contain
+// .indexOn entries for the fields under the rules path, canceling the
+// operation when the passed context is done.
+func (r *DatabaseRef) EnsureIndexContext(ctxt context.Context, path string, fields ...string) ([]string, error) {
+	return EnsureIndexContext(r, ctxt, path, fields...)
+}
+
+// GetDatabaseMetadata retrieves the metadata for the database instance of the
+// Firebase database ref. See GetDatabaseMetadataContext.
+func (r *DatabaseRef) GetDatabaseMetadata(client *http.Client, projectID string) (*DatabaseMetadata, error) {
+	return GetDatabaseMetadata(r, client, projectID)
+}
+
+// GetDatabaseMetadataContext retrieves the metadata for the database instance
+// of the Firebase database ref, canceling the operation when the passed
+// context is done.
+func (r *DatabaseRef) GetDatabaseMetadataContext(ctxt context.Context, client *http.Client, projectID string) (*DatabaseMetadata, error) {
+	return GetDatabaseMetadataContext(r, ctxt, client, projectID)
+}
+
+// Transaction atomically modifies the value stored at the Firebase database
+// ref using the mutation func fn. See Transaction for more information.
+func (r *DatabaseRef) Transaction(fn TransactionFunc, opts ...QueryOption) error {
+	return Transaction(r, fn, opts...)
+}
+
+// TransactionContext atomically modifies the value stored at the Firebase
+// database ref using the mutation func fn, canceling the transaction when the
+// passed context is done.
+func (r *DatabaseRef) TransactionContext(ctxt context.Context, fn TransactionFunc, opts ...QueryOption) error {
+	return TransactionContext(r, ctxt, fn, opts...)
+}
+
 // Watch watches the Firebase database ref for events, emitting encountered
 // events on the returned channel. Watch ends when the passed context is done,
 // when the remote connection is closed, or when an error is encountered while
@@ -417,6 +1285,12 @@ func (r *DatabaseRef) Watch(ctxt context.Context, opts ...QueryOption) (<-chan *
 	return Watch(r, ctxt, opts...)
 }
 
+// WatchFunc watches the Firebase database ref for events, calling f with each
+// event. See WatchFunc.
+func (r *DatabaseRef) WatchFunc(ctxt context.Context, f WatchHandler, opts ...QueryOption) error {
+	return WatchFunc(r, ctxt, f, opts...)
+}
+
 // Listen listens on the Firebase database ref for any of the the specified
 // eventTypes, emitting them on the returned channel.
 //
@@ -428,3 +1302,13 @@ func (r *DatabaseRef) Watch(ctxt context.Context, opts ...QueryOption) (<-chan *
 func (r *DatabaseRef) Listen(ctxt context.Context, eventTypes []EventType, opts ...QueryOption) <-chan *Event {
 	return Listen(r, ctxt, eventTypes, opts...)
 }
+
+// ListenFrom listens on the Firebase database ref for any of the specified
+// eventTypes, emitting them on the returned channel, resuming from the
+// previously known state identified by stateHash. See ListenFrom for more
+// information.
+//
+// NOTE: the Log option will not work with Watch/Listen.
+func (r *DatabaseRef) ListenFrom(ctxt context.Context, stateHash string, eventTypes []EventType, opts ...QueryOption) <-chan *Event {
+	return ListenFrom(r, ctxt, stateHash, eventTypes, opts...)
+}
@@ -0,0 +1,64 @@
+package firebase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetRaw(t *testing.T) {
+	var query string
+	var reqs int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		reqs++
+		if req.URL.Path == "/denied.json" {
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+			return
+		}
+		if name := req.URL.Query().Get("download"); name != "" {
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		}
+		w.Write([]byte(`{"b":2,"a":1.50}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// body is not decoded
+	rc, err := r.Ref("a").GetRaw(Download("a.json"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(buf) != `{"b":2,"a":1.50}` {
+		t.Errorf("expected unprocessed body, got: %s", buf)
+	}
+	if query != "download=a.json" {
+		t.Errorf("expected download query, got: %s", query)
+	}
+
+	// server error
+	if rc, err = r.Ref("denied").GetRaw(); err == nil || rc != nil {
+		t.Errorf("expected error, got: %v", err)
+	}
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized error, got: %v", err)
+	}
+
+	// empty download filename
+	if _, err = r.GetRaw(Download("")); err == nil {
+		t.Errorf("expected error")
+	}
+	if reqs != 2 {
+		t.Errorf("expected 2 requests, got: %d", reqs)
+	}
+}
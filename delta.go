@@ -0,0 +1,75 @@
+package firebase
+
+import "encoding/json"
+
+// deltaTracker tracks the last known value of a watched ref, computing diffs
+// for put and patch events.
+type deltaTracker struct {
+	root interface{}
+}
+
+// diff applies the put or patch event to the tracked value, returning the
+// event's JSON encoded diff against the previous value.
+//
+// The diff has the same envelope as the event data (ie, {"path": ..., "data":
+// ...}), with data containing a JSON merge patch (RFC 7386) relative to path.
+func (dt *deltaTracker) diff(ev *Event) ([]byte, error) {
+	var env eventData
+	err := json.Unmarshal(ev.Data, &env)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decodeJSON(env.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// compute diff
+	parts := splitPath(env.Path)
+	var d interface{}
+	switch ev.Type {
+	case EventTypePut:
+		var changed bool
+		d, changed = mergeDiff(treeGet(dt.root, parts), data)
+		if !changed {
+			d = map[string]interface{}{}
+		}
+
+	case EventTypePatch:
+		m := make(map[string]interface{})
+		patch, _ := data.(map[string]interface{})
+		for k, v := range patch {
+			old := treeGet(dt.root, append(parts[:len(parts):len(parts)], splitPath(k)...))
+			if kd, changed := mergeDiff(old, v); changed {
+				setPatch(m, splitPath(k), kd)
+			}
+		}
+		d = m
+	}
+
+	// apply
+	dt.root = treeApply(dt.root, ev.Type, env.Path, data)
+
+	return json.Marshal(map[string]interface{}{
+		"path": env.Path,
+		"data": d,
+	})
+}
+
+// setPatch sets v in the merge patch m at the relative path parts, expanding
+// multi-segment keys of a patch event (ie, "a/b") into nested objects.
+func setPatch(m map[string]interface{}, parts []string, v interface{}) {
+	if len(parts) == 0 {
+		return
+	}
+	for _, p := range parts[:len(parts)-1] {
+		c, ok := m[p].(map[string]interface{})
+		if !ok {
+			c = make(map[string]interface{})
+			m[p] = c
+		}
+		m = c
+	}
+	m[parts[len(parts)-1]] = v
+}
@@ -0,0 +1,30 @@
+package firebase
+
+import "testing"
+
+func TestDeltaTrackerDiff(t *testing.T) {
+	tests := []struct {
+		typ  EventType
+		data string
+		exp  string
+	}{
+		{EventTypePut, `{"path":"/","data":{"a":1,"b":{"c":"d"}}}`, `{"data":{"a":1,"b":{"c":"d"}},"path":"/"}`},
+		{EventTypePut, `{"path":"/b","data":{"c":"d","e":"f"}}`, `{"data":{"e":"f"},"path":"/b"}`},
+		{EventTypePut, `{"path":"/b","data":{"e":"f"}}`, `{"data":{"c":null},"path":"/b"}`},
+		{EventTypePut, `{"path":"/a","data":1}`, `{"data":{},"path":"/a"}`},
+		{EventTypePatch, `{"path":"/","data":{"a":2,"b/e":"f","g":null}}`, `{"data":{"a":2},"path":"/"}`},
+		{EventTypePatch, `{"path":"/b","data":{"e":"g","h/i/j":1,"h/k":2}}`, `{"data":{"e":"g","h":{"i":{"j":1},"k":2}},"path":"/b"}`},
+		{EventTypePut, `{"path":"/b","data":null}`, `{"data":null,"path":"/b"}`},
+	}
+
+	dt := new(deltaTracker)
+	for i, test := range tests {
+		buf, err := dt.diff(&Event{Type: test.typ, Data: []byte(test.data)})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := string(buf); s != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, s)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// EmulatorExportVersion is the firebase-tools version written to the
+	// metadata of emulator exports.
+	EmulatorExportVersion = "13.0.0"
+
+	// emulatorMetadataFile is the name of the emulator export metadata file.
+	emulatorMetadataFile = "firebase-export-metadata.json"
+
+	// emulatorDatabaseDir is the directory of the database export within an
+	// emulator export directory.
+	emulatorDatabaseDir = "database_export"
+)
+
+// emulatorMetadata is the metadata of an emulator export directory.
+type emulatorMetadata struct {
+	Version  string                     `json:"version"`
+	Database *emulatorMetadataComponent `json:"database,omitempty"`
+
+	// Other holds the metadata of the other emulators (firestore, auth, ...),
+	// which is preserved when exporting to an existing directory.
+	Other map[string]json.RawMessage `json:"-"`
+}
+
+// emulatorMetadataComponent is the metadata of an exported emulator.
+type emulatorMetadataComponent struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// readEmulatorMetadata reads the emulator export metadata in dir.
+func readEmulatorMetadata(dir string) (*emulatorMetadata, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, emulatorMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	md := new(emulatorMetadata)
+	if err = json.Unmarshal(buf, md); err != nil {
+		return nil, fmt.Errorf("could not decode emulator export metadata: %v", err)
+	}
+	if err = json.Unmarshal(buf, &md.Other); err != nil {
+		return nil, fmt.Errorf("could not decode emulator export metadata: %v", err)
+	}
+	delete(md.Other, "version")
+	delete(md.Other, "database")
+
+	return md, nil
+}
+
+// write writes the emulator export metadata to dir.
+func (md *emulatorMetadata) write(dir string) error {
+	m := make(map[string]interface{}, len(md.Other)+2)
+	for k, v := range md.Other {
+		m[k] = v
+	}
+	m["version"] = md.Version
+	m["database"] = md.Database
+
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, emulatorMetadataFile), buf, 0644)
+}
+
+// ExportEmulatorSeed exports the complete contents of the database of
+// Firebase database ref r to dir, using the Firebase emulator's
+// import/export directory format, such that it can be loaded with:
+//
+//	firebase emulators:start --import <dir>
+//
+// The data is written as the database export for namespace (ie, the
+// project's database instance name). Metadata for other emulators in an
+// existing export directory is preserved.
+func ExportEmulatorSeed(ctxt context.Context, r *DatabaseRef, dir, namespace string) error {
+	if namespace == "" {
+		return &Error{
+			Err: "emulator export namespace cannot be empty",
+		}
+	}
+
+	// create dirs
+	dbDir := filepath.Join(dir, emulatorDatabaseDir)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return err
+	}
+
+	// export data
+	body, err := GetRawContext(r.root(), ctxt)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp := filepath.Join(dbDir, namespace+".json.tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, filepath.Join(dbDir, namespace+".json")); err != nil {
+		return err
+	}
+
+	// write metadata
+	md, err := readEmulatorMetadata(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if md == nil {
+		md = new(emulatorMetadata)
+	}
+	md.Version = EmulatorExportVersion
+	md.Database = &emulatorMetadataComponent{
+		Version: EmulatorExportVersion,
+		Path:    emulatorDatabaseDir,
+	}
+
+	return md.write(dir)
+}
+
+// ImportEmulatorSeed replaces the complete contents of the database of
+// Firebase database ref r with the database export for namespace in the
+// emulator export directory dir.
+//
+// This is useful for loading an emulator export into a running emulator (or
+// a development database) without restarting it.
+func ImportEmulatorSeed(ctxt context.Context, r *DatabaseRef, dir, namespace string) error {
+	md, err := readEmulatorMetadata(dir)
+	if err != nil {
+		return err
+	}
+	if md.Database == nil {
+		return &Error{
+			Err: fmt.Sprintf("emulator export %s does not contain a database export", dir),
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, md.Database.Path, namespace+".json"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return SetContext(r.root(), ctxt, f)
+}
@@ -0,0 +1,51 @@
+package firebase
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportEmulatorSeed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"a":1}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "emulator-export")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, emulatorMetadataFile), []byte(`{"version":"1","firestore":{"version":"1","path":"firestore_export"}}`), 0644)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err = ExportEmulatorSeed(context.Background(), r.Ref("/x"), dir, "test"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, emulatorDatabaseDir, "test.json"))
+	if err != nil || string(buf) != `{"a":1}` {
+		t.Errorf("expected exported data, got: %s (%v)", buf, err)
+	}
+
+	md, err := readEmulatorMetadata(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if md.Database == nil || md.Database.Path != emulatorDatabaseDir || md.Other["firestore"] == nil {
+		t.Errorf("expected database and firestore metadata, got: %+v", md)
+	}
+}
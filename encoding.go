@@ -0,0 +1,558 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structField is an encoded struct field.
+type structField struct {
+	index []int
+	name  string
+
+	omitEmpty, omitZero, serverTimestamp bool
+}
+
+// parseFieldTag returns the name and options of the firebase struct tag of
+// the struct field, falling back to the json struct tag for the name. Returns
+// an empty name when the field is skipped.
+func parseFieldTag(f reflect.StructField) (string, []string) {
+	name := f.Name
+	jsonTag, ok := f.Tag.Lookup("json")
+	if ok {
+		if jsonTag == "-" {
+			name = ""
+		} else if n := strings.Split(jsonTag, ",")[0]; n != "" {
+			name = n
+		}
+	}
+
+	tag, ok := f.Tag.Lookup("firebase")
+	if !ok {
+		// json options
+		var opts []string
+		if i := strings.Index(jsonTag, ","); i != -1 {
+			opts = strings.Split(jsonTag[i+1:], ",")
+		}
+		return name, opts
+	}
+	if tag == "-" {
+		return "", nil
+	}
+	opts := strings.Split(tag, ",")
+	if opts[0] != "" {
+		name = opts[0]
+	}
+	return name, opts[1:]
+}
+
+// structFieldsCache caches the encoded fields of struct types.
+var structFieldsCache sync.Map
+
+// structFields returns the encoded fields of the struct type, promoting the
+// fields of embedded structs.
+func structFields(typ reflect.Type) []structField {
+	if v, ok := structFieldsCache.Load(typ); ok {
+		return v.([]structField)
+	}
+
+	var fields []structField
+	seen := make(map[string]bool)
+	walking := map[reflect.Type]bool{typ: true}
+	var walk func(reflect.Type, []int)
+	walk = func(typ reflect.Type, index []int) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			idx := append(index[:len(index):len(index)], i)
+
+			// embedded struct fields are promoted
+			_, jsonTag := f.Tag.Lookup("json")
+			_, firebaseTag := f.Tag.Lookup("firebase")
+			if f.Anonymous && !jsonTag && !firebaseTag {
+				switch {
+				case f.Type.Kind() == reflect.Struct:
+					walk(f.Type, idx)
+					continue
+				case f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct:
+					// unexported embedded pointers cannot be allocated
+					if f.PkgPath == "" && !walking[f.Type.Elem()] {
+						walking[f.Type.Elem()] = true
+						walk(f.Type.Elem(), idx)
+						walking[f.Type.Elem()] = false
+					}
+					continue
+				}
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+
+			name, opts := parseFieldTag(f)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, structField{
+				index:           idx,
+				name:            name,
+				omitEmpty:       sliceContains(opts, "omitempty"),
+				omitZero:        sliceContains(opts, "omitzero"),
+				serverTimestamp: sliceContains(opts, "serverTimestamp"),
+			})
+		}
+	}
+	walk(typ, nil)
+
+	structFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// firebaseTagsKey is the key for the firebase struct tag cache.
+type firebaseTagsKey struct {
+	typ    reflect.Type
+	ifaces bool
+}
+
+// firebaseTagsCache caches whether types use firebase struct tags.
+var firebaseTagsCache sync.Map
+
+// hasFirebaseTags determines if the type (or any type it contains) has
+// struct fields with a firebase struct tag. When ifaces is true, types
+// containing interfaces (whose dynamic values may have firebase struct tags)
+// are included.
+func hasFirebaseTags(typ reflect.Type, ifaces bool) bool {
+	if typ == nil {
+		return false
+	}
+	key := firebaseTagsKey{typ, ifaces}
+	if v, ok := firebaseTagsCache.Load(key); ok {
+		return v.(bool)
+	}
+	ok := typeHasFirebaseTags(typ, ifaces, make(map[reflect.Type]bool))
+	firebaseTagsCache.Store(key, ok)
+	return ok
+}
+
+// typeHasFirebaseTags determines if the type has firebase struct tags.
+func typeHasFirebaseTags(typ reflect.Type, ifaces bool, seen map[reflect.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return ifaces
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array:
+		return typeHasFirebaseTags(typ.Elem(), ifaces, seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if _, ok := f.Tag.Lookup("firebase"); ok || typeHasFirebaseTags(f.Type, ifaces, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Marshal returns the JSON encoding of v for storage in Firebase, honoring
+// the firebase struct tag of struct fields, which is used by Set, Push, and
+// Update.
+//
+// The firebase struct tag has the form `firebase:"name,options..."`, where
+// name overrides the key used for the field (otherwise the json struct tag
+// name, or the field name, is used), and "-" skips the field. When a field
+// has a firebase struct tag, the options of its json struct tag are ignored.
+// Options:
+//
+//	omitempty        omit the field when empty, as with encoding/json
+//	omitzero         omit the field when it is the zero value
+//	serverTimestamp  write the server timestamp when the field is the zero
+//	                 value, with time.Time fields stored as milliseconds
+//	                 since the Unix epoch
+//	encrypt          encrypt the field (see EncryptFields)
+//
+// For example:
+//
+//	type Post struct {
+//		Title   string    `json:"title" firebase:"t"`
+//		Draft   bool      `json:"draft" firebase:",omitzero"`
+//		Created time.Time `json:"created" firebase:"c,serverTimestamp"`
+//		Secret  string    `json:"secret" firebase:"-"`
+//	}
+//
+// Values without firebase struct tags are encoded as with encoding/json.
+func Marshal(v interface{}) ([]byte, error) {
+	if !hasFirebaseTags(reflect.TypeOf(v), true) {
+		return json.Marshal(v)
+	}
+	x, err := encodeValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
+
+// marshal JSON encodes v using the ref's JSON codec (see JSONCodec), falling
+// back to Marshal.
+func (r *DatabaseRef) marshal(v interface{}) ([]byte, error) {
+	if r.jsonMarshal == nil || hasFirebaseTags(reflect.TypeOf(v), true) {
+		return Marshal(v)
+	}
+	return r.jsonMarshal(v)
+}
+
+// encodeValue encodes the value as a generic tree for json.Marshal.
+func encodeValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	typ := v.Type()
+	if !hasFirebaseTags(typ, true) || typ.Implements(jsonMarshalerType) {
+		return v.Interface(), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeValue(v.Elem())
+
+	case reflect.Struct:
+		if !hasFirebaseTags(typ, false) {
+			return v.Interface(), nil
+		}
+		m := make(map[string]interface{})
+		for _, f := range structFields(typ) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			zero := isZeroValue(fv)
+			switch {
+			case f.serverTimestamp && zero:
+				m[f.name] = json.RawMessage(serverTimestampValue)
+				continue
+			case f.omitZero && zero, f.omitEmpty && isEmptyValue(fv):
+				continue
+			case f.serverTimestamp && fv.Type() == timeType:
+				m[f.name] = fv.Interface().(time.Time).UnixNano() / int64(time.Millisecond)
+				continue
+			}
+			x, err := encodeValue(fv)
+			if err != nil {
+				return nil, err
+			}
+			m[f.name] = x
+		}
+		return m, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := encodeMapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			x, err := encodeValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[k] = x
+		}
+		return m, nil
+
+	case reflect.Slice, reflect.Array:
+		if typ.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			x, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	}
+
+	return v.Interface(), nil
+}
+
+// Unmarshal decodes the JSON encoded data retrieved from Firebase into d,
+// honoring the firebase struct tag of struct fields (see Marshal), which is
+// used by Get. Numbers decoded into interface{} values are json.Number.
+func Unmarshal(buf []byte, d interface{}) error {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: expected non-nil pointer, got: %T", d),
+		}
+	}
+	if err := decodeValue(buf, v.Elem()); err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+	return nil
+}
+
+// decodeValue decodes the JSON encoded data into the settable value.
+func decodeValue(buf []byte, v reflect.Value) error {
+	typ := v.Type()
+	if !hasFirebaseTags(typ, false) || reflect.PtrTo(typ).Implements(jsonUnmarshalerType) {
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.UseNumber()
+		return dec.Decode(v.Addr().Interface())
+	}
+
+	null := bytes.Equal(bytes.TrimSpace(buf), []byte("null"))
+	switch typ.Kind() {
+	case reflect.Ptr:
+		if null {
+			v.Set(reflect.Zero(typ))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(typ.Elem()))
+		}
+		return decodeValue(buf, v.Elem())
+
+	case reflect.Struct:
+		if null {
+			return nil
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return err
+		}
+		for _, f := range structFields(typ) {
+			raw, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			fv := allocFieldByIndex(v, f.index)
+			if f.serverTimestamp && fv.Type() == timeType {
+				t, err := decodeTimestamp(raw)
+				if err != nil {
+					return fmt.Errorf("%s: %v", f.name, err)
+				}
+				fv.Set(reflect.ValueOf(t))
+				continue
+			}
+			if err := decodeValue(raw, fv); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if null {
+			v.Set(reflect.Zero(typ))
+			return nil
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(typ, len(m)))
+		}
+		for k, raw := range m {
+			kv, err := decodeMapKey(k, typ.Key())
+			if err != nil {
+				return err
+			}
+			e := reflect.New(typ.Elem()).Elem()
+			if err := decodeValue(raw, e); err != nil {
+				return err
+			}
+			v.SetMapIndex(kv, e)
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if null {
+			v.Set(reflect.Zero(typ))
+			return nil
+		}
+		var s []json.RawMessage
+		if err := json.Unmarshal(buf, &s); err != nil {
+			return err
+		}
+		if typ.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(typ, len(s), len(s)))
+		}
+		for i := 0; i < len(s) && i < v.Len(); i++ {
+			if err := decodeValue(s[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return json.Unmarshal(buf, v.Addr().Interface())
+}
+
+// decodeTimestamp decodes a timestamp stored as milliseconds since the Unix
+// epoch.
+func decodeTimestamp(buf []byte) (time.Time, error) {
+	s := string(bytes.TrimSpace(buf))
+	if s == "null" {
+		return time.Time{}, nil
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s", s)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+// encodeMapKey returns the encoded map key, handling keys as encoding/json
+// does (ie, strings, integers, and encoding.TextMarshaler implementations).
+func encodeMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		buf, err := tm.MarshalText()
+		return string(buf), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", &Error{
+		Err: fmt.Sprintf("cannot encode map with %s keys", k.Type()),
+	}
+}
+
+// decodeMapKey returns the map key of type typ for the encoded key, handling
+// keys as encoding/json does (ie, encoding.TextUnmarshaler implementations,
+// strings, and integers).
+func decodeMapKey(k string, typ reflect.Type) (reflect.Value, error) {
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		kv := reflect.New(typ)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(k)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+	kv := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		kv.SetString(k)
+		return kv, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(k, 10, 64)
+		if err != nil || kv.OverflowInt(n) {
+			return reflect.Value{}, &Error{
+				Err: fmt.Sprintf("cannot decode map key %q into %s", k, typ),
+			}
+		}
+		kv.SetInt(n)
+		return kv, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(k, 10, 64)
+		if err != nil || kv.OverflowUint(n) {
+			return reflect.Value{}, &Error{
+				Err: fmt.Sprintf("cannot decode map key %q into %s", k, typ),
+			}
+		}
+		kv.SetUint(n)
+		return kv, nil
+	}
+	return reflect.Value{}, &Error{
+		Err: fmt.Sprintf("cannot decode map with %s keys", typ),
+	}
+}
+
+// fieldByIndex returns the nested field of the struct value, returning false
+// when an embedded pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// allocFieldByIndex returns the nested field of the struct value, allocating
+// embedded pointers.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// isZeroValue determines if the value is the zero value, using the value's
+// IsZero method when available (ie, time.Time).
+func isZeroValue(v reflect.Value) bool {
+	if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+		if v.Kind() != reflect.Ptr || !v.IsNil() {
+			return z.IsZero()
+		}
+	}
+	return v.IsZero()
+}
+
+// isEmptyValue determines if the value is empty, as with encoding/json's
+// omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encodingBase struct {
+	ID string `firebase:"id"`
+}
+
+type encodingPost struct {
+	encodingBase
+	Title   string                    `json:"title" firebase:"t"`
+	Draft   bool                      `json:"draft" firebase:",omitzero"`
+	Tags    []string                  `json:"tags,omitempty"`
+	Created time.Time                 `json:"created" firebase:"c,serverTimestamp"`
+	Secret  string                    `json:"secret" firebase:"-"`
+	Authors map[string]*encodingNamed `json:"authors,omitempty" firebase:"a,omitempty"`
+}
+
+type encodingNamed struct {
+	Name string `json:"name" firebase:"n"`
+}
+
+type EncodingTitled struct {
+	Title string `json:"title" firebase:"t"`
+}
+
+type encodingEmbedded struct {
+	*encodingBase
+	*EncodingTitled
+}
+
+type encodingKey struct {
+	a, b string
+}
+
+func (k encodingKey) MarshalText() ([]byte, error) {
+	return []byte(k.a + "-" + k.b), nil
+}
+
+func (k *encodingKey) UnmarshalText(buf []byte) error {
+	i := strings.IndexByte(string(buf), '-')
+	if i == -1 {
+		return fmt.Errorf("invalid key %q", buf)
+	}
+	k.a, k.b = string(buf[:i]), string(buf[i+1:])
+	return nil
+}
+
+func TestMapKeys(t *testing.T) {
+	ints := map[int]encodingNamed{-1: {"x"}, 2: {"y"}}
+	buf, err := Marshal(ints)
+	if err != nil || string(buf) != `{"-1":{"n":"x"},"2":{"n":"y"}}` {
+		t.Fatalf("expected int keys, got: %s, %v", string(buf), err)
+	}
+	var m map[int]encodingNamed
+	if err = Unmarshal(buf, &m); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(m) != 2 || m[-1].Name != "x" || m[2].Name != "y" {
+		t.Errorf("unexpected value: %+v", m)
+	}
+
+	keys := map[encodingKey]encodingNamed{{"a", "b"}: {"x"}}
+	if buf, err = Marshal(keys); err != nil || string(buf) != `{"a-b":{"n":"x"}}` {
+		t.Fatalf("expected text keys, got: %s, %v", string(buf), err)
+	}
+	var k map[encodingKey]encodingNamed
+	if err = Unmarshal(buf, &k); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if k[encodingKey{"a", "b"}].Name != "x" {
+		t.Errorf("unexpected value: %+v", k)
+	}
+
+	// invalid keys
+	var u map[uint8]encodingNamed
+	if err = Unmarshal([]byte(`{"256":{"n":"x"}}`), &u); err == nil {
+		t.Errorf("expected error")
+	}
+	if err = Unmarshal([]byte(`{"ab":{"n":"x"}}`), &k); err == nil {
+		t.Errorf("expected error")
+	}
+	var f map[float64]encodingNamed
+	if err = Unmarshal([]byte(`{"1":{"n":"x"}}`), &f); err == nil {
+		t.Errorf("expected error")
+	}
+	if _, err = Marshal(map[float64]encodingNamed{1: {"x"}}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	created := time.Unix(1500000000, 0)
+	tests := []struct {
+		v   interface{}
+		exp string
+	}{
+		{encodingPost{Title: "x", Secret: "s"}, `{"c":{".sv":"timestamp"},"id":"","t":"x"}`},
+		{&encodingPost{Title: "x", Draft: true, Tags: []string{"a"}, Created: created, Authors: map[string]*encodingNamed{"u": {"bob"}}}, `{"a":{"u":{"n":"bob"}},"c":1500000000000,"draft":true,"id":"","t":"x","tags":["a"]}`},
+		{map[string]interface{}{"posts/1": encodingNamed{"x"}, "n": 1}, `{"n":1,"posts/1":{"n":"x"}}`},
+		{[]encodingNamed{{"x"}}, `[{"n":"x"}]`},
+		{struct {
+			A int `json:"a,string"`
+		}{1}, `{"a":"1"}`},
+	}
+	for i, test := range tests {
+		buf, err := Marshal(test.v)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, string(buf))
+		}
+	}
+
+	// other json consumers are unaffected
+	buf, err := json.Marshal(encodingNamed{"x"})
+	if err != nil || string(buf) != `{"name":"x"}` {
+		t.Errorf("expected json encoding, got: %s, %v", string(buf), err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var p encodingPost
+	err := Unmarshal([]byte(`{"id":"1","t":"x","draft":true,"tags":["a"],"c":1500000000000,"secret":"s","a":{"u":{"n":"bob"}}}`), &p)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if p.ID != "1" || p.Title != "x" || !p.Draft || len(p.Tags) != 1 || !p.Created.Equal(time.Unix(1500000000, 0)) || p.Secret != "" || p.Authors["u"].Name != "bob" {
+		t.Errorf("unexpected value: %+v", p)
+	}
+
+	var m map[string]encodingNamed
+	if err = Unmarshal([]byte(`{"a":{"n":"x"},"b":null}`), &m); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(m) != 2 || m["a"].Name != "x" {
+		t.Errorf("unexpected value: %+v", m)
+	}
+
+	// embedded pointers are promoted
+	var e encodingEmbedded
+	if err = Unmarshal([]byte(`{"id":"1","t":"x"}`), &e); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if e.encodingBase != nil || e.EncodingTitled == nil || e.Title != "x" {
+		t.Errorf("unexpected value: %+v", e)
+	}
+	buf, err := Marshal(e)
+	if err != nil || string(buf) != `{"t":"x"}` {
+		t.Errorf("expected promoted field, got: %s, %v", string(buf), err)
+	}
+
+	var v interface{}
+	if err = Unmarshal([]byte(`{"a":1}`), &v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n, ok := v.(map[string]interface{})["a"].(json.Number); !ok || n != "1" {
+		t.Errorf("expected json.Number, got: %T", v.(map[string]interface{})["a"])
+	}
+}
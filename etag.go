@@ -0,0 +1,96 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ErrPreconditionFailed is the error returned by SetIfMatch and RemoveIfMatch
+// when the value stored at the ref has changed since the ETag was retrieved.
+var ErrPreconditionFailed = &Error{
+	Err:        "precondition failed",
+	StatusCode: http.StatusPreconditionFailed,
+}
+
+// GetWithETag retrieves the value stored at Firebase database ref r,
+// decoding it into d, and returning its ETag for use with SetIfMatch and
+// RemoveIfMatch.
+func GetWithETag(r *DatabaseRef, d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETagContext(r, context.Background(), d, opts...)
+}
+
+// GetWithETagContext retrieves the value stored at Firebase database ref r,
+// decoding it into d, and returning its ETag for use with SetIfMatch and
+// RemoveIfMatch.
+func GetWithETagContext(r *DatabaseRef, ctxt context.Context, d interface{}, opts ...QueryOption) (string, error) {
+	buf, etag, err := getWithETag(r, ctxt, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if d != nil {
+		if err = r.decodeBody(OpTypeGet, bytes.NewReader(buf), d); err != nil {
+			return "", err
+		}
+	}
+
+	return etag, nil
+}
+
+// SetIfMatch stores value v at Firebase database ref r, only if the ETag of
+// the currently stored value matches etag. Returns ErrPreconditionFailed when
+// the value has changed.
+func SetIfMatch(r *DatabaseRef, etag string, v interface{}, opts ...QueryOption) error {
+	return SetIfMatchContext(r, context.Background(), etag, v, opts...)
+}
+
+// SetIfMatchContext stores value v at Firebase database ref r, only if the
+// ETag of the currently stored value matches etag. Returns
+// ErrPreconditionFailed when the value has changed.
+func SetIfMatchContext(r *DatabaseRef, ctxt context.Context, etag string, v interface{}, opts ...QueryOption) error {
+	if v == Delete {
+		return errDeleteValue
+	}
+
+	if v == nil {
+		v = json.RawMessage("null")
+	}
+	body, err := r.encodeBody(OpTypeSet, v)
+	if err != nil {
+		return err
+	}
+
+	return doIfMatch(r, ctxt, OpTypeSet, etag, body, opts...)
+}
+
+// RemoveIfMatch removes the value stored at Firebase database ref r, only if
+// the ETag of the currently stored value matches etag. Returns
+// ErrPreconditionFailed when the value has changed.
+func RemoveIfMatch(r *DatabaseRef, etag string, opts ...QueryOption) error {
+	return RemoveIfMatchContext(r, context.Background(), etag, opts...)
+}
+
+// RemoveIfMatchContext removes the value stored at Firebase database ref r,
+// only if the ETag of the currently stored value matches etag. Returns
+// ErrPreconditionFailed when the value has changed.
+func RemoveIfMatchContext(r *DatabaseRef, ctxt context.Context, etag string, opts ...QueryOption) error {
+	return doIfMatch(r, ctxt, OpTypeRemove, etag, nil, opts...)
+}
+
+// doIfMatch executes a conditional operation with the If-Match header.
+func doIfMatch(r *DatabaseRef, ctxt context.Context, op OpType, etag string, body io.Reader, opts ...QueryOption) error {
+	res, err := r.do(ctxt, string(op), body, http.Header{
+		"If-Match": []string{etag},
+	}, opts...)
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusPreconditionFailed {
+		return ErrPreconditionFailed
+	}
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
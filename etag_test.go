@@ -0,0 +1,41 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetIfMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == "GET" && req.Header.Get("X-Firebase-ETag") == "true":
+			w.Header().Set("ETag", "v1")
+			w.Write([]byte(`1`))
+		case req.Header.Get("If-Match") == "v1":
+			w.Write([]byte(`2`))
+		default:
+			w.Header().Set("ETag", "v2")
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`2`))
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var n int
+	etag, err := r.GetWithETag(&n)
+	if err != nil || etag != "v1" || n != 1 {
+		t.Fatalf("expected etag v1 and 1, got: %q and %d (%v)", etag, n, err)
+	}
+	if err = r.SetIfMatch(etag, 2); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if err = r.RemoveIfMatch("v0"); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed, got: %v", err)
+	}
+}
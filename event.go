@@ -1,6 +1,11 @@
 package firebase
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
 
 // EventType is a Firebase event type.
 type EventType string
@@ -37,6 +42,42 @@ const (
 	// Firebase server is closed.
 	EventTypeClosed EventType = "closed"
 
+	// EventTypeError is the event type sent when reading from the connection
+	// with the Firebase server fails (ie, a network error), with the
+	// underlying error set as the event's Err.
+	EventTypeError EventType = "error"
+
+	// EventTypeConnected is the event type sent by Listen when the
+	// connection with the Firebase server has been established.
+	EventTypeConnected EventType = "connected"
+
+	// EventTypeDisconnected is the event type sent by Listen when the
+	// connection with the Firebase server has been lost, with the error that
+	// ended the connection set as the event's Err.
+	EventTypeDisconnected EventType = "disconnected"
+
+	// EventTypeReconnecting is the event type sent by Listen prior to
+	// reconnecting to the Firebase server, with the reconnection attempt set
+	// as the event's Attempt.
+	EventTypeReconnecting EventType = "reconnecting"
+
+	// EventTypeHeartbeat is the event type sent by Listen in place of a
+	// keep-alive event excluded by the Listen filter, when the
+	// ListenHeartbeats option has been set on the ref.
+	EventTypeHeartbeat EventType = "heartbeat"
+
+	// EventTypeDropped is the event type sent when events were dropped due to
+	// a full event buffer, with the number of dropped events set as the
+	// event's Dropped. Only sent when the WatchOverflow option has been set
+	// on the ref with a drop policy, and always sent by Listen regardless of
+	// the passed event types.
+	EventTypeDropped EventType = "dropped"
+
+	// EventTypeStale is the event type sent when no data was received from
+	// the Firebase server within the stale timeout, ending the watch. See
+	// WatchStaleTimeout.
+	EventTypeStale EventType = "stale"
+
 	// EventTypeUnknownError is the event type sent when an unknown error is
 	// encountered.
 	EventTypeUnknownError EventType = "unknown_error"
@@ -59,9 +100,147 @@ func (e EventType) String() string {
 type Event struct {
 	Type EventType
 	Data []byte
+
+	// Diff is the JSON encoded diff of a put or patch event against the
+	// last known value of the watched ref, and is only populated when the
+	// WatchDiffs option has been set on the ref.
+	//
+	// Diff has the same envelope as Data (ie, {"path": ..., "data": ...}),
+	// where the data is a JSON merge patch (RFC 7386) relative to the path.
+	Diff []byte
+
+	// Err is the error that ended the watch, and is only populated for
+	// synthesized terminal events (ie, EventTypeError, EventTypeClosed,
+	// etc).
+	Err error
+
+	// StatusCode and Header are the HTTP status code and response headers of
+	// the watch connection, and are only populated for synthesized terminal
+	// events.
+	StatusCode int
+	Header     http.Header
+
+	// Attempt is the reconnection attempt (starting at 1) of an
+	// EventTypeReconnecting event.
+	Attempt int
+
+	// Dropped is the number of events dropped of an EventTypeDropped event.
+	Dropped int
+
+	// unmarshal is the watched ref's JSON unmarshal func (see JSONCodec).
+	unmarshal func([]byte, interface{}) error
 }
 
 // String satisfies the stringer interface.
 func (e Event) String() string {
 	return fmt.Sprintf("%s: %s", e.Type, string(e.Data))
 }
+
+// envelope decodes the {"path": ..., "data": ...} envelope of a put or patch
+// event.
+func (e *Event) envelope() (*eventData, error) {
+	if e.Type != EventTypePut && e.Type != EventTypePatch {
+		return nil, &Error{
+			Err: fmt.Sprintf("cannot decode %s event", e.Type),
+		}
+	}
+
+	env := new(eventData)
+	err := json.Unmarshal(e.Data, env)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not decode event data: %v", err),
+		}
+	}
+
+	return env, nil
+}
+
+// Path returns the path (relative to the watched ref) of a put or patch
+// event, or the empty string for all other events.
+func (e *Event) Path() string {
+	env, err := e.envelope()
+	if err != nil {
+		return ""
+	}
+	return env.Path
+}
+
+// Raw returns the path (relative to the watched ref) and the raw JSON data of
+// a put or patch event.
+func (e *Event) Raw() (string, json.RawMessage, error) {
+	env, err := e.envelope()
+	if err != nil {
+		return "", nil, err
+	}
+	return env.Path, env.Data, nil
+}
+
+// Decode decodes the data of a put or patch event into v, returning the path
+// (relative to the watched ref).
+//
+// For put events, the data is the complete new value at the path (null when
+// removed). For patch events, the data is an object of the children updated
+// at the path, keyed by their relative paths; see Patch.
+func (e *Event) Decode(v interface{}) (string, error) {
+	env, err := e.envelope()
+	if err != nil {
+		return "", err
+	}
+
+	unmarshal := json.Unmarshal
+	if e.unmarshal != nil {
+		unmarshal = e.unmarshal
+	}
+	err = unmarshal(env.Data, v)
+	if err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	return env.Path, nil
+}
+
+// Patch returns the changes of a put or patch event as a map of absolute
+// paths (relative to the watched ref) to their raw JSON values, where a null
+// value indicates the path was removed.
+//
+// A put event is returned as a single change at the event path.
+func (e *Event) Patch() (map[string]json.RawMessage, error) {
+	env, err := e.envelope()
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Type == EventTypePut {
+		return map[string]json.RawMessage{
+			joinPath(env.Path, ""): env.Data,
+		}, nil
+	}
+
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(env.Data, &m)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not decode patch data: %v", err),
+		}
+	}
+
+	changes := make(map[string]json.RawMessage, len(m))
+	for k, v := range m {
+		changes[joinPath(env.Path, k)] = v
+	}
+
+	return changes, nil
+}
+
+// PatchPaths returns the sorted absolute paths of a Patch.
+func PatchPaths(changes map[string]json.RawMessage) []string {
+	paths := make([]string, 0, len(changes))
+	for k := range changes {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
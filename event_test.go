@@ -0,0 +1,31 @@
+package firebase
+
+import "testing"
+
+func TestEventPatch(t *testing.T) {
+	ev := &Event{
+		Type: EventTypePatch,
+		Data: []byte(`{"path":"/users","data":{"a/name":"alice","b":null}}`),
+	}
+
+	if p := ev.Path(); p != "/users" {
+		t.Errorf("expected path /users, got: %s", p)
+	}
+
+	changes, err := ev.Patch()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	paths := PatchPaths(changes)
+	if len(paths) != 2 || paths[0] != "/users/a/name" || paths[1] != "/users/b" {
+		t.Errorf("expected [/users/a/name /users/b], got: %v", paths)
+	}
+	if string(changes["/users/b"]) != "null" {
+		t.Errorf("expected null, got: %s", changes["/users/b"])
+	}
+
+	var m map[string]interface{}
+	if _, err = ev.Decode(&m); err != nil || m["a/name"] != "alice" {
+		t.Errorf("expected decoded patch data, got: %v (%v)", m, err)
+	}
+}
@@ -0,0 +1,206 @@
+// Package fcm provides a Firebase Cloud Messaging (FCM) client for sending
+// messages via the FCM HTTP v1 API.
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/knq/firebase/internal/gcreds"
+)
+
+const (
+	// DefaultEndpoint is the default FCM HTTP v1 API endpoint.
+	DefaultEndpoint = "https://fcm.googleapis.com/v1"
+)
+
+// Notification is the basic notification template used across all platforms.
+type Notification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+// Message is a FCM message. Exactly one of Token, Topic, or Condition must be
+// set.
+//
+// Platform specific configuration (Android, APNS, Webpush) is passed through
+// as-is, and should match the structure documented for the FCM HTTP v1 API.
+type Message struct {
+	// Token is the registration token of the target device.
+	Token string `json:"token,omitempty"`
+
+	// Topic is the name of the target topic (ie, "news").
+	Topic string `json:"topic,omitempty"`
+
+	// Condition is the target condition (ie, "'a' in topics && 'b' in
+	// topics").
+	Condition string `json:"condition,omitempty"`
+
+	// Notification is the notification to display.
+	Notification *Notification `json:"notification,omitempty"`
+
+	// Data is the arbitrary data payload.
+	Data map[string]string `json:"data,omitempty"`
+
+	Android map[string]interface{} `json:"android,omitempty"`
+	APNS    map[string]interface{} `json:"apns,omitempty"`
+	Webpush map[string]interface{} `json:"webpush,omitempty"`
+
+	// FCMOptions are the platform independent FCM options.
+	FCMOptions map[string]interface{} `json:"fcm_options,omitempty"`
+}
+
+// validate checks that the message has exactly one target.
+func (m *Message) validate() error {
+	var n int
+	for _, s := range []string{m.Token, m.Topic, m.Condition} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("message must have exactly one of token, topic, or condition")
+	}
+	return nil
+}
+
+// Error is a FCM API error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Error satisfies the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("fcm: %s (%s)", e.Message, e.Status)
+}
+
+// Client is a FCM client.
+type Client struct {
+	cfg gcreds.Config
+}
+
+// New creates a new FCM client using the supplied options.
+func New(opts ...Option) (*Client, error) {
+	var err error
+
+	c := &Client{
+		cfg: gcreds.Config{
+			Endpoint: DefaultEndpoint,
+		},
+	}
+
+	// apply opts
+	for _, o := range opts {
+		err = o(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.cfg.ProjectID == "" {
+		return nil, errors.New("no project id specified")
+	}
+
+	return c, nil
+}
+
+// Send sends the message, returning the message name (ID) assigned by FCM.
+func (c *Client) Send(ctxt context.Context, msg *Message) (string, error) {
+	return c.send(ctxt, msg, false)
+}
+
+// Validate validates the message with FCM without delivering it.
+func (c *Client) Validate(ctxt context.Context, msg *Message) error {
+	_, err := c.send(ctxt, msg, true)
+	return err
+}
+
+// send sends the message.
+func (c *Client) send(ctxt context.Context, msg *Message, validateOnly bool) (string, error) {
+	var err error
+
+	if err = msg.validate(); err != nil {
+		return "", err
+	}
+
+	// encode
+	buf, err := json.Marshal(map[string]interface{}{
+		"message":       msg,
+		"validate_only": validateOnly,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not marshal json: %v", err)
+	}
+
+	// create request
+	req, err := http.NewRequest("POST", c.cfg.Endpoint+"/projects/"+url.PathEscape(c.cfg.ProjectID)+"/messages:send", bytes.NewReader(buf))
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// execute
+	res, err := c.cfg.HTTPClient().Do(req.WithContext(ctxt))
+	if err != nil {
+		return "", fmt.Errorf("could not execute request: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not read response: %v", err)
+	}
+
+	// check error
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e struct {
+			Error *Error `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != nil {
+			return "", e.Error
+		}
+		return "", &Error{
+			Code:    res.StatusCode,
+			Message: string(body),
+			Status:  res.Status,
+		}
+	}
+
+	// decode
+	var v struct {
+		Name string `json:"name"`
+	}
+	err = json.Unmarshal(body, &v)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal json: %v", err)
+	}
+
+	return v.Name, nil
+}
+
+// SendToToken sends a notification and data payload to the device with the
+// registration token.
+func (c *Client) SendToToken(ctxt context.Context, token string, n *Notification, data map[string]string) (string, error) {
+	return c.Send(ctxt, &Message{Token: token, Notification: n, Data: data})
+}
+
+// SendToTopic sends a notification and data payload to the devices
+// subscribed to topic.
+func (c *Client) SendToTopic(ctxt context.Context, topic string, n *Notification, data map[string]string) (string, error) {
+	return c.Send(ctxt, &Message{Topic: topic, Notification: n, Data: data})
+}
+
+// SendToCondition sends a notification and data payload to the devices
+// matching the topic condition.
+func (c *Client) SendToCondition(ctxt context.Context, condition string, n *Notification, data map[string]string) (string, error) {
+	return c.Send(ctxt, &Message{Condition: condition, Notification: n, Data: data})
+}
@@ -0,0 +1,111 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestSend(t *testing.T) {
+	var reqs []map[string]interface{}
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || req.URL.Path != "/projects/test/messages:send" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		auth = req.Header.Get("Authorization")
+		var v map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqs = append(reqs, v)
+		w.Write([]byte(`{"name":"projects/test/messages/1"}`))
+	}))
+	defer ts.Close()
+
+	c, err := New(
+		ProjectID("test"),
+		Endpoint(ts.URL),
+		TokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctxt := context.Background()
+
+	name, err := c.SendToTopic(ctxt, "news", &Notification{Title: "hello"}, map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if name != "projects/test/messages/1" {
+		t.Errorf("expected message name, got: %q", name)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("expected bearer token, got: %q", auth)
+	}
+	msg, _ := reqs[0]["message"].(map[string]interface{})
+	if msg["topic"] != "news" || msg["notification"].(map[string]interface{})["title"] != "hello" || msg["data"].(map[string]interface{})["a"] != "b" {
+		t.Errorf("unexpected message: %v", reqs[0])
+	}
+	if reqs[0]["validate_only"] != false {
+		t.Errorf("expected validate_only false, got: %v", reqs[0]["validate_only"])
+	}
+
+	// validate only
+	if err = c.Validate(ctxt, &Message{Token: "device"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if msg, _ = reqs[1]["message"].(map[string]interface{}); msg["token"] != "device" || reqs[1]["validate_only"] != true {
+		t.Errorf("unexpected request: %v", reqs[1])
+	}
+
+	// invalid targets are not sent
+	for i, m := range []*Message{{}, {Token: "a", Topic: "b"}, {Topic: "a", Condition: "b"}} {
+		if _, err = c.Send(ctxt, m); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+	if len(reqs) != 2 {
+		t.Errorf("expected 2 requests, got: %d", len(reqs))
+	}
+}
+
+func TestSendError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var v struct {
+			Message Message `json:"message"`
+		}
+		json.NewDecoder(req.Body).Decode(&v)
+		if v.Message.Token == "stale" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`))
+			return
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c, err := New(ProjectID("test"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	_, err = c.SendToToken(context.Background(), "stale", nil, nil)
+	if e, ok := err.(*Error); !ok || e.Code != 404 || e.Status != "NOT_FOUND" {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+	_, err = c.SendToCondition(context.Background(), "'a' in topics", nil, nil)
+	if e, ok := err.(*Error); !ok || e.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected unavailable error, got: %v", err)
+	}
+
+	if _, err = New(); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,115 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	// DefaultFieldConcurrency is the default number of concurrent requests
+	// issued by GetFields and GetFieldsForKeys.
+	DefaultFieldConcurrency = 8
+)
+
+// GetFields retrieves only the specified child fields of each child of
+// Firebase database ref r, decoding the assembled sparse result into d.
+//
+// The child keys are first retrieved using a shallow query, after which the
+// fields for each key are retrieved using parallel requests. Fields may be
+// nested paths (ie, "profile/name"). Children missing a field are assembled
+// without the field.
+//
+// This approximates a field mask, which the Firebase REST API lacks, and can
+// greatly reduce the data transferred when children are large but only a few
+// fields are needed.
+func GetFields(r *DatabaseRef, d interface{}, fields []string, opts ...QueryOption) error {
+	// retrieve keys
+	var shallow map[string]interface{}
+	err := Get(r, &shallow, append([]QueryOption{Shallow}, opts...)...)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(shallow))
+	for k := range shallow {
+		keys = append(keys, k)
+	}
+
+	return GetFieldsForKeys(r, d, keys, fields, opts...)
+}
+
+// GetFieldsForKeys retrieves only the specified child fields of the children
+// with keys of Firebase database ref r, decoding the assembled sparse result
+// into d.
+//
+// GetFieldsForKeys is useful when the keys have been retrieved using an index
+// query, or are already known.
+func GetFieldsForKeys(r *DatabaseRef, d interface{}, keys, fields []string, opts ...QueryOption) error {
+	type job struct {
+		key, field string
+	}
+
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var firstErr error
+	var root interface{} = map[string]interface{}{}
+
+	// start workers
+	var wg sync.WaitGroup
+	for i := 0; i < DefaultFieldConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var buf json.RawMessage
+				err := Get(r.Ref(j.key+"/"+j.field), &buf, opts...)
+
+				var v interface{}
+				if err == nil {
+					v, err = decodeJSON(buf)
+				}
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil && v != nil {
+					root = treeSet(root, append([]string{j.key}, splitPath(j.field)...), v)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// queue
+	for _, k := range keys {
+		for _, f := range fields {
+			jobs <- job{k, f}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// decode assembled result
+	buf, err := json.Marshal(root)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	err = dec.Decode(d)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package firebase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetFields(t *testing.T) {
+	s := &memServer{}
+	s.set("/users/a", map[string]interface{}{
+		"name":    "alice",
+		"bio":     "a long biography",
+		"profile": map[string]interface{}{"age": 30, "photo": "large"},
+	})
+	s.set("/users/b", map[string]interface{}{"name": "bob"})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var d map[string]interface{}
+	if err = GetFields(r.Ref("users"), &d, []string{"name", "profile/age"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := map[string]interface{}{
+		"a": map[string]interface{}{"name": "alice", "profile": map[string]interface{}{"age": json.Number("30")}},
+		"b": map[string]interface{}{"name": "bob"},
+	}
+	if !reflect.DeepEqual(d, exp) {
+		t.Errorf("expected %v, got: %v", exp, d)
+	}
+
+	// only the shallow keys and the requested fields are retrieved
+	s.Lock()
+	reqs := append([]string{}, s.reqs...)
+	s.reqs = nil
+	s.Unlock()
+	sort.Strings(reqs)
+	expReqs := []string{
+		"GET /users",
+		"GET /users/a/name",
+		"GET /users/a/profile/age",
+		"GET /users/b/name",
+		"GET /users/b/profile/age",
+	}
+	if !reflect.DeepEqual(reqs, expReqs) {
+		t.Errorf("expected requests %v, got: %v", expReqs, reqs)
+	}
+
+	// explicit keys
+	var u map[string]struct {
+		Name string `json:"name"`
+	}
+	if err = GetFieldsForKeys(r.Ref("users"), &u, []string{"b", "c"}, []string{"name"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(u) != 1 || u["b"].Name != "bob" {
+		t.Errorf("expected only user b, got: %v", u)
+	}
+	s.Lock()
+	defer s.Unlock()
+	if len(s.reqs) != 2 {
+		t.Errorf("expected 2 requests, got: %v", s.reqs)
+	}
+}
+
+func TestGetFieldsError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/users/b/name.json" {
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`"x"`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var d map[string]interface{}
+	err = GetFieldsForKeys(r.Ref("users"), &d, []string{"a", "b", "c"}, []string{"name"})
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized error, got: %v", err)
+	}
+	if d != nil {
+		t.Errorf("expected no result, got: %v", d)
+	}
+}
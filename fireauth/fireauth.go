@@ -0,0 +1,389 @@
+// Package fireauth provides a Firebase Authentication admin client for
+// managing users via the Google Identity Toolkit REST API.
+package fireauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/knq/firebase/internal/gcreds"
+)
+
+const (
+	// DefaultEndpoint is the default Identity Toolkit API endpoint.
+	DefaultEndpoint = "https://identitytoolkit.googleapis.com/v1"
+
+	// DefaultPageSize is the default page size used by ListUsers.
+	DefaultPageSize = 1000
+)
+
+// User is a Firebase Authentication user.
+type User struct {
+	UID              string `json:"localId"`
+	Email            string `json:"email,omitempty"`
+	EmailVerified    bool   `json:"emailVerified,omitempty"`
+	DisplayName      string `json:"displayName,omitempty"`
+	PhotoURL         string `json:"photoUrl,omitempty"`
+	PhoneNumber      string `json:"phoneNumber,omitempty"`
+	Disabled         bool   `json:"disabled,omitempty"`
+	CreatedAt        string `json:"createdAt,omitempty"`
+	LastLoginAt      string `json:"lastLoginAt,omitempty"`
+	CustomAttributes string `json:"customAttributes,omitempty"`
+	TenantID         string `json:"tenantId,omitempty"`
+}
+
+// CustomClaims decodes the user's custom claims.
+func (u *User) CustomClaims() (map[string]interface{}, error) {
+	if u.CustomAttributes == "" {
+		return nil, nil
+	}
+
+	var claims map[string]interface{}
+	err := json.Unmarshal([]byte(u.CustomAttributes), &claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CreateUserParams are the parameters for creating a user. If UID is empty,
+// then a UID will be assigned by the server.
+type CreateUserParams struct {
+	UID           string `json:"localId,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified,omitempty"`
+	Password      string `json:"password,omitempty"`
+	DisplayName   string `json:"displayName,omitempty"`
+	PhotoURL      string `json:"photoUrl,omitempty"`
+	PhoneNumber   string `json:"phoneNumber,omitempty"`
+	Disabled      bool   `json:"disabled,omitempty"`
+}
+
+// UpdateUserParams are the parameters for updating a user. Only non-nil
+// fields are updated. Setting DisplayName, PhotoURL, or PhoneNumber to the
+// empty string removes the attribute from the user.
+type UpdateUserParams struct {
+	Email         *string
+	EmailVerified *bool
+	Password      *string
+	DisplayName   *string
+	PhotoURL      *string
+	PhoneNumber   *string
+	Disabled      *bool
+	CustomClaims  map[string]interface{}
+}
+
+// Error is a Identity Toolkit API error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Error satisfies the error interface.
+func (e *Error) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("fireauth: %s (%s)", e.Message, e.Status)
+	}
+	return "fireauth: " + e.Message
+}
+
+// ErrUserNotFound is the error returned when a user does not exist.
+var ErrUserNotFound = errors.New("fireauth: user not found")
+
+// Client is a Firebase Authentication admin client.
+type Client struct {
+	cfg gcreds.Config
+
+	keys *keyCache
+
+	// signer, clientEmail, and keyID are the service account private key,
+	// email, and key ID used to sign custom tokens.
+	signer      *rsa.PrivateKey
+	clientEmail string
+	keyID       string
+}
+
+// New creates a new Firebase Authentication admin client using the supplied
+// options.
+func New(opts ...Option) (*Client, error) {
+	var err error
+
+	c := &Client{
+		cfg: gcreds.Config{
+			Endpoint: DefaultEndpoint,
+		},
+		keys: &keyCache{
+			url: DefaultPublicKeysURL,
+		},
+	}
+
+	// apply opts
+	for _, o := range opts {
+		err = o(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.cfg.ProjectID == "" {
+		return nil, errors.New("no project id specified")
+	}
+
+	return c, nil
+}
+
+// do executes a request against the Identity Toolkit API resource for the
+// project, encoding v as the JSON request body and decoding the response to
+// d.
+func (c *Client) do(ctxt context.Context, method, resource string, v, d interface{}) error {
+	var err error
+
+	// encode
+	var body io.Reader
+	if v != nil {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("could not marshal json: %v", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	// create request
+	req, err := http.NewRequest(method, c.cfg.Endpoint+"/projects/"+url.PathEscape(c.cfg.ProjectID)+resource, body)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// execute
+	res, err := c.cfg.HTTPClient().Do(req.WithContext(ctxt))
+	if err != nil {
+		return fmt.Errorf("could not execute request: %v", err)
+	}
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+
+	// check error
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e struct {
+			Error *Error `json:"error"`
+		}
+		if json.Unmarshal(buf, &e) == nil && e.Error != nil {
+			if e.Error.Message == "USER_NOT_FOUND" {
+				return ErrUserNotFound
+			}
+			return e.Error
+		}
+		return &Error{
+			Code:    res.StatusCode,
+			Message: string(buf),
+			Status:  res.Status,
+		}
+	}
+
+	// decode
+	if d != nil {
+		err = json.Unmarshal(buf, d)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal json: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// CreateUser creates a user, returning the created user.
+func (c *Client) CreateUser(ctxt context.Context, params *CreateUserParams) (*User, error) {
+	var res struct {
+		UID string `json:"localId"`
+	}
+	err := c.do(ctxt, "POST", "/accounts", params, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetUser(ctxt, res.UID)
+}
+
+// lookup retrieves the first user matching the lookup request.
+func (c *Client) lookup(ctxt context.Context, req map[string]interface{}) (*User, error) {
+	var res struct {
+		Users []*User `json:"users"`
+	}
+	err := c.do(ctxt, "POST", "/accounts:lookup", req, &res)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Users) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return res.Users[0], nil
+}
+
+// GetUser retrieves the user with the uid.
+func (c *Client) GetUser(ctxt context.Context, uid string) (*User, error) {
+	return c.lookup(ctxt, map[string]interface{}{
+		"localId": []string{uid},
+	})
+}
+
+// GetUserByEmail retrieves the user with the email address.
+func (c *Client) GetUserByEmail(ctxt context.Context, email string) (*User, error) {
+	return c.lookup(ctxt, map[string]interface{}{
+		"email": []string{email},
+	})
+}
+
+// GetUserByPhoneNumber retrieves the user with the phone number.
+func (c *Client) GetUserByPhoneNumber(ctxt context.Context, phoneNumber string) (*User, error) {
+	return c.lookup(ctxt, map[string]interface{}{
+		"phoneNumber": []string{phoneNumber},
+	})
+}
+
+// UpdateUser updates the user with the uid, returning the updated user.
+func (c *Client) UpdateUser(ctxt context.Context, uid string, params *UpdateUserParams) (*User, error) {
+	req := map[string]interface{}{
+		"localId": uid,
+	}
+
+	var deleteAttrs, deleteProviders []string
+	if params.Email != nil {
+		req["email"] = *params.Email
+	}
+	if params.EmailVerified != nil {
+		req["emailVerified"] = *params.EmailVerified
+	}
+	if params.Password != nil {
+		req["password"] = *params.Password
+	}
+	if params.DisplayName != nil {
+		if *params.DisplayName == "" {
+			deleteAttrs = append(deleteAttrs, "DISPLAY_NAME")
+		} else {
+			req["displayName"] = *params.DisplayName
+		}
+	}
+	if params.PhotoURL != nil {
+		if *params.PhotoURL == "" {
+			deleteAttrs = append(deleteAttrs, "PHOTO_URL")
+		} else {
+			req["photoUrl"] = *params.PhotoURL
+		}
+	}
+	if params.PhoneNumber != nil {
+		if *params.PhoneNumber == "" {
+			deleteProviders = append(deleteProviders, "phone")
+		} else {
+			req["phoneNumber"] = *params.PhoneNumber
+		}
+	}
+	if params.Disabled != nil {
+		req["disableUser"] = *params.Disabled
+	}
+	if params.CustomClaims != nil {
+		buf, err := json.Marshal(params.CustomClaims)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal custom claims: %v", err)
+		}
+		req["customAttributes"] = string(buf)
+	}
+	if deleteAttrs != nil {
+		req["deleteAttribute"] = deleteAttrs
+	}
+	if deleteProviders != nil {
+		req["deleteProvider"] = deleteProviders
+	}
+
+	err := c.do(ctxt, "POST", "/accounts:update", req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetUser(ctxt, uid)
+}
+
+// SetCustomClaims sets the custom claims for the user with the uid.
+func (c *Client) SetCustomClaims(ctxt context.Context, uid string, claims map[string]interface{}) error {
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+	_, err := c.UpdateUser(ctxt, uid, &UpdateUserParams{
+		CustomClaims: claims,
+	})
+	return err
+}
+
+// DeleteUser deletes the user with the uid.
+func (c *Client) DeleteUser(ctxt context.Context, uid string) error {
+	return c.do(ctxt, "POST", "/accounts:delete", map[string]interface{}{
+		"localId": uid,
+	}, nil)
+}
+
+// ListUsers retrieves a page of up to pageSize users, starting at pageToken.
+// Returns the users and the token for the next page, which is empty when
+// there are no more users.
+//
+// If pageSize is 0, then DefaultPageSize is used.
+func (c *Client) ListUsers(ctxt context.Context, pageSize int, pageToken string) ([]*User, string, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	q := url.Values{}
+	q.Set("maxResults", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		q.Set("nextPageToken", pageToken)
+	}
+
+	var res struct {
+		Users         []*User `json:"users"`
+		NextPageToken string  `json:"nextPageToken"`
+	}
+	err := c.do(ctxt, "GET", "/accounts:batchGet?"+q.Encode(), nil, &res)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return res.Users, res.NextPageToken, nil
+}
+
+// ForEachUser calls f for each user, paging through all users. Iteration
+// stops if f returns an error, which is then returned.
+func (c *Client) ForEachUser(ctxt context.Context, f func(*User) error) error {
+	var token string
+	for {
+		users, next, err := c.ListUsers(ctxt, 0, token)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			if err = f(u); err != nil {
+				return err
+			}
+		}
+
+		if next == "" || len(users) == 0 {
+			return nil
+		}
+		token = next
+	}
+}
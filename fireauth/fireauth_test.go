@@ -0,0 +1,262 @@
+package fireauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// identityServer is a test Identity Toolkit API server.
+type identityServer struct {
+	sync.Mutex
+	users map[string]*User
+	reqs  []map[string]interface{}
+}
+
+func (s *identityServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	var body map[string]interface{}
+	if req.Method == "POST" {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":{"code":400,"message":"INVALID_JSON","status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+			return
+		}
+		s.reqs = append(s.reqs, body)
+	}
+	notFound := func() {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"USER_NOT_FOUND"}}`))
+	}
+
+	var res interface{}
+	switch req.URL.Path {
+	case "/projects/test/accounts":
+		uid, _ := body["localId"].(string)
+		if uid == "" {
+			uid = fmt.Sprintf("uid%d", len(s.users))
+		}
+		email, _ := body["email"].(string)
+		s.users[uid] = &User{UID: uid, Email: email}
+		res = map[string]string{"localId": uid}
+
+	case "/projects/test/accounts:lookup":
+		var users []*User
+		for _, u := range s.users {
+			ids, _ := body["localId"].([]interface{})
+			for _, id := range ids {
+				if u.UID == id {
+					users = append(users, u)
+				}
+			}
+		}
+		res = map[string]interface{}{"users": users}
+
+	case "/projects/test/accounts:update":
+		u, ok := s.users[body["localId"].(string)]
+		if !ok {
+			notFound()
+			return
+		}
+		if v, ok := body["displayName"].(string); ok {
+			u.DisplayName = v
+		}
+		if v, ok := body["customAttributes"].(string); ok {
+			u.CustomAttributes = v
+		}
+		if v, ok := body["disableUser"].(bool); ok {
+			u.Disabled = v
+		}
+		attrs, _ := body["deleteAttribute"].([]interface{})
+		for _, attr := range attrs {
+			if attr == "DISPLAY_NAME" {
+				u.DisplayName = ""
+			}
+		}
+		res = map[string]string{"localId": u.UID}
+
+	case "/projects/test/accounts:delete":
+		uid := body["localId"].(string)
+		if _, ok := s.users[uid]; !ok {
+			notFound()
+			return
+		}
+		delete(s.users, uid)
+		res = map[string]string{}
+
+	case "/projects/test/accounts:batchGet":
+		var uids []string
+		for uid := range s.users {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		n, _ := strconv.Atoi(req.URL.Query().Get("maxResults"))
+		start, _ := strconv.Atoi(req.URL.Query().Get("nextPageToken"))
+		end, next := start+n, ""
+		if end < len(uids) {
+			next = strconv.Itoa(end)
+		} else {
+			end = len(uids)
+		}
+		var users []*User
+		for _, uid := range uids[start:end] {
+			users = append(users, s.users[uid])
+		}
+		res = map[string]interface{}{"users": users, "nextPageToken": next}
+
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+func TestUsers(t *testing.T) {
+	s := &identityServer{users: make(map[string]*User)}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := New(ProjectID("test"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctxt := context.Background()
+
+	// create
+	u, err := c.CreateUser(ctxt, &CreateUserParams{UID: "alice", Email: "alice@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if u.UID != "alice" || u.Email != "alice@example.com" {
+		t.Errorf("unexpected user: %+v", u)
+	}
+	if s.reqs[0]["password"] != "secret" {
+		t.Errorf("expected password in request, got: %v", s.reqs[0])
+	}
+
+	// update
+	name := "Alice"
+	disabled := true
+	if u, err = c.UpdateUser(ctxt, "alice", &UpdateUserParams{DisplayName: &name, Disabled: &disabled}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if u.DisplayName != "Alice" || !u.Disabled {
+		t.Errorf("unexpected user: %+v", u)
+	}
+	empty := ""
+	if u, err = c.UpdateUser(ctxt, "alice", &UpdateUserParams{DisplayName: &empty}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if u.DisplayName != "" {
+		t.Errorf("expected display name to be removed, got: %+v", u)
+	}
+
+	// custom claims
+	if err = c.SetCustomClaims(ctxt, "alice", map[string]interface{}{"admin": true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if u, err = c.GetUser(ctxt, "alice"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	claims, err := u.CustomClaims()
+	if err != nil || claims["admin"] != true {
+		t.Errorf("expected admin claim, got: %v, %v", claims, err)
+	}
+
+	// not found
+	if _, err = c.GetUser(ctxt, "bob"); err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got: %v", err)
+	}
+	if err = c.DeleteUser(ctxt, "bob"); err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got: %v", err)
+	}
+
+	// delete
+	if err = c.DeleteUser(ctxt, "alice"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err = c.GetUser(ctxt, "alice"); err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	s := &identityServer{users: make(map[string]*User)}
+	for i := 0; i < 5; i++ {
+		uid := fmt.Sprintf("u%d", i)
+		s.users[uid] = &User{UID: uid}
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := New(ProjectID("test"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctxt := context.Background()
+
+	users, next, err := c.ListUsers(ctxt, 2, "")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(users) != 2 || users[0].UID != "u0" || next != "2" {
+		t.Errorf("unexpected page: %v, %q", users, next)
+	}
+
+	var uids []string
+	err = c.ForEachUser(ctxt, func(u *User) error {
+		uids = append(uids, u.UID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if fmt.Sprint(uids) != "[u0 u1 u2 u3 u4]" {
+		t.Errorf("unexpected users: %v", uids)
+	}
+
+	// stop
+	stop := fmt.Errorf("stop")
+	if err = c.ForEachUser(ctxt, func(*User) error { return stop }); err != stop {
+		t.Errorf("expected stop error, got: %v", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/projects/test/accounts:lookup":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"PERMISSION_DENIED","status":"PERMISSION_DENIED"}}`))
+		default:
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ProjectID("test"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	_, err = c.GetUser(context.Background(), "alice")
+	if e, ok := err.(*Error); !ok || e.Code != 403 || e.Message != "PERMISSION_DENIED" {
+		t.Errorf("expected permission denied error, got: %v", err)
+	}
+	err = c.DeleteUser(context.Background(), "alice")
+	if e, ok := err.(*Error); !ok || e.Code != http.StatusBadGateway {
+		t.Errorf("expected bad gateway error, got: %v", err)
+	}
+
+	if _, err = New(); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,107 @@
+package fireauth
+
+import (
+	"net/http"
+
+	"golang.org/x/oauth2"
+
+	"github.com/knq/firebase/internal/gcreds"
+)
+
+// requiredScopes are the oauth2 scopes required when using Google service
+// accounts with the Identity Toolkit API.
+var requiredScopes = []string{
+	"https://www.googleapis.com/auth/identitytoolkit",
+	"https://www.googleapis.com/auth/cloud-platform",
+}
+
+// Option is an option to modify an auth client.
+type Option func(c *Client) error
+
+// ProjectID is an option that sets the Firebase project ID used by the auth
+// client.
+func ProjectID(projectID string) Option {
+	return func(c *Client) error {
+		return c.cfg.SetProjectID(projectID)
+	}
+}
+
+// Endpoint is an option that sets the Identity Toolkit API endpoint.
+func Endpoint(endpoint string) Option {
+	return func(c *Client) error {
+		c.cfg.Endpoint = endpoint
+		return nil
+	}
+}
+
+// PublicKeysURL is an option that sets the URL of the x509 public keys used
+// to verify ID tokens.
+func PublicKeysURL(keysURL string) Option {
+	return func(c *Client) error {
+		c.keys.url = keysURL
+		return nil
+	}
+}
+
+// Transport is an option to set the underlying HTTP transport used when making
+// requests against the Identity Toolkit API.
+func Transport(roundTripper http.RoundTripper) Option {
+	return func(c *Client) error {
+		c.cfg.Transport = roundTripper
+		return nil
+	}
+}
+
+// TokenSource is an option that sets the oauth2 token source used to
+// authorize requests.
+func TokenSource(source oauth2.TokenSource) Option {
+	return func(c *Client) error {
+		c.cfg.Source = source
+		return nil
+	}
+}
+
+// GoogleServiceAccountCredentialsJSON is an option that loads Google Service
+// Account credentials for use with the auth client from a JSON encoded buf.
+func GoogleServiceAccountCredentialsJSON(buf []byte) Option {
+	return func(c *Client) error {
+		gsa, err := c.cfg.ServiceAccountJSON(buf, requiredScopes...)
+		if err != nil {
+			return err
+		}
+
+		// load signing key for custom tokens
+		c.signer, err = parsePrivateKey([]byte(gsa.PrivateKey))
+		if err != nil {
+			return err
+		}
+		c.clientEmail, c.keyID = gsa.ClientEmail, gsa.PrivateKeyID
+
+		return nil
+	}
+}
+
+// GoogleServiceAccountCredentialsFile is an option that loads Google Service
+// Account credentials for use with the auth client from the specified file.
+func GoogleServiceAccountCredentialsFile(path string) Option {
+	return func(c *Client) error {
+		buf, err := gcreds.ReadServiceAccountFile(path)
+		if err != nil {
+			return err
+		}
+		return GoogleServiceAccountCredentialsJSON(buf)(c)
+	}
+}
+
+// GoogleComputeCredentials is an option that uses the Google Service Account
+// credentials from the GCE metadata associated with the GCE compute instance.
+// If serviceAccount is empty, then the default service account credentials
+// associated with the GCE instance will be used.
+//
+// The ProjectID option must also be supplied.
+func GoogleComputeCredentials(serviceAccount string) Option {
+	return func(c *Client) error {
+		c.cfg.Compute(serviceAccount)
+		return nil
+	}
+}
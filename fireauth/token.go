@@ -0,0 +1,213 @@
+package fireauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// CustomTokenAudience is the audience of Firebase custom tokens.
+	CustomTokenAudience = "https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"
+
+	// MaxCustomTokenExpiration is the maximum expiration of Firebase custom
+	// tokens.
+	MaxCustomTokenExpiration = 1 * time.Hour
+)
+
+// reservedClaims are the claim names reserved by Firebase, which cannot be
+// used as developer claims.
+var reservedClaims = map[string]bool{
+	"acr": true, "amr": true, "at_hash": true, "aud": true, "auth_time": true,
+	"azp": true, "cnf": true, "c_hash": true, "exp": true, "firebase": true,
+	"iat": true, "iss": true, "jti": true, "nbf": true, "nonce": true,
+	"sub": true,
+}
+
+// TokenClaims are the claims of a Firebase custom token, as modified by
+// TokenOptions.
+type TokenClaims struct {
+	// TenantID is the Identity Platform tenant ID of the user.
+	TenantID string
+
+	// Expiration is the token's expiration, relative to its issue time.
+	Expiration time.Duration
+
+	// Registered are additional top-level claims (ie, registered JWT claims
+	// such as "nbf" or "jti").
+	Registered map[string]interface{}
+
+	// Developer are the developer claims made available to security rules as
+	// auth.token (and in ID tokens after sign in).
+	Developer map[string]interface{}
+}
+
+// TokenOption is an option to modify the claims of a custom token.
+type TokenOption func(*TokenClaims) error
+
+// TenantID is a token option that sets the Identity Platform tenant ID of the
+// custom token, for multi-tenant projects.
+func TenantID(tenantID string) TokenOption {
+	return func(tc *TokenClaims) error {
+		if tenantID == "" {
+			return errors.New("tenant id cannot be empty")
+		}
+		tc.TenantID = tenantID
+		return nil
+	}
+}
+
+// Expiration is a token option that sets the expiration of the custom token,
+// which cannot exceed MaxCustomTokenExpiration.
+func Expiration(d time.Duration) TokenOption {
+	return func(tc *TokenClaims) error {
+		if d <= 0 || d > MaxCustomTokenExpiration {
+			return fmt.Errorf("token expiration must be greater than 0 and no more than %v", MaxCustomTokenExpiration)
+		}
+		tc.Expiration = d
+		return nil
+	}
+}
+
+// RegisteredClaim is a token option that adds a top-level claim to the custom
+// token. The claims set by CustomToken (iss, sub, aud, iat, exp, uid,
+// tenant_id, and claims) cannot be overridden.
+func RegisteredClaim(name string, v interface{}) TokenOption {
+	return func(tc *TokenClaims) error {
+		switch name {
+		case "", "iss", "sub", "aud", "iat", "exp", "uid", "tenant_id", "claims":
+			return fmt.Errorf("invalid registered claim %q", name)
+		}
+		if tc.Registered == nil {
+			tc.Registered = make(map[string]interface{})
+		}
+		tc.Registered[name] = v
+		return nil
+	}
+}
+
+// DeveloperClaims is a token option that merges the claims into the custom
+// token's developer claims. Claim names reserved by Firebase cannot be used.
+func DeveloperClaims(claims map[string]interface{}) TokenOption {
+	return func(tc *TokenClaims) error {
+		for k, v := range claims {
+			if reservedClaims[k] {
+				return fmt.Errorf("developer claim %q is reserved", k)
+			}
+			if tc.Developer == nil {
+				tc.Developer = make(map[string]interface{})
+			}
+			tc.Developer[k] = v
+		}
+		return nil
+	}
+}
+
+// CustomToken mints a Firebase custom token for the user uid, signed with the
+// client's Google Service Account credentials, for use with the client SDKs'
+// signInWithCustomToken.
+//
+// The client must have been created with the
+// GoogleServiceAccountCredentialsJSON or GoogleServiceAccountCredentialsFile
+// option.
+func (c *Client) CustomToken(uid string, opts ...TokenOption) (string, error) {
+	if c.signer == nil {
+		return "", errors.New("fireauth: custom tokens require google service account credentials")
+	}
+	if uid == "" || len(uid) > 128 {
+		return "", errors.New("fireauth: uid must be between 1 and 128 characters")
+	}
+
+	// apply options
+	tc := &TokenClaims{
+		Expiration: MaxCustomTokenExpiration,
+	}
+	for _, o := range opts {
+		if err := o(tc); err != nil {
+			return "", err
+		}
+	}
+
+	// build claims
+	now := time.Now()
+	claims := make(map[string]interface{}, len(tc.Registered)+8)
+	for k, v := range tc.Registered {
+		claims[k] = v
+	}
+	claims["iss"] = c.clientEmail
+	claims["sub"] = c.clientEmail
+	claims["aud"] = CustomTokenAudience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(tc.Expiration).Unix()
+	claims["uid"] = uid
+	if tc.TenantID != "" {
+		claims["tenant_id"] = tc.TenantID
+	}
+	if len(tc.Developer) != 0 {
+		claims["claims"] = tc.Developer
+	}
+
+	// encode
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	}
+	if c.keyID != "" {
+		header["kid"] = c.keyID
+	}
+	h, err := encodeSegment(header)
+	if err != nil {
+		return "", err
+	}
+	p, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	// sign
+	sum := sha256.Sum256([]byte(h + "." + p))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.signer, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	return h + "." + p + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// encodeSegment JSON encodes v as a base64 url encoded token segment.
+func encodeSegment(v interface{}) (string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// parsePrivateKey parses a PEM encoded (PKCS#8 or PKCS#1) RSA private key.
+func parsePrivateKey(buf []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
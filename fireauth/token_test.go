@@ -0,0 +1,75 @@
+package fireauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCustomToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c, err := New(ProjectID("test"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// no credentials
+	if _, err = c.CustomToken("uid"); err == nil {
+		t.Errorf("expected error")
+	}
+
+	c.signer, c.clientEmail, c.keyID = key, "svc@test.iam.gserviceaccount.com", "kid"
+	tok, err := c.CustomToken("uid",
+		TenantID("tenant-1"),
+		RegisteredClaim("jti", "abc"),
+		DeveloperClaims(map[string]interface{}{"admin": true}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// verify signature
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got: %d", len(parts))
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sig); err != nil {
+		t.Errorf("expected valid signature, got: %v", err)
+	}
+
+	// check claims
+	var claims struct {
+		Iss      string                 `json:"iss"`
+		Aud      string                 `json:"aud"`
+		UID      string                 `json:"uid"`
+		TenantID string                 `json:"tenant_id"`
+		JTI      string                 `json:"jti"`
+		Claims   map[string]interface{} `json:"claims"`
+	}
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if claims.Iss != c.clientEmail || claims.Aud != CustomTokenAudience || claims.UID != "uid" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if claims.TenantID != "tenant-1" || claims.JTI != "abc" || claims.Claims["admin"] != true {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	// reserved and invalid claims
+	if _, err = c.CustomToken("uid", DeveloperClaims(map[string]interface{}{"aud": 1})); err == nil {
+		t.Errorf("expected error")
+	}
+	if _, err = c.CustomToken("uid", RegisteredClaim("uid", "x")); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,241 @@
+package fireauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPublicKeysURL is the URL of the Google x509 public keys used to
+	// sign Firebase ID tokens.
+	DefaultPublicKeysURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+
+	// IDTokenIssuerPrefix is the prefix of the issuer of Firebase ID tokens,
+	// which is followed by the project ID.
+	IDTokenIssuerPrefix = "https://securetoken.google.com/"
+
+	// clockSkew is the allowed clock skew when validating token times.
+	clockSkew = 5 * time.Minute
+)
+
+// ErrInvalidIDToken is the error returned when an ID token is malformed, or
+// fails validation.
+var ErrInvalidIDToken = errors.New("fireauth: invalid id token")
+
+// Claims are the decoded claims of a verified Firebase ID token.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	AuthTime  int64  `json:"auth_time"`
+
+	// UID is the user's uid (same as Subject).
+	UID string `json:"-"`
+
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	PhoneNumber   string `json:"phone_number,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+
+	Firebase struct {
+		SignInProvider string                 `json:"sign_in_provider"`
+		Tenant         string                 `json:"tenant,omitempty"`
+		Identities     map[string]interface{} `json:"identities,omitempty"`
+	} `json:"firebase"`
+
+	// Custom contains all claims in the token, including any custom claims
+	// set on the user.
+	Custom map[string]interface{} `json:"-"`
+}
+
+// VerifyIDToken verifies a Firebase ID token, returning the decoded claims.
+//
+// The token's signature is verified against the Google x509 public keys
+// (which are fetched and cached according to the Cache-Control header of the
+// response), and its audience, issuer, and issue, expiry, and auth times are
+// validated against the client's project.
+func (c *Client) VerifyIDToken(ctxt context.Context, token string) (*Claims, error) {
+	var err error
+
+	// split
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+
+	// decode header
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if header.Alg != "RS256" || header.Kid == "" {
+		return nil, fmt.Errorf("%v: unexpected alg %q or missing kid", ErrInvalidIDToken, header.Alg)
+	}
+
+	// get key
+	key, err := c.keys.get(ctxt, c.keysClient(), header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify signature
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig); err != nil {
+		return nil, fmt.Errorf("%v: invalid signature", ErrInvalidIDToken)
+	}
+
+	// decode claims
+	claims := new(Claims)
+	if err = decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if err = decodeSegment(parts[1], &claims.Custom); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	claims.UID = claims.Subject
+
+	// validate
+	now := time.Now()
+	switch {
+	case claims.Audience != c.cfg.ProjectID:
+		return nil, fmt.Errorf("%v: unexpected audience %q", ErrInvalidIDToken, claims.Audience)
+	case claims.Issuer != IDTokenIssuerPrefix+c.cfg.ProjectID:
+		return nil, fmt.Errorf("%v: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case claims.Subject == "" || len(claims.Subject) > 128:
+		return nil, fmt.Errorf("%v: invalid subject", ErrInvalidIDToken)
+	case now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, fmt.Errorf("%v: issued in the future", ErrInvalidIDToken)
+	case now.Add(clockSkew).Before(time.Unix(claims.AuthTime, 0)):
+		return nil, fmt.Errorf("%v: authenticated in the future", ErrInvalidIDToken)
+	case now.Add(-clockSkew).After(time.Unix(claims.ExpiresAt, 0)):
+		return nil, fmt.Errorf("%v: expired", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// keysClient returns a http.Client suitable for retrieving the public keys.
+func (c *Client) keysClient() *http.Client {
+	return &http.Client{
+		Transport: c.cfg.Transport,
+	}
+}
+
+// decodeSegment decodes a base64 url encoded JSON token segment into v.
+func decodeSegment(seg string, v interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// maxAgeRE matches the max-age directive of a Cache-Control header.
+var maxAgeRE = regexp.MustCompile(`max-age=(\d+)`)
+
+// keyCache is a cache of the x509 public keys used to sign ID tokens.
+type keyCache struct {
+	mu sync.Mutex
+
+	url    string
+	keys   map[string]*rsa.PublicKey
+	expiry time.Time
+}
+
+// get returns the public key with the key id, refreshing the cached keys when
+// expired.
+func (kc *keyCache) get(ctxt context.Context, client *http.Client, kid string) (*rsa.PublicKey, error) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if kc.keys == nil || time.Now().After(kc.expiry) {
+		if err := kc.refresh(ctxt, client); err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok := kc.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%v: unknown kid %q", ErrInvalidIDToken, kid)
+	}
+	return key, nil
+}
+
+// refresh retrieves the public keys.
+func (kc *keyCache) refresh(ctxt context.Context, client *http.Client) error {
+	req, err := http.NewRequest("GET", kc.url, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+
+	res, err := client.Do(req.WithContext(ctxt))
+	if err != nil {
+		return fmt.Errorf("could not retrieve public keys: %v", err)
+	}
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read public keys: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not retrieve public keys: %s", res.Status)
+	}
+
+	// decode certificates
+	var certs map[string]string
+	if err = json.Unmarshal(buf, &certs); err != nil {
+		return fmt.Errorf("could not decode public keys: %v", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(certs))
+	for kid, cert := range certs {
+		block, _ := pem.Decode([]byte(cert))
+		if block == nil {
+			return fmt.Errorf("could not decode certificate %q", kid)
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("could not parse certificate %q: %v", kid, err)
+		}
+		key, ok := c.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("certificate %q does not contain a rsa public key", kid)
+		}
+		keys[kid] = key
+	}
+
+	// determine expiry
+	var maxAge int
+	if m := maxAgeRE.FindStringSubmatch(res.Header.Get("Cache-Control")); m != nil {
+		maxAge, _ = strconv.Atoi(m[1])
+	}
+
+	kc.keys, kc.expiry = keys, time.Now().Add(time.Duration(maxAge)*time.Second)
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package fireauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signToken creates a RS256 signed token with the header and claims.
+func signToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	var parts []string
+	for _, v := range []interface{}{header, claims} {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(buf))
+	}
+	h := sha256.Sum256([]byte(strings.Join(parts, ".")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	return strings.Join(append(parts, base64.RawURLEncoding.EncodeToString(sig)), ".")
+}
+
+// idTokenClaims returns valid ID token claims for the project.
+func idTokenClaims(projectID string) map[string]interface{} {
+	now := time.Now().Unix()
+	return map[string]interface{}{
+		"iss":       IDTokenIssuerPrefix + projectID,
+		"aud":       projectID,
+		"sub":       "uid-1",
+		"iat":       now - 60,
+		"exp":       now + 3600,
+		"auth_time": now - 120,
+		"email":     "alice@example.com",
+		"admin":     true,
+		"firebase": map[string]interface{}{
+			"sign_in_provider": "password",
+		},
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	c, err := New(ProjectID("test"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c.keys = &keyCache{
+		keys:   map[string]*rsa.PublicKey{"kid1": &key.PublicKey},
+		expiry: time.Now().Add(time.Hour),
+	}
+
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid1", "typ": "JWT"}
+	claims := func(k string, v interface{}) map[string]interface{} {
+		m := idTokenClaims("test")
+		m[k] = v
+		return m
+	}
+	with := func(k string, v interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		for hk, hv := range header {
+			m[hk] = hv
+		}
+		m[k] = v
+		return m
+	}
+	now := time.Now().Unix()
+	valid := signToken(t, key, header, idTokenClaims("test"))
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		token string
+		err   string
+	}{
+		{valid, ""},
+		{signToken(t, key, header, claims("aud", "other")), "unexpected audience"},
+		{signToken(t, key, header, claims("iss", IDTokenIssuerPrefix+"other")), "unexpected issuer"},
+		{signToken(t, key, header, claims("iss", "https://accounts.google.com")), "unexpected issuer"},
+		{signToken(t, key, header, claims("exp", now-int64(2*clockSkew/time.Second))), "expired"},
+		{signToken(t, key, header, claims("iat", now+int64(2*clockSkew/time.Second))), "issued in the future"},
+		{signToken(t, key, header, claims("auth_time", now+int64(2*clockSkew/time.Second))), "authenticated in the future"},
+		{signToken(t, key, header, claims("sub", "")), "invalid subject"},
+		{signToken(t, key, header, claims("sub", strings.Repeat("x", 129))), "invalid subject"},
+		{signToken(t, key, with("kid", "kid2"), idTokenClaims("test")), "unknown kid"},
+		{signToken(t, key, with("kid", ""), idTokenClaims("test")), "missing kid"},
+		{signToken(t, key, with("alg", "HS256"), idTokenClaims("test")), "unexpected alg"},
+		{signToken(t, key, with("alg", "none"), idTokenClaims("test")), "unexpected alg"},
+		{signToken(t, other, header, idTokenClaims("test")), "invalid signature"},
+		{parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2], "invalid signature"},
+		{parts[0] + "." + parts[1] + ".", "invalid signature"},
+		{parts[0] + "." + parts[1] + ".!", ""},
+		{parts[0] + "." + parts[1], ""},
+		{"", ""},
+	}
+
+	for i, test := range tests {
+		res, err := c.VerifyIDToken(context.Background(), test.token)
+		if i == 0 {
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			if res.UID != "uid-1" || res.Email != "alice@example.com" || res.Firebase.SignInProvider != "password" || res.Custom["admin"] != true {
+				t.Errorf("test %d unexpected claims: %+v", i, res)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("test %d expected error", i)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), ErrInvalidIDToken.Error()) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d expected invalid id token error %q, got: %v", i, test.err, err)
+		}
+		if res != nil {
+			t.Errorf("test %d expected no claims, got: %+v", i, res)
+		}
+	}
+}
+
+func TestPublicKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	certs, _ := json.Marshal(map[string]string{
+		"kid1": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	})
+
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+		w.Write(certs)
+	}))
+	defer ts.Close()
+
+	c, err := New(ProjectID("test"), PublicKeysURL(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid1"}
+	for i := 0; i < 2; i++ {
+		if _, err = c.VerifyIDToken(context.Background(), signToken(t, key, header, idTokenClaims("test"))); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&reqs); n != 1 {
+		t.Errorf("expected keys to be cached, got %d requests", n)
+	}
+
+	// expired cache is refreshed
+	c.keys.expiry = time.Now().Add(-time.Second)
+	header["kid"] = "kid2"
+	if _, err = c.VerifyIDToken(context.Background(), signToken(t, key, header, idTokenClaims("test"))); err == nil || !strings.Contains(err.Error(), "unknown kid") {
+		t.Errorf("expected unknown kid error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&reqs); n != 2 {
+		t.Errorf("expected keys to be refreshed, got %d requests", n)
+	}
+}
@@ -0,0 +1,102 @@
+// Package firebasetest provides isolated, automatically removed Firebase
+// database refs for use in integration tests.
+//
+// For example:
+//
+//	func TestUsers(t *testing.T) {
+//		t.Parallel()
+//		r := firebasetest.Scope(t, db)
+//		/* use r, which is removed when the test completes */
+//	}
+package firebasetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+// CleanupTimeout is the maximum time taken to remove a scoped ref when a test
+// completes.
+var CleanupTimeout = 2 * time.Minute
+
+// Scope returns a ref to a uniquely named child of Firebase database ref r
+// (ie, "TestUsers_sub-<push id>"), removing the child and all of its
+// descendants (see firebase.RemoveRecursive) when the test and its subtests
+// complete, including when the test fails or panics.
+//
+// As each call returns a distinct child, parallel tests using the same ref
+// are isolated from each other.
+func Scope(t testing.TB, r *firebase.DatabaseRef) *firebase.DatabaseRef {
+	t.Helper()
+
+	scoped := r.Ref(Name(t))
+	t.Cleanup(func() {
+		remove(t, scoped)
+	})
+	return scoped
+}
+
+// Emulator returns a ref to a uniquely named namespace (ie,
+// "test-<random hex>") of the Firebase Realtime Database emulator running at
+// host (ie, "localhost:9000"), with the additional options applied. The
+// contents of the namespace are removed when the test and its subtests
+// complete.
+//
+// The test is skipped when host is empty, allowing tests to be conditionally
+// run against an emulator (ie, with the FIREBASE_DATABASE_EMULATOR_HOST
+// environment variable).
+func Emulator(t testing.TB, host string, opts ...firebase.Option) *firebase.DatabaseRef {
+	t.Helper()
+
+	if host == "" {
+		t.Skip("firebase database emulator host not set")
+	}
+
+	// namespaces are limited to lowercase letters, digits, and hyphens
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("could not generate namespace: %v", err)
+	}
+	namespace := "test-" + hex.EncodeToString(buf)
+
+	r, err := firebase.NewDatabaseRef(append([]firebase.Option{firebase.Emulator(host, namespace)}, opts...)...)
+	if err != nil {
+		t.Fatalf("could not create emulator ref: %v", err)
+	}
+	t.Cleanup(func() {
+		remove(t, r)
+	})
+	return r
+}
+
+// Name returns a unique database key for the test, consisting of the
+// sanitized test name and a push id.
+func Name(t testing.TB) string {
+	return sanitize(t.Name()) + "-" + firebase.GeneratePushID()
+}
+
+// remove removes the ref, reporting any error as a test error.
+func remove(t testing.TB, r *firebase.DatabaseRef) {
+	ctxt, cancel := context.WithTimeout(context.Background(), CleanupTimeout)
+	defer cancel()
+	if err := firebase.RemoveRecursiveContext(r, ctxt); err != nil {
+		t.Errorf("could not remove %s: %v", r.URL().Path, err)
+	}
+}
+
+// sanitize replaces the characters not allowed in database keys.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '$', '#', '[', ']', '/', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,64 @@
+package firebasetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestScope(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, req.Method+" "+req.URL.Path)
+		switch {
+		case req.Method == "GET" && len(reqs) == 2:
+			w.Write([]byte(`{"a":true}`))
+		default:
+			w.Write([]byte(`null`))
+		}
+	}))
+	defer ts.Close()
+
+	db, err := firebase.NewDatabaseRef(firebase.URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var path string
+	t.Run("sub test", func(t *testing.T) {
+		r := Scope(t, db.Ref("tests"))
+		path = r.URL().Path
+		if err := r.Ref("a").Set(1); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+
+	if !strings.HasPrefix(path, "/tests/TestScope_sub_test-") || len(path) != len("/tests/TestScope_sub_test-")+20 {
+		t.Errorf("expected unique scoped path, got: %s", path)
+	}
+	exp := []string{
+		"PUT " + path + "/a.json",
+		"GET " + path + ".json",
+		"PATCH " + path + ".json",
+	}
+	if strings.Join(reqs, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("expected requests:\n%s\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(reqs, "\n"))
+	}
+}
+
+func TestEmulatorSkip(t *testing.T) {
+	ok := t.Run("skip", func(t *testing.T) {
+		Emulator(t, "")
+		t.Fatalf("expected test to be skipped")
+	})
+	if !ok {
+		t.Errorf("expected skipped test to pass")
+	}
+}
@@ -0,0 +1,295 @@
+// Package firestore provides a basic Cloud Firestore client for getting,
+// setting, updating, and deleting documents, and querying collections via
+// the Firestore REST API.
+//
+// Document data is converted to and from Firestore typed values via its JSON
+// encoding, such that the same types used with the Firebase Realtime Database
+// can be used with Firestore, easing migrations between the two.
+package firestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/knq/firebase/internal/gcreds"
+)
+
+const (
+	// DefaultEndpoint is the default Firestore API endpoint.
+	DefaultEndpoint = "https://firestore.googleapis.com/v1"
+
+	// DefaultDatabaseID is the default Firestore database ID.
+	DefaultDatabaseID = "(default)"
+)
+
+// Error is a Firestore API error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Error satisfies the error interface.
+func (e *Error) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("firestore: %s (%s)", e.Message, e.Status)
+	}
+	return "firestore: " + e.Message
+}
+
+// ErrNotFound is the error returned when a document does not exist.
+var ErrNotFound = errors.New("firestore: not found")
+
+// Document is a Firestore document.
+type Document struct {
+	// Name is the full resource name of the document.
+	Name string
+
+	// Path is the document path relative to the database root (ie,
+	// "users/alice").
+	Path string
+
+	// Fields are the decoded document fields.
+	Fields map[string]interface{}
+
+	// CreateTime and UpdateTime are the RFC 3339 timestamps of the
+	// document's creation and last update.
+	CreateTime string
+	UpdateTime string
+}
+
+// Decode decodes the document fields into d.
+func (doc *Document) Decode(d interface{}) error {
+	buf, err := json.Marshal(doc.Fields)
+	if err != nil {
+		return fmt.Errorf("could not marshal json: %v", err)
+	}
+	err = json.Unmarshal(buf, d)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal json: %v", err)
+	}
+	return nil
+}
+
+// ID returns the document's ID (ie, the last component of its path).
+func (doc *Document) ID() string {
+	return path.Base(doc.Path)
+}
+
+// rawDocument is an encoded Firestore document.
+type rawDocument struct {
+	Name       string              `json:"name"`
+	Fields     map[string]rawValue `json:"fields"`
+	CreateTime string              `json:"createTime"`
+	UpdateTime string              `json:"updateTime"`
+}
+
+// Client is a Cloud Firestore client.
+type Client struct {
+	cfg        gcreds.Config
+	databaseID string
+}
+
+// New creates a new Firestore client using the supplied options.
+func New(opts ...Option) (*Client, error) {
+	var err error
+
+	c := &Client{
+		databaseID: DefaultDatabaseID,
+		cfg: gcreds.Config{
+			Endpoint: DefaultEndpoint,
+		},
+	}
+
+	// apply opts
+	for _, o := range opts {
+		err = o(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.cfg.ProjectID == "" {
+		return nil, errors.New("no project id specified")
+	}
+
+	return c, nil
+}
+
+// root returns the resource name of the database's document root.
+func (c *Client) root() string {
+	return "projects/" + c.cfg.ProjectID + "/databases/" + c.databaseID + "/documents"
+}
+
+// name returns the resource name of the document path.
+func (c *Client) name(path string) string {
+	return c.root() + "/" + strings.Trim(path, "/")
+}
+
+// do executes a request against the Firestore API resource, encoding v as
+// the JSON request body and decoding the response to d.
+func (c *Client) do(ctxt context.Context, method, resource string, q url.Values, v, d interface{}) error {
+	var err error
+
+	// encode
+	var body io.Reader
+	if v != nil {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("could not marshal json: %v", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	// create request
+	urlstr := c.cfg.Endpoint + "/" + resource
+	if len(q) != 0 {
+		urlstr += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, urlstr, body)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// execute
+	res, err := c.cfg.HTTPClient().Do(req.WithContext(ctxt))
+	if err != nil {
+		return fmt.Errorf("could not execute request: %v", err)
+	}
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+
+	// check error
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e []struct {
+			Error *Error `json:"error"`
+		}
+		if json.Unmarshal(buf, &e) == nil && len(e) != 0 && e[0].Error != nil {
+			return e[0].Error
+		}
+		var e1 struct {
+			Error *Error `json:"error"`
+		}
+		if json.Unmarshal(buf, &e1) == nil && e1.Error != nil {
+			return e1.Error
+		}
+		return &Error{
+			Code:    res.StatusCode,
+			Message: string(buf),
+			Status:  res.Status,
+		}
+	}
+
+	// decode
+	if d != nil {
+		err = json.Unmarshal(buf, d)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal json: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// document converts the raw document.
+func (c *Client) document(raw *rawDocument) (*Document, error) {
+	fields, err := decodeFields(raw.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		Name:       raw.Name,
+		Path:       strings.TrimPrefix(raw.Name, c.root()+"/"),
+		Fields:     fields,
+		CreateTime: raw.CreateTime,
+		UpdateTime: raw.UpdateTime,
+	}, nil
+}
+
+// GetDocument retrieves the document at path (ie, "users/alice").
+func (c *Client) GetDocument(ctxt context.Context, path string) (*Document, error) {
+	raw := new(rawDocument)
+	err := c.do(ctxt, "GET", c.name(path), nil, nil, raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.document(raw)
+}
+
+// Get retrieves the document at path, decoding its fields into d.
+func (c *Client) Get(ctxt context.Context, path string, d interface{}) error {
+	doc, err := c.GetDocument(ctxt, path)
+	if err != nil {
+		return err
+	}
+	return doc.Decode(d)
+}
+
+// Set creates or replaces the document at path with v, which must encode to
+// a JSON object.
+func (c *Client) Set(ctxt context.Context, path string, v interface{}) error {
+	fields, err := encodeFields(v)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctxt, "PATCH", c.name(path), nil, map[string]interface{}{
+		"fields": fields,
+	}, nil)
+}
+
+// Update updates the top-level fields of the existing document at path with
+// the values in v, leaving all other fields unchanged. Returns ErrNotFound if
+// the document does not exist.
+func (c *Client) Update(ctxt context.Context, path string, v map[string]interface{}) error {
+	fields, err := encodeFields(v)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("currentDocument.exists", "true")
+	for k := range v {
+		q.Add("updateMask.fieldPaths", quoteFieldPath(k))
+	}
+
+	return c.do(ctxt, "PATCH", c.name(path), q, map[string]interface{}{
+		"fields": fields,
+	}, nil)
+}
+
+// Delete deletes the document at path. Deleting a non-existent document is
+// not an error.
+func (c *Client) Delete(ctxt context.Context, path string) error {
+	return c.do(ctxt, "DELETE", c.name(path), nil, nil, nil)
+}
+
+// quoteFieldPath quotes a field name for use in a field path, when needed.
+func quoteFieldPath(name string) string {
+	for i, r := range name {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return "`" + strings.Replace(strings.Replace(name, `\`, `\\`, -1), "`", "\\`", -1) + "`"
+		}
+	}
+	return name
+}
@@ -0,0 +1,308 @@
+package firestore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// documentsPath is the path of the test database's document root.
+const documentsPath = "/projects/p/databases/(default)/documents"
+
+// documentServer is a test Firestore API server.
+type documentServer struct {
+	sync.Mutex
+	docs    map[string]map[string]rawValue
+	queries []map[string]interface{}
+	reqs    []string
+}
+
+func (s *documentServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.docs == nil {
+		s.docs = make(map[string]map[string]rawValue)
+	}
+	s.reqs = append(s.reqs, req.Method+" "+req.URL.RequestURI())
+
+	if !strings.HasPrefix(req.URL.Path, documentsPath) {
+		http.Error(w, `{"error":{"code":404,"message":"not found","status":"NOT_FOUND"}}`, http.StatusNotFound)
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	q := req.URL.Query()
+
+	// query
+	if req.Method == "POST" && strings.HasSuffix(name, ":runQuery") {
+		var v struct {
+			StructuredQuery map[string]interface{} `json:"structuredQuery"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+			http.Error(w, `[{"error":{"code":400,"message":"invalid json","status":"INVALID_ARGUMENT"}}]`, http.StatusBadRequest)
+			return
+		}
+		s.queries = append(s.queries, v.StructuredQuery)
+
+		parent := strings.TrimSuffix(name, ":runQuery")
+		from := v.StructuredQuery["from"].([]interface{})[0].(map[string]interface{})
+		prefix := parent + "/" + from["collectionId"].(string) + "/"
+		var names []string
+		for n := range s.docs {
+			if strings.HasPrefix(n, prefix) && !strings.Contains(n[len(prefix):], "/") {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		res := []interface{}{map[string]interface{}{"readTime": "2020-01-01T00:00:00Z"}}
+		for _, n := range names {
+			res = append(res, map[string]interface{}{"document": s.doc(n)})
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+
+	fields, exists := s.docs[name]
+	switch req.Method {
+	case "GET":
+		if !exists {
+			http.Error(w, `{"error":{"code":404,"message":"document not found","status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+
+	case "PATCH":
+		if q.Get("currentDocument.exists") == "true" && !exists {
+			http.Error(w, `{"error":{"code":404,"message":"no document to update","status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		var v struct {
+			Fields map[string]rawValue `json:"fields"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+			http.Error(w, `{"error":{"code":400,"message":"invalid json","status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+			return
+		}
+		mask, ok := q["updateMask.fieldPaths"]
+		if !ok || fields == nil {
+			fields = make(map[string]rawValue)
+		}
+		if !ok {
+			fields = v.Fields
+		}
+		for _, k := range mask {
+			k = strings.Trim(k, "`")
+			if x, ok := v.Fields[k]; ok {
+				fields[k] = x
+			} else {
+				delete(fields, k)
+			}
+		}
+		s.docs[name] = fields
+
+	case "DELETE":
+		delete(s.docs, name)
+		w.Write([]byte(`{}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.doc(name))
+}
+
+// doc returns the encoded document.
+func (s *documentServer) doc(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"fields":     s.docs[name],
+		"createTime": "2020-01-01T00:00:00Z",
+		"updateTime": "2020-01-02T00:00:00Z",
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Errorf("expected error")
+	}
+	if _, err := New(ProjectID("p"), DatabaseID("")); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	s := &documentServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := New(ProjectID("p"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type user struct {
+		Name  string   `json:"name"`
+		Age   int      `json:"age"`
+		Tags  []string `json:"tags"`
+		Email string   `json:"e-mail"`
+	}
+	ctxt := context.Background()
+
+	// set
+	if err = c.Set(ctxt, "/users/alice/", &user{"alice", 30, []string{"a"}, "alice@example.com"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = c.Set(ctxt, "users/bob", "bob"); err == nil {
+		t.Errorf("expected error for non-object document data")
+	}
+
+	// get
+	doc, err := c.GetDocument(ctxt, "users/alice")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if doc.Name != strings.TrimPrefix(documentsPath, "/")+"/users/alice" || doc.Path != "users/alice" || doc.ID() != "alice" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if doc.CreateTime != "2020-01-01T00:00:00Z" || doc.UpdateTime != "2020-01-02T00:00:00Z" {
+		t.Errorf("unexpected document times: %+v", doc)
+	}
+	var u user
+	if err = c.Get(ctxt, "users/alice", &u); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(u, user{"alice", 30, []string{"a"}, "alice@example.com"}) {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	// update
+	if err = c.Update(ctxt, "users/alice", map[string]interface{}{"age": 31, "e-mail": nil}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	u = user{}
+	if err = c.Get(ctxt, "users/alice", &u); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(u, user{"alice", 31, []string{"a"}, ""}) {
+		t.Errorf("unexpected user: %+v", u)
+	}
+	s.Lock()
+	req := s.reqs[len(s.reqs)-2]
+	s.Unlock()
+	for _, p := range []string{"currentDocument.exists=true", "updateMask.fieldPaths=age", "updateMask.fieldPaths=%60e-mail%60"} {
+		if !strings.Contains(req, p) {
+			t.Errorf("expected update request to contain %s, got: %s", p, req)
+		}
+	}
+	if err = c.Update(ctxt, "users/carol", map[string]interface{}{"age": 1}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+
+	// delete
+	if err = c.Delete(ctxt, "users/alice"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = c.Get(ctxt, "users/alice", &u); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+	if err = c.Delete(ctxt, "users/alice"); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	// database id
+	c, err = New(ProjectID("p"), DatabaseID("other"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = c.Set(ctxt, "a/b", map[string]interface{}{}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+	s.Lock()
+	if req := s.reqs[len(s.reqs)-1]; req != "PATCH /projects/p/databases/other/documents/a/b" {
+		t.Errorf("expected request to database other, got: %s", req)
+	}
+	s.Unlock()
+}
+
+func TestDocumentsError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"error":{"code":403,"message":"Missing or insufficient permissions.","status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	c, err := New(ProjectID("p"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	err = c.Set(context.Background(), "users/alice", map[string]interface{}{"a": 1})
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got: %T", err)
+	}
+	if e.Code != http.StatusForbidden || e.Status != "PERMISSION_DENIED" || e.Error() != "firestore: Missing or insufficient permissions. (PERMISSION_DENIED)" {
+		t.Errorf("unexpected error: %+v", e)
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	s := &documentServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := New(ProjectID("p"), Endpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctxt := context.Background()
+	for _, p := range []string{"rooms/a", "rooms/b", "rooms/a/messages/m1", "rooms/a/messages/m2"} {
+		if err = c.Set(ctxt, p, map[string]interface{}{"id": p}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// collection
+	docs, err := c.RunQuery(ctxt, "rooms", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(docs) != 2 || docs[0].Path != "rooms/a" || docs[1].Path != "rooms/b" {
+		t.Errorf("expected rooms a and b, got: %v", docs)
+	}
+
+	// subcollection
+	docs, err = c.RunQuery(ctxt, "/rooms/a/messages", &Query{
+		Where: []Filter{
+			{Field: "id", Op: OpGreaterThan, Value: "m"},
+			{Field: "meta.by-user", Op: OpEqual, Value: 1},
+		},
+		OrderBy: []Order{{Field: "id", Descending: true}},
+		Offset:  1,
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(docs) != 2 || docs[0].Path != "rooms/a/messages/m1" || docs[0].Fields["id"] != "rooms/a/messages/m1" {
+		t.Errorf("expected messages m1 and m2, got: %v", docs)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if req := s.reqs[len(s.reqs)-1]; req != "POST "+documentsPath+"/rooms/a:runQuery" {
+		t.Errorf("expected query of room a, got: %s", req)
+	}
+	buf, _ := json.Marshal(s.queries[1])
+	exp := `{"from":[{"collectionId":"messages"}],"limit":10,"offset":1,` +
+		`"orderBy":[{"direction":"DESCENDING","field":{"fieldPath":"id"}}],` +
+		`"where":{"compositeFilter":{"filters":[` +
+		`{"fieldFilter":{"field":{"fieldPath":"id"},"op":"GREATER_THAN","value":{"stringValue":"m"}}},` +
+		`{"fieldFilter":{"field":{"fieldPath":"meta.` + "`by-user`" + `"},"op":"EQUAL","value":{"integerValue":"1"}}}` +
+		`],"op":"AND"}}}`
+	if string(buf) != exp {
+		t.Errorf("expected query:\n%s\ngot:\n%s", exp, buf)
+	}
+}
@@ -0,0 +1,159 @@
+package firestore
+
+import (
+	"context"
+	"strings"
+)
+
+// Op is a query filter operator.
+type Op string
+
+// Query filter operators.
+const (
+	OpLessThan           Op = "LESS_THAN"
+	OpLessThanOrEqual    Op = "LESS_THAN_OR_EQUAL"
+	OpGreaterThan        Op = "GREATER_THAN"
+	OpGreaterThanOrEqual Op = "GREATER_THAN_OR_EQUAL"
+	OpEqual              Op = "EQUAL"
+	OpNotEqual           Op = "NOT_EQUAL"
+	OpArrayContains      Op = "ARRAY_CONTAINS"
+	OpIn                 Op = "IN"
+	OpArrayContainsAny   Op = "ARRAY_CONTAINS_ANY"
+	OpNotIn              Op = "NOT_IN"
+)
+
+// Filter is a query field filter.
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+// Order is a query ordering.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Query is a collection query. All filters must match (ie, are combined
+// using AND).
+type Query struct {
+	Where   []Filter
+	OrderBy []Order
+	Offset  int
+	Limit   int
+}
+
+// structured returns the Firestore structured query for the collection id.
+func (q *Query) structured(collectionID string) (map[string]interface{}, error) {
+	sq := map[string]interface{}{
+		"from": []map[string]interface{}{{"collectionId": collectionID}},
+	}
+
+	// filters
+	var filters []map[string]interface{}
+	for _, f := range q.Where {
+		fields, err := encodeFields(map[string]interface{}{"v": f.Value})
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, map[string]interface{}{
+			"fieldFilter": map[string]interface{}{
+				"field": map[string]interface{}{"fieldPath": fieldPath(f.Field)},
+				"op":    f.Op,
+				"value": fields["v"],
+			},
+		})
+	}
+	switch len(filters) {
+	case 0:
+	case 1:
+		sq["where"] = filters[0]
+	default:
+		sq["where"] = map[string]interface{}{
+			"compositeFilter": map[string]interface{}{
+				"op":      "AND",
+				"filters": filters,
+			},
+		}
+	}
+
+	// order
+	var orders []map[string]interface{}
+	for _, o := range q.OrderBy {
+		dir := "ASCENDING"
+		if o.Descending {
+			dir = "DESCENDING"
+		}
+		orders = append(orders, map[string]interface{}{
+			"field":     map[string]interface{}{"fieldPath": fieldPath(o.Field)},
+			"direction": dir,
+		})
+	}
+	if orders != nil {
+		sq["orderBy"] = orders
+	}
+
+	if q.Offset > 0 {
+		sq["offset"] = q.Offset
+	}
+	if q.Limit > 0 {
+		sq["limit"] = q.Limit
+	}
+
+	return sq, nil
+}
+
+// fieldPath quotes the components of a dotted field path.
+func fieldPath(field string) string {
+	parts := strings.Split(field, ".")
+	for i, p := range parts {
+		parts[i] = quoteFieldPath(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// RunQuery runs the query against the collection (ie, "users", or
+// "rooms/a/messages"), returning the matching documents. A nil query returns
+// all documents in the collection.
+func (c *Client) RunQuery(ctxt context.Context, collection string, q *Query) ([]*Document, error) {
+	if q == nil {
+		q = new(Query)
+	}
+
+	collection = strings.Trim(collection, "/")
+	parent, id := c.root(), collection
+	if i := strings.LastIndex(collection, "/"); i != -1 {
+		parent, id = c.name(collection[:i]), collection[i+1:]
+	}
+
+	sq, err := q.structured(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []struct {
+		Document *rawDocument `json:"document"`
+	}
+	err = c.do(ctxt, "POST", parent+":runQuery", nil, map[string]interface{}{
+		"structuredQuery": sq,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*Document
+	for _, r := range res {
+		// results without a document are progress or read time markers
+		if r.Document == nil {
+			continue
+		}
+		doc, err := c.document(r.Document)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,143 @@
+package firestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// value is a Firestore typed value.
+type value map[string]interface{}
+
+// encodeFields encodes v as Firestore document fields. v must marshal to a
+// JSON object.
+func encodeFields(v interface{}) (map[string]value, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal json: %v", err)
+	}
+
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err = dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("document data must be a JSON object: %v", err)
+	}
+
+	fields := make(map[string]value, len(m))
+	for k, x := range m {
+		fields[k] = encodeValue(x)
+	}
+	return fields, nil
+}
+
+// encodeValue encodes a decoded JSON value as a Firestore typed value.
+func encodeValue(x interface{}) value {
+	switch z := x.(type) {
+	case nil:
+		return value{"nullValue": nil}
+	case bool:
+		return value{"booleanValue": z}
+	case string:
+		return value{"stringValue": z}
+	case json.Number:
+		if _, err := z.Int64(); err == nil {
+			return value{"integerValue": z.String()}
+		}
+		f, _ := z.Float64()
+		return value{"doubleValue": f}
+	case []interface{}:
+		values := make([]value, len(z))
+		for i, y := range z {
+			values[i] = encodeValue(y)
+		}
+		return value{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		fields := make(map[string]value, len(z))
+		for k, y := range z {
+			fields[k] = encodeValue(y)
+		}
+		return value{"mapValue": map[string]interface{}{"fields": fields}}
+	}
+	panic(fmt.Sprintf("unexpected json value type %T", x))
+}
+
+// rawValue is an encoded Firestore typed value.
+type rawValue map[string]json.RawMessage
+
+// decodeFields decodes Firestore document fields into plain Go values.
+func decodeFields(fields map[string]rawValue) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		x, err := decodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", k, err)
+		}
+		m[k] = x
+	}
+	return m, nil
+}
+
+// decodeValue decodes a Firestore typed value. Integers are decoded as
+// json.Number, and timestamps, bytes (base64), and references as strings.
+func decodeValue(v rawValue) (interface{}, error) {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("invalid value with types %v", keys)
+	}
+
+	buf := v[keys[0]]
+	switch keys[0] {
+	case "nullValue":
+		return nil, nil
+
+	case "integerValue":
+		var s string
+		if err := json.Unmarshal(buf, &s); err != nil {
+			return nil, err
+		}
+		return json.Number(s), nil
+
+	case "booleanValue", "doubleValue", "stringValue", "timestampValue", "bytesValue", "referenceValue", "geoPointValue":
+		var x interface{}
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.UseNumber()
+		if err := dec.Decode(&x); err != nil {
+			return nil, err
+		}
+		return x, nil
+
+	case "arrayValue":
+		var a struct {
+			Values []rawValue `json:"values"`
+		}
+		if err := json.Unmarshal(buf, &a); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(a.Values))
+		for i, y := range a.Values {
+			x, err := decodeValue(y)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = x
+		}
+		return values, nil
+
+	case "mapValue":
+		var m struct {
+			Fields map[string]rawValue `json:"fields"`
+		}
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return nil, err
+		}
+		return decodeFields(m.Fields)
+	}
+
+	return nil, fmt.Errorf("unknown value type %s", keys[0])
+}
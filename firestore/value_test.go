@@ -0,0 +1,59 @@
+package firestore
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	v := map[string]interface{}{
+		"s": "str",
+		"i": 42,
+		"f": 1.5,
+		"b": true,
+		"n": nil,
+		"a": []interface{}{"x", 1},
+		"m": map[string]interface{}{"k": "v"},
+	}
+
+	fields, err := encodeFields(v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var raw map[string]rawValue
+	if err = json.Unmarshal(buf, &raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(raw["i"]["integerValue"]); s != `"42"` {
+		t.Errorf("expected integer value \"42\", got: %s", s)
+	}
+
+	m, err := decodeFields(raw)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	doc := &Document{Fields: m}
+	var d map[string]interface{}
+	if err = doc.Decode(&d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp := map[string]interface{}{
+		"s": "str",
+		"i": float64(42),
+		"f": 1.5,
+		"b": true,
+		"n": nil,
+		"a": []interface{}{"x", float64(1)},
+		"m": map[string]interface{}{"k": "v"},
+	}
+	if !reflect.DeepEqual(d, exp) {
+		t.Errorf("expected %v, got: %v", exp, d)
+	}
+}
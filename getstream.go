@@ -0,0 +1,139 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Stream is a streaming decoder over the children of a Firebase database
+// ref, as returned by GetStream.
+//
+// Only the current child is held in memory, allowing very large nodes (ie,
+// multi-gigabyte exports) to be processed incrementally. Children are
+// yielded in the order received from the server.
+type Stream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+	kv   KeyValue
+	err  error
+	done bool
+}
+
+// GetStream retrieves the children of Firebase database ref r, returning a
+// Stream that decodes the children incrementally as they are read from the
+// response. The caller is responsible for closing the returned Stream.
+//
+// For example:
+//
+//	s, err := firebase.GetStream(r)
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	for s.Next() {
+//		kv := s.KeyValue()
+//		/* process kv.Key and kv.Value */
+//	}
+//	if err := s.Err(); err != nil {
+//		return err
+//	}
+func GetStream(r *DatabaseRef, opts ...QueryOption) (*Stream, error) {
+	return GetStreamContext(r, context.Background(), opts...)
+}
+
+// GetStreamContext retrieves the children of Firebase database ref r,
+// returning a Stream that decodes the children incrementally as they are read
+// from the response. See GetStream.
+func GetStreamContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (*Stream, error) {
+	res, err := r.do(ctxt, string(OpTypeGet), nil, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stream{
+		body: res.Body,
+		dec:  json.NewDecoder(res.Body),
+	}
+
+	// read opening token
+	tok, err := s.dec.Token()
+	if err == ErrResponseTooLarge {
+		res.Body.Close()
+		return nil, err
+	}
+	if err != nil {
+		res.Body.Close()
+		return nil, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+	switch tok {
+	case nil:
+		s.done = true
+	case json.Delim('{'):
+	default:
+		res.Body.Close()
+		return nil, &Error{
+			Err: fmt.Sprintf("expected json object, got: %v", tok),
+		}
+	}
+
+	return s, nil
+}
+
+// Next advances the stream to the next child, returning false when there are
+// no more children or an error was encountered.
+func (s *Stream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+
+	if !s.dec.More() {
+		s.done = true
+		return false
+	}
+
+	tok, err := s.dec.Token()
+	if err != nil {
+		s.fail(err)
+		return false
+	}
+
+	kv := KeyValue{Key: tok.(string)}
+	if err = s.dec.Decode(&kv.Value); err != nil {
+		s.fail(err)
+		return false
+	}
+	s.kv = kv
+
+	return true
+}
+
+// fail records the decoding error.
+func (s *Stream) fail(err error) {
+	if err == ErrResponseTooLarge {
+		s.err = err
+		return
+	}
+	s.err = &Error{
+		Err: fmt.Sprintf("could not unmarshal json: %v", err),
+	}
+}
+
+// KeyValue returns the current child.
+func (s *Stream) KeyValue() KeyValue {
+	return s.kv
+}
+
+// Err returns the error, if any, encountered while decoding the stream.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close closes the stream's underlying response body.
+func (s *Stream) Close() error {
+	s.done = true
+	return s.body.Close()
+}
@@ -0,0 +1,64 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetStream(t *testing.T) {
+	body := `{"b":{"n":1},"a":[1,2],"c":"x"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	s, err := r.GetStream()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer s.Close()
+
+	var pairs []string
+	for s.Next() {
+		kv := s.KeyValue()
+		pairs = append(pairs, kv.Key+"="+string(kv.Value))
+	}
+	if err = s.Err(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if p, exp := strings.Join(pairs, " "), `b={"n":1} a=[1,2] c="x"`; p != exp {
+		t.Errorf("expected %q, got: %q", exp, p)
+	}
+
+	// null and non-object values
+	for _, test := range []struct {
+		body string
+		err  bool
+	}{
+		{`null`, false},
+		{`"x"`, true},
+		{`{"a":1,`, false},
+	} {
+		body = test.body
+		s, err := r.GetStream()
+		if test.err != (err != nil) {
+			t.Errorf("body %s expected error %t, got: %v", test.body, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		for s.Next() {
+		}
+		if strings.HasSuffix(test.body, ",") && s.Err() == nil {
+			t.Errorf("body %s expected stream error", test.body)
+		}
+		s.Close()
+	}
+}
@@ -1,9 +1,12 @@
 module github.com/knq/firebase
 
+go 1.21
+
 require (
 	cloud.google.com/go v0.28.0
 	github.com/knq/jwt v0.0.0-20180925223530-fc44a4704737
 	github.com/knq/pemutil v0.0.0-20180607233853-a6a7785bc45a // indirect
 	golang.org/x/net v0.0.0-20180926154720-4dfa2610cdf3
 	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be
+	golang.org/x/time v0.10.0
 )
@@ -0,0 +1,182 @@
+package firebase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// ContentHash computes a canonical content hash of the JSON encoded buf,
+// returning the hex encoded SHA-256 digest of buf re-encoded with sorted
+// object keys, numbers in their shortest form, and no insignificant
+// whitespace.
+//
+// Two values that differ only in key order, formatting, or number
+// representation (ie, 1, 1.0, and 1e0) will have the same content hash.
+func ContentHash(buf []byte) (string, error) {
+	v, err := decodeJSON(buf)
+	if err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not decode json: %v", err),
+		}
+	}
+
+	// encoding/json encodes map keys in sorted order
+	if buf, err = json.Marshal(canonicalNumbers(v)); err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+
+	h := sha256.Sum256(buf)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// canonicalNumbers returns a copy of the decoded JSON value v with all
+// numbers converted to the shortest representation of their float64 value,
+// as Firebase stores all numbers as doubles.
+func canonicalNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return x
+		}
+		if f == 0 {
+			// negative zero
+			f = 0
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, y := range x {
+			m[k] = canonicalNumbers(y)
+		}
+		return m
+
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, y := range x {
+			a[i] = canonicalNumbers(y)
+		}
+		return a
+	}
+	return v
+}
+
+// ErrHashMismatch is the error returned when the content hash of a value does
+// not match the expected hash.
+var ErrHashMismatch = &Error{
+	Err: "content hash mismatch",
+}
+
+// HashValue computes the canonical content hash of v, as if v were stored in
+// Firebase and retrieved as JSON. See ContentHash.
+func HashValue(v interface{}) (string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	return ContentHash(buf)
+}
+
+// HashRef computes the canonical content hash of the value stored at Firebase
+// database ref r. See ContentHash.
+func HashRef(r *DatabaseRef, opts ...QueryOption) (string, error) {
+	return HashRefContext(r, context.Background(), opts...)
+}
+
+// HashRefContext computes the canonical content hash of the value stored at
+// Firebase database ref r. See ContentHash.
+func HashRefContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (string, error) {
+	body, err := GetRawContext(r, ctxt, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	buf, err := ioutil.ReadAll(body)
+	if err == ErrResponseTooLarge {
+		return "", err
+	}
+	if err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not read response: %v", err),
+		}
+	}
+
+	return ContentHash(buf)
+}
+
+// VerifyHash verifies that the canonical content hash of the value stored at
+// Firebase database ref r matches hash, returning ErrHashMismatch when it
+// does not.
+func VerifyHash(r *DatabaseRef, hash string, opts ...QueryOption) error {
+	return VerifyHashContext(r, context.Background(), hash, opts...)
+}
+
+// VerifyHashContext verifies that the canonical content hash of the value
+// stored at Firebase database ref r matches hash, returning ErrHashMismatch
+// when it does not.
+func VerifyHashContext(r *DatabaseRef, ctxt context.Context, hash string, opts ...QueryOption) error {
+	h, err := HashRefContext(r, ctxt, opts...)
+	if err != nil {
+		return err
+	}
+	if h != hash {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// StoreHash computes the canonical content hash of the value stored at
+// Firebase database ref r, and stores it at dest, returning the hash.
+//
+// The stored hash can later be verified with VerifyStoredHash, such as after
+// an export, replication, or migration of r.
+func StoreHash(r, dest *DatabaseRef) (string, error) {
+	return StoreHashContext(r, context.Background(), dest)
+}
+
+// StoreHashContext computes the canonical content hash of the value stored
+// at Firebase database ref r, and stores it at dest, returning the hash.
+func StoreHashContext(r *DatabaseRef, ctxt context.Context, dest *DatabaseRef) (string, error) {
+	h, err := HashRefContext(r, ctxt)
+	if err != nil {
+		return "", err
+	}
+	if err = dest.SetContext(ctxt, h); err != nil {
+		return "", err
+	}
+	return h, nil
+}
+
+// VerifyStoredHash verifies that the canonical content hash of the value
+// stored at Firebase database ref r matches the hash stored at src (ie, by
+// StoreHash), returning ErrHashMismatch when it does not.
+func VerifyStoredHash(r, src *DatabaseRef) error {
+	return VerifyStoredHashContext(r, context.Background(), src)
+}
+
+// VerifyStoredHashContext verifies that the canonical content hash of the
+// value stored at Firebase database ref r matches the hash stored at src,
+// returning ErrHashMismatch when it does not.
+func VerifyStoredHashContext(r *DatabaseRef, ctxt context.Context, src *DatabaseRef) error {
+	var hash string
+	if err := src.GetContext(ctxt, &hash); err != nil {
+		return err
+	}
+	if hash == "" {
+		return &Error{
+			Err: fmt.Sprintf("no content hash stored at %s", src.URL().Path),
+		}
+	}
+	return VerifyHashContext(r, ctxt, hash)
+}
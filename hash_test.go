@@ -0,0 +1,79 @@
+package firebase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHashRef(t *testing.T) {
+	data := map[string][]byte{
+		"/data.json": []byte(`{"b": 1, "a": {"y": true, "x": "s"}}`),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			data[req.URL.Path], _ = ioutil.ReadAll(req.Body)
+		}
+		w.Write(data[req.URL.Path])
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp, err := HashValue(map[string]interface{}{
+		"a": map[string]interface{}{"x": "s", "y": true},
+		"b": 1,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	h, err := StoreHash(r.Ref("/data"), r.Ref("/hash"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if h != exp {
+		t.Errorf("expected %s, got: %s", exp, h)
+	}
+	if err = VerifyStoredHash(r.Ref("/data"), r.Ref("/hash")); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	// modify
+	data["/data.json"] = []byte(`{"b": 2}`)
+	if err = VerifyStoredHash(r.Ref("/data"), r.Ref("/hash")); err != ErrHashMismatch {
+		t.Errorf("expected ErrHashMismatch, got: %v", err)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		a, b string
+		eq   bool
+	}{
+		{`{"a":1,"b":2}`, `{ "b": 2, "a": 1 }`, true},
+		{`1`, `1.0`, true},
+		{`100`, `1e2`, true},
+		{`{"n":[0.5,-0]}`, `{"n":[5e-1,0]}`, true},
+		{`12345678901234567890`, `12345678901234567000`, true},
+		{`1`, `2`, false},
+		{`1`, `"1"`, false},
+	}
+	for i, test := range tests {
+		a, err := ContentHash([]byte(test.a))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		b, err := ContentHash([]byte(test.b))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if (a == b) != test.eq {
+			t.Errorf("test %d expected %s and %s equal %t, got: %s, %s", i, test.a, test.b, test.eq, a, b)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package firebase
 
 import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"math/rand"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -9,6 +16,10 @@ import (
 const (
 	// defaultPushIDChars are the lexiographically correct base 64 characters for use in generated PushIDs.
 	defaultPushIDChars = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+	// machineIDChars is the number of entropy characters reserved for the
+	// machine identifier by machine-scoped Push ID generators.
+	machineIDChars = 4
 )
 
 // IDGen holds the information related to generating a Push ID.
@@ -26,6 +37,13 @@ type IDGen struct {
 	// this is appended to the generated id to prevent collisions.
 	// the numeric value is incremented in the event of a collision.
 	last [12]int
+
+	// prefix is prepended to generated ids.
+	prefix string
+
+	// fixed is the number of leading entropy characters that are fixed (ie,
+	// the machine identifier), and are not incremented on collision.
+	fixed int
 }
 
 // GeneratePushID generates a unique, 20-character ID for use with Firebase,
@@ -33,10 +51,12 @@ type IDGen struct {
 var GeneratePushID func() string
 
 // NewPushIDGenerator creates a new Push ID generator.
+//
+// If r is nil, then a random source seeded from crypto/rand is used.
 func NewPushIDGenerator(r *rand.Rand) (*IDGen, error) {
 	// make sure rand is good
 	if r == nil {
-		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+		r = newSeededRand()
 	}
 
 	// create generator and set last entropy
@@ -50,7 +70,81 @@ func NewPushIDGenerator(r *rand.Rand) (*IDGen, error) {
 	return ig, nil
 }
 
+// NewPrefixedPushIDGenerator creates a new Push ID generator that prepends
+// prefix to all generated IDs.
+//
+// Generated IDs sort in creation order within the prefix, and can be
+// distinguished from natural keys (or IDs generated with a different prefix)
+// using the generator's IsPushID. The prefix must be short (no more than 8
+// characters) and only contain Push ID characters.
+func NewPrefixedPushIDGenerator(prefix string, r *rand.Rand) (*IDGen, error) {
+	if len(prefix) > 8 {
+		return nil, errors.New("push id prefix cannot be longer than 8 characters")
+	}
+	for i := 0; i < len(prefix); i++ {
+		if strings.IndexByte(defaultPushIDChars, prefix[i]) == -1 {
+			return nil, fmt.Errorf("invalid push id prefix character %q", prefix[i])
+		}
+	}
+
+	ig, err := NewPushIDGenerator(r)
+	if err != nil {
+		return nil, err
+	}
+	ig.prefix = prefix
+
+	return ig, nil
+}
+
+// NewMachinePushIDGenerator creates a new Push ID generator that mixes a
+// machine identifier into the entropy portion of generated IDs, reducing the
+// risk of collisions between processes generating IDs at high rates.
+//
+// The machine identifier is hashed into the 4 most significant entropy
+// characters (24 bits), leaving 48 bits of random entropy. If machineID is
+// empty, then the host name and process ID are used.
+func NewMachinePushIDGenerator(machineID string, r *rand.Rand) (*IDGen, error) {
+	if machineID == "" {
+		host, _ := os.Hostname()
+		machineID = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+
+	ig, err := NewPushIDGenerator(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256([]byte(machineID))
+	for i := 0; i < machineIDChars; i++ {
+		ig.last[11-i] = int(h[i] % 64)
+	}
+	ig.fixed = machineIDChars
+
+	return ig, nil
+}
+
+// IsPushID determines if s is a valid ID generated by the generator (ie, has
+// the generator's prefix followed by a valid Push ID).
+func (ig *IDGen) IsPushID(s string) bool {
+	return strings.HasPrefix(s, ig.prefix) && IsPushID(s[len(ig.prefix):])
+}
+
+// IsPushID determines if s is a valid, unprefixed Push ID: 20 characters, all
+// of which are Push ID characters.
+func IsPushID(s string) bool {
+	if len(s) != 20 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(defaultPushIDChars, s[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
+
 // GeneratePushID generates a unique, 20-character ID for use with Firebase.
+// If the generator has a prefix, then the prefix is prepended to the ID.
 func (ig *IDGen) GeneratePushID() string {
 	var i int
 
@@ -60,7 +154,7 @@ func (ig *IDGen) GeneratePushID() string {
 	ig.mu.Lock()
 	now := time.Now().UTC().UnixNano() / 1e6
 	if ig.stamp == now {
-		for i = 0; i < 12; i++ {
+		for i = 0; i < 12-ig.fixed; i++ {
 			ig.last[i]++
 			if ig.last[i] < 64 {
 				break
@@ -82,9 +176,51 @@ func (ig *IDGen) GeneratePushID() string {
 		now /= 64
 	}
 
+	return ig.prefix + string(id)
+}
+
+// IdempotentPushID derives a deterministic, 20-character Push ID from an
+// idempotency key and the record's creation time t, such that the same key
+// and time always produce the same ID.
+//
+// The timestamp portion of the ID is derived from t (allowing derived IDs to
+// sort chronologically with generated Push IDs), and the entropy portion from
+// the SHA-256 hash of key. As such, t must be the original creation time of
+// the record (ie, persisted alongside the idempotency key), and not the time
+// of a retry. If t is the zero time, then the timestamp portion is zeroed.
+func IdempotentPushID(key string, t time.Time) string {
+	id := make([]byte, 20)
+
+	// set last 12 characters from hash
+	h := sha256.Sum256([]byte(key))
+	for i := 0; i < 12; i++ {
+		id[8+i] = defaultPushIDChars[h[i]%64]
+	}
+
+	// set first 8 characters from timestamp
+	var now int64
+	if !t.IsZero() {
+		now = t.UTC().UnixNano() / 1e6
+	}
+	for i := 7; i >= 0; i-- {
+		id[i] = defaultPushIDChars[int(now%64)]
+		now /= 64
+	}
+
 	return string(id)
 }
 
+// newSeededRand creates a new random source seeded from crypto/rand, falling
+// back to the current time if crypto/rand is unavailable.
+func newSeededRand() *rand.Rand {
+	var buf [8]byte
+	seed := time.Now().UnixNano()
+	if _, err := cryptorand.Read(buf[:]); err == nil {
+		seed = int64(binary.LittleEndian.Uint64(buf[:]))
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
 func init() {
 	// set default id generator
 	ig, err := NewPushIDGenerator(nil)
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestGeneratePushID(t *testing.T) {
@@ -56,3 +57,81 @@ func TestGeneratePushIDMany(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestIsPushID(t *testing.T) {
+	if id := GeneratePushID(); !IsPushID(id) {
+		t.Errorf("expected %s to be a push id", id)
+	}
+
+	for _, s := range []string{"", "abc", "-KqKf2WlbBxUwDbCBcLX!", "-KqKf2WlbBxUwDbCBc.X"} {
+		if IsPushID(s) {
+			t.Errorf("expected %q to not be a push id", s)
+		}
+	}
+}
+
+func TestPrefixedPushID(t *testing.T) {
+	ig, err := NewPrefixedPushIDGenerator("u_", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	a, b := ig.GeneratePushID(), ig.GeneratePushID()
+	if len(a) != 22 || !strings.HasPrefix(a, "u_") {
+		t.Errorf("expected a (%s) to be prefixed with u_", a)
+	}
+	if !(strings.Compare(a, b) < 0) {
+		t.Errorf("a (%s) should be < than b (%s)", a, b)
+	}
+	if !ig.IsPushID(a) || IsPushID(a) {
+		t.Errorf("expected a (%s) to only be a prefixed push id", a)
+	}
+
+	if _, err = NewPrefixedPushIDGenerator("u/", nil); err == nil {
+		t.Errorf("expected error for invalid prefix")
+	}
+}
+
+func TestMachinePushID(t *testing.T) {
+	a, err := NewMachinePushIDGenerator("host-a:1", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	b, err := NewMachinePushIDGenerator("host-b:1", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var prev string
+	for i := 0; i < 10000; i++ {
+		id := a.GeneratePushID()
+		if !(strings.Compare(prev, id) < 0) {
+			t.Fatalf("prev id %s is not < than generated id %s", prev, id)
+		}
+		prev = id
+	}
+
+	x, y := a.GeneratePushID(), b.GeneratePushID()
+	if x[8:12] == y[8:12] {
+		t.Errorf("expected different machine characters, got: %s and %s", x, y)
+	}
+}
+
+func TestIdempotentPushID(t *testing.T) {
+	now := time.Now()
+	a, b := IdempotentPushID("order-1", now), IdempotentPushID("order-1", now)
+	if a != b {
+		t.Errorf("expected %s and %s to be equal", a, b)
+	}
+	if !IsPushID(a) {
+		t.Errorf("expected %s to be a push id", a)
+	}
+	if c := IdempotentPushID("order-2", now); c == a || c[:8] != a[:8] {
+		t.Errorf("expected %s to differ from %s only in entropy", c, a)
+	}
+
+	// derived ids sort chronologically with generated ids
+	if g := GeneratePushID(); a[:8] > g[:8] {
+		t.Errorf("expected %s to sort before %s", a, g)
+	}
+}
@@ -0,0 +1,33 @@
+package firebase
+
+import (
+	"math"
+	"strconv"
+)
+
+// ServerIncrement provides a json.Marshal'able type for atomically
+// incrementing (or decrementing, when negative) a numeric value stored in
+// Firebase, without the need for a transaction.
+//
+// When serialized to Firebase, Firebase adds the value to the currently
+// stored number (treating a missing value as 0). For example:
+//
+//	err := r.Ref("/counters/visits").Set(firebase.ServerIncrement(1))
+//
+// or as part of an update:
+//
+//	err := r.Update(map[string]interface{}{
+//		"posts/1/likes": firebase.ServerIncrement(1),
+//		"users/2/likes": firebase.ServerIncrement(1),
+//	})
+type ServerIncrement float64
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (si ServerIncrement) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(si)) || math.IsInf(float64(si), 0) {
+		return nil, &Error{
+			Err: "server increment must be a finite number",
+		}
+	}
+	return []byte(`{".sv":{"increment":` + strconv.FormatFloat(float64(si), 'g', -1, 64) + `}}`), nil
+}
@@ -0,0 +1,25 @@
+package firebase
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestServerIncrement(t *testing.T) {
+	tests := []struct {
+		v   ServerIncrement
+		exp string
+	}{
+		{1, `{"a":{".sv":{"increment":1}}}`},
+		{-2.5, `{"a":{".sv":{"increment":-2.5}}}`},
+	}
+	for i, test := range tests {
+		buf, err := json.Marshal(map[string]interface{}{"a": test.v})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, string(buf))
+		}
+	}
+}
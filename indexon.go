@@ -0,0 +1,108 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/knq/firebase/rules"
+)
+
+// EnsureIndex ensures the security rules for the database of Firebase
+// database ref r contain .indexOn entries for the fields under the rules path
+// (ie, "users" or "rooms/$room/messages"). See EnsureIndexContext.
+func EnsureIndex(r *DatabaseRef, path string, fields ...string) ([]string, error) {
+	return EnsureIndexContext(r, context.Background(), path, fields...)
+}
+
+// EnsureIndexContext ensures the security rules for the database of Firebase
+// database ref r contain .indexOn entries for the fields under the rules path
+// (ie, "users" or "rooms/$room/messages"), canceling the operation when the
+// passed context is done.
+//
+// The current rules are retrieved, any missing fields are appended to the
+// .indexOn entry of the rules path, and the updated rules are written back to
+// the database. The differences between the current and updated rules are
+// returned (ie, for logging) in the same form as the firebase-rules -diff
+// output. For example:
+//
+//	[]string{
+//		`- /users/.indexOn: ["email"]`,
+//		`+ /users/.indexOn: ["email","name"]`,
+//	}
+//
+// When all fields are already indexed, no differences are returned and the
+// rules are not written. The updated rules are written in canonical (sorted
+// and indented) form.
+func EnsureIndexContext(r *DatabaseRef, ctxt context.Context, path string, fields ...string) ([]string, error) {
+	for _, field := range fields {
+		if field == "" {
+			return nil, &Error{
+				Err: "invalid empty index field",
+			}
+		}
+	}
+
+	buf, err := GetRulesJSONContext(r, ctxt)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rules.Parse(buf)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not parse rules: %v", err),
+		}
+	}
+
+	// add missing fields
+	n := root.Child(path)
+	existing := n.IndexOn
+	indexOn := append([]string(nil), existing...)
+	for _, field := range fields {
+		if !contains(indexOn, field) {
+			indexOn = append(indexOn, field)
+		}
+	}
+	if len(indexOn) == len(existing) {
+		return nil, nil
+	}
+	n.IndexOn = indexOn
+
+	// build diff
+	key := "/" + strings.Trim(path, "/") + "/.indexOn"
+	if strings.Trim(path, "/") == "" {
+		key = "/.indexOn"
+	}
+	var diff []string
+	if len(existing) != 0 {
+		diff = append(diff, fmt.Sprintf("- %s: %s", key, encodeIndexOn(existing)))
+	}
+	diff = append(diff, fmt.Sprintf("+ %s: %s", key, encodeIndexOn(indexOn)))
+
+	// write rules
+	if buf, err = root.MarshalRules(); err != nil {
+		return nil, err
+	}
+	if err = SetRulesJSONContext(r, ctxt, buf); err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// contains determines if s contains v.
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeIndexOn encodes the .indexOn fields as JSON.
+func encodeIndexOn(fields []string) string {
+	buf, _ := json.Marshal(fields)
+	return string(buf)
+}
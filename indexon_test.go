@@ -0,0 +1,84 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEnsureIndex(t *testing.T) {
+	current := `{
+  "rules": {
+    ".read": true,
+    "users": {
+      ".indexOn": "email"
+    }
+  }
+}`
+	var writes int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/.settings/rules.json" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		switch req.Method {
+		case "GET":
+			w.Write([]byte(current))
+		case "PUT":
+			buf, _ := ioutil.ReadAll(req.Body)
+			current = string(buf)
+			writes++
+			w.Write(buf)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		fields []string
+		diff   []string
+		writes int
+	}{
+		{"users", []string{"email", "name"}, []string{
+			`- /users/.indexOn: ["email"]`,
+			`+ /users/.indexOn: ["email","name"]`,
+		}, 1},
+		{"/users/", []string{"name"}, nil, 1},
+		{"rooms/$room/messages", []string{"ts"}, []string{
+			`+ /rooms/$room/messages/.indexOn: ["ts"]`,
+		}, 2},
+	}
+	for i, test := range tests {
+		diff, err := r.EnsureIndex(test.path, test.fields...)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if !reflect.DeepEqual(diff, test.diff) {
+			t.Errorf("test %d expected diff %q, got: %q", i, test.diff, diff)
+		}
+		if writes != test.writes {
+			t.Errorf("test %d expected %d writes, got: %d", i, test.writes, writes)
+		}
+	}
+
+	exp := `{"rules":{".read":true,"rooms":{"$room":{"messages":{".indexOn":["ts"]}}},"users":{".indexOn":["email","name"]}}}`
+	buf := new(bytes.Buffer)
+	if err = json.Compact(buf, []byte(current)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := buf.String(); s != exp {
+		t.Errorf("expected rules %s, got: %s", exp, s)
+	}
+
+	if _, err = r.EnsureIndex("users", ""); err == nil {
+		t.Errorf("expected error for empty field")
+	}
+}
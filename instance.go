@@ -0,0 +1,168 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultManagementURL is the base URL of the Firebase Realtime Database
+	// management API.
+	DefaultManagementURL = "https://firebasedatabase.googleapis.com/v1beta"
+
+	// DefaultDatabaseLocation is the default location for database instances.
+	DefaultDatabaseLocation = "us-central1"
+)
+
+// managementScopes are the oauth2 scopes required when using the Firebase
+// Realtime Database management API.
+var managementScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/firebase",
+}
+
+// DatabaseInstance is a Firebase Realtime Database instance, as returned by
+// the management API.
+type DatabaseInstance struct {
+	Name        string `json:"name,omitempty"`
+	Project     string `json:"project,omitempty"`
+	DatabaseURL string `json:"databaseUrl,omitempty"`
+	Type        string `json:"type,omitempty"`
+	State       string `json:"state,omitempty"`
+}
+
+// Location returns the location (region) of the database instance, as
+// contained in the instance's resource name (ie,
+// "projects/<project>/locations/<location>/instances/<databaseID>").
+func (inst *DatabaseInstance) Location() string {
+	parts := strings.Split(inst.Name, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "locations" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// instancePath returns the management API resource path for the database
+// instance.
+func instancePath(projectID, location, databaseID string) string {
+	if location == "" {
+		location = "-"
+	}
+	return "/projects/" + url.PathEscape(projectID) + "/locations/" + url.PathEscape(location) + "/instances/" + url.PathEscape(databaseID)
+}
+
+// managementDo executes a management API request using client, decoding the
+// response into d. Returns false if the server responded with 404 Not Found.
+func managementDo(ctxt context.Context, client *http.Client, method, urlstr string, v, d interface{}) (bool, error) {
+	var body io.Reader
+	if v != nil {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return false, &Error{
+				Err: fmt.Sprintf("could not marshal json: %v", err),
+			}
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, urlstr, body)
+	if err != nil {
+		return false, &Error{
+			Err: fmt.Sprintf("could not create request: %v", err),
+		}
+	}
+	req = req.WithContext(ctxt)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return false, &Error{
+			Err: fmt.Sprintf("could not execute request: %v", err),
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	// check for google api error
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		buf, _ := ioutil.ReadAll(res.Body)
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(buf, &e) == nil && e.Error.Message != "" {
+			return false, &Error{
+				Err: fmt.Sprintf("management api error: %s (%s)", e.Error.Message, e.Error.Status),
+			}
+		}
+		return false, &Error{
+			Err: fmt.Sprintf("unknown management api error: %s (%d)", string(buf), res.StatusCode),
+		}
+	}
+
+	if d != nil {
+		err = json.NewDecoder(res.Body).Decode(d)
+		if err != nil {
+			return false, &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// GetDatabaseInstance retrieves the database instance with databaseID from
+// the management API using client, which must be authorized with the
+// cloud-platform or firebase scopes. If location is empty, then the instance
+// is looked up in all locations.
+//
+// Returns nil if the database instance does not exist.
+func GetDatabaseInstance(ctxt context.Context, client *http.Client, projectID, location, databaseID string) (*DatabaseInstance, error) {
+	var inst DatabaseInstance
+	ok, err := managementDo(ctxt, client, "GET", DefaultManagementURL+instancePath(projectID, location, databaseID), nil, &inst)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// CreateDatabaseInstance creates a database instance with databaseID and
+// instance type typ (ie, "DEFAULT_DATABASE" or "USER_DATABASE") in location
+// using the management API via client, which must be authorized with the
+// cloud-platform or firebase scopes.
+func CreateDatabaseInstance(ctxt context.Context, client *http.Client, projectID, location, databaseID, typ string) (*DatabaseInstance, error) {
+	if location == "" {
+		location = DefaultDatabaseLocation
+	}
+
+	urlstr := DefaultManagementURL + "/projects/" + url.PathEscape(projectID) + "/locations/" + url.PathEscape(location) + "/instances?databaseId=" + url.QueryEscape(databaseID)
+
+	var inst DatabaseInstance
+	ok, err := managementDo(ctxt, client, "POST", urlstr, &DatabaseInstance{Type: typ}, &inst)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &Error{
+			Err: fmt.Sprintf("project %s or location %s not found", projectID, location),
+		}
+	}
+	return &inst, nil
+}
@@ -0,0 +1,164 @@
+// Package clierr provides structured exit codes and machine-readable errors
+// for the firebase command line tools, so that scripts wrapping the tools can
+// branch on the type of failure.
+//
+// Importing the package registers the -json-errors flag with the default
+// flag set.
+package clierr
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/knq/firebase"
+)
+
+// Exit codes.
+const (
+	// ExitOK is the exit code for success.
+	ExitOK = 0
+
+	// ExitError is the exit code for general errors.
+	ExitError = 1
+
+	// ExitUsage is the exit code for invalid flags or arguments.
+	ExitUsage = 2
+
+	// ExitAuth is the exit code for authentication failures, including
+	// invalid credentials.
+	ExitAuth = 3
+
+	// ExitPermissionDenied is the exit code for requests denied by the
+	// server.
+	ExitPermissionDenied = 4
+
+	// ExitNotFound is the exit code for missing data or files.
+	ExitNotFound = 5
+
+	// ExitNetwork is the exit code for requests that failed before a
+	// response was received.
+	ExitNetwork = 6
+)
+
+// types are the machine-readable names of the exit codes.
+var types = map[int]string{
+	ExitError:            "error",
+	ExitUsage:            "usage",
+	ExitAuth:             "auth",
+	ExitPermissionDenied: "permission_denied",
+	ExitNotFound:         "not_found",
+	ExitNetwork:          "network",
+}
+
+// JSONErrors toggles emitting errors as JSON objects.
+var JSONErrors = flag.Bool("json-errors", false, "emit errors as json objects to stderr")
+
+// Error is an error with an exit code.
+type Error struct {
+	Code int
+	Err  error
+}
+
+// Error satisfies the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Usage returns an error with the ExitUsage code.
+func Usage(format string, v ...interface{}) error {
+	return &Error{Code: ExitUsage, Err: fmt.Errorf(format, v...)}
+}
+
+// NotFound returns an error with the ExitNotFound code.
+func NotFound(format string, v ...interface{}) error {
+	return &Error{Code: ExitNotFound, Err: fmt.Errorf(format, v...)}
+}
+
+// Auth wraps err with the ExitAuth code, returning nil if err is nil.
+func Auth(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: ExitAuth, Err: err}
+}
+
+// Code returns the exit code for err.
+func Code(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	var fe *firebase.Error
+	if errors.As(err, &fe) {
+		switch {
+		case fe.Network():
+			return ExitNetwork
+		case fe.StatusCode == http.StatusUnauthorized:
+			return ExitAuth
+		case fe.StatusCode == http.StatusForbidden:
+			return ExitPermissionDenied
+		case fe.StatusCode == http.StatusNotFound:
+			return ExitNotFound
+		}
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		return ExitNotFound
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return ExitPermissionDenied
+	}
+
+	return ExitError
+}
+
+// jsonError is the JSON encoded form of an error.
+type jsonError struct {
+	Error  string `json:"error"`
+	Type   string `json:"type"`
+	Code   int    `json:"code"`
+	Status int    `json:"status,omitempty"`
+}
+
+// Write writes err to w, as a JSON object when asJSON is true, returning the
+// exit code for err.
+func Write(w io.Writer, err error, asJSON bool) int {
+	code := Code(err)
+	if !asJSON {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return code
+	}
+
+	v := jsonError{
+		Error: err.Error(),
+		Type:  types[code],
+		Code:  code,
+	}
+	var fe *firebase.Error
+	if errors.As(err, &fe) {
+		v.Status = fe.StatusCode
+	}
+	buf, _ := json.Marshal(v)
+	fmt.Fprintf(w, "%s\n", buf)
+	return code
+}
+
+// Exit writes err to stderr, and exits with its exit code.
+func Exit(err error) {
+	os.Exit(Write(os.Stderr, err, *JSONErrors))
+}
@@ -0,0 +1,46 @@
+package clierr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err error
+		exp int
+	}{
+		{nil, ExitOK},
+		{errors.New("x"), ExitError},
+		{Usage("bad flag"), ExitUsage},
+		{Auth(errors.New("bad creds")), ExitAuth},
+		{NotFound("/a not found"), ExitNotFound},
+		{&firebase.Error{Err: "x", StatusCode: 401}, ExitAuth},
+		{&firebase.Error{Err: "x", StatusCode: 403}, ExitPermissionDenied},
+		{fmt.Errorf("batch 1: %w", &firebase.Error{Err: "x", StatusCode: 404}), ExitNotFound},
+		{&firebase.Error{Err: "x", StatusCode: 500}, ExitError},
+		{&os.PathError{Op: "open", Path: "a", Err: os.ErrNotExist}, ExitNotFound},
+	}
+	for i, test := range tests {
+		if code := Code(test.err); code != test.exp {
+			t.Errorf("test %d expected %d, got: %d", i, test.exp, code)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	code := Write(&buf, &firebase.Error{Err: "Permission denied", StatusCode: 403}, true)
+	if code != ExitPermissionDenied {
+		t.Errorf("expected %d, got: %d", ExitPermissionDenied, code)
+	}
+	exp := `{"error":"firebase: Permission denied","type":"permission_denied","code":4,"status":403}` + "\n"
+	if s := buf.String(); s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+}
@@ -0,0 +1,106 @@
+// Package gcreds provides the project and Google credential configuration
+// shared by the Google API clients (ie, the fcm, fireauth, and firestore
+// packages).
+package gcreds
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/knq/jwt/gserviceaccount"
+)
+
+// Config is the project and credential configuration of a Google API client.
+type Config struct {
+	// ProjectID is the Firebase project ID.
+	ProjectID string
+
+	// Endpoint is the API endpoint.
+	Endpoint string
+
+	// Transport is the underlying HTTP transport.
+	Transport http.RoundTripper
+
+	// Source is the oauth2 token source used to authorize requests.
+	Source oauth2.TokenSource
+}
+
+// SetProjectID sets the project ID.
+func (c *Config) SetProjectID(projectID string) error {
+	if projectID == "" {
+		return errors.New("project id cannot be empty")
+	}
+	c.ProjectID = projectID
+	return nil
+}
+
+// ServiceAccountJSON loads the Google Service Account credentials from the
+// JSON encoded buf, setting the project ID and a token source for the
+// scopes. The loaded service account is returned.
+func (c *Config) ServiceAccountJSON(buf []byte, scopes ...string) (*gserviceaccount.GServiceAccount, error) {
+	var err error
+
+	// load service account credentials
+	gsa, err := gserviceaccount.FromJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// simple check
+	if gsa.ProjectID == "" || gsa.ClientEmail == "" || gsa.PrivateKey == "" {
+		return nil, errors.New("google service account credentials missing project_id, client_email or private_key")
+	}
+
+	// set project id
+	if err = c.SetProjectID(gsa.ProjectID); err != nil {
+		return nil, err
+	}
+
+	// create token source
+	ts, err := gsa.TokenSource(nil, scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Source = oauth2.ReuseTokenSource(nil, ts)
+
+	return gsa, nil
+}
+
+// ReadServiceAccountFile reads the Google Service Account credentials file.
+func ReadServiceAccountFile(path string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read google service account credentials file: %v", err)
+	}
+	return buf, nil
+}
+
+// Compute sets the token source to the Google Service Account credentials
+// from the GCE metadata associated with the GCE compute instance. If
+// serviceAccount is empty, then the default service account credentials
+// associated with the GCE instance will be used.
+func (c *Config) Compute(serviceAccount string) {
+	c.Source = google.ComputeTokenSource(serviceAccount)
+}
+
+// HTTPClient returns a http.Client using the configured transport, that
+// authorizes requests with the configured token source.
+func (c *Config) HTTPClient() *http.Client {
+	transport := c.Transport
+	if c.Source != nil {
+		transport = &oauth2.Transport{
+			Source: c.Source,
+			Base:   transport,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
@@ -0,0 +1,64 @@
+package gcreds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestServiceAccountJSON(t *testing.T) {
+	tests := []string{
+		`{`,
+		`{"project_id":"p","client_email":"a@example.com"}`,
+		`{"client_email":"a@example.com","private_key":"k"}`,
+	}
+	for i, test := range tests {
+		c := new(Config)
+		if _, err := c.ServiceAccountJSON([]byte(test)); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+		if c.ProjectID != "" || c.Source != nil {
+			t.Errorf("test %d expected config to be unchanged, got: %+v", i, c)
+		}
+	}
+}
+
+func TestSetProjectID(t *testing.T) {
+	c := new(Config)
+	if err := c.SetProjectID(""); err == nil {
+		t.Errorf("expected error")
+	}
+	if err := c.SetProjectID("p"); err != nil || c.ProjectID != "p" {
+		t.Errorf("expected project id p, got: %q, %v", c.ProjectID, err)
+	}
+}
+
+func TestReadServiceAccountFile(t *testing.T) {
+	_, err := ReadServiceAccountFile("/nonexistent/credentials.json")
+	if err == nil || !strings.Contains(err.Error(), "could not read google service account credentials file") {
+		t.Errorf("expected read error, got: %v", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	c := &Config{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "Bearer"}),
+	}
+	res, err := c.HTTPClient().Get(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	res.Body.Close()
+	if auth != "Bearer token" {
+		t.Errorf("expected bearer token, got: %q", auth)
+	}
+}
@@ -0,0 +1,219 @@
+// Package mask provides masking profiles for the firebase command line
+// tools, allowing production-shaped data to be shared without leaking
+// sensitive values.
+//
+// A profile is built from rules of the form "glob=strategy", where glob is a
+// database path glob relative to the retrieved ref (ie, "users/*/email"), and
+// strategy is one of:
+//
+//	redact      replace the value with "[redacted]"
+//	hash        replace the value with a stable hash of the value
+//	truncate:n  truncate the value to its first n characters (default 4)
+//
+// Each glob component is matched against a child key using path.Match, and
+// the component "**" matches zero or more keys. The value of a matching path
+// is masked as a whole, including any children.
+package mask
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Redacted is the value masked values are replaced with by the redact
+	// strategy.
+	Redacted = "[redacted]"
+
+	// DefaultTruncate is the default number of characters retained by the
+	// truncate strategy.
+	DefaultTruncate = 4
+)
+
+// Rule is a masking rule.
+type Rule struct {
+	Glob     string
+	Strategy string
+	N        int
+}
+
+// ParseRule parses a masking rule of the form "glob=strategy".
+func ParseRule(s string) (Rule, error) {
+	i := strings.LastIndex(s, "=")
+	if i == -1 {
+		return Rule{}, fmt.Errorf("invalid mask %q: expected glob=strategy", s)
+	}
+
+	r := Rule{
+		Glob:     strings.Trim(s[:i], "/"),
+		Strategy: s[i+1:],
+	}
+	if j := strings.Index(r.Strategy, ":"); j != -1 {
+		n, err := strconv.Atoi(r.Strategy[j+1:])
+		if err != nil || n < 0 {
+			return Rule{}, fmt.Errorf("invalid mask %q: invalid length", s)
+		}
+		r.Strategy, r.N = r.Strategy[:j], n
+	} else if r.Strategy == "truncate" {
+		r.N = DefaultTruncate
+	}
+
+	switch {
+	case r.Strategy == "redact", r.Strategy == "hash":
+		if r.N != 0 {
+			return Rule{}, fmt.Errorf("invalid mask %q: %s does not take a length", s, r.Strategy)
+		}
+	case r.Strategy == "truncate":
+	default:
+		return Rule{}, fmt.Errorf("invalid mask %q: unknown strategy %q", s, r.Strategy)
+	}
+	for _, part := range strings.Split(r.Glob, "/") {
+		if _, err := path.Match(part, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid mask %q: %v", s, err)
+		}
+	}
+
+	return r, nil
+}
+
+// String satisfies the stringer interface.
+func (r Rule) String() string {
+	if r.Strategy == "truncate" {
+		return fmt.Sprintf("%s=%s:%d", r.Glob, r.Strategy, r.N)
+	}
+	return r.Glob + "=" + r.Strategy
+}
+
+// Profile is a set of masking rules, and satisfies flag.Value, allowing a
+// profile to be built from a repeated flag.
+type Profile []Rule
+
+// String satisfies the flag.Value interface.
+func (p *Profile) String() string {
+	if p == nil {
+		return ""
+	}
+	s := make([]string, len(*p))
+	for i, r := range *p {
+		s[i] = r.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// Set satisfies the flag.Value interface.
+func (p *Profile) Set(s string) error {
+	r, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, r)
+	return nil
+}
+
+// Apply masks the generic JSON value v (as decoded by encoding/json) located
+// at path, returning the masked value.
+func (p Profile) Apply(path string, v interface{}) interface{} {
+	if len(p) == 0 {
+		return v
+	}
+	return p.apply(split(path), v)
+}
+
+// ApplyJSON masks the JSON encoded value located at path, returning the
+// masked JSON encoded value.
+func (p Profile) ApplyJSON(path string, buf []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return buf, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(p.Apply(path, v))
+}
+
+// apply masks v located at the path components.
+func (p Profile) apply(parts []string, v interface{}) interface{} {
+	for _, r := range p {
+		if match(split(r.Glob), parts) {
+			return r.mask(v)
+		}
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	res := make(map[string]interface{}, len(m))
+	for k, c := range m {
+		res[k] = p.apply(append(parts[:len(parts):len(parts)], k), c)
+	}
+	return res
+}
+
+// mask masks the value.
+func (r Rule) mask(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	switch r.Strategy {
+	case "hash":
+		buf, _ := json.Marshal(v)
+		h := sha256.Sum256(buf)
+		return "sha256:" + hex.EncodeToString(h[:8])
+
+	case "truncate":
+		s, ok := v.(string)
+		if !ok {
+			buf, _ := json.Marshal(v)
+			s = string(buf)
+		}
+		if runes := []rune(s); len(runes) > r.N {
+			s = string(runes[:r.N]) + "…"
+		}
+		return s
+	}
+
+	return Redacted
+}
+
+// split splits the path into its components.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match reports whether the path components match the glob components.
+func match(glob, parts []string) bool {
+	if len(glob) == 0 {
+		return len(parts) == 0
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if match(glob[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(glob[0], parts[0]); !ok {
+		return false
+	}
+	return match(glob[1:], parts[1:])
+}
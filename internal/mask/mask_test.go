@@ -0,0 +1,58 @@
+package mask
+
+import (
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{"users/*/email=redact", "users/*/email=redact", true},
+		{"/users/*/name=truncate", "users/*/name=truncate:4", true},
+		{"**/phone=truncate:2", "**/phone=truncate:2", true},
+		{"users/*/id=hash", "users/*/id=hash", true},
+		{"users/*/email", "", false},
+		{"users/*/email=redact:2", "", false},
+		{"users/*/email=scramble", "", false},
+		{"users/[/email=hash", "", false},
+	}
+	for i, test := range tests {
+		r, err := ParseRule(test.s)
+		if test.ok != (err == nil) {
+			t.Fatalf("test %d expected ok %t, got: %v", i, test.ok, err)
+		}
+		if err == nil && r.String() != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, r.String())
+		}
+	}
+}
+
+func TestApplyJSON(t *testing.T) {
+	var p Profile
+	for _, s := range []string{"users/*/email=redact", "**/phone=truncate:3", "users/*/id=hash"} {
+		if err := p.Set(s); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	tests := []struct {
+		path, v, exp string
+	}{
+		{"/", `{"users":{"a":{"email":"a@b.c","name":"a","phone":"5551234"}}}`, `{"users":{"a":{"email":"[redacted]","name":"a","phone":"555…"}}}`},
+		{"/users", `{"a":{"email":{"x":1},"n":12345678901234567890}}`, `{"a":{"email":"[redacted]","n":12345678901234567890}}`},
+		{"/users/a", `{"contact":{"phone":5551234},"email":null}`, `{"contact":{"phone":"555…"},"email":null}`},
+		{"/users/a/id", `"x"`, `"sha256:ba2df4903a2c14e8"`},
+	}
+	for i, test := range tests {
+		buf, err := p.ApplyJSON(test.path, []byte(test.v))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, string(buf))
+		}
+	}
+}
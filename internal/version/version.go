@@ -0,0 +1,28 @@
+// Package version provides the -version flag for the firebase command line
+// tools, reporting the version and build information of the tool.
+//
+// Importing the package registers the -version flag with the default flag
+// set.
+package version
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/knq/firebase"
+)
+
+// Flag toggles printing the version and build information.
+var Flag = flag.Bool("version", false, "print version and build information and exit")
+
+// Check prints the version and build information and exits when the -version
+// flag was passed. Check should be called after flag.Parse.
+func Check() {
+	if !*Flag {
+		return
+	}
+	fmt.Fprintf(os.Stdout, "%s %s\n", filepath.Base(os.Args[0]), firebase.BuildInfo())
+	os.Exit(0)
+}
@@ -0,0 +1,149 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	// DefaultIterPageSize is the default number of children retrieved per
+	// request by an Iterator.
+	DefaultIterPageSize = 1000
+)
+
+// ErrIteratorDone is the error returned by NextContext when there are no more
+// children.
+var ErrIteratorDone = &Error{
+	Err: "no more children",
+}
+
+// Iterator is a key-ordered iterator over the children of a Firebase
+// database ref, as returned by Iter.
+//
+// Children are retrieved lazily in pages of PageSize children ordered by
+// $key, such that breaking out of the iteration early avoids retrieving the
+// remaining children.
+type Iterator struct {
+	// PageSize is the number of children retrieved per request. If zero, then
+	// DefaultIterPageSize is used. PageSize should be set prior to the first
+	// call to Next.
+	PageSize int
+
+	r    *DatabaseRef
+	ctxt context.Context
+	opts []QueryOption
+
+	page  []KeyValue
+	kv    KeyValue
+	start *string
+	err   error
+	done  bool
+}
+
+// Iter returns an Iterator over the children of Firebase database ref r in
+// key order. The query options are passed to each request, and should not
+// include ordering or limiting query options.
+//
+// For example:
+//
+//	it := firebase.Iter(r, ctxt)
+//	for it.Next() {
+//		kv := it.KeyValue()
+//		/* process kv.Key and kv.Value */
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+func Iter(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) *Iterator {
+	return &Iterator{
+		r:    r,
+		ctxt: ctxt,
+		opts: opts,
+	}
+}
+
+// Next advances the iterator to the next child, retrieving the next page of
+// children when necessary, returning false when there are no more children
+// or an error was encountered.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil || len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.kv, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// NextContext advances the iterator to the next child as with Next, using
+// the passed context to retrieve the next page of children when necessary,
+// and returns the child's key and raw JSON value. ErrIteratorDone is returned
+// when there are no more children.
+//
+// For example:
+//
+//	it := firebase.Iter(r, ctxt)
+//	for {
+//		key, val, err := it.NextContext(ctxt)
+//		if err == firebase.ErrIteratorDone {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		/* process key and val */
+//	}
+func (it *Iterator) NextContext(ctxt context.Context) (string, json.RawMessage, error) {
+	it.ctxt = ctxt
+	if !it.Next() {
+		if it.err != nil {
+			return "", nil, it.err
+		}
+		return "", nil, ErrIteratorDone
+	}
+	return it.kv.Key, it.kv.Value, nil
+}
+
+// fetch retrieves the next page of children.
+func (it *Iterator) fetch() error {
+	n := it.PageSize
+	if n <= 0 {
+		n = DefaultIterPageSize
+	}
+
+	opts := append(it.opts[:len(it.opts):len(it.opts)], OrderBy("$key"), LimitToFirst(uint(n)))
+	if it.start != nil {
+		// startAt is inclusive, so retrieve an extra child
+		opts = append(it.opts[:len(it.opts):len(it.opts)], OrderBy("$key"), StartAt(*it.start), LimitToFirst(uint(n+1)))
+	}
+	kvs, err := GetOrderedContext(it.r, it.ctxt, opts...)
+	if err != nil {
+		return err
+	}
+	if it.start != nil && len(kvs) != 0 && kvs[0].Key == *it.start {
+		kvs = kvs[1:]
+	}
+
+	it.page, it.done = kvs, len(kvs) < n
+	if len(kvs) != 0 {
+		it.start = &kvs[len(kvs)-1].Key
+	}
+	return nil
+}
+
+// KeyValue returns the current child.
+func (it *Iterator) KeyValue() KeyValue {
+	return it.kv
+}
+
+// Err returns the error, if any, encountered while retrieving the children.
+func (it *Iterator) Err() error {
+	return it.err
+}
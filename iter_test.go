@@ -0,0 +1,101 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIter(t *testing.T) {
+	pages := map[string]string{
+		"":    `{"b":2,"a":1}`,
+		`"b"`: `{"c":3,"b":2,"d":4}`,
+		`"d"`: `{"d":4}`,
+	}
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("orderBy") != `"$key"` {
+			t.Errorf("unexpected query: %s", req.URL.RawQuery)
+		}
+		requests = append(requests, q.Get("startAt")+":"+q.Get("limitToFirst"))
+		w.Write([]byte(pages[q.Get("startAt")]))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	it := r.Iter(context.Background())
+	it.PageSize = 2
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.KeyValue().Key+"="+string(it.KeyValue().Value))
+	}
+	if err = it.Err(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := strings.Join(keys, " "); s != "a=1 b=2 c=3 d=4" {
+		t.Errorf("expected a=1 b=2 c=3 d=4, got: %s", s)
+	}
+	if s := strings.Join(requests, " "); s != `:2 "b":3 "d":3` {
+		t.Errorf("unexpected requests: %s", s)
+	}
+
+	// breaking early does not retrieve further pages
+	requests = nil
+	it = r.Iter(context.Background())
+	it.PageSize = 2
+	it.Next()
+	if len(requests) != 1 {
+		t.Errorf("expected 1 request, got: %d", len(requests))
+	}
+}
+
+func TestIterNextContext(t *testing.T) {
+	pages := map[string]string{
+		"":    `{"b":2,"a":1}`,
+		`"b"`: `{"c":3,"b":2}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(pages[req.URL.Query().Get("startAt")]))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	it := r.Iter(context.Background())
+	it.PageSize = 2
+	var keys []string
+	for {
+		key, val, err := it.NextContext(context.Background())
+		if err == ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		keys = append(keys, key+"="+string(val))
+	}
+	if s := strings.Join(keys, " "); s != "a=1 b=2 c=3" {
+		t.Errorf("expected a=1 b=2 c=3, got: %s", s)
+	}
+	if _, _, err = it.NextContext(context.Background()); err != ErrIteratorDone {
+		t.Errorf("expected ErrIteratorDone, got: %v", err)
+	}
+
+	// the passed context is used to retrieve pages
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+	it = r.Iter(context.Background())
+	if _, _, err = it.NextContext(ctxt); err == nil || err == ErrIteratorDone {
+		t.Errorf("expected context error, got: %v", err)
+	}
+}
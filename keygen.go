@@ -0,0 +1,193 @@
+package firebase
+
+import (
+	"encoding/hex"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// KeyGenerator is the common interface for time-sortable key generators.
+//
+// Keys produced by a KeyGenerator sort lexicographically in creation order,
+// and are safe for use as Firebase keys.
+type KeyGenerator interface {
+	GenerateKey() string
+}
+
+// GenerateKeyFunc is an adapter to allow the use of ordinary funcs (such as
+// GeneratePushID) as a KeyGenerator.
+type GenerateKeyFunc func() string
+
+// GenerateKey satisfies the KeyGenerator interface.
+func (f GenerateKeyFunc) GenerateKey() string {
+	return f()
+}
+
+// GenerateKey satisfies the KeyGenerator interface, returning a Push ID.
+func (ig *IDGen) GenerateKey() string {
+	return ig.GeneratePushID()
+}
+
+// UUIDv7Gen generates time-sortable UUIDv7 (RFC 9562) keys.
+//
+// Keys generated within the same millisecond use a monotonic counter to
+// preserve creation order.
+type UUIDv7Gen struct {
+	mu sync.Mutex
+
+	r     *rand.Rand
+	stamp int64
+	seq   uint16
+}
+
+// NewUUIDv7Generator creates a new UUIDv7 key generator.
+func NewUUIDv7Generator(r *rand.Rand) *UUIDv7Gen {
+	if r == nil {
+		r = newSeededRand()
+	}
+
+	return &UUIDv7Gen{
+		r: r,
+	}
+}
+
+// GenerateUUID generates a UUIDv7 as 16 bytes.
+func (g *UUIDv7Gen) GenerateUUID() [16]byte {
+	var u [16]byte
+
+	g.mu.Lock()
+	now := time.Now().UnixNano() / 1e6
+	if now <= g.stamp {
+		// same (or earlier) millisecond, increment counter
+		now = g.stamp
+		g.seq++
+		if g.seq > 0xfff {
+			now++
+			g.seq = uint16(g.r.Intn(0x800))
+		}
+	} else {
+		g.seq = uint16(g.r.Intn(0x800))
+	}
+	g.stamp = now
+	seq := g.seq
+	g.r.Read(u[8:])
+	g.mu.Unlock()
+
+	// 48 bit timestamp
+	for i := 5; i >= 0; i-- {
+		u[i] = byte(now)
+		now >>= 8
+	}
+
+	// version 7 and 12 bit counter
+	u[6] = 0x70 | byte(seq>>8)
+	u[7] = byte(seq)
+
+	// variant
+	u[8] = 0x80 | (u[8] & 0x3f)
+
+	return u
+}
+
+// GenerateKey satisfies the KeyGenerator interface, returning a UUIDv7 in
+// its canonical, lowercase hex string form.
+func (g *UUIDv7Gen) GenerateKey() string {
+	u := g.GenerateUUID()
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf)
+}
+
+const (
+	// ksuidEpoch is the KSUID epoch (2014-05-13T16:53:20Z) in seconds since
+	// the Unix epoch.
+	ksuidEpoch = 1400000000
+
+	// ksuidChars are the base62 characters used for encoding KSUIDs, in
+	// lexicographic order.
+	ksuidChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// ksuidLen is the length of a base62 encoded KSUID.
+	ksuidLen = 27
+)
+
+// KSUIDGen generates time-sortable KSUID keys: a 32-bit timestamp (seconds
+// since the KSUID epoch) followed by 128 bits of entropy, base62 encoded to 27
+// characters.
+//
+// As KSUID timestamps have second precision, keys generated within the same
+// second are ordered by incrementing the previous key's entropy.
+type KSUIDGen struct {
+	mu sync.Mutex
+
+	r     *rand.Rand
+	stamp int64
+	last  [16]byte
+}
+
+// NewKSUIDGenerator creates a new KSUID key generator.
+func NewKSUIDGenerator(r *rand.Rand) *KSUIDGen {
+	if r == nil {
+		r = newSeededRand()
+	}
+
+	return &KSUIDGen{
+		r: r,
+	}
+}
+
+// GenerateKSUID generates a KSUID as 20 bytes.
+func (g *KSUIDGen) GenerateKSUID() [20]byte {
+	var k [20]byte
+
+	g.mu.Lock()
+	now := time.Now().Unix() - ksuidEpoch
+	if now <= g.stamp {
+		// same second, increment entropy
+		now = g.stamp
+		for i := 15; i >= 0; i-- {
+			g.last[i]++
+			if g.last[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.r.Read(g.last[:])
+	}
+	g.stamp = now
+	copy(k[4:], g.last[:])
+	g.mu.Unlock()
+
+	k[0], k[1], k[2], k[3] = byte(now>>24), byte(now>>16), byte(now>>8), byte(now)
+
+	return k
+}
+
+// GenerateKey satisfies the KeyGenerator interface, returning a base62
+// encoded KSUID.
+func (g *KSUIDGen) GenerateKey() string {
+	k := g.GenerateKSUID()
+
+	// base62 encode
+	n := new(big.Int).SetBytes(k[:])
+	base, mod := big.NewInt(62), new(big.Int)
+	buf := make([]byte, ksuidLen)
+	for i := ksuidLen - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		buf[i] = ksuidChars[mod.Int64()]
+	}
+
+	return string(buf)
+}
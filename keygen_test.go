@@ -0,0 +1,41 @@
+package firebase
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyGenerators(t *testing.T) {
+	tests := []struct {
+		g KeyGenerator
+		n int
+	}{
+		{GenerateKeyFunc(GeneratePushID), 20},
+		{NewUUIDv7Generator(nil), 36},
+		{NewKSUIDGenerator(nil), 27},
+	}
+
+	for i, test := range tests {
+		var prev string
+		for j := 0; j < 10000; j++ {
+			key := test.g.GenerateKey()
+			if len(key) != test.n {
+				t.Fatalf("test %d expected key length %d, got: %d (%s)", i, test.n, len(key), key)
+			}
+			if !(strings.Compare(prev, key) < 0) {
+				t.Fatalf("test %d prev key %s is not < than generated key %s", i, prev, key)
+			}
+			prev = key
+		}
+	}
+}
+
+func TestUUIDv7Format(t *testing.T) {
+	key := NewUUIDv7Generator(nil).GenerateKey()
+	if key[14] != '7' {
+		t.Errorf("expected version 7, got: %s", key)
+	}
+	if c := key[19]; c != '8' && c != '9' && c != 'a' && c != 'b' {
+		t.Errorf("expected RFC 9562 variant, got: %s", key)
+	}
+}
@@ -0,0 +1,155 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LagTracker estimates the lag of a stream opened by Watch or Listen, by
+// comparing the arrival time of put and patch events against a server
+// timestamp (see ServerTimestamp) embedded in the event data.
+//
+// The timestamp is read from the Field child of the changed node, or of each
+// of its direct children (ie, for a put of {"updatedAt": ...} or a patch of
+// {"a": {"updatedAt": ...}}), using the most recent timestamp found. The
+// initial put event sent when a stream connects is ignored, as it contains
+// the complete (and typically stale) value of the watched ref.
+//
+// A LagTracker is attached to a ref using the WatchLag option, and should
+// only be used with a single stream.
+type LagTracker struct {
+	// Field is the child field containing the server timestamp (ie,
+	// "updatedAt").
+	Field string
+
+	// Offset is the estimated offset of the server clock from the local clock
+	// (ie, server time minus local time), used to correct the embedded
+	// timestamps. See EstimateServerTimeOffset.
+	Offset time.Duration
+
+	mu      sync.Mutex
+	initial bool
+	lag     time.Duration
+	last    time.Time
+}
+
+// Lag returns the lag of the most recent event containing a server
+// timestamp, or 0 if no such event has been received.
+func (lt *LagTracker) Lag() time.Duration {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.lag
+}
+
+// LastEventTime returns the local arrival time of the most recent event
+// containing a server timestamp.
+func (lt *LagTracker) LastEventTime() time.Time {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.last
+}
+
+// connect resets the tracker for a newly connected stream.
+func (lt *LagTracker) connect(r *DatabaseRef) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.initial = !r.watchChangesOnly
+}
+
+// observe updates the lag for the event.
+func (lt *LagTracker) observe(_ *DatabaseRef, ev *Event) {
+	now := time.Now()
+	if ev.Type != EventTypePut && ev.Type != EventTypePatch {
+		return
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	// skip initial put
+	if lt.initial && ev.Type == EventTypePut {
+		lt.initial = false
+		return
+	}
+
+	env, err := ev.envelope()
+	if err != nil {
+		return
+	}
+	v, err := decodeJSON(env.Data)
+	if err != nil {
+		return
+	}
+
+	// find most recent timestamp
+	m, _ := v.(map[string]interface{})
+	ts, ok := lt.timestamp(m)
+	for _, c := range m {
+		if t, cok := lt.timestamp(c); cok && (!ok || t.After(ts)) {
+			ts, ok = t, true
+		}
+	}
+	if !ok {
+		return
+	}
+
+	lt.lag, lt.last = now.Sub(ts.Add(-lt.Offset)), now
+}
+
+// timestamp returns the server timestamp contained in the Field child of v.
+func (lt *LagTracker) timestamp(v interface{}) (time.Time, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
+	}
+	n, ok := m[lt.Field].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, i*int64(time.Millisecond)), true
+}
+
+// WatchLag is an option that tracks the lag of streams opened by Watch and
+// Listen on the database ref with the lag tracker.
+func WatchLag(lt *LagTracker) Option {
+	return WatchHooks(WatchCallbacks{
+		OnConnect: lt.connect,
+		OnEvent:   lt.observe,
+	})
+}
+
+// EstimateServerTimeOffset estimates the offset of the Firebase server clock
+// from the local clock (ie, server time minus local time) for use with a
+// LagTracker, using the Date header of a shallow read of Firebase database
+// ref r.
+//
+// As the Date header has a resolution of one second, the estimate is only
+// accurate to within a second.
+func EstimateServerTimeOffset(r *DatabaseRef, ctxt context.Context) (time.Duration, error) {
+	info := new(ResponseInfo)
+	start := time.Now()
+	var v interface{}
+	if err := GetContext(r, WithResponseInfo(ctxt, info), &v, Shallow); err != nil {
+		return 0, err
+	}
+	end := time.Now()
+
+	date, err := http.ParseTime(info.Header.Get("Date"))
+	if err != nil {
+		return 0, &Error{
+			Err: "could not parse server date",
+		}
+	}
+
+	// the server time is truncated to the second, so use the middle of the
+	// second
+	date = date.Add(500 * time.Millisecond)
+	return date.Sub(start.Add(end.Sub(start) / 2)), nil
+}
@@ -0,0 +1,51 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLagTracker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "text/event-stream" {
+			w.Write([]byte(`{"a":true}`))
+			return
+		}
+		ms := time.Now().Add(-2*time.Second).UnixNano() / int64(time.Millisecond)
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":{\"a\":{\"updatedAt\":1}}}\n\n")
+		fmt.Fprintf(w, "event: patch\ndata: {\"path\":\"/\",\"data\":{\"a\":{\"updatedAt\":%d},\"b\":{\"updatedAt\":1}}}\n\n", ms)
+	}))
+	defer ts.Close()
+
+	lt := &LagTracker{Field: "updatedAt"}
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), WatchLag(lt))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	offset, err := EstimateServerTimeOffset(r, context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if offset < -2*time.Second || offset > 2*time.Second {
+		t.Errorf("expected offset within 2s, got: %v", offset)
+	}
+
+	events, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for range events {
+	}
+
+	if lag := lt.Lag(); lag < 2*time.Second || lag > 10*time.Second {
+		t.Errorf("expected lag of about 2s, got: %v", lag)
+	}
+	if lt.LastEventTime().IsZero() {
+		t.Errorf("expected last event time")
+	}
+}
@@ -0,0 +1,152 @@
+package firebase
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ErrClosed is the error returned for operations on a closed database ref.
+var ErrClosed = &Error{
+	Err: "database ref closed",
+}
+
+// lifecycle tracks the in-flight requests, open streams, and pending write
+// flushers shared by a database ref and all refs derived from it.
+type lifecycle struct {
+	mu sync.Mutex
+
+	closing  bool
+	closed   bool
+	inflight sync.WaitGroup
+	streams  map[*context.CancelFunc]bool
+	flushers []func(context.Context) error
+}
+
+// newLifecycle creates a new lifecycle.
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		streams: make(map[*context.CancelFunc]bool),
+	}
+}
+
+// flushKey is the context key marking requests issued by flushers while
+// closing.
+type flushKey struct{}
+
+// begin marks the start of an in-flight request, returning ErrClosed if the
+// lifecycle is closed, or is closing and the request was not issued by a
+// flusher.
+func (l *lifecycle) begin(ctxt context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed || (l.closing && ctxt.Value(flushKey{}) == nil) {
+		return ErrClosed
+	}
+	l.inflight.Add(1)
+	return nil
+}
+
+// end marks the end of an in-flight request.
+func (l *lifecycle) end() {
+	l.inflight.Done()
+}
+
+// stream registers a stream's cancel func, returning a func to unregister it.
+func (l *lifecycle) stream(cancel context.CancelFunc) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closing || l.closed {
+		return nil, ErrClosed
+	}
+	p := &cancel
+	l.streams[p] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		delete(l.streams, p)
+	}, nil
+}
+
+// onClose registers a flusher called when the lifecycle is closed, for
+// flushing pending writes.
+func (l *lifecycle) onClose(f func(context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.flushers = append(l.flushers, f)
+}
+
+// lifecycleBody wraps a http.Response body, ending the in-flight request
+// when closed.
+type lifecycleBody struct {
+	io.ReadCloser
+
+	once sync.Once
+	l    *lifecycle
+}
+
+// Close satisfies the io.Closer interface.
+func (lb *lifecycleBody) Close() error {
+	lb.once.Do(lb.l.end)
+	return lb.ReadCloser.Close()
+}
+
+// Close gracefully shuts down the database ref, and all refs derived from
+// the same NewDatabaseRef call.
+//
+// Close stops accepting new operations (which will fail with ErrClosed),
+// flushes pending writes, cancels all Watch and Listen streams, and then
+// waits for in-flight requests to complete, until the context is done.
+//
+// Returns the first error encountered flushing writes, or the context's
+// error if in-flight requests did not complete before the context was done.
+func (r *DatabaseRef) Close(ctxt context.Context) error {
+	l := r.life
+
+	// stop accepting operations, but allow flushers to issue writes
+	l.mu.Lock()
+	if l.closing || l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closing = true
+	flushers := l.flushers
+	l.mu.Unlock()
+
+	// flush pending writes
+	var firstErr error
+	flushCtxt := context.WithValue(ctxt, flushKey{}, true)
+	for _, f := range flushers {
+		if err := f(flushCtxt); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// close and cancel streams
+	l.mu.Lock()
+	l.closed = true
+	for cancel := range l.streams {
+		(*cancel)()
+	}
+	l.mu.Unlock()
+
+	// wait for in-flight requests
+	done := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctxt.Done():
+		if firstErr == nil {
+			firstErr = ctxt.Err()
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,57 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n"))
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var flushed bool
+	r.life.onClose(func(ctxt context.Context) error {
+		flushed = true
+		return r.Ref("/a").SetContext(ctxt, 1)
+	})
+
+	events, err := Watch(r.Ref("/b"), context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	<-events
+
+	ctxt, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = r.Close(ctxt); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !flushed {
+		t.Errorf("expected flusher to be called")
+	}
+
+	// stream should be closed
+	for range events {
+	}
+
+	if err = r.Ref("/c").Get(nil); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package firebase
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultListConcurrency is the default number of concurrent requests
+	// issued by List.
+	DefaultListConcurrency = 8
+)
+
+// TreeLister expands path globs against a Firebase database by walking
+// child keys level by level.
+type TreeLister struct {
+	// Concurrency is the maximum number of concurrent requests. If zero,
+	// then DefaultListConcurrency is used.
+	Concurrency int
+
+	// PageSize is the number of keys to retrieve per request. If zero, then
+	// the keys of each node are retrieved with a single shallow query.
+	//
+	// Shallow queries cannot be paginated, and may fail for nodes with very
+	// many children. When set, keys are instead retrieved in pages ordered by
+	// $key, at the cost of transferring the child values.
+	PageSize int
+}
+
+// List expands the path glob (ie, "/users/*/sessions/*") relative to
+// Firebase database ref r, returning the sorted absolute paths (relative to
+// r) of the matching nodes.
+//
+// Each glob component is matched against child keys using path.Match. Keys
+// are retrieved level by level, with each level's nodes listed concurrently.
+func (l *TreeLister) List(r *DatabaseRef, ctxt context.Context, glob string) ([]string, error) {
+	parts := splitPath(glob)
+	paths := []string{"/"}
+
+	// index of the last component with a pattern
+	last := -1
+	for i, part := range parts {
+		if isGlob(part) {
+			last = i
+		}
+	}
+
+	for i, part := range parts {
+		if !isGlob(part) {
+			for j := range paths {
+				paths[j] = joinPath(paths[j], part)
+			}
+			continue
+		}
+
+		var err error
+		paths, err = l.expand(r, ctxt, paths, part)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return paths, nil
+		}
+
+		// literal components after the last pattern need to exist
+		if i == last && i != len(parts)-1 {
+			for j := range paths {
+				paths[j] = joinPath(paths[j], strings.Join(parts[i+1:], "/"))
+			}
+			return l.existing(r, ctxt, paths)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// expand lists the children of each path, returning the paths of those
+// children whose key matches the pattern.
+func (l *TreeLister) expand(r *DatabaseRef, ctxt context.Context, paths []string, pattern string) ([]string, error) {
+	var res []string
+	err := l.each(ctxt, paths, func(p string) ([]string, error) {
+		keys, err := l.keys(r.Ref(p), ctxt)
+		if err != nil {
+			return nil, err
+		}
+		var matches []string
+		for _, k := range keys {
+			if ok, _ := path.Match(pattern, k); ok {
+				matches = append(matches, joinPath(p, k))
+			}
+		}
+		return matches, nil
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(res)
+	return res, nil
+}
+
+// existing returns the paths that exist.
+func (l *TreeLister) existing(r *DatabaseRef, ctxt context.Context, paths []string) ([]string, error) {
+	var res []string
+	err := l.each(ctxt, paths, func(p string) ([]string, error) {
+		var v interface{}
+		if err := r.Ref(p).GetContext(ctxt, &v, Shallow); err != nil || v == nil {
+			return nil, err
+		}
+		return []string{p}, nil
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(res)
+	return res, nil
+}
+
+// each concurrently calls f for each of the paths, collecting the results
+// into res.
+func (l *TreeLister) each(ctxt context.Context, paths []string, f func(string) ([]string, error), res *[]string) error {
+	ctxt, cancel := context.WithCancel(ctxt)
+	defer cancel()
+
+	concurrency := l.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultListConcurrency
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var firstErr error
+
+	// start workers
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				v, err := f(p)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				*res = append(*res, v...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// queue jobs
+loop:
+	for _, p := range paths {
+		select {
+		case jobs <- p:
+		case <-ctxt.Done():
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctxt.Err()
+}
+
+// keys retrieves the child keys of Firebase database ref r.
+func (l *TreeLister) keys(r *DatabaseRef, ctxt context.Context) ([]string, error) {
+	if l.PageSize <= 0 {
+		var shallow map[string]interface{}
+		if err := r.GetContext(ctxt, &shallow, Shallow); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(shallow))
+		for k := range shallow {
+			keys = append(keys, k)
+		}
+		return keys, nil
+	}
+
+	var keys []string
+	var start *string
+	for {
+		opts := []QueryOption{OrderBy("$key"), LimitToFirst(uint(l.PageSize))}
+		if start != nil {
+			// startAt is inclusive, so retrieve an extra key
+			opts = []QueryOption{OrderBy("$key"), StartAt(*start), LimitToFirst(uint(l.PageSize + 1))}
+		}
+		s, err := GetStreamContext(r, ctxt, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// the response order is not guaranteed, so track the last key
+		var n int
+		prev := start
+		for s.Next() {
+			k := s.KeyValue().Key
+			if prev != nil && k == *prev {
+				continue
+			}
+			keys = append(keys, k)
+			if start == nil || compareKeys(k, *start) > 0 {
+				start = &k
+			}
+			n++
+		}
+		err = s.Err()
+		s.Close()
+		if err != nil {
+			return nil, err
+		}
+		if n < l.PageSize {
+			return keys, nil
+		}
+	}
+}
+
+// isGlob determines if the path component contains a pattern.
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// List expands the path glob (ie, "/users/*/sessions/*") relative to
+// Firebase database ref r, returning the sorted absolute paths (relative to
+// r) of the matching nodes. See TreeLister.
+func List(r *DatabaseRef, glob string) ([]string, error) {
+	return ListContext(r, context.Background(), glob)
+}
+
+// ListContext expands the path glob relative to Firebase database ref r,
+// returning the sorted absolute paths (relative to r) of the matching nodes.
+// See TreeLister.
+func ListContext(r *DatabaseRef, ctxt context.Context, glob string) ([]string, error) {
+	return new(TreeLister).List(r, ctxt, glob)
+}
@@ -0,0 +1,93 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	tree := map[string]interface{}{
+		"users": map[string]interface{}{
+			"alice": map[string]interface{}{
+				"sessions": map[string]interface{}{"s1": true, "s2": true},
+				"profile":  map[string]interface{}{"name": "alice"},
+			},
+			"bob": map[string]interface{}{
+				"sessions": map[string]interface{}{"s3": true},
+			},
+			"carol": map[string]interface{}{
+				"profile": map[string]interface{}{"name": "carol"},
+			},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		v := treeGet(tree, splitPath(strings.TrimSuffix(req.URL.Path, ".json")))
+		m, ok := v.(map[string]interface{})
+		switch {
+		case ok && q.Get("shallow") == "true":
+			keys := make(map[string]interface{}, len(m))
+			for k := range m {
+				keys[k] = true
+			}
+			v = keys
+		case ok && q.Get("orderBy") == `"$key"`:
+			var keys []string
+			for k := range m {
+				var start string
+				json.Unmarshal([]byte(q.Get("startAt")), &start)
+				if k >= start {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			n, _ := strconv.Atoi(q.Get("limitToFirst"))
+			if len(keys) > n {
+				keys = keys[:n]
+			}
+			page := make(map[string]interface{}, len(keys))
+			for _, k := range keys {
+				page[k] = m[k]
+			}
+			v = page
+		}
+		json.NewEncoder(w).Encode(v)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		glob string
+		exp  string
+	}{
+		{"/users/*", "/users/alice /users/bob /users/carol"},
+		{"/users/*/sessions/*", "/users/alice/sessions/s1 /users/alice/sessions/s2 /users/bob/sessions/s3"},
+		{"users/[ab]*/sessions/s?", "/users/alice/sessions/s1 /users/alice/sessions/s2 /users/bob/sessions/s3"},
+		{"/users/*/profile", "/users/alice/profile /users/carol/profile"},
+		{"/users/*/missing/*", ""},
+		{"/users", "/users"},
+	}
+	for _, pageSize := range []int{0, 1, 2} {
+		l := &TreeLister{Concurrency: 2, PageSize: pageSize}
+		for i, test := range tests {
+			paths, err := l.List(r, context.Background(), test.glob)
+			if err != nil {
+				t.Fatalf("page size %d test %d expected no error, got: %v", pageSize, i, err)
+			}
+			if s := strings.Join(paths, " "); s != test.exp {
+				t.Errorf("page size %d test %d expected %q, got: %q", pageSize, i, test.exp, s)
+			}
+		}
+	}
+}
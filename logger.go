@@ -0,0 +1,154 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slogTransport wraps a http.RoundTripper, writing a structured log record
+// for each request once its response body has been closed.
+type slogTransport struct {
+	transport http.RoundTripper
+	l         *slog.Logger
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (st *slogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trans := st.transport
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	start := time.Now()
+	res, err := trans.RoundTrip(req)
+	if err != nil {
+		st.log(req, start, 0, 0, err)
+		return nil, err
+	}
+
+	res.Body = &slogBody{
+		ReadCloser: res.Body,
+		done: func(n int64) {
+			st.log(req, start, res.StatusCode, n, nil)
+		},
+	}
+	return res, nil
+}
+
+// log writes the log record for the request.
+func (st *slogTransport) log(req *http.Request, start time.Time, status int, n int64, err error) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", strings.TrimSuffix(req.URL.Path, ".json")),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("request_bytes", req.ContentLength))
+	}
+	if req.Header.Get("Accept") == "text/event-stream" {
+		attrs = append(attrs, slog.Bool("stream", true))
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case err != nil:
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	default:
+		attrs = append(attrs, slog.Int("status", status), slog.Int64("bytes", n))
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+	}
+
+	st.l.LogAttrs(req.Context(), level, "firebase request", attrs...)
+}
+
+// slogBody wraps a response body, counting the bytes read and calling done
+// once when the body is closed.
+type slogBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(int64)
+}
+
+// Read satisfies the io.Reader interface.
+func (sb *slogBody) Read(p []byte) (int, error) {
+	n, err := sb.ReadCloser.Read(p)
+	sb.n += int64(n)
+	return n, err
+}
+
+// Close satisfies the io.Closer interface.
+func (sb *slogBody) Close() error {
+	err := sb.ReadCloser.Close()
+	sb.once.Do(func() {
+		sb.done(sb.n)
+	})
+	return err
+}
+
+// Logger is an option that writes a structured log record to l for each
+// request made against the database ref (and its children), including each
+// retry attempt and the streams opened by Watch and Listen.
+//
+// A record is written once the response has been read (for streams, once the
+// stream has ended), with the following attributes:
+//
+//	method         the HTTP method (ie, GET, PUT)
+//	path           the database path
+//	duration       the time taken, including reading the response
+//	status         the HTTP status code
+//	bytes          the number of response bytes read
+//	request_bytes  the number of request bytes sent, if known
+//	stream         true for streams opened by Watch and Listen
+//	error          the error, if the request failed before a response
+//
+// Records are logged at the Info level, or Warn and Error for 4xx and 5xx
+// responses (and failed requests), respectively. Additionally, the lifecycle
+// of streams opened by Watch and Listen is logged (see WatchHooks).
+//
+// Unlike Log, request and response bodies are not logged, and the Logger
+// option can be combined with Watch and Listen.
+func Logger(l *slog.Logger) Option {
+	return func(r *DatabaseRef) error {
+		if l == nil {
+			return errors.New("logger cannot be nil")
+		}
+
+		err := wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &slogTransport{
+				transport: transport,
+				l:         l,
+			}
+		})(r)
+		if err != nil {
+			return err
+		}
+
+		return WatchHooks(WatchCallbacks{
+			OnConnect: func(r *DatabaseRef) {
+				l.Debug("firebase watch connected", "path", r.URL().Path)
+			},
+			OnDisconnect: func(r *DatabaseRef, err error) {
+				if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+					l.Debug("firebase watch disconnected", "path", r.URL().Path, "error", err)
+					return
+				}
+				l.Warn("firebase watch disconnected", "path", r.URL().Path, "error", err)
+			},
+			OnReconnect: func(r *DatabaseRef, attempt int) {
+				l.Info("firebase watch reconnecting", "path", r.URL().Path, "attempt", attempt)
+			},
+		})(r)
+	}
+}
@@ -0,0 +1,86 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Header.Get("Accept") == "text/event-stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n"))
+		case req.URL.Path == "/missing.json":
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`"hello"`))
+		}
+	}))
+	defer ts.Close()
+
+	buf := new(bytes.Buffer)
+	l := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), Logger(l))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var s string
+	if err = r.Ref("a/b").Get(&s); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Ref("missing").Get(&s); err == nil {
+		t.Fatalf("expected error")
+	}
+	events, err := r.Ref("c").Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for range events {
+	}
+
+	var recs []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec map[string]interface{}
+		if err = dec.Decode(&rec); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+
+	exp := []struct {
+		level, msg, path string
+		status           float64
+	}{
+		{"INFO", "firebase request", "/a/b", 200},
+		{"WARN", "firebase request", "/missing", 401},
+		{"DEBUG", "firebase watch connected", "/c", 0},
+		{"INFO", "firebase request", "/c", 200},
+		{"WARN", "firebase watch disconnected", "/c", 0},
+	}
+	if len(recs) != len(exp) {
+		t.Fatalf("expected %d records, got: %d (%s)", len(exp), len(recs), buf)
+	}
+	for i, e := range exp {
+		rec := recs[i]
+		if rec["level"] != e.level || rec["msg"] != e.msg || rec["path"] != e.path {
+			t.Errorf("record %d expected %s %q %s, got: %v", i, e.level, e.msg, e.path, rec)
+		}
+		if e.status != 0 && rec["status"] != e.status {
+			t.Errorf("record %d expected status %v, got: %v", i, e.status, rec["status"])
+		}
+	}
+	if recs[0]["method"] != "GET" || recs[0]["bytes"] != float64(len(`"hello"`)) {
+		t.Errorf("expected method and bytes, got: %v", recs[0])
+	}
+	if recs[3]["stream"] != true {
+		t.Errorf("expected stream record, got: %v", recs[3])
+	}
+}
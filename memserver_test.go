@@ -0,0 +1,210 @@
+package firebase
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memServer is an in-memory test database server implementing the parts of
+// the Firebase REST API used by the tests (ie, ETags, shallow reads, and
+// simple range queries).
+type memServer struct {
+	sync.Mutex
+	root interface{}
+
+	// hook is called before each request is handled, with the lock held.
+	hook func(*memServer, *http.Request)
+
+	// reqs are the handled requests, as "METHOD /path".
+	reqs []string
+}
+
+// get returns the value stored at path.
+func (s *memServer) get(path string) interface{} {
+	v := s.root
+	for _, k := range splitPath(path) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// set stores the value at path, pruning empty nodes.
+func (s *memServer) set(path string, v interface{}) {
+	s.root = memSet(s.root, splitPath(path), v)
+}
+
+func memSet(node interface{}, keys []string, v interface{}) interface{} {
+	if len(keys) == 0 {
+		if m, ok := v.(map[string]interface{}); ok && len(m) == 0 {
+			return nil
+		}
+		return v
+	}
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	if x := memSet(m[keys[0]], keys[1:], v); x != nil {
+		m[keys[0]] = x
+	} else {
+		delete(m, keys[0])
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// memResolve replaces server timestamps in the value.
+func memResolve(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	if len(m) == 1 && m[".sv"] == "timestamp" {
+		return float64(time.Now().UnixNano() / int64(time.Millisecond))
+	}
+	for k, x := range m {
+		m[k] = memResolve(x)
+	}
+	return m
+}
+
+// memQuery filters the children of m using the orderBy, startAt, endAt, and
+// limitToFirst query parameters, for numeric child fields only.
+func memQuery(m map[string]interface{}, q url.Values) map[string]interface{} {
+	var field string
+	json.Unmarshal([]byte(q.Get("orderBy")), &field)
+	bound := func(name string, def float64) float64 {
+		if s := q.Get(name); s != "" {
+			def, _ = strconv.ParseFloat(s, 64)
+		}
+		return def
+	}
+	start, end := bound("startAt", math.Inf(-1)), bound("endAt", math.Inf(1))
+
+	type child struct {
+		key string
+		n   float64
+	}
+	var children []child
+	for k, x := range m {
+		c, _ := x.(map[string]interface{})
+		n, ok := c[field].(float64)
+		if ok && n >= start && n <= end {
+			children = append(children, child{k, n})
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].n == children[j].n {
+			return children[i].key < children[j].key
+		}
+		return children[i].n < children[j].n
+	})
+	if n, err := strconv.Atoi(q.Get("limitToFirst")); err == nil && n < len(children) {
+		children = children[:n]
+	}
+
+	res := make(map[string]interface{}, len(children))
+	for _, c := range children {
+		res[c.key] = m[c.key]
+	}
+	return res
+}
+
+// etag returns the ETag of the value stored at path.
+func (s *memServer) etag(path string) string {
+	buf, _ := json.Marshal(s.get(path))
+	h := sha1.Sum(buf)
+	return hex.EncodeToString(h[:])
+}
+
+// keys returns the sorted keys of the value stored at path.
+func (s *memServer) keys(path string) []string {
+	m, _ := s.get(path).(map[string]interface{})
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *memServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	path := strings.TrimSuffix(req.URL.Path, ".json")
+	s.reqs = append(s.reqs, req.Method+" "+path)
+	if s.hook != nil {
+		s.hook(s, req)
+	}
+
+	// conditional request
+	if etag := req.Header.Get("If-Match"); etag != "" && etag != s.etag(path) {
+		w.Header().Set("ETag", s.etag(path))
+		w.WriteHeader(http.StatusPreconditionFailed)
+		buf, _ := json.Marshal(s.get(path))
+		w.Write(buf)
+		return
+	}
+
+	var v interface{}
+	if req.Method != "GET" && req.Method != "DELETE" {
+		buf, _ := ioutil.ReadAll(req.Body)
+		if err := json.Unmarshal(buf, &v); err != nil {
+			http.Error(w, `{"error":"invalid data"}`, http.StatusBadRequest)
+			return
+		}
+		v = memResolve(v)
+	}
+
+	switch req.Method {
+	case "GET":
+		v = s.get(path)
+		if req.Header.Get("X-Firebase-ETag") == "true" {
+			w.Header().Set("ETag", s.etag(path))
+		}
+		if m, ok := v.(map[string]interface{}); ok && req.URL.Query().Get("orderBy") != "" {
+			v = memQuery(m, req.URL.Query())
+		}
+		if m, ok := v.(map[string]interface{}); ok && req.URL.Query().Get("shallow") == "true" {
+			shallow := make(map[string]interface{}, len(m))
+			for k := range m {
+				shallow[k] = true
+			}
+			v = shallow
+		}
+	case "PUT":
+		s.set(path, v)
+	case "PATCH":
+		m, _ := v.(map[string]interface{})
+		for k, x := range m {
+			s.set(path+"/"+k, x)
+		}
+	case "POST":
+		id := GeneratePushID()
+		s.set(path+"/"+id, v)
+		v = map[string]string{"name": id}
+	case "DELETE":
+		s.set(path, nil)
+		v = nil
+	}
+
+	buf, _ := json.Marshal(v)
+	w.Write(buf)
+}
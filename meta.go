@@ -0,0 +1,312 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// DefaultMetaKey is the default child key used to store node metadata.
+	DefaultMetaKey = "_meta"
+)
+
+// Meta is the metadata stored alongside a node written through a MetaRef.
+type Meta struct {
+	UpdatedAt ServerTimestamp `json:"updatedAt"`
+	UpdatedBy string          `json:"updatedBy,omitempty"`
+}
+
+// MetaRule controls the metadata maintained for nodes beneath a path prefix.
+type MetaRule struct {
+	// Prefix is the database path prefix the rule applies to (ie, "/users").
+	Prefix string
+
+	// UpdatedBy is the value written to updatedBy. When empty, the MetaRef's
+	// default updatedBy is used.
+	UpdatedBy string
+
+	// Disabled disables metadata for nodes beneath the prefix.
+	Disabled bool
+}
+
+// MetaRef wraps a Firebase database ref, transparently maintaining a metadata
+// child node (by default _meta/updatedAt and _meta/updatedBy) on Set, Push,
+// Update, UpdateFields, and SetIfMatch.
+//
+// Metadata is only added to values that encode to JSON objects. Writes made
+// directly with the wrapped database ref (see DatabaseRef) do not maintain
+// metadata.
+type MetaRef struct {
+	ref *DatabaseRef
+
+	key       string
+	updatedBy string
+	rules     []MetaRule
+}
+
+// MetaOption is an option to modify a MetaRef.
+type MetaOption func(*MetaRef)
+
+// MetaKey is a meta option that sets the child key used to store metadata.
+func MetaKey(key string) MetaOption {
+	return func(m *MetaRef) {
+		m.key = key
+	}
+}
+
+// MetaRules is a meta option that adds per path prefix metadata rules. When
+// multiple rules match a path, the rule with the longest prefix is used.
+func MetaRules(rules ...MetaRule) MetaOption {
+	return func(m *MetaRef) {
+		m.rules = append(m.rules, rules...)
+	}
+}
+
+// NewMetaRef wraps the Firebase database ref r, maintaining node metadata on
+// writes with updatedBy as the default updater.
+func NewMetaRef(r *DatabaseRef, updatedBy string, opts ...MetaOption) *MetaRef {
+	m := &MetaRef{
+		ref:       r,
+		key:       DefaultMetaKey,
+		updatedBy: updatedBy,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	return m
+}
+
+// Ref creates a new child MetaRef, locked to the specified path, sharing the
+// same metadata configuration.
+func (m *MetaRef) Ref(path string, opts ...Option) *MetaRef {
+	return &MetaRef{
+		ref:       m.ref.Ref(path, opts...),
+		key:       m.key,
+		updatedBy: m.updatedBy,
+		rules:     m.rules,
+	}
+}
+
+// DatabaseRef returns the wrapped database ref.
+func (m *MetaRef) DatabaseRef() *DatabaseRef {
+	return m.ref
+}
+
+// meta returns the metadata for the ref, or nil if metadata is disabled for
+// the ref's path.
+func (m *MetaRef) meta() *Meta {
+	path := m.ref.URL().Path
+
+	var rule *MetaRule
+	for i, mr := range m.rules {
+		if !pathHasPrefix(path, mr.Prefix) {
+			continue
+		}
+		if rule == nil || len(mr.Prefix) > len(rule.Prefix) {
+			rule = &m.rules[i]
+		}
+	}
+
+	updatedBy := m.updatedBy
+	if rule != nil {
+		if rule.Disabled {
+			return nil
+		}
+		if rule.UpdatedBy != "" {
+			updatedBy = rule.UpdatedBy
+		}
+	}
+
+	return &Meta{
+		UpdatedBy: updatedBy,
+	}
+}
+
+// withMeta encodes v for the operation using the ref's JSON and value codecs,
+// adding the metadata when v encodes to a JSON object and metadata is
+// enabled. The encoded value is returned as a reader, so that it is not
+// encoded again.
+func (m *MetaRef) withMeta(op OpType, v interface{}) (interface{}, error) {
+	meta := m.meta()
+	if meta == nil {
+		return v, nil
+	}
+	if _, ok := v.(io.Reader); ok || v == nil {
+		return v, nil
+	}
+
+	body, err := m.ref.encodeBody(op, v)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &obj); err != nil || obj == nil {
+		return bytes.NewReader(buf), nil
+	}
+
+	// add meta to object
+	if op == OpTypeUpdate {
+		obj[m.key+"/updatedAt"], _ = json.Marshal(meta.UpdatedAt)
+		if meta.UpdatedBy != "" {
+			obj[m.key+"/updatedBy"], _ = json.Marshal(meta.UpdatedBy)
+		}
+	} else {
+		obj[m.key], err = json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if buf, err = json.Marshal(obj); err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// Get retrieves the values stored at the Firebase database ref and decodes
+// them into d.
+func (m *MetaRef) Get(d interface{}, opts ...QueryOption) error {
+	return Get(m.ref, d, opts...)
+}
+
+// GetContext retrieves the values stored at the Firebase database ref and
+// decodes them into d, canceling the operation when the passed context is
+// done.
+func (m *MetaRef) GetContext(ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return GetContext(m.ref, ctxt, d, opts...)
+}
+
+// Set stores values v and metadata at the Firebase database ref.
+func (m *MetaRef) Set(v interface{}, opts ...QueryOption) error {
+	return m.SetContext(context.Background(), v, opts...)
+}
+
+// SetContext stores values v and metadata at the Firebase database ref,
+// canceling the operation when the passed context is done.
+func (m *MetaRef) SetContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	v, err := m.withMeta(OpTypeSet, v)
+	if err != nil {
+		return err
+	}
+	return SetContext(m.ref, ctxt, v, opts...)
+}
+
+// SetIfMatch stores values v and metadata at the Firebase database ref, only
+// if the ETag of the currently stored value matches etag. See SetIfMatch.
+func (m *MetaRef) SetIfMatch(etag string, v interface{}, opts ...QueryOption) error {
+	return m.SetIfMatchContext(context.Background(), etag, v, opts...)
+}
+
+// SetIfMatchContext stores values v and metadata at the Firebase database
+// ref, only if the ETag of the currently stored value matches etag, canceling
+// the operation when the passed context is done.
+func (m *MetaRef) SetIfMatchContext(ctxt context.Context, etag string, v interface{}, opts ...QueryOption) error {
+	if v == Delete {
+		return errDeleteValue
+	}
+	v, err := m.withMeta(OpTypeSet, v)
+	if err != nil {
+		return err
+	}
+	return SetIfMatchContext(m.ref, ctxt, etag, v, opts...)
+}
+
+// Push pushes values v and metadata to the Firebase database ref, returning
+// the name (ID) of the pushed node.
+func (m *MetaRef) Push(v interface{}, opts ...QueryOption) (string, error) {
+	return m.PushContext(context.Background(), v, opts...)
+}
+
+// PushContext pushes values v and metadata to the Firebase database ref,
+// returning the name (ID) of the pushed node, canceling the operation when
+// the passed context is done.
+func (m *MetaRef) PushContext(ctxt context.Context, v interface{}, opts ...QueryOption) (string, error) {
+	if m.ref.hasCodecs() {
+		// the value's path must be known to encode it
+		id := GeneratePushID()
+		return id, m.Ref(id).SetContext(ctxt, v, opts...)
+	}
+
+	v, err := m.withMeta(OpTypePush, v)
+	if err != nil {
+		return "", err
+	}
+	return PushContext(m.ref, ctxt, v, opts...)
+}
+
+// Update updates the values stored at the Firebase database ref to v, and
+// updates the metadata.
+func (m *MetaRef) Update(v interface{}, opts ...QueryOption) error {
+	return m.UpdateContext(context.Background(), v, opts...)
+}
+
+// UpdateContext updates the values stored at the Firebase database ref to v,
+// and updates the metadata, canceling the operation when the passed context
+// is done.
+func (m *MetaRef) UpdateContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	v, err := m.withMeta(OpTypeUpdate, v)
+	if err != nil {
+		return err
+	}
+	return UpdateContext(m.ref, ctxt, v, opts...)
+}
+
+// UpdateFields updates the fields stored at the Firebase database ref, and
+// updates the metadata. See UpdateFields.
+func (m *MetaRef) UpdateFields(fields map[string]interface{}, opts ...QueryOption) error {
+	return m.UpdateFieldsContext(context.Background(), fields, opts...)
+}
+
+// UpdateFieldsContext updates the fields stored at the Firebase database
+// ref, and updates the metadata, canceling the operation when the passed
+// context is done.
+func (m *MetaRef) UpdateFieldsContext(ctxt context.Context, fields map[string]interface{}, opts ...QueryOption) error {
+	u, err := multiPathUpdate(fields)
+	if err != nil {
+		return err
+	}
+	if len(u) == 0 {
+		return nil
+	}
+	return m.UpdateContext(ctxt, u, opts...)
+}
+
+// Remove removes the values (and metadata) stored at the Firebase database
+// ref.
+func (m *MetaRef) Remove(opts ...QueryOption) error {
+	return Remove(m.ref, opts...)
+}
+
+// GetMeta retrieves the metadata stored at the Firebase database ref.
+func (m *MetaRef) GetMeta() (*Meta, error) {
+	var meta Meta
+	err := Get(m.ref.Ref(m.key), &meta)
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// pathHasPrefix determines if the database path has the path prefix,
+// matching only on whole path components.
+func pathHasPrefix(path, prefix string) bool {
+	path, prefix = "/"+strings.Trim(path, "/"), "/"+strings.Trim(prefix, "/")
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
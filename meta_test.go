@@ -0,0 +1,108 @@
+package firebase
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetaRef(t *testing.T) {
+	s := &memServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	m := NewMetaRef(r, "alice", MetaRules(
+		MetaRule{Prefix: "/admin", UpdatedBy: "admin"},
+		MetaRule{Prefix: "/raw", Disabled: true},
+	))
+	if m.DatabaseRef() != r {
+		t.Errorf("expected wrapped ref")
+	}
+	ctxt := context.Background()
+	v := map[string]interface{}{"n": "x"}
+
+	tests := []struct {
+		path      string
+		updatedBy string
+		write     func(*MetaRef) (string, error)
+	}{
+		{"set", "alice", func(m *MetaRef) (string, error) {
+			return "", m.Set(v)
+		}},
+		{"setContext", "alice", func(m *MetaRef) (string, error) {
+			return "", m.SetContext(ctxt, v)
+		}},
+		{"setIfMatch", "alice", func(m *MetaRef) (string, error) {
+			return "", m.SetIfMatch(s.etag("/setIfMatch"), v)
+		}},
+		{"push", "alice", func(m *MetaRef) (string, error) {
+			return m.Push(v)
+		}},
+		{"pushContext", "alice", func(m *MetaRef) (string, error) {
+			return m.PushContext(ctxt, v)
+		}},
+		{"update", "alice", func(m *MetaRef) (string, error) {
+			return "", m.Update(v)
+		}},
+		{"updateContext", "alice", func(m *MetaRef) (string, error) {
+			return "", m.UpdateContext(ctxt, v)
+		}},
+		{"updateFields", "alice", func(m *MetaRef) (string, error) {
+			return "", m.UpdateFields(map[string]interface{}{"n": "x"})
+		}},
+		{"updateFieldsContext", "alice", func(m *MetaRef) (string, error) {
+			return "", m.UpdateFieldsContext(ctxt, map[string]interface{}{"n": "x"})
+		}},
+		{"admin/set", "admin", func(m *MetaRef) (string, error) {
+			return "", m.Set(v)
+		}},
+		{"raw/set", "", func(m *MetaRef) (string, error) {
+			return "", m.Set(v)
+		}},
+	}
+
+	for i, test := range tests {
+		id, err := test.write(m.Ref(test.path))
+		if err != nil {
+			t.Fatalf("test %d (%s) expected no error, got: %v", i, test.path, err)
+		}
+		path := "/" + test.path
+		if id != "" {
+			path += "/" + id
+		}
+
+		if n := s.get(path + "/n"); n != "x" {
+			t.Errorf("test %d (%s) expected value to be written, got: %v", i, test.path, n)
+		}
+		meta, _ := s.get(path + "/" + DefaultMetaKey).(map[string]interface{})
+		if test.updatedBy == "" {
+			if meta != nil {
+				t.Errorf("test %d (%s) expected no metadata, got: %v", i, test.path, meta)
+			}
+			continue
+		}
+		if meta["updatedBy"] != test.updatedBy {
+			t.Errorf("test %d (%s) expected updatedBy %q, got: %v", i, test.path, test.updatedBy, meta)
+		}
+		if _, ok := meta["updatedAt"].(float64); !ok {
+			t.Errorf("test %d (%s) expected updatedAt timestamp, got: %v", i, test.path, meta)
+		}
+
+		got, err := m.Ref(path).GetMeta()
+		if err != nil {
+			t.Fatalf("test %d (%s) expected no error, got: %v", i, test.path, err)
+		}
+		if got.UpdatedBy != test.updatedBy || got.UpdatedAt.Time().IsZero() {
+			t.Errorf("test %d (%s) expected metadata, got: %+v", i, test.path, got)
+		}
+	}
+
+	// stale etag
+	if err = m.Ref("setIfMatch").SetIfMatch("v0", v); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed, got: %v", err)
+	}
+}
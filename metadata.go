@@ -0,0 +1,177 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Limits are the documented limits of a Firebase Realtime Database instance.
+//
+// See: https://firebase.google.com/docs/database/usage/limits
+type Limits struct {
+	// MaxConnections is the maximum number of simultaneous connections.
+	MaxConnections int
+
+	// MaxWritesPerSecond is the maximum sustained number of writes per
+	// second.
+	MaxWritesPerSecond int
+
+	// MaxDepth is the maximum depth of child nodes.
+	MaxDepth int
+
+	// MaxKeyBytes is the maximum length of a key, in UTF-8 encoded bytes.
+	MaxKeyBytes int
+
+	// MaxStringBytes is the maximum size of a single string value, in UTF-8
+	// encoded bytes.
+	MaxStringBytes int
+
+	// MaxWriteBytes is the maximum size of a single write request made via
+	// the REST API.
+	MaxWriteBytes int
+
+	// MaxResponseBytes is the maximum size of the data returned by a single
+	// read.
+	MaxResponseBytes int
+}
+
+// DefaultLimits are the documented limits of Firebase Realtime Database
+// instances.
+var DefaultLimits = Limits{
+	MaxConnections:     200000,
+	MaxWritesPerSecond: 1000,
+	MaxDepth:           32,
+	MaxKeyBytes:        768,
+	MaxStringBytes:     10 * 1024 * 1024,
+	MaxWriteBytes:      256 * 1024 * 1024,
+	MaxResponseBytes:   256 * 1024 * 1024,
+}
+
+// CheckWrite checks the JSON encoded value that would be written to the
+// database path against the limits, returning an error describing the first
+// exceeded limit.
+func (l Limits) CheckWrite(path string, buf []byte) error {
+	if l.MaxWriteBytes != 0 && len(buf) > l.MaxWriteBytes {
+		return &Error{
+			Err: fmt.Sprintf("write of %d bytes exceeds maximum write size of %d bytes", len(buf), l.MaxWriteBytes),
+		}
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not decode json: %v", err),
+		}
+	}
+
+	path = strings.Trim(path, "/")
+	var depth int
+	if path != "" {
+		depth = strings.Count(path, "/") + 1
+	}
+	return l.check(path, depth, v)
+}
+
+// check checks the value at path and depth against the limits.
+func (l Limits) check(path string, depth int, v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		if l.MaxStringBytes != 0 && len(x) > l.MaxStringBytes {
+			return &Error{
+				Err: fmt.Sprintf("/%s: string of %d bytes exceeds maximum string size of %d bytes", path, len(x), l.MaxStringBytes),
+			}
+		}
+
+	case map[string]interface{}:
+		if len(x) != 0 && l.MaxDepth != 0 && depth+1 > l.MaxDepth {
+			return &Error{
+				Err: fmt.Sprintf("/%s: children exceed maximum depth of %d", path, l.MaxDepth),
+			}
+		}
+		for k, c := range x {
+			p := strings.TrimPrefix(path+"/"+k, "/")
+			if l.MaxKeyBytes != 0 && len(k) > l.MaxKeyBytes {
+				return &Error{
+					Err: fmt.Sprintf("/%s: key of %d bytes exceeds maximum key length of %d bytes", p, len(k), l.MaxKeyBytes),
+				}
+			}
+			if err := l.check(p, depth+1, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DatabaseMetadata is the metadata and limits of a Firebase Realtime
+// Database instance.
+type DatabaseMetadata struct {
+	// Instance is the database instance, as returned by the management API.
+	Instance *DatabaseInstance
+
+	// Location is the location (region) of the database instance (ie,
+	// "us-central1").
+	Location string
+
+	// WriteSizeLimit is the defaultWriteSizeLimit database setting, and is
+	// one of the WriteSizeLimit* values.
+	WriteSizeLimit string
+
+	// Limits are the limits of the database instance.
+	Limits Limits
+}
+
+// GetDatabaseMetadata retrieves the metadata for the database instance of
+// Firebase database ref r. See GetDatabaseMetadataContext.
+func GetDatabaseMetadata(r *DatabaseRef, client *http.Client, projectID string) (*DatabaseMetadata, error) {
+	return GetDatabaseMetadataContext(r, context.Background(), client, projectID)
+}
+
+// GetDatabaseMetadataContext retrieves the metadata for the database instance
+// of Firebase database ref r, canceling the operation when the passed context
+// is done.
+//
+// The instance state, type, and location are retrieved from the management
+// API via client (see GetDatabaseInstance), and the write size limit from
+// the database settings. The database instance ID is determined from the
+// ref's URL (ie, "<databaseID>.firebaseio.com").
+func GetDatabaseMetadataContext(r *DatabaseRef, ctxt context.Context, client *http.Client, projectID string) (*DatabaseMetadata, error) {
+	databaseID := r.URL().Hostname()
+	if i := strings.Index(databaseID, "."); i != -1 {
+		databaseID = databaseID[:i]
+	}
+	if databaseID == "" {
+		return nil, &Error{
+			Err: "could not determine database instance id",
+		}
+	}
+
+	inst, err := GetDatabaseInstance(ctxt, client, projectID, "", databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if inst == nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("database instance %s not found", databaseID),
+		}
+	}
+
+	size, err := GetDefaultWriteSizeLimitContext(r, ctxt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DatabaseMetadata{
+		Instance:       inst,
+		Location:       inst.Location(),
+		WriteSizeLimit: size,
+		Limits:         DefaultLimits,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLimitsCheckWrite(t *testing.T) {
+	l := Limits{
+		MaxDepth:       3,
+		MaxKeyBytes:    4,
+		MaxStringBytes: 5,
+		MaxWriteBytes:  64,
+	}
+	tests := []struct {
+		path, v string
+		err     string
+	}{
+		{"", `{"a":{"b":{"c":1}}}`, ""},
+		{"a", `{"b":{"c":1}}`, ""},
+		{"a/b", `{"c":{"d":1}}`, "/a/b/c: children exceed maximum depth of 3"},
+		{"", `{"abcde":1}`, "/abcde: key of 5 bytes exceeds maximum key length of 4 bytes"},
+		{"/a/", `{"b":"abcdef"}`, "/a/b: string of 6 bytes exceeds maximum string size of 5 bytes"},
+		{"", `"` + strings.Repeat("a", 64) + `"`, "write of 66 bytes exceeds maximum write size of 64 bytes"},
+		{"", `{`, "could not decode json: unexpected EOF"},
+	}
+	for i, test := range tests {
+		err := l.CheckWrite(test.path, []byte(test.v))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case test.err != "" && (err == nil || err.(*Error).Err != test.err):
+			t.Errorf("test %d expected error %q, got: %v", i, test.err, err)
+		}
+	}
+}
+
+func TestGetDatabaseMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v1beta/projects/p/locations/-/instances/db":
+			w.Write([]byte(`{"name":"projects/123/locations/europe-west1/instances/db","type":"USER_DATABASE","state":"ACTIVE"}`))
+		case "/.settings/defaultWriteSizeLimit.json":
+			w.Write([]byte(`"large"`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			http.NotFound(w, req)
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	// route all requests to the test server
+	trans := &rewriteTransport{host: u.Host}
+	r, err := NewDatabaseRef(URL("http://db.europe-west1.firebasedatabase.app/"), Transport(trans))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	md, err := r.GetDatabaseMetadata(&http.Client{Transport: trans}, "p")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if md.Instance.State != "ACTIVE" || md.Instance.Type != "USER_DATABASE" {
+		t.Errorf("expected instance state and type, got: %+v", md.Instance)
+	}
+	if md.Location != "europe-west1" {
+		t.Errorf("expected location europe-west1, got: %q", md.Location)
+	}
+	if md.WriteSizeLimit != WriteSizeLimitLarge {
+		t.Errorf("expected write size limit %q, got: %q", WriteSizeLimitLarge, md.WriteSizeLimit)
+	}
+	if md.Limits != DefaultLimits {
+		t.Errorf("expected default limits, got: %+v", md.Limits)
+	}
+}
+
+// rewriteTransport sends all requests to host over http.
+type rewriteTransport struct {
+	host string
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", rt.host
+	return http.DefaultTransport.RoundTrip(req)
+}
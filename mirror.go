@@ -0,0 +1,143 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MirrorFunc is a change callback for a Mirror, called with the absolute
+// path (relative to the mirrored ref) of each change, after the change has
+// been applied.
+type MirrorFunc func(path string, ev *Event)
+
+// Mirror maintains an in-memory copy of a Firebase ref, applying put and
+// patch events as they arrive from the server.
+type Mirror struct {
+	mu sync.RWMutex
+
+	r    *DatabaseRef
+	root interface{}
+
+	callbacks []MirrorFunc
+
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewMirror creates a new mirror of the Firebase ref r. The mirror is
+// populated once Run is called.
+func NewMirror(r *DatabaseRef) *Mirror {
+	return &Mirror{
+		r:     r,
+		ready: make(chan struct{}),
+	}
+}
+
+// OnChange registers a change callback. Callbacks are called serially, in
+// the order registered.
+func (m *Mirror) OnChange(fn MirrorFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.callbacks = append(m.callbacks, fn)
+}
+
+// Ready returns a channel that is closed once the mirror has received the
+// initial value of the ref.
+func (m *Mirror) Ready() <-chan struct{} {
+	return m.ready
+}
+
+// Run listens for events on the mirrored ref, applying them to the mirror
+// until the context is done. Run reconnects as with Listen, replacing the
+// mirror's contents with the ref's current value upon reconnecting.
+func (m *Mirror) Run(ctxt context.Context, opts ...QueryOption) error {
+	events := Listen(m.r, ctxt, []EventType{EventTypePut, EventTypePatch}, opts...)
+	for ev := range events {
+		if err := m.apply(ev); err != nil {
+			return err
+		}
+	}
+
+	if err := ctxt.Err(); err != nil {
+		return err
+	}
+	return &Error{
+		Err: "mirror listen closed",
+	}
+}
+
+// apply applies the event to the mirror.
+func (m *Mirror) apply(ev *Event) error {
+	var env eventData
+	err := json.Unmarshal(ev.Data, &env)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not decode event data: %v", err),
+		}
+	}
+	data, err := decodeJSON(env.Data)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not decode event data: %v", err),
+		}
+	}
+
+	m.mu.Lock()
+	m.root = treeApply(m.root, ev.Type, env.Path, data)
+	callbacks := m.callbacks
+	m.mu.Unlock()
+
+	if ev.Type == EventTypePut {
+		m.readyOnce.Do(func() {
+			close(m.ready)
+		})
+	}
+
+	// notify
+	if len(callbacks) != 0 {
+		changes, err := ev.Patch()
+		if err != nil {
+			return err
+		}
+		for _, path := range PatchPaths(changes) {
+			for _, fn := range callbacks {
+				fn(path, ev)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Get decodes the mirrored value at path (relative to the mirrored ref) into
+// d.
+func (m *Mirror) Get(path string, d interface{}) error {
+	m.mu.RLock()
+	buf, err := json.Marshal(treeGet(m.root, splitPath(path)))
+	m.mu.RUnlock()
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+
+	err = json.Unmarshal(buf, d)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns the JSON encoded value of the mirror.
+func (m *Mirror) Snapshot() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return json.Marshal(m.root)
+}
@@ -0,0 +1,45 @@
+package firebase
+
+import "testing"
+
+func TestMirrorApply(t *testing.T) {
+	m := NewMirror(nil)
+
+	var paths []string
+	m.OnChange(func(path string, ev *Event) {
+		paths = append(paths, path)
+	})
+
+	for _, ev := range []*Event{
+		{Type: EventTypePut, Data: []byte(`{"path":"/","data":{"a":{"b":1},"c":2}}`)},
+		{Type: EventTypePatch, Data: []byte(`{"path":"/a","data":{"d":3}}`)},
+		{Type: EventTypePut, Data: []byte(`{"path":"/c","data":null}`)},
+	} {
+		if err := m.apply(ev); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	buf, err := m.Snapshot()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(buf); s != `{"a":{"b":1,"d":3}}` {
+		t.Errorf("expected snapshot {\"a\":{\"b\":1,\"d\":3}}, got: %s", s)
+	}
+
+	var d int
+	if err = m.Get("/a/d", &d); err != nil || d != 3 {
+		t.Errorf("expected 3, got: %d (%v)", d, err)
+	}
+
+	if len(paths) != 3 || paths[1] != "/a/d" || paths[2] != "/c" {
+		t.Errorf("expected [/ /a/d /c], got: %v", paths)
+	}
+
+	select {
+	case <-m.Ready():
+	default:
+		t.Errorf("expected mirror to be ready")
+	}
+}
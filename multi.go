@@ -0,0 +1,130 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UpdateMulti atomically writes the values in updates, keyed by their full
+// database paths (ie, "/users/x/name", "/posts/y/title"), using a single
+// multi-path update against the root of Firebase database ref r.
+//
+// The paths must not be the root, and must not overlap (ie, "/a" and "/a/b"),
+// as Firebase rejects overlapping multi-path updates. A nil value removes the
+// data at the path.
+//
+// When the update fails, a *MultiPathError is returned identifying the
+// paths in the failed request.
+func UpdateMulti(r *DatabaseRef, updates map[string]interface{}, opts ...QueryOption) error {
+	return UpdateMultiContext(r, context.Background(), updates, opts...)
+}
+
+// UpdateMultiContext atomically writes the values in updates, keyed by their
+// full database paths, using a single multi-path update against the root of
+// Firebase database ref r. See UpdateMulti.
+func UpdateMultiContext(r *DatabaseRef, ctxt context.Context, updates map[string]interface{}, opts ...QueryOption) error {
+	m, err := multiPathUpdate(updates)
+	if err != nil {
+		return err
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	err = UpdateContext(r.root(), ctxt, m, opts...)
+	if err != nil {
+		paths := make([]string, 0, len(m))
+		for p := range m {
+			paths = append(paths, "/"+p)
+		}
+		sort.Strings(paths)
+		e := &MultiPathError{
+			Paths: paths,
+			Err:   err,
+		}
+		// only a response from Firebase confirms the update was rejected
+		e.Atomic = e.StatusCode() != 0
+		return e
+	}
+
+	return nil
+}
+
+// MultiPathError is the error returned when a multi-path update fails,
+// identifying the paths included in the failed request, allowing callers to
+// precisely compensate for the failure.
+type MultiPathError struct {
+	// Paths are the sorted full database paths included in the failed
+	// request.
+	Paths []string
+
+	// Atomic indicates the request was rejected by Firebase, and as the
+	// update is applied atomically, none of the paths were written. Atomic is
+	// false when the outcome is unknown (ie, on a network error, timeout, or
+	// cancellation), in which case some paths may have been written.
+	Atomic bool
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *MultiPathError) Error() string {
+	written := "some paths may have been written"
+	if e.Atomic {
+		written = "no paths were written"
+	}
+	return fmt.Sprintf("firebase: multi-path update of %d paths failed (%s): %s", len(e.Paths), written, strings.TrimPrefix(e.Err.Error(), "firebase: "))
+}
+
+// Unwrap returns the underlying error.
+func (e *MultiPathError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status code of the underlying error, if any.
+func (e *MultiPathError) StatusCode() int {
+	if err, ok := e.Err.(*Error); ok {
+		return err.StatusCode
+	}
+	return 0
+}
+
+// multiPathUpdate normalizes and validates the full paths of updates,
+// returning the update relative to the root.
+func multiPathUpdate(updates map[string]interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(updates))
+	paths := make([]string, 0, len(updates))
+	for path, v := range updates {
+		p := strings.Join(splitPath(path), "/")
+		if p == "" {
+			return nil, &Error{
+				Err: "multi-path update cannot write to the root",
+			}
+		}
+		if _, ok := m[p]; ok {
+			return nil, &Error{
+				Err: fmt.Sprintf("duplicate multi-path update path /%s", p),
+			}
+		}
+		m[p] = v
+		paths = append(paths, p)
+	}
+
+	// check overlaps; sorting with the separator as the lowest character
+	// places a path's descendants immediately after it
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Replace(paths[i], "/", "\x00", -1) < strings.Replace(paths[j], "/", "\x00", -1)
+	})
+	for i := 1; i < len(paths); i++ {
+		if strings.HasPrefix(paths[i], paths[i-1]+"/") {
+			return nil, &Error{
+				Err: fmt.Sprintf("overlapping multi-path update paths /%s and /%s", paths[i-1], paths[i]),
+			}
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,99 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultiPathUpdate(t *testing.T) {
+	tests := []struct {
+		paths []string
+		ok    bool
+	}{
+		{[]string{"/users/x/name", "/posts/y/title"}, true},
+		{[]string{"/a", "a-b", "/ab"}, true},
+		{[]string{"/a", "a-b", "/a/b"}, false},
+		{[]string{"/a/b/", "a/b"}, false},
+		{[]string{"/"}, false},
+	}
+
+	for i, test := range tests {
+		updates := make(map[string]interface{})
+		for _, p := range test.paths {
+			updates[p] = 1
+		}
+		_, err := multiPathUpdate(updates)
+		if test.ok != (err == nil) {
+			t.Errorf("test %d expected ok %t, got: %v", i, test.ok, err)
+		}
+	}
+}
+
+func TestMultiPathError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"Permission denied"}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = r.UpdateMulti(map[string]interface{}{
+		"/posts/y/title": "b",
+		"users/x/name":   "a",
+	})
+	e, ok := err.(*MultiPathError)
+	if !ok {
+		t.Fatalf("expected *MultiPathError, got: %T", err)
+	}
+	if p := strings.Join(e.Paths, " "); p != "/posts/y/title /users/x/name" {
+		t.Errorf("expected paths, got: %q", p)
+	}
+	if !e.Atomic || e.StatusCode() != http.StatusUnauthorized {
+		t.Errorf("expected atomic unauthorized error, got: %v", e)
+	}
+}
+
+func TestMultiPathErrorUnknown(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = r.UpdateMulti(map[string]interface{}{"/a": 1})
+	e, ok := err.(*MultiPathError)
+	if !ok {
+		t.Fatalf("expected *MultiPathError, got: %T", err)
+	}
+	if e.Atomic || e.StatusCode() != 0 {
+		t.Errorf("expected non-atomic network error, got: %v", e)
+	}
+	if !strings.Contains(e.Error(), "some paths may have been written") {
+		t.Errorf("expected unknown outcome, got: %v", e)
+	}
+}
+
+func TestMultiPathErrorUnwrap(t *testing.T) {
+	r, err := NewDatabaseRef(URL("https://example.firebaseio.com/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = r.UpdateMulti(map[string]interface{}{"/a": 1})
+	if _, ok := err.(*MultiPathError); !ok || !errors.Is(err, ErrClosed) {
+		t.Errorf("expected *MultiPathError wrapping ErrClosed, got: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package firebase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IDObfuscator is the common interface for reversible ID obfuscators, used
+// to expose identifiers (ie, Push IDs) publicly without leaking the
+// information they contain, such as their creation time.
+type IDObfuscator interface {
+	// Obfuscate returns the public (obfuscated) form of id.
+	Obfuscate(id string) (string, error)
+
+	// Deobfuscate returns the id of the public (obfuscated) form s.
+	Deobfuscate(s string) (string, error)
+}
+
+const (
+	// pushIDObfuscatorRounds is the number of Feistel rounds used by
+	// PushIDObfuscator.
+	pushIDObfuscatorRounds = 10
+
+	// pushIDHalfBits is the number of bits of each half of a Push ID (ie,
+	// 10 characters of 6 bits).
+	pushIDHalfBits = 60
+)
+
+// PushIDObfuscator is a keyed, format-preserving ID obfuscator for Push IDs,
+// that encrypts the 120 bits of a Push ID using a balanced Feistel network
+// with an HMAC-SHA256 round function.
+//
+// Obfuscated IDs are valid 20-character Push IDs (see IsPushID), but no
+// longer reveal their creation time, and do not sort in creation order.
+type PushIDObfuscator struct {
+	key []byte
+}
+
+// NewPushIDObfuscator creates a new Push ID obfuscator using the secret key,
+// which must be at least 16 bytes.
+//
+// Changing the key changes the obfuscated form of all IDs, and as such, the
+// key should be retained for as long as obfuscated IDs are in use.
+func NewPushIDObfuscator(key []byte) (*PushIDObfuscator, error) {
+	if len(key) < 16 {
+		return nil, errors.New("push id obfuscator key must be at least 16 bytes")
+	}
+	return &PushIDObfuscator{
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
+// Obfuscate satisfies the IDObfuscator interface.
+func (o *PushIDObfuscator) Obfuscate(id string) (string, error) {
+	l, r, err := splitPushID(id)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < pushIDObfuscatorRounds; i++ {
+		l, r = r, l^o.round(i, r)
+	}
+	return joinPushID(l, r), nil
+}
+
+// Deobfuscate satisfies the IDObfuscator interface.
+func (o *PushIDObfuscator) Deobfuscate(s string) (string, error) {
+	l, r, err := splitPushID(s)
+	if err != nil {
+		return "", err
+	}
+	for i := pushIDObfuscatorRounds - 1; i >= 0; i-- {
+		l, r = r^o.round(i, l), l
+	}
+	return joinPushID(l, r), nil
+}
+
+// round is the Feistel round function.
+func (o *PushIDObfuscator) round(i int, x uint64) uint64 {
+	var buf [9]byte
+	buf[0] = byte(i)
+	binary.BigEndian.PutUint64(buf[1:], x)
+	h := hmac.New(sha256.New, o.key)
+	h.Write(buf[:])
+	return binary.BigEndian.Uint64(h.Sum(nil)) & (1<<pushIDHalfBits - 1)
+}
+
+// splitPushID splits the Push ID into the numeric values of its two 10
+// character halves.
+func splitPushID(id string) (uint64, uint64, error) {
+	if !IsPushID(id) {
+		return 0, 0, fmt.Errorf("invalid push id %q", id)
+	}
+	var v [2]uint64
+	for i := 0; i < 20; i++ {
+		v[i/10] = v[i/10]<<6 | uint64(strings.IndexByte(defaultPushIDChars, id[i]))
+	}
+	return v[0], v[1], nil
+}
+
+// joinPushID joins the numeric values of the two halves of a Push ID.
+func joinPushID(l, r uint64) string {
+	id := make([]byte, 20)
+	for i := 9; i >= 0; i-- {
+		id[i], id[10+i] = defaultPushIDChars[l&63], defaultPushIDChars[r&63]
+		l, r = l>>6, r>>6
+	}
+	return string(id)
+}
+
+// idCodec is a value codec that stores the actual IDs of tagged fields
+// holding public (obfuscated) IDs.
+type idCodec struct {
+	obfuscator IDObfuscator
+}
+
+// Encode satisfies the ValueCodec interface.
+func (c idCodec) Encode(buf []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, errors.New("obfuscated id must be a string")
+	}
+	if s == "" {
+		return buf, nil
+	}
+	id, err := c.obfuscator.Deobfuscate(s)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(id)
+}
+
+// Decode satisfies the ValueCodec interface.
+func (c idCodec) Decode(buf []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil || s == "" {
+		return buf, nil
+	}
+	id, err := c.obfuscator.Obfuscate(s)
+	if err != nil {
+		// not an id
+		return buf, nil
+	}
+	return json.Marshal(id)
+}
+
+// ObfuscateIDs is an option that applies the ID obfuscator (typically a
+// PushIDObfuscator) to struct fields tagged with `firebase:",obfuscate"`,
+// such that the fields hold the public (obfuscated) IDs, while the actual
+// IDs are stored in the database. Tagged fields of values written with Set,
+// Push, and Update are deobfuscated, and tagged fields of values read with
+// Get are obfuscated.
+//
+// For example:
+//
+//	type Order struct {
+//		ID     string `json:"id" firebase:",obfuscate"`
+//		UserID string `json:"userId" firebase:",obfuscate"`
+//	}
+//
+// Tagged fields must be strings. Stored values that are not valid IDs are
+// read unchanged. Database keys are not obfuscated, and should be converted
+// with the obfuscator directly (ie, r.Ref(id) after Deobfuscate).
+func ObfuscateIDs(obfuscator IDObfuscator) Option {
+	return func(r *DatabaseRef) error {
+		if obfuscator == nil {
+			return errors.New("id obfuscator cannot be nil")
+		}
+		r.setFieldCodec("obfuscate", idCodec{obfuscator: obfuscator})
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPushIDObfuscator(t *testing.T) {
+	if _, err := NewPushIDObfuscator([]byte("short")); err == nil {
+		t.Errorf("expected error")
+	}
+
+	o, err := NewPushIDObfuscator([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	other, err := NewPushIDObfuscator([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		id := GeneratePushID()
+		s, err := o.Obfuscate(id)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if !IsPushID(s) {
+			t.Errorf("expected %q to be a push id", s)
+		}
+		if s == id || s[:8] == id[:8] {
+			t.Errorf("expected %q to be obfuscated, got: %q", id, s)
+		}
+		if x, _ := other.Obfuscate(id); x == s {
+			t.Errorf("expected different keys to produce different ids")
+		}
+		res, err := o.Deobfuscate(s)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if res != id {
+			t.Errorf("expected %q, got: %q", id, res)
+		}
+	}
+
+	if _, err = o.Obfuscate("not-an-id"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestObfuscateIDs(t *testing.T) {
+	var stored []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write(stored)
+			return
+		}
+		stored, _ = ioutil.ReadAll(req.Body)
+		w.Write(stored)
+	}))
+	defer ts.Close()
+
+	o, err := NewPushIDObfuscator([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), ObfuscateIDs(o))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type order struct {
+		ID     string `json:"id" firebase:",obfuscate"`
+		UserID string `json:"userId" firebase:",obfuscate"`
+		Note   string `json:"note"`
+	}
+	id := GeneratePushID()
+	public, _ := o.Obfuscate(id)
+	if err = r.Ref("/orders/a").Set(order{ID: public, Note: public}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// check stored id is the actual id
+	var raw map[string]string
+	if err = json.Unmarshal(stored, &raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if raw["id"] != id {
+		t.Errorf("expected stored id %q, got: %q", id, raw["id"])
+	}
+	if raw["note"] != public {
+		t.Errorf("expected untagged field to be unchanged, got: %q", raw["note"])
+	}
+
+	var v order
+	if err = r.Ref("/orders/a").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v.ID != public || v.UserID != "" {
+		t.Errorf("expected id %q, got: %+v", public, v)
+	}
+
+	// invalid public id
+	if err = r.Ref("/orders/b").Set(order{ID: "x"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,69 @@
+package firebase
+
+import (
+	"context"
+	"time"
+)
+
+// OperationInfo describes a completed request made against a Firebase
+// database ref.
+type OperationInfo struct {
+	// Context is the context of the request.
+	Context context.Context
+
+	// Op is the operation type.
+	Op OpType
+
+	// Path is the database path.
+	Path string
+
+	// Auth is the JSON encoded auth variable override, if any.
+	Auth string
+
+	// Attempt is the attempt number (starting at 1) of the request, when
+	// retried per the ref's RetryPolicy.
+	Attempt int
+
+	// Start is the time the request was started.
+	Start time.Time
+
+	// Duration is the time taken until the response headers were received
+	// (or the request failed).
+	Duration time.Duration
+
+	// StatusCode is the HTTP status code returned by the server, or 0 if the
+	// request failed before a response was received.
+	StatusCode int
+
+	// Err is the error returned for the operation, if any.
+	Err error
+
+	// Response is the server response information, or nil if the request
+	// failed before a response was received.
+	Response *ResponseInfo
+}
+
+// Observer is called with each completed request made against a database
+// ref, excluding streams opened by Watch and Listen.
+//
+// Observers are invoked synchronously, and should not block.
+type Observer func(info *OperationInfo)
+
+// Observe is an option that adds an observer called with each completed
+// request made against the database ref (and its children).
+//
+// Multiple Observe options may be applied to a ref, and the observers will be
+// invoked in the order they were added.
+func Observe(obs Observer) Option {
+	return func(r *DatabaseRef) error {
+		r.observers = append(r.observers[:len(r.observers):len(r.observers)], obs)
+		return nil
+	}
+}
+
+// observe invokes the observers for the ref.
+func (r *DatabaseRef) observe(info *OperationInfo) {
+	for _, obs := range r.observers {
+		obs(info)
+	}
+}
@@ -1,6 +1,7 @@
 package firebase
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
@@ -68,15 +70,148 @@ func ProjectID(projectID string) Option {
 	}
 }
 
+// emulatorTransport wraps a http.RoundTripper, adding the namespace query
+// parameter and owner credentials expected by the Firebase Realtime Database
+// emulator.
+type emulatorTransport struct {
+	transport http.RoundTripper
+	namespace string
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (et *emulatorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trans := et.transport
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	// copy request
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	r.URL = &u
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	// add namespace and owner credentials
+	q := r.URL.Query()
+	q.Set("ns", et.namespace)
+	r.URL.RawQuery = q.Encode()
+	if r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer owner")
+	}
+
+	return trans.RoundTrip(r)
+}
+
+// Emulator is an option that sets the Firebase database base ref to the
+// Firebase Realtime Database emulator running at host (ie, "localhost:9000")
+// using the database namespace.
+//
+// Requests are made using the emulator's owner credentials, which bypass
+// security rules. Use the AuthOverride query option to make requests as a
+// specific user.
+func Emulator(host, namespace string) Option {
+	return func(r *DatabaseRef) error {
+		if host == "" || namespace == "" {
+			return errors.New("emulator host and namespace cannot be empty")
+		}
+
+		err := URL("http://" + host + "/")(r)
+		if err != nil {
+			return err
+		}
+
+		return wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &emulatorTransport{
+				transport: transport,
+				namespace: namespace,
+			}
+		})(r)
+	}
+}
+
 // Transport is an option to set the underlying HTTP transport used when making
 // requests against a Firebase database ref.
+//
+// Options wrapping the transport (ie, Log, Logger, Emulator, SessionAffinity,
+// and ReadReplicas) wrap the underlying transport when the ref's http.Client
+// is built, and can be combined with Transport in any order.
 func Transport(roundTripper http.RoundTripper) Option {
 	return func(r *DatabaseRef) error {
-		r.transport = roundTripper
+		r.transport, r.client = roundTripper, nil
 		return nil
 	}
 }
 
+// wrapTransport returns an option that wraps the ref's underlying transport
+// with f when the ref's http.Client is built. Wrappers are applied in the
+// order the options were applied, with the last applied being outermost.
+func wrapTransport(f func(http.RoundTripper) http.RoundTripper) Option {
+	return func(r *DatabaseRef) error {
+		// copy, as the wrappers are shared with child refs
+		n := len(r.wrappers)
+		r.wrappers, r.client = append(r.wrappers[:n:n], f), nil
+		return nil
+	}
+}
+
+// transportOption returns an option that applies f to a copy of the ref's
+// underlying *http.Transport (or http.DefaultTransport when no transport has
+// been set), and sets the copy as the ref's transport.
+func transportOption(f func(*http.Transport)) Option {
+	return func(r *DatabaseRef) error {
+		var t *http.Transport
+		switch x := r.transport.(type) {
+		case nil:
+			t = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			t = x.Clone()
+		default:
+			return fmt.Errorf("cannot configure transport of type %T", r.transport)
+		}
+		f(t)
+		r.transport, r.client = t, nil
+		return nil
+	}
+}
+
+// MaxIdleConnsPerHost is an option that sets the maximum number of idle
+// (keep-alive) connections kept per host by the underlying HTTP transport.
+//
+// Options configuring the underlying HTTP transport (MaxIdleConnsPerHost,
+// IdleConnTimeout, and HTTP2) can be combined with Transport only when the
+// passed transport is an *http.Transport, and must be applied after it.
+func MaxIdleConnsPerHost(n int) Option {
+	return transportOption(func(t *http.Transport) {
+		t.MaxIdleConnsPerHost = n
+	})
+}
+
+// IdleConnTimeout is an option that sets the maximum amount of time an idle
+// (keep-alive) connection remains open in the underlying HTTP transport. See
+// MaxIdleConnsPerHost.
+func IdleConnTimeout(d time.Duration) Option {
+	return transportOption(func(t *http.Transport) {
+		t.IdleConnTimeout = d
+	})
+}
+
+// HTTP2 is an option that toggles the use of HTTP/2 by the underlying HTTP
+// transport. See MaxIdleConnsPerHost.
+func HTTP2(enabled bool) Option {
+	return transportOption(func(t *http.Transport) {
+		t.ForceAttemptHTTP2 = enabled
+		t.TLSNextProto = nil
+		if !enabled {
+			// a non-nil, empty map disables HTTP/2
+			t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+	})
+}
+
 // WatchBufferLen is an option that sets the channel buffer size for the
 // returned event channels from Watch and Listen.
 func WatchBufferLen(len int) Option {
@@ -86,6 +221,132 @@ func WatchBufferLen(len int) Option {
 	}
 }
 
+// RecordAccess is an option that records the operation counts and byte
+// volumes for all requests made against the database ref (and its children)
+// with the supplied access recorder.
+func RecordAccess(rec *AccessRecorder) Option {
+	return func(r *DatabaseRef) error {
+		r.recorder = rec
+		return nil
+	}
+}
+
+// WatchDiffs is an option that toggles computing diffs for put and patch
+// events emitted from Watch and Listen. When enabled, the last known value of
+// the watched ref is kept in memory, and the Diff field of each emitted put
+// and patch event is populated with the changes relative to that value.
+func WatchDiffs(enabled bool) Option {
+	return func(r *DatabaseRef) error {
+		r.watchDiffs = enabled
+		return nil
+	}
+}
+
+// WatchChangesOnly is an option that toggles suppressing the initial put
+// event sent by Firebase when Watch (or Listen) connects, for consumers only
+// interested in subsequent changes.
+//
+// Note that Firebase will still send the initial put event containing the
+// complete value of the watched ref, however it will not be emitted. As
+// Listen reconnects, any changes made while disconnected will not be emitted
+// when this option is enabled. Use Get to retrieve the current value of the
+// ref when needed.
+func WatchChangesOnly(enabled bool) Option {
+	return func(r *DatabaseRef) error {
+		r.watchChangesOnly = enabled
+		return nil
+	}
+}
+
+// ListenHeartbeats is an option that toggles delivering heartbeat events
+// (EventTypeHeartbeat) from Listen (and ListenFrom) in place of the keep-alive
+// events sent by the Firebase server, regardless of the Listen filter.
+//
+// This provides consumers filtering out keep-alive events a liveness signal
+// for implementing their own staleness alarms.
+func ListenHeartbeats(enabled bool) Option {
+	return func(r *DatabaseRef) error {
+		r.listenHeartbeats = enabled
+		return nil
+	}
+}
+
+// WatchHooks is an option that adds lifecycle callbacks invoked by Watch and
+// Listen when a stream connects, disconnects, or emits an event.
+//
+// Multiple WatchHooks options may be applied to a ref, and the callbacks will
+// be invoked in the order they were added.
+func WatchHooks(cb WatchCallbacks) Option {
+	return func(r *DatabaseRef) error {
+		r.watchCallbacks = append(r.watchCallbacks[:len(r.watchCallbacks):len(r.watchCallbacks)], cb)
+		return nil
+	}
+}
+
+// TransactionRetries is an option that sets the maximum number of times a
+// transaction will be retried after a conflicting write.
+func TransactionRetries(n int) Option {
+	return func(r *DatabaseRef) error {
+		if n < 0 {
+			return errors.New("transaction retries cannot be negative")
+		}
+		r.transactionRetries = n
+		return nil
+	}
+}
+
+// MaxResponseBytes is an option that limits the size of read responses for
+// the database ref (and its children), such that reads (ie, Get) of values
+// larger than n bytes fail with ErrResponseTooLarge, protecting against
+// accidentally retrieving very large values (such as the root of the
+// database).
+//
+// A response with a Content-Length exceeding n is aborted without reading
+// the body. Otherwise, decoding is aborted once more than n bytes have been
+// read. If n is 0, then responses are not limited.
+func MaxResponseBytes(n int64) Option {
+	return func(r *DatabaseRef) error {
+		if n < 0 {
+			return errors.New("max response bytes cannot be negative")
+		}
+		r.maxResponseBytes = n
+		return nil
+	}
+}
+
+// Timeout is an option that sets the default timeout for operations on the
+// database ref (and its children), including all retries and reading the
+// response. Timeouts set by ReadOptions, WriteOptions, WithCallOptions, or
+// QueryTimeout take precedence.
+func Timeout(d time.Duration) Option {
+	return func(r *DatabaseRef) error {
+		if d < 0 {
+			return errors.New("timeout cannot be negative")
+		}
+		r.timeout = d
+		return nil
+	}
+}
+
+// JSONCodec is an option that sets the JSON marshal and unmarshal funcs
+// used when encoding values written to, and decoding values read from, the
+// database ref (and its children), including the data of events decoded with
+// Event.Decode, allowing a faster implementation (ie, jsoniter or sonic) to be
+// used in place of encoding/json.
+//
+// Values with firebase struct tags (see Marshal) are always encoded and
+// decoded using encoding/json. Unmarshal should decode numbers into
+// interface{} values as json.Number, as encoding/json does for Get.
+func JSONCodec(marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) Option {
+	return func(r *DatabaseRef) error {
+		if marshal == nil || unmarshal == nil {
+			return errors.New("json codec requires marshal and unmarshal funcs")
+		}
+		r.jsonMarshal, r.jsonUnmarshal = marshal, unmarshal
+		return nil
+	}
+}
+
 // GoogleServiceAccountCredentialsJSON is an option that loads Google Service
 // Account credentials for use with the Firebase database ref from a JSON
 // encoded buf.
@@ -130,7 +391,7 @@ func GoogleServiceAccountCredentialsJSON(buf []byte) Option {
 		}*/
 
 		// wrap with a reusable token source
-		r.source = oauth2.ReuseTokenSource(nil, ts)
+		r.source, r.client = oauth2.ReuseTokenSource(nil, ts), nil
 
 		return nil
 	}
@@ -194,16 +455,23 @@ func GoogleComputeCredentials(serviceAccount string) Option {
 			return err
 		}
 
-		// set transport as the oauth2.Transport
-		return Transport(&oauth2.Transport{
-			Source: google.ComputeTokenSource(serviceAccount),
-			Base:   r.transport,
+		// wrap transport with the oauth2.Transport
+		source := google.ComputeTokenSource(serviceAccount)
+		return wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &oauth2.Transport{
+				Source: source,
+				Base:   transport,
+			}
 		})(r)
 	}
 }
 
 // DefaultQueryOptions is an option that sets the default query options on the
 // database ref.
+//
+// Query options passed to individual calls take precedence over the default
+// query options, replacing any same-named query parameters. Use ClearDefault
+// to remove a default query parameter for a single call.
 func DefaultQueryOptions(opts ...QueryOption) Option {
 	return func(r *DatabaseRef) error {
 		r.rw.Lock()
@@ -260,13 +528,16 @@ type Logf func(string, ...interface{})
 // Log is an option that writes all HTTP request and response data to the
 // respective logger.
 //
-// NOTE: this Option will not work with Watch/Listen.
+// NOTE: this Option will not work with Watch/Listen. Use Logger for structured
+// logging of requests and streams.
 func Log(requestLogf, responseLogf Logf) Option {
 	return func(r *DatabaseRef) error {
-		return Transport(&httpLogger{
-			transport:    r.transport,
-			requestLogf:  requestLogf,
-			responseLogf: responseLogf,
+		return wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &httpLogger{
+				transport:    transport,
+				requestLogf:  requestLogf,
+				responseLogf: responseLogf,
+			}
 		})(r)
 	}
 }
@@ -275,6 +546,91 @@ func Log(requestLogf, responseLogf Logf) Option {
 // Firebase.
 type QueryOption func(url.Values) error
 
+// clearDefaultKey is the query parameter name used internally by ClearDefault
+// to mark default query parameters for removal.
+const clearDefaultKey = "\x00clear"
+
+// buildQuery builds the query parameters for a request from the default
+// query options and the per-call query options.
+//
+// Per-call query options take precedence over default query options: a
+// parameter set by a per-call option replaces all values of the same-named
+// parameter set by the default options. Default parameters can be removed for
+// a single call using ClearDefault.
+func buildQuery(defaults, opts []QueryOption) (url.Values, error) {
+	var err error
+
+	// apply defaults
+	v := make(url.Values)
+	for _, o := range defaults {
+		err = o(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	v.Del(clearDefaultKey)
+
+	// apply per-call
+	c := make(url.Values)
+	for _, o := range opts {
+		err = o(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// clear defaults
+	for _, name := range c[clearDefaultKey] {
+		v.Del(name)
+	}
+	c.Del(clearDefaultKey)
+
+	// override defaults
+	for name, vals := range c {
+		v[name] = vals
+	}
+
+	return v, nil
+}
+
+// validateQuery checks the query parameters for combinations that Firebase
+// rejects, returning a descriptive error.
+func validateQuery(v url.Values) error {
+	has := func(name string) bool {
+		_, ok := v[name]
+		return ok
+	}
+
+	switch {
+	case !has("orderBy") && (has("startAt") || has("endAt") || has("equalTo") || has("limitToFirst") || has("limitToLast")):
+		return errors.New("startAt, endAt, equalTo, limitToFirst, and limitToLast require orderBy")
+	case has("limitToFirst") && has("limitToLast"):
+		return errors.New("limitToFirst and limitToLast cannot be combined")
+	case has("equalTo") && (has("startAt") || has("endAt")):
+		return errors.New("equalTo cannot be combined with startAt or endAt")
+	case has("shallow") && has("orderBy"):
+		return errors.New("shallow cannot be combined with orderBy or filtering query options")
+	}
+
+	for _, name := range []string{"orderBy", "startAt", "endAt", "equalTo", "limitToFirst", "limitToLast"} {
+		if len(v[name]) > 1 {
+			return fmt.Errorf("%s cannot be specified more than once", name)
+		}
+	}
+
+	return nil
+}
+
+// ClearDefault is a query option that removes the named query parameter (ie,
+// "auth_variable_override") set by the ref's default query options for a
+// single call.
+func ClearDefault(name string) QueryOption {
+	return func(v url.Values) error {
+		v.Add(clearDefaultKey, name)
+		return nil
+	}
+}
+
 // Shallow is a query option that toggles a query to return shallow result (ie, the keys only).
 func Shallow(v url.Values) error {
 	v.Add("shallow", "true")
@@ -288,6 +644,96 @@ func PrintPretty(v url.Values) error {
 	return nil
 }
 
+// PrintSilent is a query option that toggles silent formatting for query
+// results, causing Firebase to respond with a 204 (No Content) status and no
+// body. This avoids the server echoing the written data of a Set or Update.
+//
+// When used with Get or Push, nothing is decoded, and Push returns an empty
+// name.
+func PrintSilent(v url.Values) error {
+	v.Add("print", "silent")
+	return nil
+}
+
+// WriteSizeLimit is a query option that sets the maximum size of a write
+// (ie, one of the WriteSizeLimit* values), overriding the database's
+// defaultWriteSizeLimit setting. Writes exceeding the limit are rejected by
+// Firebase.
+func WriteSizeLimit(size string) QueryOption {
+	return func(v url.Values) error {
+		if !validWriteSizeLimit(size) {
+			return fmt.Errorf("invalid write size limit %q", size)
+		}
+
+		v.Add("writeSizeLimit", size)
+		return nil
+	}
+}
+
+// ExportFormat is a query option that toggles export formatting for query
+// results, which includes the priority of each value (ie, ".priority"), with
+// priorities of primitive values returned as {".value": ..., ".priority":
+// ...}. Values retrieved in the export format can be written back with Set,
+// preserving their priorities.
+func ExportFormat(v url.Values) error {
+	v.Add("format", "export")
+	return nil
+}
+
+// Download is a query option that sets the download filename for query
+// results, causing Firebase to respond with a Content-Disposition attachment
+// header.
+//
+// Download is typically used in conjunction with GetRaw.
+func Download(filename string) QueryOption {
+	return func(v url.Values) error {
+		if filename == "" {
+			return errors.New("download filename cannot be empty")
+		}
+
+		v.Add("download", filename)
+		return nil
+	}
+}
+
+// DryRun is a query option that causes Firebase to validate a write (ie,
+// SetRules) without applying it.
+func DryRun(v url.Values) error {
+	v.Add("dryRun", "true")
+	return nil
+}
+
+// MaxQueryTimeout is the maximum timeout accepted by Firebase for the
+// QueryTimeout query option.
+const MaxQueryTimeout = 15 * time.Minute
+
+// QueryTimeout is a query option that limits how long a single call can take,
+// both on the Firebase server (which aborts reads that exceed the timeout)
+// and on the client, overriding the ref's Timeout and CallOptions.
+//
+// The timeout must be greater than 0 and no more than MaxQueryTimeout, and is
+// rounded up to the nearest millisecond.
+func QueryTimeout(d time.Duration) QueryOption {
+	return func(v url.Values) error {
+		if d <= 0 || d > MaxQueryTimeout {
+			return fmt.Errorf("query timeout must be greater than 0 and no more than %v", MaxQueryTimeout)
+		}
+
+		ms := (d + time.Millisecond - 1) / time.Millisecond
+		v.Set("timeout", strconv.FormatInt(int64(ms), 10)+"ms")
+		return nil
+	}
+}
+
+// queryTimeout returns the timeout set by QueryTimeout in the query, if any.
+func queryTimeout(v url.Values) time.Duration {
+	ms, err := strconv.ParseInt(strings.TrimSuffix(v.Get("timeout"), "ms"), 10, 64)
+	if err != nil || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 // jsonQuery returns a QueryOption for a field and json encodes the val.
 func jsonQuery(field string, val interface{}) QueryOption {
 	// json encode
@@ -335,9 +781,52 @@ func EndAt(val interface{}) QueryOption {
 	return jsonQuery("endAt", val)
 }
 
+// MaxAuthOverrideLength is the maximum length of a URL encoded
+// auth_variable_override, keeping request URLs within the limits accepted by
+// Firebase.
+const MaxAuthOverrideLength = 4096
+
+// StartAtPriority is a query option that orders results by $priority,
+// filtering to children with a priority of at least p (a number or string).
+func StartAtPriority(p interface{}) QueryOption {
+	return priorityQuery(StartAt(p))
+}
+
+// EndAtPriority is a query option that orders results by $priority,
+// filtering to children with a priority of at most p (a number or string).
+func EndAtPriority(p interface{}) QueryOption {
+	return priorityQuery(EndAt(p))
+}
+
+// priorityQuery returns a QueryOption that sets the $priority order by, and
+// applies the filter.
+func priorityQuery(filter QueryOption) QueryOption {
+	return func(v url.Values) error {
+		v.Set("orderBy", `"$priority"`)
+		return filter(v)
+	}
+}
+
 // AuthOverride is a query option that sets the auth_variable_override.
+//
+// The JSON encoded override must not exceed MaxAuthOverrideLength when URL
+// encoded.
 func AuthOverride(val interface{}) QueryOption {
-	return jsonQuery("auth_variable_override", val)
+	buf, err := json.Marshal(val)
+	if err != nil {
+		err = fmt.Errorf("could not marshal query option: %v", err)
+	} else if n := len(url.QueryEscape(string(buf))); n > MaxAuthOverrideLength {
+		err = fmt.Errorf("auth_variable_override length %d exceeds %d when encoded", n, MaxAuthOverrideLength)
+	}
+
+	return func(v url.Values) error {
+		if err != nil {
+			return err
+		}
+
+		v.Add("auth_variable_override", string(buf))
+		return nil
+	}
 }
 
 // AuthUID is a query option that sets the auth user id ("uid") via the
@@ -348,6 +837,57 @@ func AuthUID(uid string) QueryOption {
 	})
 }
 
+// AdminOverride is a query option that removes the ref's default
+// auth_variable_override for a single query, making the request with the
+// full (admin) privileges of the ref's credentials.
+func AdminOverride() QueryOption {
+	return ClearDefault("auth_variable_override")
+}
+
+// UnauthenticatedOverride is a query option that sets the
+// auth_variable_override to null, making the request as an unauthenticated
+// user (ie, auth == null in security rules).
+func UnauthenticatedOverride() QueryOption {
+	return AuthOverride(nil)
+}
+
+// ServiceOverride is a query option that sets the auth_variable_override to
+// an auth variable for the named backend service, with the uid set to name
+// and the custom claim "service" set to true (ie, auth.token.service ==
+// true in security rules).
+func ServiceOverride(name string) QueryOption {
+	return NewAuthOverride(name, map[string]interface{}{
+		"service": true,
+	})
+}
+
+// NewAuthOverride is a query option that sets the auth_variable_override to
+// an auth variable with the uid and the custom claims, available as
+// auth.token.<claim> in security rules. Multiple claim sets are merged in
+// order, with later claims overriding earlier ones.
+func NewAuthOverride(uid string, claims ...map[string]interface{}) QueryOption {
+	if uid == "" {
+		return func(url.Values) error {
+			return errors.New("auth override uid cannot be empty")
+		}
+	}
+
+	val := map[string]interface{}{
+		"uid": uid,
+	}
+	if len(claims) != 0 {
+		token := make(map[string]interface{})
+		for _, c := range claims {
+			for k, v := range c {
+				token[k] = v
+			}
+		}
+		val["token"] = token
+	}
+
+	return AuthOverride(val)
+}
+
 // LimitToFirst is a query option that limit's Firebase's returned results to
 // the first n items.
 func LimitToFirst(n uint) QueryOption {
@@ -0,0 +1,282 @@
+package firebase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildQueryPrecedence(t *testing.T) {
+	defaults := []QueryOption{AuthUID("default"), PrintPretty, Shallow}
+
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{nil, `auth_variable_override=%7B%22uid%22%3A%22default%22%7D&print=pretty&shallow=true`},
+		{[]QueryOption{AuthUID("call")}, `auth_variable_override=%7B%22uid%22%3A%22call%22%7D&print=pretty&shallow=true`},
+		{[]QueryOption{ClearDefault("auth_variable_override")}, `print=pretty&shallow=true`},
+		{[]QueryOption{ClearDefault("shallow"), ClearDefault("print"), LimitToFirst(2)}, `auth_variable_override=%7B%22uid%22%3A%22default%22%7D&limitToFirst=2`},
+	}
+
+	for i, test := range tests {
+		v, err := buildQuery(defaults, test.opts)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := v.Encode(); s != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, s)
+		}
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		opts []QueryOption
+		ok   bool
+	}{
+		{[]QueryOption{OrderBy("$key"), StartAt("a"), LimitToFirst(2)}, true},
+		{[]QueryOption{Shallow, PrintPretty}, true},
+		{[]QueryOption{StartAt("a")}, false},
+		{[]QueryOption{OrderBy("$key"), LimitToFirst(1), LimitToLast(1)}, false},
+		{[]QueryOption{OrderBy("$key"), EqualTo("a"), EndAt("b")}, false},
+		{[]QueryOption{OrderBy("$key"), Shallow}, false},
+		{[]QueryOption{OrderBy("$key"), OrderBy("$value")}, false},
+	}
+
+	for i, test := range tests {
+		v, err := buildQuery(nil, test.opts)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if err = validateQuery(v); test.ok != (err == nil) {
+			t.Errorf("test %d expected ok %t, got: %v", i, test.ok, err)
+		}
+	}
+}
+
+func TestAuthOverridePresets(t *testing.T) {
+	defaults := []QueryOption{AuthUID("default")}
+
+	tests := []struct {
+		opt QueryOption
+		exp string
+	}{
+		{AdminOverride(), ``},
+		{UnauthenticatedOverride(), `auth_variable_override=null`},
+		{ServiceOverride("worker"), `auth_variable_override=%7B%22token%22%3A%7B%22service%22%3Atrue%7D%2C%22uid%22%3A%22worker%22%7D`},
+		{NewAuthOverride("u", map[string]interface{}{"a": 1, "b": 2}, map[string]interface{}{"b": 3}), `auth_variable_override=%7B%22token%22%3A%7B%22a%22%3A1%2C%22b%22%3A3%7D%2C%22uid%22%3A%22u%22%7D`},
+	}
+
+	for i, test := range tests {
+		v, err := buildQuery(defaults, []QueryOption{test.opt})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := v.Encode(); s != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, s)
+		}
+	}
+
+	for i, opt := range []QueryOption{
+		NewAuthOverride(""),
+		NewAuthOverride("u", map[string]interface{}{"big": strings.Repeat("x", MaxAuthOverrideLength)}),
+	} {
+		if _, err := buildQuery(nil, []QueryOption{opt}); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestPrintSilent(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err = r.Set(map[string]interface{}{"a": 1}, PrintSilent, WriteSizeLimit(WriteSizeLimitLarge)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := "print=silent&writeSizeLimit=large"; query != exp {
+		t.Errorf("expected query %q, got: %q", exp, query)
+	}
+
+	id, err := r.Push(map[string]interface{}{"a": 1}, PrintSilent)
+	if err != nil || id != "" {
+		t.Errorf("expected no error and empty id, got: %q, %v", id, err)
+	}
+
+	if err = r.Set(1, WriteSizeLimit("huge")); err == nil {
+		t.Errorf("expected error for invalid write size limit")
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"a":1}`))
+	}))
+	defer ts.Close()
+
+	var marshals, unmarshals int
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), JSONCodec(
+		func(v interface{}) ([]byte, error) {
+			marshals++
+			return json.Marshal(v)
+		},
+		func(buf []byte, v interface{}) error {
+			unmarshals++
+			return json.Unmarshal(buf, v)
+		},
+	))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	c := r.Ref("a")
+	if err = c.Set(map[string]int{"a": 1}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var v map[string]int
+	if err = c.Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v["a"] != 1 {
+		t.Errorf("expected a to be 1, got: %v", v)
+	}
+	if marshals != 1 || unmarshals != 1 {
+		t.Errorf("expected 1 marshal and unmarshal, got: %d, %d", marshals, unmarshals)
+	}
+}
+
+func TestTransportOptions(t *testing.T) {
+	r, err := NewDatabaseRef(
+		URL("https://example.firebaseio.com/"),
+		MaxIdleConnsPerHost(32),
+		IdleConnTimeout(time.Minute),
+		HTTP2(false),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tr, ok := r.transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got: %T", r.transport)
+	}
+	if tr == http.DefaultTransport {
+		t.Errorf("expected http.DefaultTransport to not be modified")
+	}
+	if tr.MaxIdleConnsPerHost != 32 || tr.IdleConnTimeout != time.Minute {
+		t.Errorf("expected 32 and 1m, got: %d, %v", tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+	if tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Errorf("expected http2 to be disabled")
+	}
+
+	// options cannot be applied to a wrapped transport
+	_, err = NewDatabaseRef(
+		URL("https://example.firebaseio.com/"),
+		Transport(&emulatorTransport{}),
+		MaxIdleConnsPerHost(32),
+	)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestSharedClient(t *testing.T) {
+	r, err := NewDatabaseRef(URL("https://example.firebaseio.com/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	a, err := r.httpClient()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	b, err := r.Ref("a/b").httpClient()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if a != b {
+		t.Errorf("expected child ref to share the client")
+	}
+
+	// changing the transport of a child creates a new client
+	c, err := r.Ref("c", Transport(http.DefaultTransport)).httpClient()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c == a || c.Transport != http.DefaultTransport {
+		t.Errorf("expected new client with the child's transport")
+	}
+}
+
+func TestTransportOrder(t *testing.T) {
+	var ns, auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ns, auth = req.URL.Query().Get("ns"), req.Header.Get("Authorization")
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	var logged int
+	logf := func(string, ...interface{}) {
+		logged++
+	}
+	transport := Transport(&rewriteTransport{host: strings.TrimPrefix(ts.URL, "http://")})
+	tests := [][]Option{
+		{transport, Emulator("example.com", "test"), Log(logf, logf)},
+		{Emulator("example.com", "test"), Log(logf, logf), transport},
+		{Log(logf, logf), transport, Emulator("example.com", "test")},
+	}
+	for i, opts := range tests {
+		ns, auth, logged = "", "", 0
+		r, err := NewDatabaseRef(opts...)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var v interface{}
+		if err = r.Get(&v); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if ns != "test" || auth != "Bearer owner" {
+			t.Errorf("test %d expected emulator namespace and credentials, got: %q, %q", i, ns, auth)
+		}
+		if logged != 2 {
+			t.Errorf("test %d expected request and response to be logged, got: %d", i, logged)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqs = append(reqs, req.Method+" "+req.URL.Path+"?"+req.URL.RawQuery)
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.SetRulesJSON([]byte(`{"rules":{".read":true}}`), DryRun); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.SetRules(map[string]interface{}{"rules": map[string]interface{}{".read": true}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := "PUT /.settings/rules.json?dryRun=true PUT /.settings/rules.json?"
+	if s := strings.Join(reqs, " "); s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+}
@@ -0,0 +1,290 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyValue is a child key and its raw JSON value, as returned by GetOrdered.
+type KeyValue struct {
+	Key   string
+	Value json.RawMessage
+
+	// Priority is the priority of the child, and is only populated for
+	// results ordered by $priority.
+	Priority interface{}
+}
+
+// Decode decodes the value into d.
+func (kv KeyValue) Decode(d interface{}) error {
+	return Unmarshal(kv.Value, d)
+}
+
+// GetOrdered retrieves the children of Firebase database ref r, returning
+// them as an ordered slice of key and raw value pairs.
+//
+// As the Firebase REST API does not guarantee the order of the keys in the
+// returned JSON object, the children are sorted client side using Firebase's
+// ordering rules for the OrderBy query option ($key, $value, or a child
+// path, or $priority), falling back to key order.
+//
+// Results ordered by $priority are retrieved using ExportFormat, with the
+// priority of each child set as the KeyValue's Priority, and removed from the
+// child's Value.
+func GetOrdered(r *DatabaseRef, opts ...QueryOption) ([]KeyValue, error) {
+	return GetOrderedContext(r, context.Background(), opts...)
+}
+
+// GetOrderedContext retrieves the children of Firebase database ref r,
+// returning them as an ordered slice of key and raw value pairs. See
+// GetOrdered.
+func GetOrderedContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) ([]KeyValue, error) {
+	// determine order
+	q, err := buildQuery(r.queryOpts, opts)
+	if err != nil {
+		return nil, err
+	}
+	var orderBy string
+	if s := q.Get("orderBy"); s != "" {
+		json.Unmarshal([]byte(s), &orderBy)
+	}
+	if orderBy == "$priority" {
+		opts = append(opts[:len(opts):len(opts)], ExportFormat)
+	}
+
+	s, err := GetStreamContext(r, ctxt, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	// decode in received order
+	var kvs []KeyValue
+	for s.Next() {
+		kv := s.KeyValue()
+		if orderBy == "$priority" {
+			if kv, err = priorityKeyValue(kv); err != nil {
+				return nil, err
+			}
+		}
+		kvs = append(kvs, kv)
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	if kvs == nil {
+		return nil, nil
+	}
+
+	sortKeyValues(kvs, orderBy)
+
+	return kvs, nil
+}
+
+// priorityKeyValue splits the exported value of the key value (see
+// ExportFormat) into its priority and plain value.
+func priorityKeyValue(kv KeyValue) (KeyValue, error) {
+	v, err := decodeJSON(kv.Value)
+	if err != nil {
+		return kv, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+	v, kv.Priority = exportValue(v)
+	if kv.Value, err = json.Marshal(v); err != nil {
+		return kv, &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	return kv, nil
+}
+
+// exportValue returns the plain value and priority of an exported value,
+// removing the priorities of its descendants.
+func exportValue(v interface{}) (interface{}, interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	priority := m[".priority"]
+	if x, ok := m[".value"]; ok {
+		return x, priority
+	}
+	plain := make(map[string]interface{}, len(m))
+	for k, c := range m {
+		if k != ".priority" {
+			plain[k], _ = exportValue(c)
+		}
+	}
+	return plain, priority
+}
+
+// GetInto retrieves the children of Firebase database ref r, decoding them
+// into d, and returning the child keys in query order (see GetOrdered).
+//
+// d must be a pointer to a map with string keys (ie, *map[string]T), or a
+// pointer to a slice (ie, *[]T), whose elements are decoded in query order
+// and correspond to the returned keys.
+//
+// For example:
+//
+//	var people []Person
+//	keys, err := firebase.GetInto(r, &people, firebase.OrderBy("age"), firebase.LimitToFirst(10))
+func GetInto(r *DatabaseRef, d interface{}, opts ...QueryOption) ([]string, error) {
+	return GetIntoContext(r, context.Background(), d, opts...)
+}
+
+// GetIntoContext retrieves the children of Firebase database ref r,
+// decoding them into d, and returning the child keys in query order. See
+// GetInto.
+func GetIntoContext(r *DatabaseRef, ctxt context.Context, d interface{}, opts ...QueryOption) ([]string, error) {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Ptr || v.IsNil() ||
+		(v.Elem().Kind() != reflect.Slice && (v.Elem().Kind() != reflect.Map || v.Elem().Type().Key().Kind() != reflect.String)) {
+		return nil, &Error{
+			Err: fmt.Sprintf("expected pointer to map with string keys or slice, got: %T", d),
+		}
+	}
+	v = v.Elem()
+
+	kvs, err := GetOrderedContext(r, ctxt, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(kvs))
+	switch v.Kind() {
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(v.Type(), len(kvs))
+		for i, kv := range kvs {
+			e := reflect.New(v.Type().Elem())
+			if err = kv.Decode(e.Interface()); err != nil {
+				return nil, err
+			}
+			m.SetMapIndex(reflect.ValueOf(kv.Key).Convert(v.Type().Key()), e.Elem())
+			keys[i] = kv.Key
+		}
+		v.Set(m)
+
+	case reflect.Slice:
+		sl := reflect.MakeSlice(v.Type(), len(kvs), len(kvs))
+		for i, kv := range kvs {
+			if err = kv.Decode(sl.Index(i).Addr().Interface()); err != nil {
+				return nil, err
+			}
+			keys[i] = kv.Key
+		}
+		v.Set(sl)
+	}
+
+	return keys, nil
+}
+
+// sortKeyValues sorts the key values using Firebase's ordering rules for
+// orderBy.
+func sortKeyValues(kvs []KeyValue, orderBy string) {
+	vals := make([]interface{}, len(kvs))
+	switch orderBy {
+	case "", "$key":
+	case "$priority":
+		for i, kv := range kvs {
+			vals[i] = kv.Priority
+		}
+	default:
+		for i, kv := range kvs {
+			v, _ := decodeJSON(kv.Value)
+			if orderBy != "$value" {
+				v = treeGet(v, splitPath(orderBy))
+			}
+			vals[i] = v
+		}
+	}
+
+	idx := make([]int, len(kvs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := idx[i], idx[j]
+		if c := compareValues(vals[a], vals[b]); c != 0 {
+			return c < 0
+		}
+		return compareKeys(kvs[a].Key, kvs[b].Key) < 0
+	})
+
+	sorted := make([]KeyValue, len(kvs))
+	for i, j := range idx {
+		sorted[i] = kvs[j]
+	}
+	copy(kvs, sorted)
+}
+
+// valueRank returns the Firebase sort rank of the value's type: null, false,
+// true, numbers, strings, then objects.
+func valueRank(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !x {
+			return 1
+		}
+		return 2
+	case json.Number:
+		return 3
+	case string:
+		return 4
+	}
+	return 5
+}
+
+// compareValues compares values using Firebase's ordering rules.
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch x := a.(type) {
+	case json.Number:
+		fa, _ := x.Float64()
+		fb, _ := b.(json.Number).Float64()
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+	case string:
+		return strings.Compare(x, b.(string))
+	}
+	return 0
+}
+
+// compareKeys compares keys using Firebase's ordering rules: keys parseable
+// as 32-bit integers first (in numeric order), then all other keys in
+// lexicographic order.
+func compareKeys(a, b string) int {
+	ia, aerr := strconv.ParseInt(a, 10, 32)
+	ib, berr := strconv.ParseInt(b, 10, 32)
+	switch {
+	case aerr == nil && berr == nil:
+		switch {
+		case ia < ib:
+			return -1
+		case ia > ib:
+			return 1
+		}
+		return 0
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
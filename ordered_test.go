@@ -0,0 +1,152 @@
+package firebase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetOrdered(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"c":{"age":30},"b":{"age":"x"},"10":{},"a":{"age":20},"2":{"age":20}}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{nil, "2 10 a b c"},
+		{[]QueryOption{OrderBy("$key")}, "2 10 a b c"},
+		{[]QueryOption{OrderBy("age")}, "10 2 a c b"},
+		{[]QueryOption{OrderBy("$priority")}, "2 10 a b c"},
+	}
+
+	for i, test := range tests {
+		kvs, err := r.GetOrdered(test.opts...)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var keys []string
+		for _, kv := range kvs {
+			keys = append(keys, kv.Key)
+		}
+		if s := strings.Join(keys, " "); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"c":{"age":30},"a":{"age":20},"b":{"age":10}}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	type person struct {
+		Age int `json:"age"`
+	}
+
+	var people []person
+	keys, err := r.GetInto(&people, OrderBy("age"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := strings.Join(keys, " "); s != "b a c" {
+		t.Errorf("expected keys %q, got: %q", "b a c", s)
+	}
+	if len(people) != 3 || people[0].Age != 10 || people[1].Age != 20 || people[2].Age != 30 {
+		t.Errorf("unexpected slice: %v", people)
+	}
+
+	m := map[string]*person{"stale": nil}
+	if _, err = r.GetInto(&m); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(m) != 3 || m["a"].Age != 20 || m["c"].Age != 30 {
+		t.Errorf("unexpected map: %v", m)
+	}
+
+	if _, err = r.GetInto(m); err == nil {
+		t.Errorf("expected error for non-pointer")
+	}
+}
+
+func TestGetOrderedPriority(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("format") != "export" || q.Get("orderBy") != `"$priority"` || q.Get("startAt") != "1" {
+			t.Errorf("unexpected query: %s", req.URL.RawQuery)
+		}
+		w.Write([]byte(`{"a":{".value":1,".priority":"x"},"b":{"n":{".value":2,".priority":5},".priority":2},"c":{".value":3,".priority":1},"d":4}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	kvs, err := r.GetOrdered(StartAtPriority(1))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var s []string
+	for _, kv := range kvs {
+		s = append(s, fmt.Sprintf("%s=%s:%v", kv.Key, string(kv.Value), kv.Priority))
+	}
+	if exp := `d=4:<nil> c=3:1 b={"n":2}:2 a=1:x`; strings.Join(s, " ") != exp {
+		t.Errorf("expected %s, got: %s", exp, strings.Join(s, " "))
+	}
+}
+
+func TestGetOrderedQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("orderBy") != `"$value"` || q.Get("limitToLast") != "3" {
+			t.Errorf("unexpected query: %s", req.URL.RawQuery)
+		}
+		// the server applies the limit, but does not preserve the order of
+		// the returned object's keys
+		w.Write([]byte(`{"z":3,"x":"b","y":true,"w":"a"}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{[]QueryOption{OrderBy("$value"), LimitToLast(3)}, "y z w x"},
+		{[]QueryOption{LimitToLast(3), OrderBy("$value")}, "y z w x"},
+	}
+	for i, test := range tests {
+		kvs, err := r.GetOrdered(test.opts...)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var keys []string
+		for _, kv := range kvs {
+			keys = append(keys, kv.Key)
+		}
+		if s := strings.Join(keys, " "); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
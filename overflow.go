@@ -0,0 +1,117 @@
+package firebase
+
+import (
+	"errors"
+)
+
+// OverflowPolicy is the policy applied by Watch when the event buffer of a
+// watch is full (ie, the consumer is slower than the rate of events).
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks reading from the connection until the consumer
+	// receives an event. This is the default policy.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest buffered event, making room for the
+	// newest event. When the event buffer length is 0, the newest event is
+	// dropped instead.
+	OverflowDropOldest
+
+	// OverflowDropNewest drops the newest event.
+	OverflowDropNewest
+)
+
+// WatchOverflow is an option that sets the policy applied by Watch (and
+// Listen) when the event buffer of a watch is full. See WatchBufferLen.
+//
+// With the drop policies, reading from the connection never stalls, and the
+// number of dropped events is reported to the consumer with a synthesized
+// EventTypeDropped event (with the count set as the event's Dropped) sent
+// once space is available in the buffer, and to the OnDrop callback (see
+// WatchHooks). Terminal events are never dropped.
+//
+// As dropped put and patch events leave the consumer's view of the data
+// incomplete, consumers should resynchronize (ie, by retrieving the current
+// value) after receiving an EventTypeDropped event.
+func WatchOverflow(policy OverflowPolicy) Option {
+	return func(r *DatabaseRef) error {
+		switch policy {
+		case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+		default:
+			return errors.New("invalid overflow policy")
+		}
+		r.watchOverflow = policy
+		return nil
+	}
+}
+
+// eventSender sends events on a watch's event channel per the ref's overflow
+// policy.
+type eventSender struct {
+	r       *DatabaseRef
+	events  chan *Event
+	dropped int
+}
+
+// send sends the event.
+func (s *eventSender) send(ev *Event) {
+	// block for default policy and terminal events
+	if s.r.watchOverflow == OverflowBlock || ev.Err != nil {
+		if s.dropped != 0 {
+			s.events <- s.droppedEvent()
+		}
+		s.events <- ev
+		return
+	}
+
+	for {
+		// notify dropped events, leaving space for the event when dropping
+		// the oldest
+		if s.dropped != 0 && (s.r.watchOverflow == OverflowDropNewest || len(s.events) < cap(s.events)-1) {
+			select {
+			case s.events <- &Event{Type: EventTypeDropped, Dropped: s.dropped}:
+				s.dropped = 0
+			default:
+			}
+		}
+
+		select {
+		case s.events <- ev:
+			return
+		default:
+		}
+
+		if s.r.watchOverflow == OverflowDropNewest || cap(s.events) == 0 {
+			s.drop(1)
+			return
+		}
+
+		// drop oldest, merging dropped notifications
+		select {
+		case old := <-s.events:
+			if old.Type == EventTypeDropped {
+				s.dropped += old.Dropped
+			} else {
+				s.drop(1)
+			}
+		default:
+		}
+	}
+}
+
+// drop records n dropped events.
+func (s *eventSender) drop(n int) {
+	s.dropped += n
+	s.r.onDrop(n)
+}
+
+// droppedEvent returns the pending dropped event, resetting the count.
+func (s *eventSender) droppedEvent() *Event {
+	ev := &Event{
+		Type:    EventTypeDropped,
+		Dropped: s.dropped,
+	}
+	s.dropped = 0
+	return ev
+}
@@ -0,0 +1,82 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWatchOverflow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%d}\n\n", i)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		policy  OverflowPolicy
+		exp     []string
+		dropped int
+	}{
+		{OverflowDropNewest, []string{"1", "2", "dropped 3"}, 3},
+		{OverflowDropOldest, []string{"4", "5", "dropped 3"}, 3},
+	}
+
+	for i, test := range tests {
+		done := make(chan struct{})
+		var dropped int
+		r, err := NewDatabaseRef(
+			URL(ts.URL+"/"),
+			WatchBufferLen(2),
+			WatchOverflow(test.policy),
+			WatchHooks(WatchCallbacks{
+				OnEvent: func(_ *DatabaseRef, ev *Event) {
+					if ev.Err != nil {
+						close(done)
+					}
+				},
+				OnDrop: func(_ *DatabaseRef, n int) {
+					dropped += n
+				},
+			}),
+		)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		events, err := r.Watch(context.Background())
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		// consume only after the stream has been read
+		<-done
+		var res []string
+		for ev := range events {
+			switch {
+			case ev.Type == EventTypeDropped:
+				res = append(res, fmt.Sprintf("dropped %d", ev.Dropped))
+			case ev.Err == nil:
+				var v int
+				if _, err = ev.Decode(&v); err != nil {
+					t.Fatalf("test %d expected no error, got: %v", i, err)
+				}
+				res = append(res, fmt.Sprintf("%d", v))
+			}
+		}
+
+		if fmt.Sprint(res) != fmt.Sprint(test.exp) {
+			t.Errorf("test %d expected events %v, got: %v", i, test.exp, res)
+		}
+		if dropped != test.dropped {
+			t.Errorf("test %d expected %d dropped, got: %d", i, test.dropped, dropped)
+		}
+	}
+
+	if _, err := NewDatabaseRef(WatchOverflow(OverflowPolicy(-1))); err == nil {
+		t.Errorf("expected error")
+	}
+}
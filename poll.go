@@ -0,0 +1,265 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWatchPollInterval is the default interval at which the polling
+	// fallback for Watch and Listen retrieves the watched ref.
+	DefaultWatchPollInterval = 5 * time.Second
+
+	// DefaultWatchPollFailures is the default number of consecutive
+	// characteristic stream failures after which WatchTransportAuto selects
+	// polling.
+	DefaultWatchPollFailures = 3
+)
+
+// WatchTransport is a transport used by Watch and Listen to receive events.
+// Other operations (ie, Get, Set, Update) always use the REST API.
+type WatchTransport string
+
+const (
+	// WatchTransportAuto uses event streams, automatically falling back to
+	// polling after DefaultWatchPollFailures characteristic stream failures.
+	WatchTransportAuto WatchTransport = "auto"
+
+	// WatchTransportStream always uses event streams (text/event-stream).
+	WatchTransportStream WatchTransport = "stream"
+
+	// WatchTransportPoll always uses polling.
+	WatchTransportPoll WatchTransport = "poll"
+)
+
+// WatchTransportMode is an option that sets the transport selection policy
+// for Watch and Listen, where WatchTransportStream and WatchTransportPoll
+// force the transport, and WatchTransportAuto selects it based on the
+// environment. Polling uses DefaultWatchPollInterval; use WatchPollFallback
+// to control the fallback directly.
+//
+// By default, Watch and Listen use event streams without a fallback.
+func WatchTransportMode(mode WatchTransport) Option {
+	return func(r *DatabaseRef) error {
+		switch mode {
+		case WatchTransportAuto:
+			return WatchPollFallback(DefaultWatchPollFailures, 0)(r)
+		case WatchTransportStream:
+			r.watchPoll = nil
+			return nil
+		case WatchTransportPoll:
+			return WatchPollFallback(0, 0)(r)
+		}
+		return &Error{
+			Err: fmt.Sprintf("unknown watch transport %q", mode),
+		}
+	}
+}
+
+// WatchTransport returns the transport currently selected for Watch and
+// Listen on the ref, which is either WatchTransportStream or
+// WatchTransportPoll.
+func (r *DatabaseRef) WatchTransport() WatchTransport {
+	if r.watchPoll != nil && r.watchPoll.polling() {
+		return WatchTransportPoll
+	}
+	return WatchTransportStream
+}
+
+// watchPoller tracks characteristic stream failures for Watch, selecting the
+// polling fallback once the failure threshold is reached.
+type watchPoller struct {
+	mu sync.Mutex
+
+	threshold int
+	interval  time.Duration
+	failures  int
+}
+
+// WatchPollFallback is an option that enables a polling fallback for Watch
+// and Listen, for environments where proxies buffer or terminate
+// text/event-stream responses.
+//
+// A stream fails characteristically when the response has a Content-Type
+// other than text/event-stream, or when the stream ends before any event is
+// received. After failures consecutive characteristic failures, Watch
+// instead polls the ref every interval using conditional GETs with the ref's
+// ETag, emitting a put event with the complete value of the ref when it
+// changes, and a keep-alive event otherwise. Once selected, polling remains
+// in effect for all refs derived from the ref.
+//
+// When failures is 0, Watch always polls. When interval is 0,
+// DefaultWatchPollInterval is used.
+func WatchPollFallback(failures int, interval time.Duration) Option {
+	return func(r *DatabaseRef) error {
+		if failures < 0 || interval < 0 {
+			return &Error{
+				Err: "watch poll failures and interval cannot be negative",
+			}
+		}
+		if interval == 0 {
+			interval = DefaultWatchPollInterval
+		}
+
+		r.watchPoll = &watchPoller{
+			threshold: failures,
+			interval:  interval,
+		}
+		return nil
+	}
+}
+
+// polling determines if the polling fallback has been selected.
+func (p *watchPoller) polling() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.failures >= p.threshold
+}
+
+// done records the outcome of a stream, where ok indicates the stream
+// received at least one event.
+func (p *watchPoller) done(ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.failures >= p.threshold:
+	case ok:
+		p.failures = 0
+	default:
+		p.failures++
+	}
+}
+
+// pollWatch watches a Firebase ref by polling, emitting put events on the
+// returned channel when the value of the ref changes. See WatchPollFallback.
+func pollWatch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *Event, error) {
+	// register stream, so that it is canceled on Close
+	ctxt, cancel := context.WithCancel(ctxt)
+	unregister, err := r.life.stream(cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stop := func() {
+		unregister()
+		cancel()
+	}
+
+	// retrieve initial value
+	buf, etag, err := getIfNoneMatch(r, ctxt, "", opts...)
+	if err != nil {
+		stop()
+		r.onDisconnect(err)
+		return nil, err
+	}
+	r.onConnect()
+
+	// track last value for diffs
+	var delta *deltaTracker
+	if r.watchDiffs {
+		delta = new(deltaTracker)
+	}
+
+	events := make(chan *Event, r.watchBufLen)
+	go func() {
+		var closeErr error
+		defer func() {
+			stop()
+			// notify before closing, so callbacks complete before the
+			// consumer sees the end of the watch
+			r.onDisconnect(closeErr)
+			close(events)
+		}()
+
+		// emit sends an event per the overflow policy, notifying callbacks
+		sender := &eventSender{r: r, events: events}
+		emit := func(ev *Event) {
+			r.onEvent(ev)
+			sender.send(ev)
+		}
+
+		// put emits a put event with the complete value, returning false if
+		// the watch failed
+		put := func(buf []byte, skip bool) bool {
+			data, _ := json.Marshal(eventData{Path: "/", Data: buf})
+			ev := &Event{
+				Type:      EventTypePut,
+				Data:      data,
+				unmarshal: r.jsonUnmarshal,
+			}
+			if delta != nil {
+				diff, err := delta.diff(ev)
+				if err != nil {
+					closeErr = err
+					emit(&Event{
+						Type: EventTypeMalformedDataError,
+						Data: []byte(err.Error()),
+						Err:  err,
+					})
+					return false
+				}
+				ev.Diff = diff
+			}
+			if !skip {
+				emit(ev)
+			}
+			return true
+		}
+
+		if !put(buf, r.watchChangesOnly) {
+			return
+		}
+
+		t := time.NewTicker(r.watchPoll.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				buf, next, err := getIfNoneMatch(r, ctxt, etag, opts...)
+				switch {
+				case err != nil && ctxt.Err() != nil:
+					closeErr = ctxt.Err()
+					return
+				case err != nil:
+					closeErr = err
+					emit(&Event{
+						Type: EventTypeError,
+						Data: []byte(err.Error()),
+						Err:  err,
+					})
+					return
+				case buf == nil || next == etag:
+					emit(&Event{
+						Type: EventTypeKeepAlive,
+						Data: []byte("null"),
+					})
+				default:
+					etag = next
+					if !put(buf, false) {
+						return
+					}
+				}
+
+			// context finished
+			case <-ctxt.Done():
+				closeErr = ctxt.Err()
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// errUnexpectedContentType returns the error for a watch response with an
+// unexpected content type.
+func errUnexpectedContentType(typ string) error {
+	return &Error{
+		Err: fmt.Sprintf("unexpected watch content type %q", typ),
+	}
+}
@@ -0,0 +1,75 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// withPriority returns the JSON encoded value v with the priority, in the
+// export format (see ExportFormat).
+func withPriority(v, priority interface{}) ([]byte, error) {
+	switch priority.(type) {
+	case nil, string, json.Number, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		return nil, &Error{
+			Err: fmt.Sprintf("priority must be a string, number, or nil, got: %T", priority),
+		}
+	}
+
+	buf, err := Marshal(v)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	x, err := decodeJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := x.(map[string]interface{}); ok {
+		m[".priority"] = priority
+	} else {
+		x = map[string]interface{}{
+			".value":    x,
+			".priority": priority,
+		}
+	}
+
+	return json.Marshal(x)
+}
+
+// SetWithPriority stores values v at Firebase database ref r with the
+// priority (a string, number, or nil to clear the priority).
+func SetWithPriority(r *DatabaseRef, v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriorityContext(r, context.Background(), v, priority, opts...)
+}
+
+// SetWithPriorityContext stores values v at Firebase database ref r with the
+// priority, canceling the operation when the passed context is done.
+func SetWithPriorityContext(r *DatabaseRef, ctxt context.Context, v, priority interface{}, opts ...QueryOption) error {
+	buf, err := withPriority(v, priority)
+	if err != nil {
+		return err
+	}
+	return DoContext(OpTypeSet, r, ctxt, buf, nil, opts...)
+}
+
+// GetPriority retrieves the priority of the value stored at Firebase
+// database ref r, which is a string, json.Number, or nil when the value has
+// no priority.
+func GetPriority(r *DatabaseRef, opts ...QueryOption) (interface{}, error) {
+	return GetPriorityContext(r, context.Background(), opts...)
+}
+
+// GetPriorityContext retrieves the priority of the value stored at Firebase
+// database ref r, canceling the operation when the passed context is done.
+func GetPriorityContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (interface{}, error) {
+	var priority interface{}
+	err := DoContext(OpTypeGet, r.Ref(".priority"), ctxt, nil, &priority, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return priority, nil
+}
@@ -0,0 +1,60 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == "PUT":
+			buf, _ := ioutil.ReadAll(req.Body)
+			body = string(buf)
+			w.Write(buf)
+		case req.URL.Path == "/a/.priority.json":
+			w.Write([]byte(`5`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	a := r.Ref("a")
+
+	tests := []struct {
+		v, priority interface{}
+		exp         string
+	}{
+		{map[string]interface{}{"b": 1}, 5, `{".priority":5,"b":1}`},
+		{"x", "p", `{".priority":"p",".value":"x"}`},
+		{nil, nil, `{".priority":null,".value":null}`},
+	}
+	for i, test := range tests {
+		if err = a.SetWithPriority(test.v, test.priority); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if body != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, body)
+		}
+	}
+	if err = a.SetWithPriority(1, true); err == nil {
+		t.Errorf("expected error for invalid priority")
+	}
+
+	p, err := a.GetPriority()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n, ok := p.(json.Number); !ok || n != "5" {
+		t.Errorf("expected priority 5, got: %v", p)
+	}
+}
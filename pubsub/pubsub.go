@@ -0,0 +1,221 @@
+// Package pubsub provides a bridge that publishes Firebase Realtime Database
+// events to a message broker, such as Google Cloud Pub/Sub or NATS, so that
+// downstream services can consume database changes without holding
+// server-sent event connections.
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+const (
+	// DefaultEndpoint is the default Google Cloud Pub/Sub API endpoint.
+	DefaultEndpoint = "https://pubsub.googleapis.com/v1"
+
+	// DefaultOrderingDepth is the default number of event path components
+	// used to derive a message's ordering key.
+	DefaultOrderingDepth = 1
+
+	// DefaultMaxBackoff is the default maximum delay between publish
+	// attempts.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Message is a message published by a Bridge.
+type Message struct {
+	// Data is the event's data (ie, {"path": ..., "data": ...}).
+	Data []byte
+
+	// OrderingKey is the ordering key derived from the event path. Messages
+	// with the same ordering key are published in the order received.
+	OrderingKey string
+
+	// Attributes are the message attributes, containing the event type
+	// ("type"), the event path ("path"), and the watched ref's path ("ref").
+	Attributes map[string]string
+}
+
+// Publisher is the interface for message brokers.
+type Publisher interface {
+	Publish(ctxt context.Context, msg *Message) error
+}
+
+// PublisherFunc is an adapter to allow the use of ordinary funcs (such as a
+// wrapped NATS connection's Publish) as a Publisher.
+type PublisherFunc func(ctxt context.Context, msg *Message) error
+
+// Publish satisfies the Publisher interface.
+func (f PublisherFunc) Publish(ctxt context.Context, msg *Message) error {
+	return f(ctxt, msg)
+}
+
+// CloudPublisher publishes messages to a Google Cloud Pub/Sub topic via the
+// Pub/Sub REST API.
+//
+// Message ordering must be enabled on the topic's subscriptions for the
+// ordering keys to take effect.
+type CloudPublisher struct {
+	// Client is the http.Client used for requests, which must be authorized
+	// with the https://www.googleapis.com/auth/pubsub scope (ie, an
+	// oauth2.Transport using the same token source as the database ref).
+	Client *http.Client
+
+	// Endpoint is the Pub/Sub API endpoint. If empty, then DefaultEndpoint
+	// is used.
+	Endpoint string
+
+	// ProjectID and Topic identify the topic to publish to.
+	ProjectID string
+	Topic     string
+}
+
+// Publish satisfies the Publisher interface.
+func (cp *CloudPublisher) Publish(ctxt context.Context, msg *Message) error {
+	endpoint := cp.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	buf, err := json.Marshal(map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"data":        base64.StdEncoding.EncodeToString(msg.Data),
+			"orderingKey": msg.OrderingKey,
+			"attributes":  msg.Attributes,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal json: %v", err)
+	}
+
+	req, err := http.NewRequest(
+		"POST",
+		endpoint+"/projects/"+url.PathEscape(cp.ProjectID)+"/topics/"+url.PathEscape(cp.Topic)+":publish",
+		bytes.NewReader(buf),
+	)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := cp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req.WithContext(ctxt))
+	if err != nil {
+		return fmt.Errorf("could not execute request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("could not publish message: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// Bridge publishes the put and patch events of a Firebase ref to a
+// Publisher, with at-least-once delivery.
+//
+// Events are published one at a time in the order received, and a failed
+// publish is retried with exponential backoff until it succeeds (or the
+// context is done). As the underlying Listen reconnects and re-sends the
+// ref's current value, consumers should expect duplicate and initial put
+// events.
+type Bridge struct {
+	// Ref is the watched database ref.
+	Ref *firebase.DatabaseRef
+
+	// Publisher is the publisher for events.
+	Publisher Publisher
+
+	// OrderingDepth is the number of event path components used as the
+	// message's ordering key. If less than or equal to 0, then
+	// DefaultOrderingDepth is used.
+	OrderingDepth int
+
+	// MaxBackoff is the maximum delay between publish attempts. If less than
+	// or equal to 0, then DefaultMaxBackoff is used.
+	MaxBackoff time.Duration
+
+	// OnError, if set, is called with each failed publish attempt.
+	OnError func(msg *Message, attempt int, err error)
+}
+
+// Run listens for events on the ref, publishing them until the context is
+// done.
+func (b *Bridge) Run(ctxt context.Context, opts ...firebase.QueryOption) error {
+	ref := b.Ref.URL().Path
+	events := firebase.Listen(b.Ref, ctxt, []firebase.EventType{firebase.EventTypePut, firebase.EventTypePatch}, opts...)
+	for ev := range events {
+		msg := &Message{
+			Data:        ev.Data,
+			OrderingKey: b.orderingKey(ev.Path()),
+			Attributes: map[string]string{
+				"type": string(ev.Type),
+				"path": ev.Path(),
+				"ref":  ref,
+			},
+		}
+		if err := b.publish(ctxt, msg); err != nil {
+			return err
+		}
+	}
+
+	return ctxt.Err()
+}
+
+// orderingKey returns the ordering key for the event path.
+func (b *Bridge) orderingKey(path string) string {
+	depth := b.OrderingDepth
+	if depth <= 0 {
+		depth = DefaultOrderingDepth
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// publish publishes the message, retrying until successful or the context is
+// done.
+func (b *Bridge) publish(ctxt context.Context, msg *Message) error {
+	maxBackoff := b.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := b.Publisher.Publish(ctxt, msg)
+		if err == nil {
+			return nil
+		}
+		if b.OnError != nil {
+			b.OnError(msg, attempt, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctxt.Done():
+			return ctxt.Err()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestCloudPublisher(t *testing.T) {
+	var req struct {
+		Messages []struct {
+			Data        string            `json:"data"`
+			OrderingKey string            `json:"orderingKey"`
+			Attributes  map[string]string `json:"attributes"`
+		} `json:"messages"`
+	}
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad content type", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(path, "missing") {
+			http.Error(w, `{"error":{"status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"messageIds":["1"]}`))
+	}))
+	defer ts.Close()
+
+	msg := &Message{
+		Data:        []byte(`{"path":"/a","data":1}`),
+		OrderingKey: "/a",
+		Attributes:  map[string]string{"type": "put"},
+	}
+	cp := &CloudPublisher{Endpoint: ts.URL, ProjectID: "p", Topic: "events"}
+	if err := cp.Publish(context.Background(), msg); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if path != "/projects/p/topics/events:publish" {
+		t.Errorf("expected publish path, got: %s", path)
+	}
+	if len(req.Messages) != 1 {
+		t.Fatalf("expected 1 message, got: %d", len(req.Messages))
+	}
+	data, _ := base64.StdEncoding.DecodeString(req.Messages[0].Data)
+	if string(data) != string(msg.Data) || req.Messages[0].OrderingKey != "/a" || req.Messages[0].Attributes["type"] != "put" {
+		t.Errorf("unexpected message: %+v", req.Messages[0])
+	}
+
+	cp.Topic = "missing"
+	if err := cp.Publish(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "NOT_FOUND") {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	tests := []struct {
+		depth int
+		path  string
+		exp   string
+	}{
+		{0, "/", "/"},
+		{0, "/a/b/c", "/a"},
+		{2, "/a/b/c", "/a/b"},
+		{2, "/a", "/a"},
+		{5, "/a/b/c/", "/a/b/c"},
+	}
+	for i, test := range tests {
+		b := &Bridge{OrderingDepth: test.depth}
+		if s := b.orderingKey(test.path); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestBridge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: put\ndata: {\"path\":\"/rooms/r1/name\",\"data\":\"one\"}\n\n"))
+		w.Write([]byte("event: keep-alive\ndata: null\n\n"))
+		w.Write([]byte("event: patch\ndata: {\"path\":\"/rooms/r2\",\"data\":{\"name\":\"two\"}}\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer ts.Close()
+
+	r, err := firebase.NewDatabaseRef(firebase.URL(ts.URL + "/chat"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var msgs []*Message
+	var attempts []int
+	b := &Bridge{
+		Ref: r,
+		Publisher: PublisherFunc(func(_ context.Context, msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			// fail the first attempt
+			if len(attempts) == 0 {
+				return errors.New("unavailable")
+			}
+			if msgs = append(msgs, msg); len(msgs) == 2 {
+				cancel()
+			}
+			return nil
+		}),
+		OrderingDepth: 2,
+		OnError: func(msg *Message, attempt int, err error) {
+			attempts = append(attempts, attempt)
+		},
+	}
+	if err = b.Run(ctxt); err != context.Canceled {
+		t.Fatalf("expected context canceled, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 1 || attempts[0] != 1 {
+		t.Errorf("expected 1 failed attempt, got: %v", attempts)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got: %d", len(msgs))
+	}
+	tests := []struct {
+		typ, path, key, data string
+	}{
+		{"put", "/rooms/r1/name", "/rooms/r1", `{"path":"/rooms/r1/name","data":"one"}`},
+		{"patch", "/rooms/r2", "/rooms/r2", `{"path":"/rooms/r2","data":{"name":"two"}}`},
+	}
+	for i, test := range tests {
+		msg := msgs[i]
+		if msg.Attributes["type"] != test.typ || msg.Attributes["path"] != test.path || msg.Attributes["ref"] != "/chat" {
+			t.Errorf("test %d unexpected attributes: %v", i, msg.Attributes)
+		}
+		if msg.OrderingKey != test.key {
+			t.Errorf("test %d expected ordering key %q, got: %q", i, test.key, msg.OrderingKey)
+		}
+		if string(msg.Data) != test.data {
+			t.Errorf("test %d expected data %s, got: %s", i, test.data, msg.Data)
+		}
+	}
+}
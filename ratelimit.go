@@ -0,0 +1,72 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is an option that limits the rate of requests made against the
+// database ref (and its children) to rps requests per second, allowing bursts
+// of up to burst requests. See RateLimiter.
+func RateLimit(rps float64, burst int) Option {
+	return func(r *DatabaseRef) error {
+		if rps <= 0 || burst <= 0 {
+			return errors.New("rate limit rps and burst must be greater than zero")
+		}
+		return RateLimiter(rate.NewLimiter(rate.Limit(rps), burst))(r)
+	}
+}
+
+// RateLimiter is an option that sets the rate limiter used to limit the rate
+// of requests made against the database ref (and its children), such that
+// batch operations (ie, firebase-merge) do not trip the write throttling of
+// the Firebase server.
+//
+// Each request (including each retry attempt and each stream opened by Watch
+// and Listen) waits for the limiter before being sent. When the context is
+// done before the wait has elapsed, an *Error is returned with RateLimitWait
+// set to the time the request would have waited.
+//
+// The limiter may be shared with other refs or clients to enforce a combined
+// rate.
+func RateLimiter(l *rate.Limiter) Option {
+	return func(r *DatabaseRef) error {
+		r.limiter = l
+		return nil
+	}
+}
+
+// wait waits for the ref's rate limiter, if any.
+func (r *DatabaseRef) wait(ctxt context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+
+	res := r.limiter.Reserve()
+	if !res.OK() {
+		return &Error{
+			Err: "request exceeds rate limiter burst",
+		}
+	}
+	delay := res.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctxt.Done():
+		res.Cancel()
+		return &Error{
+			Err:           fmt.Sprintf("rate limit wait of %v canceled: %v", delay, ctxt.Err()),
+			RateLimitWait: delay,
+		}
+	}
+}
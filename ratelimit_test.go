@@ -0,0 +1,52 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		w.Write([]byte(`1`))
+	}))
+	defer ts.Close()
+
+	if _, err := NewDatabaseRef(URL(ts.URL+"/"), RateLimit(0, 1)); err == nil {
+		t.Errorf("expected error for invalid rate limit")
+	}
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), RateLimit(20, 1))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// burst and one limited request
+	start := time.Now()
+	var v int
+	for i := 0; i < 2; i++ {
+		if err = r.Ref("a").Get(&v); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("expected requests to be limited, took: %v", d)
+	}
+
+	// canceled wait
+	ctxt, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err = r.Ref("b").GetContext(ctxt, &v); err == nil {
+		t.Fatalf("expected error")
+	}
+	if e, ok := err.(*Error); !ok || e.RateLimitWait <= 0 {
+		t.Errorf("expected rate limit wait, got: %#v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 requests, got: %d", n)
+	}
+}
@@ -0,0 +1,344 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAccessWindow is the default time window used by an
+	// AccessRecorder to aggregate operations.
+	DefaultAccessWindow = 1 * time.Hour
+
+	// DefaultAccessDepth is the default number of path components used by an
+	// AccessRecorder to group operations.
+	DefaultAccessDepth = 2
+
+	// DefaultAccessRetention is the default number of time windows retained
+	// by an AccessRecorder (ie, one week of the default hourly windows).
+	DefaultAccessRetention = 168
+)
+
+// AccessStats are the aggregated statistics for an operation type on a path
+// prefix.
+type AccessStats struct {
+	Count         int64 `json:"count"`
+	BytesSent     int64 `json:"bytesSent"`
+	BytesReceived int64 `json:"bytesReceived"`
+}
+
+// AccessWindow holds the aggregated statistics for all path prefixes during a
+// time window.
+type AccessWindow struct {
+	Start    time.Time                          `json:"start"`
+	End      time.Time                          `json:"end"`
+	Prefixes map[string]map[OpType]*AccessStats `json:"prefixes"`
+}
+
+// AccessReport is a report of recorded access patterns.
+type AccessReport struct {
+	Windows []*AccessWindow `json:"windows"`
+}
+
+// AccessRecorder aggregates per path prefix operation counts and byte volumes
+// over fixed time windows, for use in capacity planning.
+//
+// An AccessRecorder is enabled on a database ref using the RecordAccess
+// option.
+type AccessRecorder struct {
+	mu sync.Mutex
+
+	window    time.Duration
+	depth     int
+	retention int
+	now       func() time.Time
+
+	windows []*AccessWindow
+
+	log     io.Writer
+	dropped int64
+}
+
+// NewAccessRecorder creates a new access recorder that aggregates operations
+// into time windows of the specified duration, grouping the operations by the
+// first depth components of the ref path.
+//
+// If window or depth are less than or equal to 0, then DefaultAccessWindow
+// and DefaultAccessDepth are used, respectively. The most recent
+// DefaultAccessRetention windows are retained (see SetRetention).
+func NewAccessRecorder(window time.Duration, depth int) *AccessRecorder {
+	if window <= 0 {
+		window = DefaultAccessWindow
+	}
+	if depth <= 0 {
+		depth = DefaultAccessDepth
+	}
+
+	return &AccessRecorder{
+		window:    window,
+		depth:     depth,
+		retention: DefaultAccessRetention,
+		now:       time.Now,
+	}
+}
+
+// SetRetention sets the maximum number of time windows retained by the
+// access recorder, discarding the oldest windows when a new window is
+// started. If n is less than or equal to 0, then DefaultAccessRetention is
+// used.
+func (ar *AccessRecorder) SetRetention(n int) {
+	if n <= 0 {
+		n = DefaultAccessRetention
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.retention = n
+	ar.trim()
+}
+
+// trim discards the oldest windows exceeding the retention.
+func (ar *AccessRecorder) trim() {
+	n := len(ar.windows) - ar.retention
+	if n <= 0 {
+		return
+	}
+	i := copy(ar.windows, ar.windows[n:])
+	for j := i; j < len(ar.windows); j++ {
+		ar.windows[j] = nil
+	}
+	ar.windows = ar.windows[:i]
+}
+
+// prefix returns the path prefix for path.
+func (ar *AccessRecorder) prefix(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > ar.depth {
+		parts = parts[:ar.depth]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Record records an operation of type op on path, with the number of bytes
+// sent and received.
+func (ar *AccessRecorder) Record(op OpType, path string, sent, received int64) {
+	prefix := ar.prefix(path)
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	now := ar.now()
+
+	// get current window
+	var w *AccessWindow
+	if n := len(ar.windows); n > 0 && now.Before(ar.windows[n-1].End) {
+		w = ar.windows[n-1]
+	} else {
+		start := now.Truncate(ar.window)
+		w = &AccessWindow{
+			Start:    start,
+			End:      start.Add(ar.window),
+			Prefixes: make(map[string]map[OpType]*AccessStats),
+		}
+		ar.windows = append(ar.windows, w)
+		ar.trim()
+	}
+
+	// get stats
+	ops, ok := w.Prefixes[prefix]
+	if !ok {
+		ops = make(map[OpType]*AccessStats)
+		w.Prefixes[prefix] = ops
+	}
+	stats, ok := ops[op]
+	if !ok {
+		stats = new(AccessStats)
+		ops[op] = stats
+	}
+
+	stats.Count++
+	stats.BytesSent += sent
+	stats.BytesReceived += received
+}
+
+// Report returns a copy of the recorded access statistics, ordered by window
+// start time.
+func (ar *AccessRecorder) Report() *AccessReport {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	report := &AccessReport{
+		Windows: make([]*AccessWindow, len(ar.windows)),
+	}
+	for i, w := range ar.windows {
+		c := &AccessWindow{
+			Start:    w.Start,
+			End:      w.End,
+			Prefixes: make(map[string]map[OpType]*AccessStats, len(w.Prefixes)),
+		}
+		for prefix, ops := range w.Prefixes {
+			m := make(map[OpType]*AccessStats, len(ops))
+			for op, stats := range ops {
+				s := *stats
+				m[op] = &s
+			}
+			c.Prefixes[prefix] = m
+		}
+		report.Windows[i] = c
+	}
+
+	sort.Slice(report.Windows, func(i, j int) bool {
+		return report.Windows[i].Start.Before(report.Windows[j].Start)
+	})
+
+	return report
+}
+
+// WriteJSON writes the access report as JSON to w.
+func (ar *AccessRecorder) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ar.Report())
+}
+
+// Reset discards all recorded access statistics.
+func (ar *AccessRecorder) Reset() {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.windows = nil
+}
+
+// OperationRecord is a recorded operation, as written to an AccessRecorder's
+// operation log.
+type OperationRecord struct {
+	Time time.Time       `json:"time"`
+	Op   OpType          `json:"op"`
+	Path string          `json:"path"`
+	Auth json.RawMessage `json:"auth,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// LogOperations writes a record of each subsequent operation to w as JSON,
+// one record per line, for later replay (for example, with the rulestest
+// package). Operation data is included for write operations. Records that
+// cannot be written are counted (see Dropped).
+//
+// Pass a nil w to stop logging operations.
+func (ar *AccessRecorder) LogOperations(w io.Writer) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.log = w
+}
+
+// logging returns true when operations are being logged.
+func (ar *AccessRecorder) logging() bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return ar.log != nil
+}
+
+// Dropped returns the number of operation records that could not be written
+// to the operation log, such as when the operation data is not valid JSON, or
+// when the write to the log fails.
+func (ar *AccessRecorder) Dropped() int64 {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return ar.dropped
+}
+
+// logOperation writes the operation record to the operation log.
+func (ar *AccessRecorder) logOperation(rec *OperationRecord) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if ar.log == nil {
+		return
+	}
+
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		ar.dropped++
+		return
+	}
+	if _, err = ar.log.Write(append(buf, '\n')); err != nil {
+		ar.dropped++
+	}
+}
+
+// countingBody wraps a http.Request body counting (and optionally capturing)
+// the bytes read.
+type countingBody struct {
+	io.ReadCloser
+
+	n       int64
+	capture *bytes.Buffer
+}
+
+// Read satisfies the io.Reader interface.
+func (cb *countingBody) Read(buf []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(buf)
+	cb.n += int64(n)
+	if cb.capture != nil {
+		cb.capture.Write(buf[:n])
+	}
+	return n, err
+}
+
+// recordingBody wraps a http.Response body, recording the operation with the
+// access recorder when closed.
+type recordingBody struct {
+	io.ReadCloser
+
+	once     sync.Once
+	rec      *AccessRecorder
+	op       OpType
+	path     string
+	auth     string
+	sent     *countingBody
+	received int64
+}
+
+// Read satisfies the io.Reader interface.
+func (rb *recordingBody) Read(buf []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(buf)
+	rb.received += int64(n)
+	return n, err
+}
+
+// Close satisfies the io.Closer interface.
+func (rb *recordingBody) Close() error {
+	rb.once.Do(func() {
+		var sent int64
+		var data json.RawMessage
+		if rb.sent != nil {
+			sent = rb.sent.n
+			if rb.sent.capture != nil && rb.sent.capture.Len() != 0 {
+				data = rb.sent.capture.Bytes()
+			}
+		}
+		rb.rec.Record(rb.op, rb.path, sent, rb.received)
+
+		// log operation
+		rec := &OperationRecord{
+			Time: time.Now(),
+			Op:   rb.op,
+			Path: rb.path,
+			Data: data,
+		}
+		if rb.auth != "" && json.Valid([]byte(rb.auth)) {
+			rec.Auth = json.RawMessage(rb.auth)
+		}
+		rb.rec.logOperation(rec)
+	})
+	return rb.ReadCloser.Close()
+}
@@ -0,0 +1,149 @@
+package firebase
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessRecorder(t *testing.T) {
+	ar := NewAccessRecorder(time.Minute, 2)
+	now := time.Unix(6000, 0)
+	ar.now = func() time.Time { return now }
+
+	ar.Record(OpTypeGet, "/users/a/name", 10, 100)
+	ar.Record(OpTypeGet, "/users/b", 20, 200)
+	ar.Record(OpTypeSet, "/users/b", 5, 0)
+	ar.Record(OpTypeGet, "/", 1, 2)
+
+	// rollover
+	now = now.Add(90 * time.Second)
+	ar.Record(OpTypeGet, "/posts", 1, 1)
+
+	report := ar.Report()
+	if len(report.Windows) != 2 {
+		t.Fatalf("expected 2 windows, got: %d", len(report.Windows))
+	}
+	w := report.Windows[0]
+	if !w.Start.Equal(time.Unix(6000, 0)) || !w.End.Equal(time.Unix(6060, 0)) {
+		t.Errorf("unexpected window: %v - %v", w.Start, w.End)
+	}
+	tests := []struct {
+		prefix string
+		op     OpType
+		exp    AccessStats
+	}{
+		{"/users/a", OpTypeGet, AccessStats{1, 10, 100}},
+		{"/users/b", OpTypeGet, AccessStats{1, 20, 200}},
+		{"/users/b", OpTypeSet, AccessStats{1, 5, 0}},
+		{"/", OpTypeGet, AccessStats{1, 1, 2}},
+	}
+	if len(w.Prefixes) != 3 {
+		t.Errorf("expected 3 prefixes, got: %v", w.Prefixes)
+	}
+	for i, test := range tests {
+		if s := w.Prefixes[test.prefix][test.op]; s == nil || *s != test.exp {
+			t.Errorf("test %d expected %s %s %+v, got: %+v", i, test.op, test.prefix, test.exp, s)
+		}
+	}
+	if s := report.Windows[1].Prefixes["/posts"][OpTypeGet]; s == nil || s.Count != 1 {
+		t.Errorf("expected /posts in second window, got: %v", report.Windows[1].Prefixes)
+	}
+
+	// retention
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute)
+		ar.Record(OpTypeGet, "/a", 0, 0)
+	}
+	ar.SetRetention(2)
+	if report = ar.Report(); len(report.Windows) != 2 || !report.Windows[1].Start.Equal(now.Truncate(time.Minute)) {
+		t.Fatalf("expected 2 most recent windows, got: %d", len(report.Windows))
+	}
+	now = now.Add(time.Minute)
+	ar.Record(OpTypeGet, "/a", 0, 0)
+	if report = ar.Report(); len(report.Windows) != 2 || !report.Windows[1].Start.Equal(now.Truncate(time.Minute)) {
+		t.Errorf("expected 2 most recent windows, got: %d", len(report.Windows))
+	}
+}
+
+func TestRecordAccess(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"a":1}`))
+	}))
+	defer ts.Close()
+
+	ar := NewAccessRecorder(0, 1)
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), RecordAccess(ar))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var v interface{}
+	if err = r.Ref("/users/a").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = r.Ref("/users/b").Set("hello"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	report := ar.Report()
+	if len(report.Windows) != 1 {
+		t.Fatalf("expected 1 window, got: %d", len(report.Windows))
+	}
+	ops := report.Windows[0].Prefixes["/users"]
+	if s := ops[OpTypeGet]; s == nil || *s != (AccessStats{1, 0, 7}) {
+		t.Errorf("expected get stats, got: %+v", s)
+	}
+	if s := ops[OpTypeSet]; s == nil || s.Count != 1 || s.BytesSent != int64(len(`"hello"`)) {
+		t.Errorf("expected set stats, got: %+v", s)
+	}
+}
+
+func TestLogOperations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	ar := NewAccessRecorder(0, 0)
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), RecordAccess(ar))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	ar.LogOperations(&buf)
+	if err = r.Ref("/a").Set(map[string]int{"b": 1}, AuthUID("alice")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// invalid json data cannot be logged
+	if err = r.Ref("/a").Set([]byte(`{"b":`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := ar.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped record, got: %d", n)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 logged record, got: %q", buf.String())
+	}
+	for _, s := range []string{`"op":"PUT"`, `"path":"/a"`, `"auth":{"uid":"alice"}`, `"data":{"b":1}`} {
+		if !strings.Contains(lines[0], s) {
+			t.Errorf("expected record to contain %s, got: %s", s, lines[0])
+		}
+	}
+
+	// stop logging
+	ar.LogOperations(nil)
+	if err = r.Ref("/a").Set([]byte(`{"b":`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := ar.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped record, got: %d", n)
+	}
+}
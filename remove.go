@@ -0,0 +1,105 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+const (
+	// DefaultRemoveBatchSize is the default number of children removed per
+	// request by a RecursiveRemover.
+	DefaultRemoveBatchSize = 100
+)
+
+// RecursiveRemover removes large nodes from a Firebase database by removing
+// their children in bounded batches, as removing a very large node with a
+// single request fails or times out.
+type RecursiveRemover struct {
+	// BatchSize is the number of children removed per request. If zero, then
+	// DefaultRemoveBatchSize is used.
+	BatchSize int
+
+	// Progress, if not nil, is called after each batch of children has been
+	// removed, with the path of the parent and the number of children
+	// removed by the batch.
+	Progress func(path string, n int)
+}
+
+// Remove removes Firebase database ref r and all of its descendants.
+//
+// The child keys of r are retrieved with a shallow query, and the children
+// are removed in batches of BatchSize using multi-path updates. When a batch
+// fails (ie, because a child is itself too large to remove with a single
+// request), the children of the batch are removed recursively. Permission
+// denied errors are returned immediately.
+//
+// As each batch removes the children it contains, an interrupted removal can
+// be resumed by calling Remove again.
+func (rr *RecursiveRemover) Remove(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) error {
+	size := rr.BatchSize
+	if size <= 0 {
+		size = DefaultRemoveBatchSize
+	}
+
+	// retrieve keys
+	var shallow interface{}
+	if err := GetContext(r, ctxt, &shallow, append([]QueryOption{Shallow}, opts...)...); err != nil {
+		return err
+	}
+	m, ok := shallow.(map[string]interface{})
+	if !ok {
+		if shallow == nil {
+			return nil
+		}
+		// leaf value
+		return RemoveContext(r, ctxt, opts...)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// remove in batches
+	for i := 0; i < len(keys); i += size {
+		batch := keys[i:min(i+size, len(keys))]
+		update := make(map[string]interface{}, len(batch))
+		for _, k := range batch {
+			update[k] = nil
+		}
+
+		err := UpdateContext(r, ctxt, update, opts...)
+		if err != nil {
+			if e, ok := err.(*Error); !ok || e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden || ctxt.Err() != nil {
+				return err
+			}
+
+			// remove each child recursively
+			for _, k := range batch {
+				if err = rr.Remove(r.Ref(k), ctxt, opts...); err != nil {
+					return err
+				}
+			}
+		}
+
+		if rr.Progress != nil {
+			rr.Progress(r.URL().Path, len(batch))
+		}
+	}
+
+	return nil
+}
+
+// RemoveRecursive removes Firebase database ref r and all of its descendants
+// in batches of DefaultRemoveBatchSize children. See RecursiveRemover.Remove.
+func RemoveRecursive(r *DatabaseRef, opts ...QueryOption) error {
+	return RemoveRecursiveContext(r, context.Background(), opts...)
+}
+
+// RemoveRecursiveContext removes Firebase database ref r and all of its
+// descendants in batches of DefaultRemoveBatchSize children, canceling the
+// operation when the passed context is done. See RecursiveRemover.Remove.
+func RemoveRecursiveContext(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) error {
+	return new(RecursiveRemover).Remove(r, ctxt, opts...)
+}
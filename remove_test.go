@@ -0,0 +1,91 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRemoveRecursive(t *testing.T) {
+	var mu sync.Mutex
+	data := map[string]interface{}{
+		"a": 1, "b": 2, "c": 3, "d": 4,
+		"huge": map[string]interface{}{"x": 1, "y": 2, "z": 3},
+	}
+	var updates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/root"), ".json")
+		node := data
+		if path == "/huge" {
+			node, _ = data["huge"].(map[string]interface{})
+		}
+
+		switch req.Method {
+		case "GET":
+			if req.URL.Query().Get("shallow") != "true" {
+				t.Errorf("expected shallow query")
+			}
+			if len(node) == 0 {
+				w.Write([]byte(`null`))
+				return
+			}
+			shallow := make(map[string]bool)
+			for k := range node {
+				shallow[k] = true
+			}
+			json.NewEncoder(w).Encode(shallow)
+
+		case "PATCH":
+			updates++
+			var m map[string]interface{}
+			json.NewDecoder(req.Body).Decode(&m)
+			if _, ok := m["huge"]; ok && path == "" {
+				http.Error(w, `{"error":"Data to write exceeds the maximum size"}`, http.StatusBadRequest)
+				return
+			}
+			for k := range m {
+				delete(node, k)
+			}
+			if len(data["huge"].(map[string]interface{})) == 0 {
+				delete(data, "huge")
+			}
+			w.Write([]byte(`null`))
+
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var progress int
+	rr := &RecursiveRemover{
+		BatchSize: 2,
+		Progress: func(path string, n int) {
+			progress += n
+		},
+	}
+	if err = rr.Remove(r.Ref("root"), context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("expected all data removed, got: %v", data)
+	}
+	if progress != 8 {
+		t.Errorf("expected progress of 8 children, got: %d", progress)
+	}
+	if updates != 5 {
+		t.Errorf("expected 5 updates, got: %d", updates)
+	}
+}
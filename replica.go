@@ -0,0 +1,205 @@
+package firebase
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ReadReplica is a database instance serving reads for a primary database
+// (see ReadReplicas), kept in sync with the primary by a Replicator.
+type ReadReplica struct {
+	// Ref is the replica database ref corresponding to the primary database
+	// ref the ReadReplicas option is applied to. Requests to the replica are
+	// made with the ref's client (ie, credentials and transport). If nil,
+	// then the Replicator's destination is used.
+	Ref *DatabaseRef
+
+	// Replicator is the replicator syncing the primary to the replica, used
+	// to determine the staleness of the replica. If nil, then the replica is
+	// assumed to always be in sync.
+	Replicator *Replicator
+}
+
+// ReadReplicas is an option that splits reads and writes made by the
+// database ref (and its children) between the ref (ie, the primary) and the
+// read replicas, for scaling out read-heavy workloads across multiple
+// database instances.
+//
+// Reads (see Get) are sent to the replicas in turn, skipping replicas whose
+// replicator is not synced (see ReplicationStats), or whose replication lag
+// exceeds maxLag. Reads are sent to the primary when no replica is within the
+// staleness bounds, or when a request to a replica fails before a response is
+// received or with a 5xx, 401, or 403 status. Other replica errors (ie, a 400
+// for a missing index) are returned as-is. A maxLag of 0 only skips unsynced
+// replicas.
+//
+// Writes, streams opened by Watch and Listen, conditional reads made by
+// transactions, reads made as a specific user (see AuthOverride), and
+// requests for the database rules are always sent to the primary. As replicas
+// are eventually consistent, a read following a write may not reflect the
+// write.
+func ReadReplicas(maxLag time.Duration, replicas ...ReadReplica) Option {
+	return func(r *DatabaseRef) error {
+		if len(replicas) == 0 {
+			return errors.New("at least one read replica must be specified")
+		}
+		reps := make([]ReadReplica, len(replicas))
+		for i, rep := range replicas {
+			if rep.Ref == nil && rep.Replicator != nil {
+				rep.Ref = rep.Replicator.cfg.Destination
+			}
+			if rep.Ref == nil {
+				return errors.New("read replica ref cannot be nil")
+			}
+			reps[i] = rep
+		}
+
+		path := joinPath(r.URL().Path, "")
+		return wrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return &replicaTransport{
+				transport: transport,
+				path:      path,
+				replicas:  reps,
+				maxLag:    maxLag,
+			}
+		})(r)
+	}
+}
+
+// replicaTransport wraps a http.RoundTripper, sending reads to read replicas.
+type replicaTransport struct {
+	transport http.RoundTripper
+
+	// path is the path of the primary database ref corresponding to the
+	// replica refs.
+	path string
+
+	replicas []ReadReplica
+	maxLag   time.Duration
+	next     uint32
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (rt *replicaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trans := rt.transport
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	rel, ok := rt.relativePath(req)
+	if !ok {
+		return trans.RoundTrip(req)
+	}
+
+	// pick replica
+	now, n := time.Now(), uint32(len(rt.replicas))
+	start := atomic.AddUint32(&rt.next, 1)
+	for i := uint32(0); i < n; i++ {
+		rep := rt.replicas[(start+i)%n]
+		if !rep.fresh(rt.maxLag, now) {
+			continue
+		}
+		res, err := rt.replicaRoundTrip(rep, req, rel)
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			return nil, err
+		case err != nil:
+		case res.StatusCode >= 500,
+			res.StatusCode == http.StatusUnauthorized,
+			res.StatusCode == http.StatusForbidden:
+			res.Body.Close()
+		default:
+			return res, nil
+		}
+
+		// fall back to primary
+		break
+	}
+
+	return trans.RoundTrip(req)
+}
+
+// relativePath returns the database path of the request relative to the
+// primary path, when the request is a read that can be sent to a replica.
+func (rt *replicaTransport) relativePath(req *http.Request) (string, bool) {
+	if req.Method != "GET" ||
+		strings.Contains(req.Header.Get("Accept"), "text/event-stream") ||
+		req.Header.Get("X-Firebase-ETag") != "" ||
+		req.URL.Query().Get("auth_variable_override") != "" {
+		return "", false
+	}
+
+	path := joinPath(strings.TrimSuffix(req.URL.Path, ".json"), "")
+	switch {
+	case rt.path == "/":
+	case path == rt.path:
+		path = "/"
+	case strings.HasPrefix(path, rt.path+"/"):
+		path = path[len(rt.path):]
+	default:
+		return "", false
+	}
+	if strings.HasPrefix(path, "/.settings") {
+		return "", false
+	}
+
+	return path, true
+}
+
+// replicaRoundTrip sends the request to the replica.
+func (rt *replicaTransport) replicaRoundTrip(rep ReadReplica, req *http.Request, rel string) (*http.Response, error) {
+	client, err := rep.Ref.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	trans := client.Transport
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	// copy request
+	ru := rep.Ref.URL()
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	r.URL = &u
+	r.URL.Scheme, r.URL.Host = ru.Scheme, ru.Host
+	r.URL.Path, r.URL.RawPath = joinPath(ru.Path, rel)+".json", ""
+	if strings.Contains(r.URL.Path, "+") {
+		r.URL.RawPath = strings.Replace(r.URL.Path, "+", "%2B", -1)
+	}
+	r.Host = ""
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	// the replica client provides its own credentials
+	r.Header.Del("Authorization")
+
+	return trans.RoundTrip(r)
+}
+
+// fresh determines if the replica is within the staleness bounds.
+func (rep ReadReplica) fresh(maxLag time.Duration, now time.Time) bool {
+	if rep.Replicator == nil {
+		return true
+	}
+
+	s := rep.Replicator.Stats()
+	switch {
+	case !s.Synced:
+		return false
+	case maxLag <= 0:
+		return true
+	case s.Lag > maxLag:
+		return false
+	case s.LastEvent.After(s.LastApplied) && now.Sub(s.LastEvent) > maxLag:
+		// an event has not been applied within the bound
+		return false
+	}
+	return true
+}
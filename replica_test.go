@@ -0,0 +1,135 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadReplicas(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []string
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			reqs = append(reqs, name+" "+req.Method+" "+req.URL.Path)
+			mu.Unlock()
+			if name == "replica" {
+				switch strings.TrimSuffix(path.Base(req.URL.Path), ".json") {
+				case "down":
+					http.Error(w, `{"error":"unavailable"}`, http.StatusServiceUnavailable)
+					return
+				case "denied":
+					http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+					return
+				case "forbidden":
+					http.Error(w, `{"error":"Forbidden"}`, http.StatusForbidden)
+					return
+				case "invalid":
+					http.Error(w, `{"error":"Index not defined"}`, http.StatusBadRequest)
+					return
+				}
+			}
+			w.Write([]byte(`"` + name + `"`))
+		})
+	}
+	primary := httptest.NewServer(handler("primary"))
+	defer primary.Close()
+	replica := httptest.NewServer(handler("replica"))
+	defer replica.Close()
+
+	rep, err := NewDatabaseRef(URL(replica.URL + "/mirror"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	rp := &Replicator{
+		cfg: ReplicatorConfig{
+			Destination: rep,
+		},
+	}
+	r, err := NewDatabaseRef(URL(primary.URL+"/"), ReadReplicas(time.Second, ReadReplica{Replicator: rp}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// last returns the last request
+	last := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs[len(reqs)-1]
+	}
+
+	// get reads the child path
+	get := func(path string) func() error {
+		return func() error {
+			var v string
+			return r.Ref(path).Get(&v)
+		}
+	}
+
+	tests := []struct {
+		stats ReplicationStats
+		f     func() error
+		exp   string
+	}{
+		{ReplicationStats{}, get("a"), "primary GET /a.json"},
+		{ReplicationStats{Synced: true}, get("a"), "replica GET /mirror/a.json"},
+		{ReplicationStats{Synced: true}, func() error { return r.Ref("a").Set(1) }, "primary PUT /a.json"},
+		{ReplicationStats{Synced: true}, func() error { _, err := r.GetRulesJSON(); return err }, "primary GET /.settings/rules.json"},
+		{ReplicationStats{Synced: true, Lag: 2 * time.Second}, get("a"), "primary GET /a.json"},
+		{ReplicationStats{Synced: true, LastEvent: time.Now().Add(-time.Minute)}, get("a"), "primary GET /a.json"},
+		{ReplicationStats{Synced: true}, get("down"), "primary GET /down.json"},
+		{ReplicationStats{Synced: true}, get("denied"), "primary GET /denied.json"},
+		{ReplicationStats{Synced: true}, get("forbidden"), "primary GET /forbidden.json"},
+		{ReplicationStats{Synced: true}, func() error { var v string; return r.Ref("a").Get(&v, AuthUID("alice")) }, "primary GET /a.json"},
+	}
+	for i, test := range tests {
+		rp.stats = test.stats
+		if err = test.f(); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := last(); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+
+	// failed replica requests are retried against the primary
+	mu.Lock()
+	n := len(reqs)
+	mu.Unlock()
+	if err = get("down")(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	mu.Lock()
+	if s := strings.Join(reqs[n:], ", "); s != "replica GET /mirror/down.json, primary GET /down.json" {
+		t.Errorf("expected replica then primary request, got: %s", s)
+	}
+	mu.Unlock()
+
+	// other replica errors are returned as-is
+	if err = get("invalid")(); err == nil || !strings.Contains(err.Error(), "Index not defined") {
+		t.Errorf("expected replica error, got: %v", err)
+	}
+	if s := last(); s != "replica GET /mirror/invalid.json" {
+		t.Errorf("expected replica request, got: %q", s)
+	}
+
+	// falls back to primary when the replica is unavailable
+	rp.stats = ReplicationStats{Synced: true}
+	replica.Close()
+	var v string
+	if err = r.Ref("b").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v != "primary" {
+		t.Errorf("expected primary read, got: %q", v)
+	}
+
+	if _, err = NewDatabaseRef(ReadReplicas(0)); err == nil {
+		t.Errorf("expected error")
+	}
+}
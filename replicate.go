@@ -0,0 +1,242 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplicatorConfig is the configuration for a Replicator.
+type ReplicatorConfig struct {
+	// Source is the database ref to replicate from.
+	Source *DatabaseRef
+
+	// Destination is the database ref to replicate to, which may be in a
+	// different database or project than the source.
+	Destination *DatabaseRef
+
+	// Include are the paths (relative to the source) to replicate. If empty,
+	// then all paths are replicated.
+	Include []string
+
+	// Exclude are the paths (relative to the source) to not replicate.
+	// Excluded paths beneath a replicated put are removed from the put, and
+	// as such, will be removed from the destination.
+	Exclude []string
+}
+
+// ReplicationStats are the metrics of a Replicator.
+type ReplicationStats struct {
+	// Snapshots is the number of full snapshots synced, which occurs upon
+	// each (re)connect to the source.
+	Snapshots int64 `json:"snapshots"`
+
+	// Events is the number of events received from the source.
+	Events int64 `json:"events"`
+
+	// Writes is the number of writes made to the destination.
+	Writes int64 `json:"writes"`
+
+	// Errors is the number of errors encountered writing to the destination.
+	Errors int64 `json:"errors"`
+
+	// LastEvent is the time the last event was received.
+	LastEvent time.Time `json:"lastEvent"`
+
+	// LastApplied is the time the last event was applied to the destination.
+	LastApplied time.Time `json:"lastApplied"`
+
+	// Lag is the time between receiving the last applied event from the
+	// source and its write to the destination completing.
+	Lag time.Duration `json:"lag"`
+
+	// LastError is the last error encountered, if any.
+	LastError string `json:"lastError,omitempty"`
+
+	// Synced indicates the replicator is connected to the source, and has
+	// applied the snapshot of the source sent upon connecting.
+	Synced bool `json:"synced"`
+}
+
+// Replicator tails a source database ref, applying put and patch events to a
+// destination database ref, for migrations and disaster recovery read
+// replicas.
+//
+// Upon connecting (and reconnecting) to the source, the current value of the
+// source is synced to the destination as a full snapshot, after which changes
+// are replicated as they occur.
+type Replicator struct {
+	mu sync.Mutex
+
+	cfg   ReplicatorConfig
+	stats ReplicationStats
+}
+
+// NewReplicator creates a new replicator.
+func NewReplicator(cfg *ReplicatorConfig) (*Replicator, error) {
+	if cfg.Source == nil || cfg.Destination == nil {
+		return nil, &Error{
+			Err: "replicator source and destination must be specified",
+		}
+	}
+
+	return &Replicator{
+		cfg: *cfg,
+	}, nil
+}
+
+// Run replicates the source to the destination until the context is done,
+// or an error is encountered writing to the destination.
+func (rp *Replicator) Run(ctxt context.Context, opts ...QueryOption) error {
+	defer rp.update(func(s *ReplicationStats) {
+		s.Synced = false
+	})
+
+	events := Listen(rp.cfg.Source, ctxt, []EventType{EventTypePut, EventTypePatch, EventTypeConnected, EventTypeDisconnected}, opts...)
+	for ev := range events {
+		switch ev.Type {
+		case EventTypeConnected, EventTypeDisconnected:
+			// the snapshot sent upon connecting has not been applied
+			rp.update(func(s *ReplicationStats) {
+				s.Synced = false
+			})
+			continue
+		}
+
+		if err := rp.apply(ctxt, ev); err != nil {
+			return err
+		}
+		rp.update(func(s *ReplicationStats) {
+			s.Synced = true
+		})
+	}
+
+	if err := ctxt.Err(); err != nil {
+		return err
+	}
+	return &Error{
+		Err: "replicator listen closed",
+	}
+}
+
+// apply applies the event to the destination.
+func (rp *Replicator) apply(ctxt context.Context, ev *Event) error {
+	received := time.Now()
+	rp.update(func(s *ReplicationStats) {
+		s.Events++
+		s.LastEvent = received
+	})
+
+	changes, err := ev.Patch()
+	if err != nil {
+		return err
+	}
+
+	// filter changes
+	writes := make(map[string]interface{})
+	for path, raw := range changes {
+		v, err := decodeJSON(raw)
+		if err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not decode event data: %v", err),
+			}
+		}
+		rp.filter(path, v, writes)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	// write
+	if v, ok := writes["/"]; ok {
+		err = rp.cfg.Destination.SetContext(ctxt, v)
+	} else {
+		update := make(map[string]interface{}, len(writes))
+		for path, v := range writes {
+			update[strings.TrimPrefix(path, "/")] = v
+		}
+		err = rp.cfg.Destination.UpdateContext(ctxt, update)
+	}
+
+	rp.update(func(s *ReplicationStats) {
+		if err != nil {
+			s.Errors++
+			s.LastError = err.Error()
+			return
+		}
+		if _, ok := writes["/"]; ok {
+			s.Snapshots++
+		}
+		s.Writes++
+		s.LastApplied = time.Now()
+		s.Lag = s.LastApplied.Sub(received)
+	})
+
+	return err
+}
+
+// filter adds the write of v at path to writes, when the path is replicated,
+// pruning any excluded paths from v.
+func (rp *Replicator) filter(path string, v interface{}, writes map[string]interface{}) {
+	if rp.excluded(path) {
+		return
+	}
+
+	if rp.included(path) {
+		for _, e := range rp.cfg.Exclude {
+			if e = joinPath(e, ""); pathHasPrefix(e, path) {
+				v = treeSet(v, splitPath(strings.TrimPrefix(e, path)), nil)
+			}
+		}
+		writes[path] = v
+		return
+	}
+
+	// path is an ancestor of included paths
+	for _, i := range rp.cfg.Include {
+		if i = joinPath(i, ""); pathHasPrefix(i, path) {
+			rp.filter(i, treeGet(v, splitPath(strings.TrimPrefix(i, path))), writes)
+		}
+	}
+}
+
+// included determines if path is at or beneath an included path.
+func (rp *Replicator) included(path string) bool {
+	if len(rp.cfg.Include) == 0 {
+		return true
+	}
+	for _, i := range rp.cfg.Include {
+		if pathHasPrefix(path, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded determines if path is at or beneath an excluded path.
+func (rp *Replicator) excluded(path string) bool {
+	for _, e := range rp.cfg.Exclude {
+		if pathHasPrefix(path, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// update updates the stats.
+func (rp *Replicator) update(f func(*ReplicationStats)) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	f(&rp.stats)
+}
+
+// Stats returns a copy of the replicator's metrics.
+func (rp *Replicator) Stats() ReplicationStats {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	return rp.stats
+}
@@ -0,0 +1,34 @@
+package firebase
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReplicatorFilter(t *testing.T) {
+	rp := &Replicator{
+		cfg: ReplicatorConfig{
+			Include: []string{"/users", "/rooms/a"},
+			Exclude: []string{"users/secret"},
+		},
+	}
+
+	v, _ := decodeJSON([]byte(`{"users":{"alice":1,"secret":2},"rooms":{"a":3,"b":4},"other":5}`))
+	writes := make(map[string]interface{})
+	rp.filter("/", v, writes)
+
+	buf, err := json.Marshal(writes)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(buf); s != `{"/rooms/a":3,"/users":{"alice":1}}` {
+		t.Errorf("expected filtered writes, got: %s", s)
+	}
+
+	writes = make(map[string]interface{})
+	rp.filter("/users/secret/x", 1, writes)
+	rp.filter("/other", 1, writes)
+	if len(writes) != 0 {
+		t.Errorf("expected no writes, got: %v", writes)
+	}
+}
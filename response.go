@@ -0,0 +1,106 @@
+package firebase
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// ResponseInfo holds information about the server response for an
+// operation.
+type ResponseInfo struct {
+	// StatusCode is the HTTP status code.
+	StatusCode int
+
+	// ETag is the ETag header, which is only returned by Firebase when
+	// requested (see GetWithETag).
+	ETag string
+
+	// Warnings are the warning headers returned by Firebase (ie, for
+	// unindexed queries).
+	Warnings []string
+
+	// Header contains all response headers.
+	Header http.Header
+}
+
+// newResponseInfo creates response info for the http.Response.
+func newResponseInfo(res *http.Response) *ResponseInfo {
+	info := &ResponseInfo{
+		StatusCode: res.StatusCode,
+		ETag:       res.Header.Get("ETag"),
+		Header:     res.Header,
+	}
+	for _, k := range []string{"Warning", "X-Firebase-Warning"} {
+		info.Warnings = append(info.Warnings, res.Header[k]...)
+	}
+	return info
+}
+
+// responseInfoKey is the context key for a ResponseInfo.
+type responseInfoKey struct{}
+
+// WithResponseInfo returns a copy of the context that captures the server
+// response information for operations made with the context (ie, with
+// GetContext, SetContext, etc) into info.
+//
+// When an operation is retried, info contains the last response. When
+// multiple requests are made with the same context, info contains the
+// response of the last request made.
+func WithResponseInfo(ctxt context.Context, info *ResponseInfo) context.Context {
+	return context.WithValue(ctxt, responseInfoKey{}, info)
+}
+
+// captureResponse captures the response information into the context's
+// ResponseInfo, if any, returning the response info.
+func captureResponse(ctxt context.Context, res *http.Response) *ResponseInfo {
+	info := newResponseInfo(res)
+	if dest, ok := ctxt.Value(responseInfoKey{}).(*ResponseInfo); ok && dest != nil {
+		*dest = *info
+	}
+	return info
+}
+
+// ErrResponseTooLarge is the error returned when the size of a read response
+// exceeds the ref's MaxResponseBytes.
+var ErrResponseTooLarge = &Error{
+	Err: "response exceeds maximum size",
+}
+
+// limitResponse limits the size of the response body of read requests to the
+// ref's MaxResponseBytes, if any, closing the response body and returning
+// ErrResponseTooLarge when the response's Content-Length exceeds the limit.
+func (r *DatabaseRef) limitResponse(method string, res *http.Response) error {
+	if r.maxResponseBytes <= 0 || OpType(method) != OpTypeGet {
+		return nil
+	}
+	if res.ContentLength > r.maxResponseBytes {
+		res.Body.Close()
+		return ErrResponseTooLarge
+	}
+	res.Body = &limitBody{ReadCloser: res.Body, n: r.maxResponseBytes}
+	return nil
+}
+
+// limitBody wraps a response body, returning ErrResponseTooLarge once more
+// than n bytes have been read.
+type limitBody struct {
+	io.ReadCloser
+
+	n int64
+}
+
+// Read satisfies the io.Reader interface.
+func (lb *limitBody) Read(p []byte) (int, error) {
+	if lb.n < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > lb.n+1 {
+		p = p[:lb.n+1]
+	}
+	n, err := lb.ReadCloser.Read(p)
+	if lb.n -= int64(n); lb.n < 0 {
+		return n + int(lb.n), ErrResponseTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithResponseInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", "abc")
+		w.Header().Add("X-Firebase-Warning", "unindexed query")
+		w.Write([]byte(`1`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var info ResponseInfo
+	var n int
+	if err = r.GetContext(WithResponseInfo(context.Background(), &info), &n); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if info.StatusCode != http.StatusOK || info.ETag != "abc" || len(info.Warnings) != 1 {
+		t.Errorf("expected status 200, etag abc, and 1 warning, got: %+v", info)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/chunked.json" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(`{"a":"0123456789"}`))
+	}))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		max int64
+		err error
+	}{
+		{0, nil},
+		{18, nil},
+		{17, ErrResponseTooLarge},
+	} {
+		r, err := NewDatabaseRef(URL(ts.URL+"/"), MaxResponseBytes(test.max))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// with and without content length
+		for _, path := range []string{"/", "/chunked"} {
+			var v interface{}
+			if err = r.Ref(path).Get(&v); err != test.err {
+				t.Errorf("max %d path %s expected %v, got: %v", test.max, path, test.err, err)
+			}
+		}
+
+		// writes are not limited
+		if err = r.Set("x"); err != nil {
+			t.Errorf("max %d expected no error, got: %v", test.max, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetentionChunkSize is the default number of children deleted per
+	// request by a RetentionEngine.
+	DefaultRetentionChunkSize = 500
+)
+
+// RetentionPolicy is a declarative data retention policy, expiring the
+// children of a path once the value of their TTL field is older than the max
+// age.
+type RetentionPolicy struct {
+	// Name is the policy name, used when reporting metrics. If empty, then
+	// the path is used.
+	Name string
+
+	// Path is the path pattern whose children are subject to the policy.
+	// Path components starting with $ are wildcards (ie,
+	// "/rooms/$room/messages").
+	Path string
+
+	// Field is the child field holding the timestamp (in milliseconds since
+	// the Unix epoch, as written by ServerTimestamp) used to determine a
+	// child's age. The field should be indexed (.indexOn) in the security
+	// rules.
+	//
+	// Children without the field are never deleted.
+	Field string
+
+	// MaxAge is the maximum age of a child before it is deleted.
+	MaxAge time.Duration
+}
+
+// name returns the policy's name.
+func (p *RetentionPolicy) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Path
+}
+
+// RetentionStats are the per policy metrics of a RetentionEngine.
+type RetentionStats struct {
+	// Runs is the number of times the policy has been enforced.
+	Runs int64 `json:"runs"`
+
+	// Expired is the number of expired children found.
+	Expired int64 `json:"expired"`
+
+	// Deleted is the number of expired children deleted.
+	Deleted int64 `json:"deleted"`
+
+	// Errors is the number of errors encountered.
+	Errors int64 `json:"errors"`
+
+	// LastRun is the time of the last enforcement.
+	LastRun time.Time `json:"lastRun"`
+
+	// LastError is the last error encountered, if any.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// RetentionConfig is the configuration for a RetentionEngine.
+type RetentionConfig struct {
+	// Policies are the retention policies to enforce.
+	Policies []*RetentionPolicy
+
+	// ChunkSize is the maximum number of children queried and deleted per
+	// request. If less than or equal to 0, then DefaultRetentionChunkSize is
+	// used.
+	ChunkSize int
+
+	// DryRun, when true, reports the expired children without deleting them.
+	DryRun bool
+}
+
+// RetentionEngine enforces retention policies against a Firebase database,
+// using indexed range queries to find expired children, which are then
+// deleted in chunks via multi-path updates.
+type RetentionEngine struct {
+	mu sync.Mutex
+
+	r     *DatabaseRef
+	cfg   RetentionConfig
+	stats map[string]*RetentionStats
+}
+
+// NewRetentionEngine creates a new retention engine enforcing the configured
+// policies relative to database ref r.
+func NewRetentionEngine(r *DatabaseRef, cfg *RetentionConfig) (*RetentionEngine, error) {
+	for i, p := range cfg.Policies {
+		switch {
+		case p.Field == "":
+			return nil, fmt.Errorf("retention policy %d: field cannot be empty", i)
+		case p.MaxAge <= 0:
+			return nil, fmt.Errorf("retention policy %d: max age must be greater than 0", i)
+		}
+	}
+
+	e := &RetentionEngine{
+		r:     r,
+		cfg:   *cfg,
+		stats: make(map[string]*RetentionStats),
+	}
+	if e.cfg.ChunkSize <= 0 {
+		e.cfg.ChunkSize = DefaultRetentionChunkSize
+	}
+
+	return e, nil
+}
+
+// Enforce enforces all policies once, continuing past policies that fail,
+// and returning the first error encountered.
+func (e *RetentionEngine) Enforce(ctxt context.Context) error {
+	var firstErr error
+	for _, p := range e.cfg.Policies {
+		err := e.EnforcePolicy(ctxt, p)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Run enforces all policies every interval, until the context is done.
+func (e *RetentionEngine) Run(ctxt context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		e.Enforce(ctxt)
+
+		select {
+		case <-t.C:
+		case <-ctxt.Done():
+			return ctxt.Err()
+		}
+	}
+}
+
+// EnforcePolicy enforces a single policy, deleting the expired children
+// (unless configured for a dry-run) and updating the policy's metrics.
+func (e *RetentionEngine) EnforcePolicy(ctxt context.Context, p *RetentionPolicy) error {
+	var expired, deleted int64
+
+	// expand wildcards
+	paths, err := e.expand(ctxt, splitPath(p.Path))
+	cutoff := time.Now().Add(-p.MaxAge).UnixNano() / int64(time.Millisecond)
+
+	for i := 0; err == nil && i < len(paths); i++ {
+		var x, d int64
+		x, d, err = e.expire(ctxt, e.r.Ref(paths[i]), p.Field, cutoff)
+		expired, deleted = expired+x, deleted+d
+	}
+
+	// update stats
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.stats[p.name()]
+	if !ok {
+		s = new(RetentionStats)
+		e.stats[p.name()] = s
+	}
+	s.Runs++
+	s.Expired += expired
+	s.Deleted += deleted
+	s.LastRun = time.Now()
+	if err != nil {
+		s.Errors++
+		s.LastError = err.Error()
+		return fmt.Errorf("retention policy %s: %v", p.name(), err)
+	}
+
+	return nil
+}
+
+// expire deletes the children of r whose field is older than cutoff,
+// returning the number of expired and deleted children.
+func (e *RetentionEngine) expire(ctxt context.Context, r *DatabaseRef, field string, cutoff int64) (int64, int64, error) {
+	// children without the field (null) sort first, so start the range at 0
+	opts := []QueryOption{OrderBy(field), StartAt(0), EndAt(cutoff)}
+
+	// dry-run reports all expired children
+	if e.cfg.DryRun {
+		var m map[string]json.RawMessage
+		if err := r.GetContext(ctxt, &m, opts...); err != nil {
+			return 0, 0, err
+		}
+		return int64(len(m)), 0, nil
+	}
+
+	var expired, deleted int64
+	for {
+		var m map[string]json.RawMessage
+		err := r.GetContext(ctxt, &m, append(opts, LimitToFirst(uint(e.cfg.ChunkSize)))...)
+		if err != nil {
+			return expired, deleted, err
+		}
+		if len(m) == 0 {
+			return expired, deleted, nil
+		}
+		expired += int64(len(m))
+
+		// delete chunk
+		update := make(map[string]interface{}, len(m))
+		for k := range m {
+			update[k] = nil
+		}
+		if err = r.UpdateContext(ctxt, update); err != nil {
+			return expired, deleted, err
+		}
+		deleted += int64(len(m))
+
+		if len(m) < e.cfg.ChunkSize {
+			return expired, deleted, nil
+		}
+	}
+}
+
+// expand expands the $ wildcard components of the path components, returning
+// the matching paths.
+func (e *RetentionEngine) expand(ctxt context.Context, parts []string) ([]string, error) {
+	paths := []string{""}
+	for _, part := range parts {
+		if !strings.HasPrefix(part, "$") {
+			for i := range paths {
+				paths[i] += "/" + part
+			}
+			continue
+		}
+
+		// list children
+		var next []string
+		for _, path := range paths {
+			var shallow map[string]interface{}
+			err := e.r.Ref(path).GetContext(ctxt, &shallow, Shallow)
+			if err != nil {
+				return nil, err
+			}
+			keys := make([]string, 0, len(shallow))
+			for k := range shallow {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				next = append(next, path+"/"+k)
+			}
+		}
+		paths = next
+	}
+
+	return paths, nil
+}
+
+// Stats returns a copy of the per policy metrics, keyed by policy name.
+func (e *RetentionEngine) Stats() map[string]RetentionStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := make(map[string]RetentionStats, len(e.stats))
+	for name, s := range e.stats {
+		stats[name] = *s
+	}
+	return stats
+}
@@ -0,0 +1,141 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetentionEngine(t *testing.T) {
+	now := time.Now()
+	ms := func(d time.Duration) float64 {
+		return float64(now.Add(-d).UnixNano() / int64(time.Millisecond))
+	}
+	seed := func(s *memServer) {
+		s.root = nil
+		s.set("/rooms/r1/messages", map[string]interface{}{
+			"m1": map[string]interface{}{"ts": ms(48 * time.Hour)},
+			"m2": map[string]interface{}{"ts": ms(time.Minute)},
+			"m3": map[string]interface{}{"text": "no ts"},
+		})
+		s.set("/rooms/r2/messages", map[string]interface{}{
+			"m4": map[string]interface{}{"ts": ms(72 * time.Hour)},
+			"m5": map[string]interface{}{"ts": ms(49 * time.Hour)},
+			"m6": map[string]interface{}{"ts": ms(25 * time.Hour)},
+		})
+		s.set("/rooms/r3/name", "empty")
+		s.set("/logs", map[string]interface{}{
+			"l1": map[string]interface{}{"at": ms(2 * time.Hour)},
+		})
+		s.reqs = nil
+	}
+
+	s := &memServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	policies := []*RetentionPolicy{
+		{Name: "messages", Path: "/rooms/$room/messages", Field: "ts", MaxAge: 24 * time.Hour},
+		{Path: "/logs", Field: "at", MaxAge: time.Hour},
+	}
+
+	tests := []struct {
+		dryRun  bool
+		remain  string
+		expired int64
+		deleted int64
+		updates int
+	}{
+		{true, "[m1 m2 m3] [m4 m5 m6] [l1]", 5, 0, 0},
+		{false, "[m2 m3] [] []", 5, 5, 4},
+	}
+
+	for i, test := range tests {
+		seed(s)
+		e, err := NewRetentionEngine(r, &RetentionConfig{
+			Policies:  policies,
+			ChunkSize: 2,
+			DryRun:    test.dryRun,
+		})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if err = e.Enforce(context.Background()); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		remain := fmt.Sprint(s.keys("/rooms/r1/messages"), s.keys("/rooms/r2/messages"), s.keys("/logs"))
+		if remain != test.remain {
+			t.Errorf("test %d expected remaining %s, got: %s", i, test.remain, remain)
+		}
+		if s.get("/rooms/r3/name") != "empty" {
+			t.Errorf("test %d expected unrelated data to remain", i)
+		}
+
+		// wildcard expanded with a shallow read
+		if s.reqs[0] != "GET /rooms" {
+			t.Errorf("test %d expected shallow read of /rooms, got: %v", i, s.reqs)
+		}
+		var updates int
+		for _, req := range s.reqs {
+			if strings.HasPrefix(req, "PATCH") {
+				updates++
+			}
+		}
+		if updates != test.updates {
+			t.Errorf("test %d expected %d chunked updates, got: %d (%v)", i, test.updates, updates, s.reqs)
+		}
+
+		stats := e.Stats()
+		msgs, logs := stats["messages"], stats["/logs"]
+		if msgs.Runs != 1 || logs.Runs != 1 || msgs.Errors != 0 {
+			t.Errorf("test %d unexpected stats: %+v", i, stats)
+		}
+		if msgs.Expired+logs.Expired != test.expired || msgs.Deleted+logs.Deleted != test.deleted {
+			t.Errorf("test %d expected %d expired and %d deleted, got: %+v", i, test.expired, test.deleted, stats)
+		}
+	}
+}
+
+func TestRetentionEngineErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"error":"Index not defined"}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// invalid policies
+	for i, p := range []*RetentionPolicy{
+		{Path: "/a", MaxAge: time.Hour},
+		{Path: "/a", Field: "ts"},
+	} {
+		if _, err = NewRetentionEngine(r, &RetentionConfig{Policies: []*RetentionPolicy{p}}); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+
+	e, err := NewRetentionEngine(r, &RetentionConfig{
+		Policies: []*RetentionPolicy{{Path: "/a", Field: "ts", MaxAge: time.Hour}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = e.Enforce(context.Background()); err == nil || !strings.Contains(err.Error(), "Index not defined") {
+		t.Errorf("expected index error, got: %v", err)
+	}
+	if s := e.Stats()["/a"]; s.Errors != 1 || s.LastError == "" {
+		t.Errorf("expected error stats, got: %+v", s)
+	}
+}
@@ -0,0 +1,133 @@
+package firebase
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is the default maximum number of attempts made by
+	// a RetryPolicy.
+	DefaultRetryAttempts = 4
+
+	// DefaultRetryBackoff is the default initial backoff of a RetryPolicy.
+	DefaultRetryBackoff = 100 * time.Millisecond
+
+	// DefaultRetryMaxBackoff is the default maximum backoff of a
+	// RetryPolicy.
+	DefaultRetryMaxBackoff = 5 * time.Second
+)
+
+// DefaultRetryStatusCodes are the default HTTP status codes retried by a
+// RetryPolicy.
+var DefaultRetryStatusCodes = []int{429, 500, 502, 503, 504}
+
+// RetryPolicy is a policy for automatically retrying idempotent operations
+// (Get, Set, and Remove) that fail with a transient error.
+//
+// Push and Update are only retried when marked Idempotent (see CallOptions),
+// as a retried Push could create a duplicate child.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// If less than or equal to 0, then DefaultRetryAttempts is used.
+	MaxAttempts int
+
+	// StatusCodes are the retried HTTP status codes. If nil, then
+	// DefaultRetryStatusCodes is used. Network errors are always retried.
+	StatusCodes []int
+
+	// Backoff is the initial delay between attempts, which is doubled
+	// (with jitter) after each attempt. If less than or equal to 0, then
+	// DefaultRetryBackoff is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum delay between attempts. If less than or equal
+	// to 0, then DefaultRetryMaxBackoff is used.
+	MaxBackoff time.Duration
+}
+
+// Retry is an option that sets the retry policy used for idempotent
+// operations on the database ref (and its children).
+//
+// When retries are exhausted, a copy of the last error is returned with its
+// Attempts field set.
+func Retry(policy RetryPolicy) Option {
+	return func(r *DatabaseRef) error {
+		if policy.Backoff < 0 || policy.MaxBackoff < 0 {
+			return errors.New("retry backoff cannot be negative")
+		}
+		r.retryPolicy = &policy
+		return nil
+	}
+}
+
+// retryMethod determines if requests with the HTTP method are retried.
+func (p *RetryPolicy) retryMethod(method string) bool {
+	switch OpType(method) {
+	case OpTypeGet, OpTypeSet, OpTypeRemove:
+		return true
+	}
+	return false
+}
+
+// maxAttempts returns the maximum number of attempts.
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryAttempts
+	}
+	return p.MaxAttempts
+}
+
+// retryable determines if the error is retryable.
+func (p *RetryPolicy) retryable(e *Error) bool {
+	if e.network {
+		return true
+	}
+
+	codes := p.StatusCodes
+	if codes == nil {
+		codes = DefaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		if e.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withAttempts returns a copy of the error annotated with the number of
+// attempts made. Sentinel errors (ie, ErrClosed) are shared, and are returned
+// unmodified so that they can still be compared.
+func (e *Error) withAttempts(attempt int) error {
+	switch e {
+	case ErrPreconditionFailed, ErrHashMismatch, ErrIteratorDone, ErrClosed, ErrResponseTooLarge, errDeleteValue:
+		return e
+	}
+
+	ce := *e
+	ce.Attempts = attempt
+	return &ce
+}
+
+// backoff returns the delay after the attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d, max := p.Backoff, p.MaxBackoff
+	if d <= 0 {
+		d = DefaultRetryBackoff
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxBackoff
+	}
+
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// full jitter over the upper half
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
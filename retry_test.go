@@ -0,0 +1,137 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), Retry(RetryPolicy{Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var s string
+	if err = r.Get(&s); err != nil || s != "ok" {
+		t.Errorf("expected ok, got: %q (%v)", s, err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 attempts, got: %d", n)
+	}
+
+	// push is not retried
+	atomic.StoreInt32(&n, 0)
+	if _, err = r.Push("v"); err == nil {
+		t.Errorf("expected error")
+	}
+	if n != 1 {
+		t.Errorf("expected 1 attempt, got: %d", n)
+	}
+
+	// retries exhausted
+	atomic.StoreInt32(&n, -10)
+	err = r.Set("v")
+	if e, ok := err.(*Error); !ok || e.Attempts != DefaultRetryAttempts || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected error after %d attempts, got: %v", DefaultRetryAttempts, err)
+	}
+}
+
+func TestPushAndGet(t *testing.T) {
+	var n int32
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		switch req.Method {
+		case "PUT":
+			path = req.URL.Path
+			w.Write([]byte(`{"a":{".sv":"timestamp"}}`))
+		case "GET":
+			if req.URL.Path != path {
+				t.Errorf("expected get of %s, got: %s", path, req.URL.Path)
+			}
+			w.Write([]byte(`{"a":1234}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), Retry(RetryPolicy{Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var v struct {
+		A int64 `json:"a"`
+	}
+	id, err := r.Ref("items").PushAndGet(map[string]interface{}{"a": ServerTimestamp{}}, &v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !IsPushID(id) || path != "/items/"+id+".json" {
+		t.Errorf("expected push id, got: %s (%s)", id, path)
+	}
+	if v.A != 1234 {
+		t.Errorf("expected 1234, got: %d", v.A)
+	}
+}
+
+func TestRetryClosed(t *testing.T) {
+	failed := make(chan struct{})
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			close(failed)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"unavailable"}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), Retry(RetryPolicy{Backoff: 200 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// close the ref while the first attempt is backing off
+	done := make(chan error, 1)
+	go func() {
+		var v interface{}
+		done <- r.Get(&v)
+	}()
+	<-failed
+	if err = r.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = <-done
+	if err != ErrClosed || !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got: %v", err)
+	}
+	if ErrClosed.Attempts != 0 || ErrClosed.Error() != "firebase: database ref closed" {
+		t.Errorf("expected ErrClosed to not be annotated, got: %v", ErrClosed)
+	}
+	if n := atomic.LoadInt32(&n); n != 1 {
+		t.Errorf("expected 1 attempt, got: %d", n)
+	}
+}
@@ -0,0 +1,280 @@
+// Package rules provides a typed builder, canonical serializer, and client
+// side validator for Firebase Realtime Database security rules, allowing
+// programs to generate and diff rules instead of templating JSON strings.
+//
+// For example:
+//
+//	root := rules.New()
+//	root.Child("users/$uid").Read = "auth != null && auth.uid == $uid"
+//	root.Child("users/$uid").Write = "auth != null && auth.uid == $uid"
+//	root.Child("users").IndexOn = []string{"email"}
+//	if err := rules.Validate(root); err != nil {
+//		return err
+//	}
+//	buf, err := root.MarshalRules()
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Node is a rules node for a database path component.
+//
+// Expressions are stored as strings, where the expressions "true" and
+// "false" are serialized as JSON booleans. Empty expressions are omitted.
+type Node struct {
+	// Read is the .read expression.
+	Read string
+
+	// Write is the .write expression.
+	Write string
+
+	// Validate is the .validate expression.
+	Validate string
+
+	// IndexOn are the .indexOn child keys.
+	IndexOn []string
+
+	// Children are the child nodes, keyed by path component, where
+	// components starting with $ are wildcards.
+	Children map[string]*Node
+}
+
+// New creates a new root rules node.
+func New() *Node {
+	return &Node{}
+}
+
+// Child returns the descendant node at the relative path (ie,
+// "users/$uid"), creating any missing nodes.
+func (n *Node) Child(path string) *Node {
+	for _, k := range split(path) {
+		if n.Children == nil {
+			n.Children = make(map[string]*Node)
+		}
+		c, ok := n.Children[k]
+		if !ok {
+			c = &Node{}
+			n.Children[k] = c
+		}
+		n = c
+	}
+	return n
+}
+
+// Lookup returns the descendant node at the relative path, or nil if the
+// node does not exist.
+func (n *Node) Lookup(path string) *Node {
+	for _, k := range split(path) {
+		if n = n.Children[k]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Walk calls f for the node and each of its descendants, in path order, with
+// the absolute path of each node (ie, "/users/$uid").
+func (n *Node) Walk(f func(path string, n *Node)) {
+	n.walk("/", f)
+}
+
+// walk walks the node.
+func (n *Node) walk(path string, f func(string, *Node)) {
+	f(path, n)
+
+	keys := make([]string, 0, len(n.Children))
+	for k := range n.Children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		n.Children[k].walk(strings.TrimSuffix(path, "/")+"/"+k, f)
+	}
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, encoding the node with
+// sorted keys.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	return encode(n.tree(), "")
+}
+
+// tree returns the node as a generic JSON tree.
+func (n *Node) tree() map[string]interface{} {
+	m := make(map[string]interface{}, len(n.Children)+4)
+	for k, v := range map[string]string{".read": n.Read, ".write": n.Write, ".validate": n.Validate} {
+		switch v {
+		case "":
+		case "true":
+			m[k] = true
+		case "false":
+			m[k] = false
+		default:
+			m[k] = v
+		}
+	}
+	if len(n.IndexOn) != 0 {
+		idx := append([]string(nil), n.IndexOn...)
+		sort.Strings(idx)
+		m[".indexOn"] = idx
+	}
+	for k, c := range n.Children {
+		m[k] = c.tree()
+	}
+	return m
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (n *Node) UnmarshalJSON(buf []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return err
+	}
+
+	*n = Node{}
+	for k, raw := range m {
+		switch k {
+		case ".read", ".write", ".validate":
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			var expr string
+			switch x := v.(type) {
+			case bool:
+				expr = fmt.Sprintf("%t", x)
+			case string:
+				expr = x
+			default:
+				return fmt.Errorf("%s must be a boolean or string expression", k)
+			}
+			switch k {
+			case ".read":
+				n.Read = expr
+			case ".write":
+				n.Write = expr
+			case ".validate":
+				n.Validate = expr
+			}
+
+		case ".indexOn":
+			var idx []string
+			if err := json.Unmarshal(raw, &idx); err != nil {
+				var s string
+				if err = json.Unmarshal(raw, &s); err != nil {
+					return fmt.Errorf(".indexOn must be a string or array of strings")
+				}
+				idx = []string{s}
+			}
+			n.IndexOn = idx
+
+		default:
+			if strings.HasPrefix(k, ".") {
+				return fmt.Errorf("unknown rule %s", k)
+			}
+			c := new(Node)
+			if err := json.Unmarshal(raw, c); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			if n.Children == nil {
+				n.Children = make(map[string]*Node)
+			}
+			n.Children[k] = c
+		}
+	}
+
+	return nil
+}
+
+// MarshalRules encodes the node as a canonical (sorted and indented) rules
+// document (ie, {"rules": {...}}), suitable for SetRulesJSON.
+func (n *Node) MarshalRules() ([]byte, error) {
+	return encode(map[string]interface{}{"rules": n.tree()}, "  ")
+}
+
+// encode encodes v as JSON without escaping HTML characters, as rules
+// expressions commonly contain &&, <, and >.
+func encode(v interface{}, indent string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if indent == "" {
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse parses a JSON encoded rules document (ie, {"rules": {...}}), as
+// returned by GetRulesJSON. Comments (// and /* */) are allowed, as with
+// rules files used by the Firebase CLI.
+func Parse(buf []byte) (*Node, error) {
+	var v struct {
+		Rules *Node `json:"rules"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(stripComments(buf)))
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("could not decode rules: %v", err)
+	}
+	if v.Rules == nil {
+		return nil, fmt.Errorf("missing rules")
+	}
+	return v.Rules, nil
+}
+
+// stripComments removes // and /* */ comments outside of strings from buf.
+func stripComments(buf []byte) []byte {
+	out := make([]byte, 0, len(buf))
+	for i := 0; i < len(buf); i++ {
+		switch {
+		case buf[i] == '"':
+			// copy string
+			j := i + 1
+			for ; j < len(buf) && buf[j] != '"'; j++ {
+				if buf[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(buf) {
+				j = len(buf) - 1
+			}
+			out = append(out, buf[i:j+1]...)
+			i = j
+
+		case buf[i] == '/' && i+1 < len(buf) && buf[i+1] == '/':
+			for i < len(buf) && buf[i] != '\n' {
+				i++
+			}
+			if i < len(buf) {
+				out = append(out, '\n')
+			}
+
+		case buf[i] == '/' && i+1 < len(buf) && buf[i+1] == '*':
+			end := bytes.Index(buf[i+2:], []byte("*/"))
+			if end == -1 {
+				return out
+			}
+			i += end + 3
+
+		default:
+			out = append(out, buf[i])
+		}
+	}
+	return out
+}
+
+// split splits a path into its components.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalRules(t *testing.T) {
+	root := New()
+	root.Read = "false"
+	root.Child("users/$uid").Read = "auth != null && auth.uid == $uid"
+	root.Child("users/$uid").Write = "true"
+	root.Child("users").IndexOn = []string{"name", "email"}
+
+	buf, err := root.MarshalRules()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := `{
+  "rules": {
+    ".read": false,
+    "users": {
+      "$uid": {
+        ".read": "auth != null && auth.uid == $uid",
+        ".write": true
+      },
+      ".indexOn": [
+        "email",
+        "name"
+      ]
+    }
+  }
+}
+`
+	if string(buf) != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, string(buf))
+	}
+
+	// round trip
+	n, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c := n.Lookup("users/$uid"); c == nil || c.Write != "true" || c.Read != root.Child("users/$uid").Read {
+		t.Errorf("expected users/$uid to round trip, got: %+v", c)
+	}
+	again, err := n.MarshalRules()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(again) != string(buf) {
+		t.Errorf("expected canonical output to be stable, got:\n%s", string(again))
+	}
+}
+
+func TestParse(t *testing.T) {
+	n, err := Parse([]byte(`{
+  // comment
+  "rules": {
+    /* block "comment" */
+    "a": {".indexOn": "b", ".validate": "newData.isString() // not a comment"}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	a := n.Lookup("a")
+	if a == nil || len(a.IndexOn) != 1 || a.IndexOn[0] != "b" || a.Validate != "newData.isString() // not a comment" {
+		t.Errorf("unexpected node: %+v", a)
+	}
+
+	if _, err = Parse([]byte(`{"rules": {".bogus": true}}`)); err == nil {
+		t.Errorf("expected error for unknown rule")
+	}
+}
+
+func TestValidateExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		vars []string
+		err  string
+	}{
+		{`true`, nil, ""},
+		{`auth != null && auth.uid === $uid`, []string{"$uid"}, ""},
+		{`newData.child('name').isString() && newData.child("name").val().length < 100`, nil, ""},
+		{`root.child('users/' + auth.uid).exists() ? data.val() == null : !data.exists()`, nil, ""},
+		{`newData.val().matches(/^[a-z0-9]+@example\.com$/i)`, nil, ""},
+		{`newData.val() / 2 > now - 60 * 1000`, nil, ""},
+		{`query.orderByKey && query.limitToFirst <= 50`, nil, ""},
+		{`auth.uid == $uid`, nil, "unknown variable $uid"},
+		{`foo.bar`, nil, "unknown variable foo"},
+		{`(auth != null`, nil, `expected ")"`},
+		{`auth != null)`, nil, `unexpected ")"`},
+		{`data.child('a).exists()`, nil, "unterminated string"},
+		{`auth !=`, nil, "unexpected end of expression"},
+		{`auth.`, nil, "expected property name"},
+		{`auth # 1`, nil, `unexpected '#'`},
+	}
+	for i, test := range tests {
+		err := ValidateExpr(test.expr, test.vars...)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("test %d %q expected no error, got: %v", i, test.expr, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("test %d %q expected error containing %q, got: %v", i, test.expr, test.err, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	root := New()
+	root.Child("users/$uid").Read = "auth.uid == $uid"
+	root.Child("users/$uid/posts/$post").Write = "$uid == auth.uid && $post != ''"
+	if err := Validate(root); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	root.Child("users/$other").Read = "true"
+	root.Child("posts").Write = "$uid == auth.uid"
+	root.Child("bad.key")
+	err := Validate(root)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 validation errors, got: %v", err)
+	}
+	for i, s := range []string{
+		"/bad.key: invalid key",
+		"/posts/.write: unknown variable $uid",
+		"/users: multiple wildcards",
+	} {
+		if !strings.HasPrefix(errs[i].Error(), s) {
+			t.Errorf("error %d expected %q, got: %v", i, s, errs[i])
+		}
+	}
+}
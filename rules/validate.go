@@ -0,0 +1,429 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError is a rules validation error.
+type ValidationError struct {
+	// Path is the absolute path of the node with the error.
+	Path string
+
+	// Rule is the rule with the error (ie, ".read"), or empty when the error
+	// is with the node itself.
+	Rule string
+
+	// Err is the error message.
+	Err string
+}
+
+// Error satisfies the error interface.
+func (err *ValidationError) Error() string {
+	if err.Rule == "" {
+		return fmt.Sprintf("%s: %s", err.Path, err.Err)
+	}
+	return fmt.Sprintf("%s/%s: %s", strings.TrimSuffix(err.Path, "/"), err.Rule, err.Err)
+}
+
+// ValidationErrors are the errors returned by Validate.
+type ValidationErrors []*ValidationError
+
+// Error satisfies the error interface.
+func (errs ValidationErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Validate checks the syntax of the rules n, returning ValidationErrors when
+// any expression is malformed, refers to an unknown variable, or when a node
+// has more than one wildcard child.
+//
+// Validation is client side only, and does not check types or that the
+// rules will be accepted by the server.
+func Validate(n *Node) error {
+	var errs ValidationErrors
+	n.validate("/", nil, &errs)
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate validates the node.
+func (n *Node) validate(path string, vars []string, errs *ValidationErrors) {
+	for _, r := range []struct {
+		rule, expr string
+	}{
+		{".read", n.Read},
+		{".write", n.Write},
+		{".validate", n.Validate},
+	} {
+		if r.expr == "" {
+			continue
+		}
+		if err := ValidateExpr(r.expr, vars...); err != nil {
+			*errs = append(*errs, &ValidationError{Path: path, Rule: r.rule, Err: err.Error()})
+		}
+	}
+	for _, k := range n.IndexOn {
+		if k == "" || strings.ContainsAny(k, ".#$[]") && k != ".value" {
+			*errs = append(*errs, &ValidationError{Path: path, Rule: ".indexOn", Err: fmt.Sprintf("invalid key %q", k)})
+		}
+	}
+
+	keys := make([]string, 0, len(n.Children))
+	for k := range n.Children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var wildcard string
+	for _, k := range keys {
+		p := strings.TrimSuffix(path, "/") + "/" + k
+		switch {
+		case strings.HasPrefix(k, "$"):
+			if !isIdent(k[1:]) {
+				*errs = append(*errs, &ValidationError{Path: p, Err: fmt.Sprintf("invalid wildcard %q", k)})
+			}
+			if wildcard != "" {
+				*errs = append(*errs, &ValidationError{Path: path, Err: fmt.Sprintf("multiple wildcards %s and %s", wildcard, k)})
+			}
+			wildcard = k
+			n.Children[k].validate(p, append(vars[:len(vars):len(vars)], k), errs)
+		case k == "" || strings.ContainsAny(k, ".#$[]"):
+			*errs = append(*errs, &ValidationError{Path: p, Err: fmt.Sprintf("invalid key %q", k)})
+		default:
+			n.Children[k].validate(p, vars, errs)
+		}
+	}
+}
+
+// builtins are the variables available to all rules expressions.
+var builtins = map[string]bool{
+	"auth":    true,
+	"data":    true,
+	"newData": true,
+	"now":     true,
+	"query":   true,
+	"root":    true,
+	"true":    true,
+	"false":   true,
+	"null":    true,
+}
+
+// ValidateExpr checks the syntax of a rules expression, where vars are the
+// wildcard variables (ie, "$uid") in scope.
+func ValidateExpr(expr string, vars ...string) error {
+	toks, err := lex(expr)
+	if err != nil {
+		return err
+	}
+
+	p := &parser{toks: toks, vars: make(map[string]bool, len(vars))}
+	for _, v := range vars {
+		p.vars[v] = true
+	}
+	if err := p.expr(); err != nil {
+		return err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+
+	return nil
+}
+
+// tokKind is a token kind.
+type tokKind int
+
+// token kinds.
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokRegexp
+	tokOp
+)
+
+// token is a lexed expression token.
+type token struct {
+	kind tokKind
+	val  string
+	pos  int
+}
+
+// String satisfies the stringer interface.
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// ops are the expression operators, longest first.
+var ops = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "?", ":", ".", ",", "(", ")", "[", "]",
+}
+
+// lex splits the expression into tokens.
+func lex(expr string) ([]token, error) {
+	var toks []token
+	operand := true
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c == '$' || c == '_' || isLetter(c):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || expr[j] == '$' || isLetter(expr[j]) || isDigit(expr[j])) {
+				j++
+			}
+			toks, operand = append(toks, token{tokIdent, expr[i:j], i}), false
+			i = j
+			continue
+
+		case isDigit(c):
+			j := i + 1
+			for j < len(expr) && (isDigit(expr[j]) || expr[j] == '.') {
+				j++
+			}
+			toks, operand = append(toks, token{tokNumber, expr[i:j], i}), false
+			i = j
+			continue
+
+		case c == '\'' || c == '"':
+			j := i + 1
+			for ; j < len(expr) && expr[j] != c; j++ {
+				if expr[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			toks, operand = append(toks, token{tokString, expr[i : j+1], i}), false
+			i = j + 1
+			continue
+
+		case c == '/' && operand:
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '/'; j++ {
+				if expr[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated regular expression at position %d", i)
+			}
+			for j++; j < len(expr) && isLetter(expr[j]); j++ {
+			}
+			toks, operand = append(toks, token{tokRegexp, expr[i:j], i}), false
+			i = j
+			continue
+		}
+
+		var op string
+		for _, o := range ops {
+			if strings.HasPrefix(expr[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		}
+		toks, operand = append(toks, token{tokOp, op, i}), op != ")" && op != "]"
+		i += len(op)
+	}
+
+	return append(toks, token{kind: tokEOF, pos: len(expr)}), nil
+}
+
+// parser is a recursive descent parser for rules expressions.
+type parser struct {
+	toks []token
+	i    int
+	vars map[string]bool
+}
+
+// peek returns the current token.
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+// next returns the current token and advances the parser.
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept advances the parser if the current token is one of the operators.
+func (p *parser) accept(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.val == op {
+			p.i++
+			return true
+		}
+	}
+	return false
+}
+
+// expect advances the parser if the current token is op, returning an error
+// otherwise.
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, got %s at position %d", op, t, t.pos)
+	}
+	return nil
+}
+
+// expr parses a conditional expression.
+func (p *parser) expr() error {
+	if err := p.binary(0); err != nil {
+		return err
+	}
+	if p.accept("?") {
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		return p.expr()
+	}
+	return nil
+}
+
+// precedence are the binary operators, by increasing precedence.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "===", "!=="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// binary parses a binary expression at the precedence level.
+func (p *parser) binary(level int) error {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	if err := p.binary(level + 1); err != nil {
+		return err
+	}
+	for p.accept(precedence[level]...) {
+		if err := p.binary(level + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unary parses a unary expression.
+func (p *parser) unary() error {
+	if p.accept("!", "-") {
+		return p.unary()
+	}
+	if err := p.primary(); err != nil {
+		return err
+	}
+
+	// member access, calls, and indexing
+	for {
+		switch {
+		case p.accept("."):
+			if t := p.next(); t.kind != tokIdent {
+				return fmt.Errorf("expected property name, got %s at position %d", t, t.pos)
+			}
+		case p.accept("("):
+			if p.accept(")") {
+				continue
+			}
+			for {
+				if err := p.expr(); err != nil {
+					return err
+				}
+				if !p.accept(",") {
+					break
+				}
+			}
+			if err := p.expect(")"); err != nil {
+				return err
+			}
+		case p.accept("["):
+			if err := p.expr(); err != nil {
+				return err
+			}
+			if err := p.expect("]"); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// primary parses a primary expression.
+func (p *parser) primary() error {
+	t := p.next()
+	switch t.kind {
+	case tokNumber, tokString, tokRegexp:
+		return nil
+	case tokIdent:
+		if !builtins[t.val] && !p.vars[t.val] {
+			return fmt.Errorf("unknown variable %s at position %d", t.val, t.pos)
+		}
+		return nil
+	case tokOp:
+		if t.val == "(" {
+			if err := p.expr(); err != nil {
+				return err
+			}
+			return p.expect(")")
+		}
+	}
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+// isIdent determines if s is a valid identifier.
+func isIdent(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c != '_' && !isLetter(c) && !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLetter determines if c is an ascii letter.
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// isDigit determines if c is an ascii digit.
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,270 @@
+package rulestest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/knq/firebase"
+)
+
+// ReadOperations reads a JSON encoded operation log (one record per line), as
+// written by firebase.AccessRecorder's LogOperations.
+func ReadOperations(r io.Reader) ([]firebase.OperationRecord, error) {
+	var ops []firebase.OperationRecord
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for i := 1; s.Scan(); i++ {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var op firebase.OperationRecord
+		err := json.Unmarshal(line, &op)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i, err)
+		}
+		ops = append(ops, op)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+// RuleCoverage is the coverage of a single rules expression.
+type RuleCoverage struct {
+	// Path is the rules path (ie, "/users/$uid").
+	Path string
+
+	// Kind is the rule kind (ie, ".read", ".write", or ".validate").
+	Kind string
+
+	// Expr is the rules expression.
+	Expr string
+
+	// Hits is the number of replayed operations that exercised the
+	// expression.
+	Hits int
+}
+
+// Coverage is a security rules coverage report.
+type Coverage struct {
+	// Rules is the coverage of each rules expression in the candidate rules,
+	// ordered by path.
+	Rules []*RuleCoverage
+
+	// NewlyDenied are the replayed operations that were allowed by the
+	// baseline rules, but are denied by the candidate rules.
+	NewlyDenied []firebase.OperationRecord
+
+	// NewlyAllowed are the replayed operations that were denied by the
+	// baseline rules, but are allowed by the candidate rules.
+	NewlyAllowed []firebase.OperationRecord
+
+	// Errors are the errors, other than permission denied, encountered when
+	// replaying the operations against the candidate rules.
+	Errors []error
+}
+
+// Unexercised returns the rules expressions that were not exercised by any
+// replayed operation.
+func (c *Coverage) Unexercised() []*RuleCoverage {
+	var rules []*RuleCoverage
+	for _, rc := range c.Rules {
+		if rc.Hits == 0 {
+			rules = append(rules, rc)
+		}
+	}
+	return rules
+}
+
+// WriteReport writes a human readable coverage report to w.
+func (c *Coverage) WriteReport(w io.Writer) {
+	var hit int
+	for _, rc := range c.Rules {
+		mark := " "
+		if rc.Hits > 0 {
+			hit++
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %6d  %s/%s: %s\n", mark, rc.Hits, strings.TrimSuffix(rc.Path, "/"), rc.Kind, rc.Expr)
+	}
+	fmt.Fprintf(w, "%d of %d rules expressions exercised\n", hit, len(c.Rules))
+
+	for _, op := range c.NewlyDenied {
+		fmt.Fprintf(w, "NEWLY DENIED: %s %s (auth: %s)\n", op.Op, op.Path, string(op.Auth))
+	}
+	for _, op := range c.NewlyAllowed {
+		fmt.Fprintf(w, "NEWLY ALLOWED: %s %s (auth: %s)\n", op.Op, op.Path, string(op.Auth))
+	}
+	for _, err := range c.Errors {
+		fmt.Fprintf(w, "ERROR: %v\n", err)
+	}
+}
+
+// Coverage replays the recorded operations against the emulator, first with
+// the baseline rules and then with the candidate rules (resetting the data to
+// seed before each replay), and reports the operations whose outcome changed
+// along with which candidate rules expressions were exercised.
+//
+// A rules expression is considered exercised by an operation when Firebase
+// would evaluate it for the operation: .read and .write rules on the
+// operation's path or any of its ancestors, and .validate rules on the
+// operation's path, ancestors, or descendants (for writes).
+func (rn *Runner) Coverage(ctxt context.Context, baseline, candidate []byte, seed interface{}, ops []firebase.OperationRecord) (*Coverage, error) {
+	// convert to cases
+	cases := make([]Case, len(ops))
+	for i, op := range ops {
+		var auth interface{}
+		if len(op.Auth) != 0 {
+			if err := json.Unmarshal(op.Auth, &auth); err != nil {
+				return nil, fmt.Errorf("operation %d: invalid auth: %v", i, err)
+			}
+		}
+
+		var data interface{}
+		if len(op.Data) != 0 {
+			data = op.Data
+		}
+
+		cases[i] = Case{
+			Auth: auth,
+			Op:   op.Op,
+			Path: op.Path,
+			Data: data,
+		}
+	}
+
+	// replay
+	if seed == nil {
+		seed = map[string]interface{}{}
+	}
+	before, err := rn.Run(ctxt, baseline, seed, cases)
+	if err != nil {
+		return nil, err
+	}
+	after, err := rn.Run(ctxt, candidate, seed, cases)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect rules
+	rules, err := collectRules(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	cov := &Coverage{
+		Rules: rules,
+	}
+	for i, op := range ops {
+		switch {
+		case after[i].Err != nil:
+			cov.Errors = append(cov.Errors, fmt.Errorf("%s %s: %v", op.Op, op.Path, after[i].Err))
+		case before[i].Allowed && !after[i].Allowed:
+			cov.NewlyDenied = append(cov.NewlyDenied, op)
+		case !before[i].Allowed && after[i].Allowed:
+			cov.NewlyAllowed = append(cov.NewlyAllowed, op)
+		}
+
+		// mark exercised rules
+		write := op.Op != firebase.OpTypeGet
+		for _, rc := range rules {
+			if exercises(rc, op.Path, write) {
+				rc.Hits++
+			}
+		}
+	}
+
+	return cov, nil
+}
+
+// exercises determines if an operation on path exercises the rule.
+func exercises(rc *RuleCoverage, path string, write bool) bool {
+	switch rc.Kind {
+	case ".read":
+		return !write && pathMatches(rc.Path, path, false)
+	case ".write":
+		return write && pathMatches(rc.Path, path, false)
+	case ".validate":
+		return write && pathMatches(rc.Path, path, true)
+	}
+	return false
+}
+
+// pathMatches determines if the rules path matches a prefix of the database
+// path, treating $ components as wildcards. When descendants is true, rules
+// paths beneath the database path also match.
+func pathMatches(rulePath, path string, descendants bool) bool {
+	rp, p := split(rulePath), split(path)
+	if len(rp) > len(p) && !descendants {
+		return false
+	}
+
+	for i := 0; i < len(rp) && i < len(p); i++ {
+		if !strings.HasPrefix(rp[i], "$") && rp[i] != p[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// split splits a path into its components.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// collectRules collects the rules expressions from the JSON encoded rules.
+func collectRules(buf []byte) ([]*RuleCoverage, error) {
+	var v struct {
+		Rules map[string]interface{} `json:"rules"`
+	}
+	err := json.Unmarshal(buf, &v)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode rules: %v", err)
+	}
+
+	var rules []*RuleCoverage
+	var walk func(string, map[string]interface{})
+	walk = func(path string, m map[string]interface{}) {
+		for k, x := range m {
+			switch k {
+			case ".read", ".write", ".validate":
+				rules = append(rules, &RuleCoverage{
+					Path: path,
+					Kind: k,
+					Expr: fmt.Sprintf("%v", x),
+				})
+			default:
+				if child, ok := x.(map[string]interface{}); ok && !strings.HasPrefix(k, ".") {
+					walk(path+k+"/", child)
+				}
+			}
+		}
+	}
+	walk("/", v.Rules)
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Path == rules[j].Path {
+			return rules[i].Kind < rules[j].Kind
+		}
+		return rules[i].Path < rules[j].Path
+	})
+
+	return rules, nil
+}
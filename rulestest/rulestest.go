@@ -0,0 +1,258 @@
+// Package rulestest provides a runner for testing Firebase security rules
+// against the Firebase Realtime Database emulator.
+//
+// A Runner loads a rules file into the emulator, and then executes a table of
+// cases against the emulator, each as a specific auth variable, reporting the
+// cases where the operation was not allowed or denied as expected.
+package rulestest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/knq/firebase"
+)
+
+const (
+	// DefaultHost is the default Firebase Realtime Database emulator host.
+	DefaultHost = "localhost:9000"
+
+	// authDebugHeader is the header used to request (and return) the
+	// security rules evaluation debug output.
+	authDebugHeader = "X-Firebase-Auth-Debug"
+)
+
+// Case is a security rules test case.
+type Case struct {
+	// Name is the name of the case.
+	Name string
+
+	// Auth is the auth variable used for the operation. If nil, the operation
+	// is made unauthenticated.
+	Auth interface{}
+
+	// Op is the operation type.
+	Op firebase.OpType
+
+	// Path is the database path for the operation.
+	Path string
+
+	// Data is the data written by the operation.
+	Data interface{}
+
+	// Want is whether or not the operation should be allowed.
+	Want bool
+}
+
+// String satisfies the fmt.Stringer interface.
+func (c Case) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s %s (auth: %v)", c.Op, c.Path, c.Auth)
+}
+
+// Result is the result of executing a Case.
+type Result struct {
+	// Case is the executed case.
+	Case Case
+
+	// Allowed is whether or not the operation was allowed.
+	Allowed bool
+
+	// Err is any error, other than permission denied, encountered when
+	// executing the operation.
+	Err error
+
+	// Debug is the rules evaluation debug output returned by the emulator.
+	Debug string
+}
+
+// Passed returns true when the operation was allowed or denied as expected.
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Allowed == r.Case.Want
+}
+
+// Runner executes security rules test cases against the Firebase Realtime
+// Database emulator.
+type Runner struct {
+	db    *firebase.DatabaseRef
+	debug *debugTransport
+}
+
+// NewRunner creates a new security rules test runner for the emulator running
+// at host (ie, "localhost:9000") using the database namespace.
+func NewRunner(host, namespace string) (*Runner, error) {
+	if host == "" {
+		host = DefaultHost
+	}
+
+	dt := new(debugTransport)
+	db, err := firebase.NewDatabaseRef(
+		firebase.Transport(dt),
+		firebase.Emulator(host, namespace),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		db:    db,
+		debug: dt,
+	}, nil
+}
+
+// DatabaseRef returns the database ref used by the runner, which has owner
+// credentials on the emulator.
+func (rn *Runner) DatabaseRef() *firebase.DatabaseRef {
+	return rn.db
+}
+
+// Run loads the JSON encoded rules into the emulator, replaces the
+// emulator's data with seed (unless nil), and then executes each of the
+// cases in order, returning the results.
+//
+// As cases are executed in order, writes by earlier cases are visible to
+// later cases.
+func (rn *Runner) Run(ctxt context.Context, rules []byte, seed interface{}, cases []Case) ([]Result, error) {
+	var err error
+
+	// load rules
+	err = rn.db.SetRulesJSON(rules)
+	if err != nil {
+		return nil, fmt.Errorf("could not load rules: %v", err)
+	}
+
+	// seed data
+	if seed != nil {
+		err = rn.db.Set(seed)
+		if err != nil {
+			return nil, fmt.Errorf("could not seed data: %v", err)
+		}
+	}
+
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		select {
+		case <-ctxt.Done():
+			return results, ctxt.Err()
+		default:
+		}
+		results = append(results, rn.exec(c))
+	}
+
+	return results, nil
+}
+
+// exec executes the case.
+func (rn *Runner) exec(c Case) Result {
+	r := rn.db.Ref(c.Path)
+	opts := []firebase.QueryOption{firebase.AuthOverride(c.Auth)}
+
+	rn.debug.reset()
+
+	var err error
+	switch c.Op {
+	case firebase.OpTypeGet:
+		err = r.Get(nil, opts...)
+	case firebase.OpTypeSet:
+		err = r.Set(c.Data, opts...)
+	case firebase.OpTypePush:
+		_, err = r.Push(c.Data, opts...)
+	case firebase.OpTypeUpdate:
+		err = r.Update(c.Data, opts...)
+	case firebase.OpTypeRemove:
+		err = r.Remove(opts...)
+	default:
+		err = fmt.Errorf("unknown op type %s", c.Op)
+	}
+
+	res := Result{
+		Case:    c,
+		Allowed: err == nil,
+		Debug:   rn.debug.last(),
+	}
+	if err != nil && !isPermissionDenied(err) {
+		res.Err = err
+	}
+
+	return res
+}
+
+// Report writes a human readable report of the results to w, returning the
+// number of failed cases.
+func Report(w io.Writer, results []Result) int {
+	var failed int
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "PASS: %s\n", r.Case)
+			continue
+		}
+
+		failed++
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(w, "FAIL: %s: error: %v\n", r.Case, r.Err)
+		case r.Case.Want:
+			fmt.Fprintf(w, "FAIL: %s: expected allowed, was denied\n", r.Case)
+		default:
+			fmt.Fprintf(w, "FAIL: %s: expected denied, was allowed\n", r.Case)
+		}
+		if r.Debug != "" {
+			fmt.Fprintf(w, "  debug:\n    %s\n", strings.Replace(strings.TrimSpace(r.Debug), "\n", "\n    ", -1))
+		}
+	}
+
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+
+	return failed
+}
+
+// isPermissionDenied determines if err is a permission denied error from the
+// server.
+func isPermissionDenied(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "permission denied")
+}
+
+// debugTransport is a http.RoundTripper that requests security rules debug
+// output from the server, retaining the debug output of the last response.
+type debugTransport struct {
+	mu    sync.Mutex
+	debug string
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (dt *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set(authDebugHeader, "true")
+
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	dt.mu.Lock()
+	dt.debug = res.Header.Get(authDebugHeader)
+	dt.mu.Unlock()
+
+	return res, nil
+}
+
+// reset clears the retained debug output.
+func (dt *debugTransport) reset() {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.debug = ""
+}
+
+// last returns the debug output of the last response.
+func (dt *debugTransport) last() string {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	return dt.debug
+}
@@ -0,0 +1,159 @@
+package rulestest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+// emulator is a test emulator, that allows reads of /public by anyone, and
+// reads and writes of /users/<uid> by the user.
+type emulator struct {
+	sync.Mutex
+	rules string
+	data  map[string]string
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	e.Lock()
+	defer e.Unlock()
+
+	if req.URL.Query().Get("ns") != "test" || req.Header.Get(authDebugHeader) != "true" {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	path := strings.TrimSuffix(req.URL.Path, ".json")
+	body, _ := ioutil.ReadAll(req.Body)
+
+	// owner
+	override, ok := req.URL.Query()["auth_variable_override"]
+	if !ok {
+		switch path {
+		case "/.settings/rules":
+			buf := new(bytes.Buffer)
+			json.Compact(buf, body)
+			e.rules = buf.String()
+		case "/":
+			e.data = map[string]string{"/": string(body)}
+		}
+		w.Write(body)
+		return
+	}
+
+	var auth struct {
+		UID string `json:"uid"`
+	}
+	json.Unmarshal([]byte(override[0]), &auth)
+	allowed := req.Method == "GET" && strings.HasPrefix(path, "/public/")
+	if auth.UID != "" && strings.HasPrefix(path+"/", "/users/"+auth.UID+"/") {
+		allowed = true
+	}
+	if path == "/error" {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		w.Header().Set(authDebugHeader, "Attempt to "+req.Method+" "+path+" denied.")
+		http.Error(w, `{"error":"Permission denied"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if req.Method != "GET" {
+		e.data[path] = string(body)
+	}
+	switch req.Method {
+	case "POST":
+		w.Write([]byte(`{"name":"-id"}`))
+	case "GET":
+		w.Write([]byte(`null`))
+	default:
+		w.Write(body)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	e := &emulator{}
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	rn, err := NewRunner(strings.TrimPrefix(ts.URL, "http://"), "test")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if rn.DatabaseRef() == nil {
+		t.Fatalf("expected database ref")
+	}
+
+	alice := map[string]interface{}{"uid": "alice"}
+	cases := []Case{
+		{Name: "public read", Op: firebase.OpTypeGet, Path: "public/posts", Want: true},
+		{Auth: alice, Op: firebase.OpTypeSet, Path: "users/alice/name", Data: "Alice", Want: true},
+		{Auth: alice, Op: firebase.OpTypePush, Path: "users/alice/posts", Data: "hi", Want: true},
+		{Auth: alice, Op: firebase.OpTypeUpdate, Path: "users/alice", Data: map[string]interface{}{"age": 30}, Want: true},
+		{Auth: alice, Op: firebase.OpTypeRemove, Path: "users/alice/age", Want: true},
+		{Name: "other user", Auth: alice, Op: firebase.OpTypeSet, Path: "users/bob/name", Data: "Bob", Want: false},
+		{Name: "unauthenticated write", Op: firebase.OpTypeSet, Path: "users/alice/name", Data: "x", Want: true},
+		{Name: "server error", Auth: alice, Op: firebase.OpTypeGet, Path: "error", Want: true},
+	}
+
+	results, err := rn.Run(context.Background(), []byte(`{"rules":{}}`), map[string]interface{}{"public": true}, cases)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if e.rules != `{"rules":{}}` || e.data["/"] != `{"public":true}` {
+		t.Errorf("expected rules and seed to be loaded, got: %q, %v", e.rules, e.data)
+	}
+	if e.data["/users/alice/name"] != `"Alice"` || e.data["/users/bob/name"] != "" {
+		t.Errorf("unexpected data: %v", e.data)
+	}
+
+	if len(results) != len(cases) {
+		t.Fatalf("expected %d results, got: %d", len(cases), len(results))
+	}
+	for i, res := range results[:6] {
+		if !res.Passed() || res.Err != nil {
+			t.Errorf("test %d expected %s to pass, got: %+v", i, res.Case, res)
+		}
+	}
+	if res := results[5]; res.Allowed || !strings.Contains(res.Debug, "denied") {
+		t.Errorf("expected denied with debug output, got: %+v", res)
+	}
+	if res := results[6]; res.Passed() || res.Err != nil || res.Allowed {
+		t.Errorf("expected unexpected denial to fail, got: %+v", res)
+	}
+	if res := results[7]; res.Passed() || res.Err == nil {
+		t.Errorf("expected server error, got: %+v", res)
+	}
+
+	// report
+	buf := new(bytes.Buffer)
+	if n := Report(buf, results); n != 2 {
+		t.Errorf("expected 2 failed, got: %d", n)
+	}
+	for _, s := range []string{
+		"PASS: public read\n",
+		"PASS: PUT users/alice/name (auth: map[uid:alice])\n",
+		"FAIL: unauthenticated write: expected allowed, was denied\n  debug:\n    Attempt to PUT /users/alice/name denied.\n",
+		"FAIL: server error: error: ",
+		"6 passed, 2 failed\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected report to contain %q, got:\n%s", s, buf)
+		}
+	}
+
+	// canceled
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+	if results, err = rn.Run(ctxt, []byte(`{"rules":{}}`), nil, cases); err != context.Canceled || len(results) != 0 {
+		t.Errorf("expected context canceled, got: %d results, %v", len(results), err)
+	}
+}
@@ -0,0 +1,223 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultSeedBatchSize is the default number of records written per
+	// request by Seed.
+	DefaultSeedBatchSize = 500
+)
+
+// SeedConfig is the configuration for Seed.
+type SeedConfig struct {
+	// Template is a text/template that renders a single JSON encoded record.
+	// The template is executed with SeedData, and has the SeedFuncs
+	// available.
+	Template string
+
+	// Key is an optional text/template that renders the key for each record.
+	// If empty, then a Push ID is used.
+	Key string
+
+	// Count is the number of records to generate.
+	Count int
+
+	// Seed is the random seed, allowing datasets to be regenerated
+	// identically. If 0, then the current time is used.
+	Seed int64
+
+	// BatchSize is the number of records written per request. If less than
+	// or equal to 0, then DefaultSeedBatchSize is used.
+	BatchSize int
+}
+
+// SeedData is the data passed to the templates when seeding.
+type SeedData struct {
+	// Index is the index of the record being generated (starting at 0).
+	Index int
+
+	// Count is the total number of records being generated.
+	Count int
+}
+
+// Seed generates Count records by rendering the templates, writing them as
+// children of Firebase database ref r in batches, for generating realistic
+// development datasets.
+//
+// For example, the following generates 1000 users:
+//
+//	err := firebase.Seed(ctxt, db.Ref("/users"), &firebase.SeedConfig{
+//		Template: `{"name": {{name | json}}, "email": {{email | json}}, "age": {{int 18 80}}}`,
+//		Count:    1000,
+//	})
+func Seed(ctxt context.Context, r *DatabaseRef, cfg *SeedConfig) error {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	funcs := SeedFuncs(rnd)
+
+	// parse templates
+	tmpl, err := template.New("record").Funcs(funcs).Parse(cfg.Template)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not parse seed template: %v", err),
+		}
+	}
+	var keyTmpl *template.Template
+	if cfg.Key != "" {
+		keyTmpl, err = template.New("key").Funcs(funcs).Parse(cfg.Key)
+		if err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not parse seed key template: %v", err),
+			}
+		}
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultSeedBatchSize
+	}
+
+	// generate and write
+	batch := make(map[string]interface{}, batchSize)
+	for i := 0; i < cfg.Count; i++ {
+		data := &SeedData{Index: i, Count: cfg.Count}
+
+		// render key
+		key := funcs["pushID"].(func() string)()
+		if keyTmpl != nil {
+			buf := new(bytes.Buffer)
+			if err = keyTmpl.Execute(buf, data); err != nil {
+				return &Error{
+					Err: fmt.Sprintf("could not render seed key %d: %v", i, err),
+				}
+			}
+			key = strings.TrimSpace(buf.String())
+		}
+
+		// render record
+		buf := new(bytes.Buffer)
+		if err = tmpl.Execute(buf, data); err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not render seed record %d: %v", i, err),
+			}
+		}
+		if !json.Valid(buf.Bytes()) {
+			return &Error{
+				Err: fmt.Sprintf("seed record %d is not valid json: %s", i, buf.String()),
+			}
+		}
+		batch[key] = json.RawMessage(buf.Bytes())
+
+		// flush
+		if len(batch) >= batchSize || i == cfg.Count-1 {
+			if err = r.UpdateContext(ctxt, batch); err != nil {
+				return err
+			}
+			batch = make(map[string]interface{}, batchSize)
+		}
+	}
+
+	return nil
+}
+
+var (
+	seedFirstNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Yuki"}
+	seedLastNames  = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Lee", "Walker", "Hall", "Young", "King", "Wright", "Lopez", "Hill", "Scott", "Green"}
+	seedWords      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim"}
+	seedDomains    = []string{"example.com", "example.net", "example.org"}
+)
+
+// SeedFuncs returns the template funcs available to seed templates, using
+// rnd as the source of randomness:
+//
+//	pushID                  a Push ID
+//	uuid                    a UUIDv7
+//	ksuid                   a KSUID
+//	int min max             a random integer in [min, max]
+//	float min max           a random float in [min, max)
+//	bool                    a random bool
+//	choice a b ...          a random choice of the arguments
+//	firstName, lastName     a random first or last name
+//	name                    a random full name
+//	email                   a random email address
+//	word                    a random word
+//	sentence n              a sentence of n random words
+//	timestamp days          a random time in the last n days, in milliseconds
+//	now                     the current time, in milliseconds
+//	json v                  the JSON encoding of v
+func SeedFuncs(rnd *rand.Rand) template.FuncMap {
+	pick := func(s []string) string {
+		return s[rnd.Intn(len(s))]
+	}
+
+	pushIDs, _ := NewPushIDGenerator(rnd)
+	uuids := NewUUIDv7Generator(rnd)
+	ksuids := NewKSUIDGenerator(rnd)
+
+	return template.FuncMap{
+		"pushID": pushIDs.GeneratePushID,
+		"uuid":   uuids.GenerateKey,
+		"ksuid":  ksuids.GenerateKey,
+		"int": func(min, max int) int {
+			return min + rnd.Intn(max-min+1)
+		},
+		"float": func(min, max float64) float64 {
+			return min + rnd.Float64()*(max-min)
+		},
+		"bool": func() bool {
+			return rnd.Intn(2) == 1
+		},
+		"choice": func(choices ...interface{}) interface{} {
+			return choices[rnd.Intn(len(choices))]
+		},
+		"firstName": func() string {
+			return pick(seedFirstNames)
+		},
+		"lastName": func() string {
+			return pick(seedLastNames)
+		},
+		"name": func() string {
+			return pick(seedFirstNames) + " " + pick(seedLastNames)
+		},
+		"email": func() string {
+			return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(pick(seedFirstNames)), strings.ToLower(pick(seedLastNames)), rnd.Intn(1000), pick(seedDomains))
+		},
+		"word": func() string {
+			return pick(seedWords)
+		},
+		"sentence": func(n int) string {
+			words := make([]string, n)
+			for i := range words {
+				words[i] = pick(seedWords)
+			}
+			s := strings.Join(words, " ")
+			if s != "" {
+				s = strings.ToUpper(s[:1]) + s[1:] + "."
+			}
+			return s
+		},
+		"timestamp": func(days int) int64 {
+			now := time.Now().UnixNano() / int64(time.Millisecond)
+			return now - rnd.Int63n(int64(days)*24*60*60*1000+1)
+		},
+		"now": func() int64 {
+			return time.Now().UnixNano() / int64(time.Millisecond)
+		},
+		"json": func(v interface{}) (string, error) {
+			buf, err := json.Marshal(v)
+			return string(buf), err
+		},
+	}
+}
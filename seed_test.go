@@ -0,0 +1,47 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	var records int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf, _ := ioutil.ReadAll(req.Body)
+		var m map[string]struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		if err := json.Unmarshal(buf, &m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		records += len(m)
+		w.Write(buf)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = Seed(context.Background(), r.Ref("/users"), &SeedConfig{
+		Template:  `{"name": {{name | json}}, "email": {{email | json}}, "age": {{int 18 80}}}`,
+		Key:       `user{{.Index}}`,
+		Count:     25,
+		Seed:      1,
+		BatchSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if records != 25 {
+		t.Errorf("expected 25 records, got: %d", records)
+	}
+}
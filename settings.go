@@ -0,0 +1,115 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SettingDefaultWriteSizeLimit is the database setting for the maximum
+	// size of a write, and is one of the WriteSizeLimit* values.
+	SettingDefaultWriteSizeLimit = "defaultWriteSizeLimit"
+
+	// SettingStrictTriggerValidation is the database setting toggling strict
+	// validation of writes that trigger Cloud Functions.
+	SettingStrictTriggerValidation = "strictTriggerValidation"
+)
+
+// Write size limits for the defaultWriteSizeLimit database setting, and the
+// WriteSizeLimit query option.
+const (
+	WriteSizeLimitTiny      = "tiny"
+	WriteSizeLimitSmall     = "small"
+	WriteSizeLimitMedium    = "medium"
+	WriteSizeLimitLarge     = "large"
+	WriteSizeLimitUnlimited = "unlimited"
+)
+
+// validWriteSizeLimit determines if the size is a valid write size limit.
+func validWriteSizeLimit(size string) bool {
+	switch size {
+	case WriteSizeLimitTiny, WriteSizeLimitSmall, WriteSizeLimitMedium, WriteSizeLimitLarge, WriteSizeLimitUnlimited:
+		return true
+	}
+	return false
+}
+
+// settingRef returns the ref for the named database setting.
+func settingRef(r *DatabaseRef, name string) (*DatabaseRef, error) {
+	if name == "" || strings.ContainsAny(name, "/.#$[]") {
+		return nil, &Error{
+			Err: fmt.Sprintf("invalid setting name %q", name),
+		}
+	}
+	return r.Ref("/.settings/" + name), nil
+}
+
+// GetSetting retrieves the named database setting (ie,
+// "defaultWriteSizeLimit") for Firebase database ref r, decoding it into d.
+func GetSetting(r *DatabaseRef, name string, d interface{}) error {
+	return GetSettingContext(r, context.Background(), name, d)
+}
+
+// GetSettingContext retrieves the named database setting for Firebase
+// database ref r, decoding it into d, canceling the operation when the passed
+// context is done.
+func GetSettingContext(r *DatabaseRef, ctxt context.Context, name string, d interface{}) error {
+	s, err := settingRef(r, name)
+	if err != nil {
+		return err
+	}
+	return DoContext(OpTypeGet, s, ctxt, nil, d)
+}
+
+// SetSetting sets the named database setting (ie, "defaultWriteSizeLimit")
+// for Firebase database ref r.
+func SetSetting(r *DatabaseRef, name string, v interface{}) error {
+	return SetSettingContext(r, context.Background(), name, v)
+}
+
+// SetSettingContext sets the named database setting for Firebase database
+// ref r, canceling the operation when the passed context is done.
+func SetSettingContext(r *DatabaseRef, ctxt context.Context, name string, v interface{}) error {
+	s, err := settingRef(r, name)
+	if err != nil {
+		return err
+	}
+	return DoContext(OpTypeSet, s, ctxt, v, nil)
+}
+
+// GetDefaultWriteSizeLimit retrieves the defaultWriteSizeLimit database
+// setting for Firebase database ref r.
+func GetDefaultWriteSizeLimit(r *DatabaseRef) (string, error) {
+	return GetDefaultWriteSizeLimitContext(r, context.Background())
+}
+
+// GetDefaultWriteSizeLimitContext retrieves the defaultWriteSizeLimit
+// database setting for Firebase database ref r, canceling the operation when
+// the passed context is done.
+func GetDefaultWriteSizeLimitContext(r *DatabaseRef, ctxt context.Context) (string, error) {
+	var size string
+	err := GetSettingContext(r, ctxt, SettingDefaultWriteSizeLimit, &size)
+	if err != nil {
+		return "", err
+	}
+	return size, nil
+}
+
+// SetDefaultWriteSizeLimit sets the defaultWriteSizeLimit database setting
+// for Firebase database ref r to one of the WriteSizeLimit* values.
+func SetDefaultWriteSizeLimit(r *DatabaseRef, size string) error {
+	return SetDefaultWriteSizeLimitContext(r, context.Background(), size)
+}
+
+// SetDefaultWriteSizeLimitContext sets the defaultWriteSizeLimit database
+// setting for Firebase database ref r to one of the WriteSizeLimit* values,
+// canceling the operation when the passed context is done.
+func SetDefaultWriteSizeLimitContext(r *DatabaseRef, ctxt context.Context, size string) error {
+	if !validWriteSizeLimit(size) {
+		return &Error{
+			Err: fmt.Sprintf("invalid write size limit %q", size),
+		}
+	}
+	return SetSettingContext(r, ctxt, SettingDefaultWriteSizeLimit, size)
+}
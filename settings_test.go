@@ -0,0 +1,74 @@
+package firebase
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	s := &memServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// write size limit
+	if err = r.SetDefaultWriteSizeLimit(WriteSizeLimitSmall); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v := s.get("/.settings/defaultWriteSizeLimit"); v != "small" {
+		t.Errorf("expected small, got: %v", v)
+	}
+	size, err := r.GetDefaultWriteSizeLimit()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if size != WriteSizeLimitSmall {
+		t.Errorf("expected small, got: %q", size)
+	}
+	if err = r.SetDefaultWriteSizeLimit("huge"); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// generic setting
+	if err = r.SetSetting(SettingStrictTriggerValidation, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var strict bool
+	if err = r.GetSetting(SettingStrictTriggerValidation, &strict); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strict {
+		t.Errorf("expected strict trigger validation")
+	}
+
+	// invalid names
+	for i, name := range []string{"", "rules/.read", "a.b", "$a", "a[0]", "#a"} {
+		if err = r.SetSetting(name, 1); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+		if err = r.GetSetting(name, &strict); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	exp := []string{
+		"PUT /.settings/defaultWriteSizeLimit",
+		"GET /.settings/defaultWriteSizeLimit",
+		"PUT /.settings/strictTriggerValidation",
+		"GET /.settings/strictTriggerValidation",
+	}
+	if len(s.reqs) != len(exp) {
+		t.Fatalf("expected requests %v, got: %v", exp, s.reqs)
+	}
+	for i, req := range exp {
+		if s.reqs[i] != req {
+			t.Errorf("test %d expected %s, got: %s", i, req, s.reqs[i])
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package firebase
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SLOConfig is the configuration for an SLOTracker.
+type SLOConfig struct {
+	// Window is the rolling window over which compliance is computed. If
+	// zero, then 5 minutes is used.
+	Window time.Duration
+
+	// Buckets is the number of buckets the window is divided into, which
+	// determines the granularity at which old requests expire from the
+	// window. If zero, then 30 is used.
+	Buckets int
+
+	// SuccessTarget is the target fraction of successful requests (ie,
+	// 0.999). If zero, then the success rate is not checked.
+	SuccessTarget float64
+
+	// LatencyThreshold is the duration within which a request must complete
+	// to be considered fast.
+	LatencyThreshold time.Duration
+
+	// LatencyTarget is the target fraction of requests completing within the
+	// LatencyThreshold (ie, 0.99). If zero, then latency is not checked.
+	LatencyTarget float64
+
+	// MinRequests is the minimum number of requests in the window before
+	// the tracker can be considered unhealthy.
+	MinRequests int64
+}
+
+// SLOStats are the rolling SLO metrics of an SLOTracker.
+type SLOStats struct {
+	// Requests is the number of requests in the window.
+	Requests int64 `json:"requests"`
+
+	// Failures is the number of failed requests in the window.
+	Failures int64 `json:"failures"`
+
+	// Slow is the number of requests in the window that exceeded the latency
+	// threshold.
+	Slow int64 `json:"slow"`
+
+	// SuccessRate is the fraction of successful requests in the window.
+	SuccessRate float64 `json:"successRate"`
+
+	// LatencyCompliance is the fraction of requests in the window that
+	// completed within the latency threshold.
+	LatencyCompliance float64 `json:"latencyCompliance"`
+
+	// ErrorBudget is the fraction of the error budget remaining in the
+	// window, which is negative when the budget has been exceeded.
+	ErrorBudget float64 `json:"errorBudget"`
+
+	// Healthy indicates whether the window meets the targets.
+	Healthy bool `json:"healthy"`
+}
+
+// sloBucket is a bucket of the rolling window.
+type sloBucket struct {
+	start                  time.Time
+	requests, failed, slow int64
+}
+
+// SLOTracker computes rolling success rate and latency SLO compliance for
+// requests made against a database ref, exposing the compliance via Stats
+// and Healthy.
+//
+// An SLOTracker is attached to a ref using its Observe method:
+//
+//	slo, err := firebase.NewSLOTracker(&firebase.SLOConfig{
+//		SuccessTarget:    0.999,
+//		LatencyThreshold: 500 * time.Millisecond,
+//		LatencyTarget:    0.99,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	db, err := firebase.NewDatabaseRef(..., firebase.Observe(slo.Observe))
+//
+// A request fails when it returns a network error, a 5xx status, or a 429
+// (Too Many Requests) status. Other errors (ie, 412 ETag mismatches) are
+// considered successful requests.
+//
+// SLOTracker is also an http.Handler that responds with the JSON encoded
+// Stats, and a 503 (Service Unavailable) status when not Healthy, usable as
+// a load balancer health check endpoint.
+type SLOTracker struct {
+	mu sync.Mutex
+
+	cfg     SLOConfig
+	width   time.Duration
+	buckets []sloBucket
+
+	now func() time.Time
+}
+
+// NewSLOTracker creates a new SLO tracker.
+func NewSLOTracker(cfg *SLOConfig) (*SLOTracker, error) {
+	t := &SLOTracker{
+		cfg: *cfg,
+		now: time.Now,
+	}
+	if t.cfg.Window == 0 {
+		t.cfg.Window = 5 * time.Minute
+	}
+	if t.cfg.Buckets == 0 {
+		t.cfg.Buckets = 30
+	}
+
+	switch {
+	case t.cfg.Window < 0 || t.cfg.Buckets < 0:
+		return nil, &Error{
+			Err: "slo window and buckets must be positive",
+		}
+	case t.cfg.SuccessTarget < 0 || t.cfg.SuccessTarget >= 1:
+		return nil, &Error{
+			Err: "slo success target must be at least 0 and less than 1",
+		}
+	case t.cfg.LatencyTarget < 0 || t.cfg.LatencyTarget > 1:
+		return nil, &Error{
+			Err: "slo latency target must be between 0 and 1",
+		}
+	case t.cfg.LatencyTarget != 0 && t.cfg.LatencyThreshold <= 0:
+		return nil, &Error{
+			Err: "slo latency threshold must be specified with latency target",
+		}
+	}
+
+	t.width = t.cfg.Window / time.Duration(t.cfg.Buckets)
+	t.buckets = make([]sloBucket, t.cfg.Buckets)
+
+	return t, nil
+}
+
+// Observe records a completed request, and is an Observer for use with the
+// Observe option.
+func (t *SLOTracker) Observe(info *OperationInfo) {
+	failed := info.Err != nil && (info.StatusCode == 0 || info.StatusCode >= 500 || info.StatusCode == http.StatusTooManyRequests)
+	slow := t.cfg.LatencyThreshold > 0 && info.Duration > t.cfg.LatencyThreshold
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.bucket(t.now())
+	b.requests++
+	if failed {
+		b.failed++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// bucket returns the bucket for the time, resetting it if it has expired.
+func (t *SLOTracker) bucket(now time.Time) *sloBucket {
+	start := now.Truncate(t.width)
+	b := &t.buckets[int(start.UnixNano()/int64(t.width))%len(t.buckets)]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	return b
+}
+
+// Stats returns the SLO metrics for the current window.
+func (t *SLOTracker) Stats() SLOStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var s SLOStats
+	cutoff := t.now().Truncate(t.width).Add(-t.cfg.Window)
+	for _, b := range t.buckets {
+		if b.start.After(cutoff) {
+			s.Requests += b.requests
+			s.Failures += b.failed
+			s.Slow += b.slow
+		}
+	}
+
+	s.SuccessRate, s.LatencyCompliance, s.ErrorBudget = 1, 1, 1
+	if s.Requests != 0 {
+		s.SuccessRate = 1 - float64(s.Failures)/float64(s.Requests)
+		s.LatencyCompliance = 1 - float64(s.Slow)/float64(s.Requests)
+		if t.cfg.SuccessTarget != 0 {
+			s.ErrorBudget = 1 - (1-s.SuccessRate)/(1-t.cfg.SuccessTarget)
+		}
+	}
+	s.Healthy = s.Requests < t.cfg.MinRequests ||
+		(s.SuccessRate >= t.cfg.SuccessTarget && s.LatencyCompliance >= t.cfg.LatencyTarget)
+
+	return s
+}
+
+// Healthy determines whether the current window meets the success rate and
+// latency targets. A tracker with fewer than MinRequests in the window is
+// always healthy.
+func (t *SLOTracker) Healthy() bool {
+	return t.Stats().Healthy
+}
+
+// ServeHTTP satisfies the http.Handler interface, responding with the JSON
+// encoded Stats, and a 503 (Service Unavailable) status when not healthy.
+func (t *SLOTracker) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s := t.Stats()
+	res.Header().Set("Content-Type", "application/json")
+	if !s.Healthy {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(res).Encode(s)
+}
@@ -0,0 +1,65 @@
+package firebase
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSLOTracker(t *testing.T) {
+	slo, err := NewSLOTracker(&SLOConfig{
+		Window:           time.Minute,
+		Buckets:          6,
+		SuccessTarget:    0.9,
+		LatencyThreshold: 100 * time.Millisecond,
+		LatencyTarget:    0.5,
+		MinRequests:      5,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	slo.now = func() time.Time { return now }
+
+	fail := errors.New("fail")
+	for _, info := range []*OperationInfo{
+		{Duration: 10 * time.Millisecond},
+		{Duration: 10 * time.Millisecond},
+		{Duration: 200 * time.Millisecond},
+		{Duration: 10 * time.Millisecond, Err: fail, StatusCode: http.StatusPreconditionFailed},
+	} {
+		slo.Observe(info)
+	}
+	if s := slo.Stats(); s.Requests != 4 || s.Failures != 0 || s.Slow != 1 || !s.Healthy {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// fail enough to exceed the error budget
+	now = now.Add(20 * time.Second)
+	slo.Observe(&OperationInfo{Err: fail})
+	slo.Observe(&OperationInfo{Err: fail, StatusCode: http.StatusServiceUnavailable})
+	s := slo.Stats()
+	if s.Requests != 6 || s.Failures != 2 || s.Healthy || s.ErrorBudget >= 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	rec := httptest.NewRecorder()
+	slo.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got: %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	// first requests expire from window
+	now = now.Add(50 * time.Second)
+	if s := slo.Stats(); s.Requests != 2 || !s.Healthy {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// all requests expire
+	now = now.Add(time.Hour)
+	if s := slo.Stats(); s.Requests != 0 || !slo.Healthy() {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,433 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const (
+	// DefaultTrashPath is the default database path where soft deleted data is
+	// stored.
+	DefaultTrashPath = "/_trash"
+)
+
+// TrashEntry is a soft deleted node stored in the trash.
+type TrashEntry struct {
+	// Path is the original database path of the removed node.
+	Path string `json:"path"`
+
+	// DeletedAt is the time the node was removed.
+	DeletedAt ServerTimestamp `json:"deletedAt"`
+
+	// Data is the removed data.
+	Data json.RawMessage `json:"data"`
+}
+
+// SoftDeleteRef wraps a Firebase database ref so that calls to Remove (and
+// its variants) move the data stored at the ref to a trash path instead of
+// destroying it. The root ref, and refs containing or within the trash path,
+// cannot be removed.
+//
+// Writes of null values via Set, Update, and UpdateFields are similarly moved
+// to the trash, with the remaining values written afterwards. As such, writes
+// containing null values are not atomic. The wrapped ref is not exposed for
+// writing, as its writes would bypass the trash.
+//
+// Removed data can be listed with Trash, and then restored to its original
+// location via Restore, or permanently removed via Purge.
+type SoftDeleteRef struct {
+	ref *DatabaseRef
+
+	trashPath string
+}
+
+// NewSoftDeleteRef wraps the Firebase database ref r so that removed data is
+// moved to trashPath. If trashPath is empty, then DefaultTrashPath is used.
+func NewSoftDeleteRef(r *DatabaseRef, trashPath string) *SoftDeleteRef {
+	if trashPath == "" {
+		trashPath = DefaultTrashPath
+	}
+
+	return &SoftDeleteRef{
+		ref:       r,
+		trashPath: "/" + strings.Trim(trashPath, "/"),
+	}
+}
+
+// Ref creates a new child SoftDeleteRef, locked to the specified path, that
+// shares the same trash path.
+func (s *SoftDeleteRef) Ref(path string, opts ...Option) *SoftDeleteRef {
+	return &SoftDeleteRef{
+		ref:       s.ref.Ref(path, opts...),
+		trashPath: s.trashPath,
+	}
+}
+
+// DatabaseRef returns the wrapped database ref.
+//
+// Note: removals and null writes made directly with the wrapped ref are not
+// moved to the trash.
+func (s *SoftDeleteRef) DatabaseRef() *DatabaseRef {
+	return s.ref
+}
+
+// URL returns the URL of the wrapped database ref.
+func (s *SoftDeleteRef) URL() *url.URL {
+	return s.ref.URL()
+}
+
+// trash returns the database ref for the trash.
+func (s *SoftDeleteRef) trash() *DatabaseRef {
+	return s.ref.root().Ref(s.trashPath)
+}
+
+// Get retrieves the values stored at the Firebase database ref and decodes
+// them into d.
+func (s *SoftDeleteRef) Get(d interface{}, opts ...QueryOption) error {
+	return Get(s.ref, d, opts...)
+}
+
+// GetContext retrieves the values stored at the Firebase database ref and
+// decodes them into d, canceling the operation when the passed context is
+// done.
+func (s *SoftDeleteRef) GetContext(ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return GetContext(s.ref, ctxt, d, opts...)
+}
+
+// Set stores values v at the Firebase database ref. If v encodes to null,
+// then the values stored at the ref are moved to the trash (see Remove).
+func (s *SoftDeleteRef) Set(v interface{}, opts ...QueryOption) error {
+	return s.SetContext(context.Background(), v, opts...)
+}
+
+// SetContext stores values v at the Firebase database ref, canceling the
+// operation when the passed context is done. See Set.
+func (s *SoftDeleteRef) SetContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	buf, v, err := s.peek(v)
+	if err != nil {
+		return err
+	}
+	if buf == nil || string(bytes.TrimSpace(buf)) == "null" {
+		_, err = s.RemoveContext(ctxt, opts...)
+		return err
+	}
+	return SetContext(s.ref, ctxt, v, opts...)
+}
+
+// Push pushes values v to the Firebase database ref, returning the name of
+// the created child.
+func (s *SoftDeleteRef) Push(v interface{}, opts ...QueryOption) (string, error) {
+	return Push(s.ref, v, opts...)
+}
+
+// PushContext pushes values v to the Firebase database ref, returning the
+// name of the created child, canceling the operation when the passed context
+// is done.
+func (s *SoftDeleteRef) PushContext(ctxt context.Context, v interface{}, opts ...QueryOption) (string, error) {
+	return PushContext(s.ref, ctxt, v, opts...)
+}
+
+// Update updates the values stored at the Firebase database ref to v. Keys of
+// v that encode to null (ie, Delete) are moved to the trash (see Remove),
+// before the remaining keys are updated.
+func (s *SoftDeleteRef) Update(v interface{}, opts ...QueryOption) error {
+	return s.UpdateContext(context.Background(), v, opts...)
+}
+
+// UpdateContext updates the values stored at the Firebase database ref to v,
+// canceling the operation when the passed context is done. See Update.
+func (s *SoftDeleteRef) UpdateContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	buf, v, err := s.peek(v)
+	if err != nil {
+		return err
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &obj); err != nil || obj == nil {
+		return UpdateContext(s.ref, ctxt, v, opts...)
+	}
+
+	// remove null keys
+	var keys []string
+	for k, x := range obj {
+		if string(x) == "null" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return UpdateContext(s.ref, ctxt, v, opts...)
+	}
+	for _, k := range keys {
+		if _, err := s.Ref(k).RemoveContext(ctxt, opts...); err != nil {
+			return err
+		}
+		delete(obj, k)
+	}
+	if len(obj) == 0 {
+		return nil
+	}
+
+	// update remaining keys, retaining the value types of maps so that field
+	// codecs still apply
+	if vals := reflect.Indirect(reflect.ValueOf(v)); vals.Kind() == reflect.Map && vals.Type().Key().Kind() == reflect.String {
+		m := reflect.MakeMapWithSize(vals.Type(), len(obj))
+		for _, k := range vals.MapKeys() {
+			if _, ok := obj[k.String()]; ok {
+				m.SetMapIndex(k, vals.MapIndex(k))
+			}
+		}
+		return UpdateContext(s.ref, ctxt, m.Interface(), opts...)
+	}
+	if buf, err = json.Marshal(obj); err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	return UpdateContext(s.ref, ctxt, buf, opts...)
+}
+
+// UpdateFields updates the fields of the Firebase database ref in a single
+// request, as with the UpdateFields func. Fields that are null (ie, Delete)
+// are moved to the trash (see Update).
+func (s *SoftDeleteRef) UpdateFields(fields map[string]interface{}, opts ...QueryOption) error {
+	return s.UpdateFieldsContext(context.Background(), fields, opts...)
+}
+
+// UpdateFieldsContext updates the fields of the Firebase database ref,
+// canceling the operation when the passed context is done. See
+// UpdateFields.
+func (s *SoftDeleteRef) UpdateFieldsContext(ctxt context.Context, fields map[string]interface{}, opts ...QueryOption) error {
+	m, err := multiPathUpdate(fields)
+	if err != nil {
+		return err
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return s.UpdateContext(ctxt, m, opts...)
+}
+
+// peek JSON encodes v using the ref's JSON codec, returning the encoded value
+// and a value equivalent to v for the write. Readers are read in full, and
+// are replaced by the read value.
+func (s *SoftDeleteRef) peek(v interface{}) ([]byte, interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil, nil
+
+	case io.Reader:
+		buf, err := ioutil.ReadAll(x)
+		if err != nil {
+			return nil, nil, err
+		}
+		return buf, buf, nil
+
+	case []byte:
+		return x, x, nil
+	}
+
+	buf, err := s.ref.marshal(v)
+	if err != nil {
+		return nil, nil, &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+		}
+	}
+	return buf, v, nil
+}
+
+// Remove moves the values stored at the Firebase database ref to the trash,
+// returning the ID of the created trash entry. If there is no data at the
+// ref, then an empty ID is returned.
+//
+// The value and its ETag are retrieved, and the value is written to the trash
+// before being conditionally removed from the ref. If the value is modified
+// by another client before the removal, then the trash entry is removed and
+// the move is retried, up to the ref's maximum transaction retries (see the
+// TransactionRetries option). As such, the move is not atomic (ie, the trash
+// entry and the value briefly coexist), but concurrent writes are not lost.
+func (s *SoftDeleteRef) Remove(opts ...QueryOption) (string, error) {
+	return s.RemoveContext(context.Background(), opts...)
+}
+
+// RemoveContext moves the values stored at the Firebase database ref to the
+// trash, returning the ID of the created trash entry. See Remove for more
+// information.
+func (s *SoftDeleteRef) RemoveContext(ctxt context.Context, opts ...QueryOption) (string, error) {
+	for i := 0; ; i++ {
+		id, err := s.remove(ctxt, "", opts...)
+		if err != ErrPreconditionFailed || i >= s.ref.transactionRetries {
+			return id, err
+		}
+	}
+}
+
+// RemoveRecursive moves the values stored at the Firebase database ref to the
+// trash, as with Remove. It exists so that the recursive removal of the
+// wrapped ref does not bypass the trash.
+func (s *SoftDeleteRef) RemoveRecursive(opts ...QueryOption) (string, error) {
+	return s.RemoveContext(context.Background(), opts...)
+}
+
+// RemoveRecursiveContext moves the values stored at the Firebase database ref
+// to the trash, as with RemoveContext.
+func (s *SoftDeleteRef) RemoveRecursiveContext(ctxt context.Context, opts ...QueryOption) (string, error) {
+	return s.RemoveContext(ctxt, opts...)
+}
+
+// RemoveIfMatch moves the values stored at the Firebase database ref to the
+// trash, only when the ref's current ETag matches etag. Returns
+// ErrPreconditionFailed when the ETag does not match.
+func (s *SoftDeleteRef) RemoveIfMatch(etag string, opts ...QueryOption) (string, error) {
+	return s.RemoveIfMatchContext(context.Background(), etag, opts...)
+}
+
+// RemoveIfMatchContext moves the values stored at the Firebase database ref
+// to the trash, only when the ref's current ETag matches etag. See
+// RemoveIfMatch for more information.
+func (s *SoftDeleteRef) RemoveIfMatchContext(ctxt context.Context, etag string, opts ...QueryOption) (string, error) {
+	if etag == "" {
+		return "", ErrPreconditionFailed
+	}
+	return s.remove(ctxt, etag, opts...)
+}
+
+// remove moves the value stored at the ref to the trash, conditionally
+// removing the value using its ETag. When match is not empty, the value is
+// only moved when its ETag is match.
+//
+// Only the auth override of opts is applied when retrieving the value and
+// writing the trash entry, as other query options (ie, Shallow) would
+// otherwise alter the moved data.
+func (s *SoftDeleteRef) remove(ctxt context.Context, match string, opts ...QueryOption) (string, error) {
+	// the trash cannot be moved to itself
+	path := s.ref.URL().Path
+	if path == "/" || path == s.trashPath ||
+		strings.HasPrefix(s.trashPath, path+"/") ||
+		strings.HasPrefix(path, s.trashPath+"/") {
+		return "", &Error{
+			Err: fmt.Sprintf("cannot move %s to trash %s", path, s.trashPath),
+		}
+	}
+
+	authOpts, err := authOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	// retrieve current value
+	data, etag, err := getWithETag(s.ref, ctxt, authOpts...)
+	if err != nil {
+		return "", err
+	}
+	if match != "" && etag != match {
+		return "", ErrPreconditionFailed
+	}
+	if len(data) == 0 || string(data) == "null" {
+		return "", nil
+	}
+
+	// copy to trash
+	id := GeneratePushID()
+	entry := s.trash().Ref(id)
+	err = SetContext(entry, ctxt, &TrashEntry{
+		Path: path,
+		Data: data,
+	}, authOpts...)
+	if err != nil {
+		return "", err
+	}
+
+	// remove, unless modified
+	err = RemoveIfMatchContext(s.ref, ctxt, etag, opts...)
+	if err != nil {
+		if rerr := RemoveContext(entry, ctxt, authOpts...); rerr != nil {
+			return "", rerr
+		}
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Trash retrieves all entries currently in the trash, keyed by ID.
+func (s *SoftDeleteRef) Trash(opts ...QueryOption) (map[string]*TrashEntry, error) {
+	entries := make(map[string]*TrashEntry)
+	err := Get(s.trash(), &entries, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Restore restores the trash entry with the specified ID to its original
+// location, removing the entry from the trash.
+//
+// Any data written to the original location since its removal will be
+// overwritten.
+func (s *SoftDeleteRef) Restore(id string, opts ...QueryOption) error {
+	var entry *TrashEntry
+	err := Get(s.trash().Ref(id), &entry, opts...)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return &Error{
+			Err: "trash entry " + id + " does not exist",
+		}
+	}
+
+	return Update(s.ref.root(), map[string]interface{}{
+		rootKey(entry.Path):             entry.Data,
+		rootKey(s.trashPath + "/" + id): nil,
+	}, opts...)
+}
+
+// Purge permanently removes the trash entry with the specified ID.
+func (s *SoftDeleteRef) Purge(id string, opts ...QueryOption) error {
+	return Remove(s.trash().Ref(id), opts...)
+}
+
+// PurgeAll permanently removes all entries in the trash.
+func (s *SoftDeleteRef) PurgeAll(opts ...QueryOption) error {
+	return Remove(s.trash(), opts...)
+}
+
+// rootKey converts the database path to a key suitable for use in a
+// multi-path update issued against the root ref.
+func rootKey(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// authOptions returns query options that only apply the auth override (and
+// any clearing of the default auth override) set by opts.
+func authOptions(opts []QueryOption) ([]QueryOption, error) {
+	const name = "auth_variable_override"
+
+	v := make(url.Values)
+	for _, o := range opts {
+		if err := o(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var res []QueryOption
+	for _, n := range v[clearDefaultKey] {
+		if n == name {
+			res = append(res, ClearDefault(name))
+		}
+	}
+	if vals, ok := v[name]; ok {
+		res = append(res, func(q url.Values) error {
+			q[name] = vals
+			return nil
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,194 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	s := &memServer{}
+	s.set("/a/b", map[string]interface{}{"n": "x"})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	sd := NewSoftDeleteRef(r, "")
+
+	// move to trash
+	id, err := sd.Ref("a/b").Remove()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if id == "" || s.get("/a/b") != nil {
+		t.Fatalf("expected value to be moved to trash, got: %q, %v", id, s.get("/a/b"))
+	}
+	entries, err := sd.Trash()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if e := entries[id]; len(entries) != 1 || e == nil || e.Path != "/a/b" || string(e.Data) != `{"n":"x"}` {
+		t.Errorf("unexpected trash entries: %v", entries)
+	}
+
+	// no value
+	if id, err := sd.Ref("a/b").RemoveContext(context.Background()); err != nil || id != "" {
+		t.Errorf("expected no error and empty id, got: %q, %v", id, err)
+	}
+
+	// restore
+	if err = sd.Restore(id); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m, ok := s.get("/a/b").(map[string]interface{}); !ok || m["n"] != "x" || s.get(DefaultTrashPath) != nil {
+		t.Errorf("expected value to be restored, got: %v", s.root)
+	}
+
+	// recursive removal also goes to the trash
+	if id, err = sd.Ref("a").RemoveRecursive(); err != nil || id == "" {
+		t.Fatalf("expected no error, got: %q, %v", id, err)
+	}
+	if s.get("/a") != nil || len(s.keys(DefaultTrashPath)) != 1 {
+		t.Errorf("expected value to be moved to trash, got: %v", s.root)
+	}
+	if err = sd.PurgeAll(); err != nil || s.root != nil {
+		t.Errorf("expected empty database, got: %v, %v", s.root, err)
+	}
+}
+
+func TestSoftDeleteWrites(t *testing.T) {
+	s := &memServer{}
+	s.set("/a", map[string]interface{}{"b": map[string]interface{}{"n": "x"}, "c": 1, "d": 2, "e": 3})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	sd := NewSoftDeleteRef(r, "")
+
+	// shallow is not applied when moving to the trash
+	if err = sd.Ref("a/b").Set(nil, Shallow); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s.get("/a/b") != nil {
+		t.Errorf("expected value to be moved to trash, got: %v", s.get("/a/b"))
+	}
+
+	// null keys of updates are moved to the trash, others are written
+	if err = sd.Ref("a").Update(map[string]interface{}{"c": nil, "d": Delete, "f": 4}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = sd.Ref("a").UpdateFields(map[string]interface{}{"e": nil}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = sd.Ref("a").Update([]byte(`{"g":5}`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m, ok := s.get("/a").(map[string]interface{}); !ok || len(m) != 2 || m["f"] != float64(4) || m["g"] != float64(5) {
+		t.Errorf("expected only f and g to remain, got: %v", s.get("/a"))
+	}
+
+	entries, err := sd.Trash()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	data := make(map[string]string)
+	for _, e := range entries {
+		data[e.Path] = string(e.Data)
+	}
+	exp := map[string]string{"/a/b": `{"n":"x"}`, "/a/c": "1", "/a/d": "2", "/a/e": "3"}
+	if !reflect.DeepEqual(data, exp) {
+		t.Errorf("expected trash entries %v, got: %v", exp, data)
+	}
+
+	// non-null writes pass through
+	if err = sd.Ref("a/b").Set("y"); err != nil || s.get("/a/b") != "y" {
+		t.Errorf("expected value to be set, got: %v, %v", s.get("/a/b"), err)
+	}
+}
+
+func TestSoftDeleteConflict(t *testing.T) {
+	s := &memServer{}
+	s.set("/a", "x")
+	conflicts := 2
+	s.hook = func(s *memServer, req *http.Request) {
+		// concurrent write between the read and removal
+		if req.Method == "DELETE" && req.URL.Path == "/a.json" && conflicts > 0 {
+			conflicts--
+			s.set("/a", "y")
+		}
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	sd := NewSoftDeleteRef(r, "/trash/")
+
+	id, err := sd.Ref("a").Remove()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s.get("/a") != nil {
+		t.Errorf("expected value to be removed, got: %v", s.get("/a"))
+	}
+	if keys := s.keys("/trash"); len(keys) != 1 || keys[0] != id {
+		t.Fatalf("expected single trash entry %s, got: %v", id, keys)
+	}
+	if v := s.get("/trash/" + id + "/data"); v != "y" {
+		t.Errorf("expected last written value in trash, got: %v", v)
+	}
+
+	// retries exhausted
+	r, err = NewDatabaseRef(URL(ts.URL+"/"), TransactionRetries(0))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	s.set("/a", "x")
+	conflicts = 1
+	if _, err = NewSoftDeleteRef(r, "/trash").Ref("a").Remove(); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed, got: %v", err)
+	}
+	if s.get("/a") != "y" || len(s.keys("/trash")) != 1 {
+		t.Errorf("expected value and trash to be unchanged, got: %v", s.root)
+	}
+
+	// etag mismatch
+	if _, err = sd.Ref("a").RemoveIfMatch("v0"); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed, got: %v", err)
+	}
+	if id, err = sd.Ref("a").RemoveIfMatch(s.etag("/a")); err != nil || id == "" || s.get("/a") != nil {
+		t.Errorf("expected value to be moved to trash, got: %q, %v", id, err)
+	}
+}
+
+func TestSoftDeleteInvalid(t *testing.T) {
+	s := &memServer{}
+	s.set("/a", "x")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	sd := NewSoftDeleteRef(r, "/x/trash")
+
+	for i, path := range []string{"", "/", "x", "x/trash", "x/trash/id"} {
+		if _, err := sd.Ref(path).Remove(); err == nil {
+			t.Errorf("test %d expected error for %q", i, path)
+		}
+	}
+	if len(s.reqs) != 0 || s.get("/a") != "x" {
+		t.Errorf("expected no requests, got: %v", s.reqs)
+	}
+}
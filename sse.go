@@ -3,8 +3,10 @@ package firebase
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/net/context"
 )
@@ -29,8 +31,9 @@ func readLine(rdr *bufio.Reader, prefix string, errEventType EventType) ([]byte,
 		}
 	} else if err != nil {
 		return nil, &Event{
-			Type: EventTypeUnknownError,
+			Type: EventTypeError,
 			Data: []byte(err.Error()),
+			Err:  err,
 		}
 	}
 
@@ -63,80 +66,207 @@ func readLine(rdr *bufio.Reader, prefix string, errEventType EventType) ([]byte,
 // remote connection is closed, or when an error is encountered while reading
 // data.
 //
+// Upon connecting, Firebase sends an initial put event containing the current
+// value of the ref. Use the WatchChangesOnly option to suppress the initial
+// put event.
+//
+// When the watch ends for any reason other than the context being done, a
+// synthesized terminal event (ie, EventTypeClosed, EventTypeError, etc) is
+// emitted with its Err, StatusCode, and Header fields set, allowing callers
+// to distinguish between the server closing the connection and network
+// errors.
+//
 // NOTE: the Log option will not work with Watch/Listen.
 // events from the server.
 func Watch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *Event, error) {
 	var err error
 
+	// fall back to polling
+	if r.watchPoll != nil && r.watchPoll.polling() {
+		return pollWatch(r, ctxt, opts...)
+	}
+
+	// register stream, so that it is canceled on Close
+	ctxt, cancel := context.WithCancel(ctxt)
+	unregister, err := r.life.stream(cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stop := func() {
+		unregister()
+		cancel()
+	}
+
 	// get client and request
 	client, req, err := r.clientAndRequest("GET", nil, opts...)
 	if err != nil {
+		stop()
 		return nil, err
 	}
 
 	// set request headers
 	req.Header.Add("Accept", "text/event-stream")
 
+	// wait for rate limiter
+	if err = r.wait(ctxt); err != nil {
+		stop()
+		return nil, err
+	}
+
 	// execute
-	res, err := client.Do(req)
+	res, err := client.Do(req.WithContext(ctxt))
 	if err != nil {
-		return nil, &Error{
+		err = &Error{
 			Err: fmt.Sprintf("could not execute request: %v", err),
 		}
+		stop()
+		r.onDisconnect(err)
+		return nil, err
 	}
 
 	// check server error
 	err = checkServerError(res)
 	if err != nil {
+		res.Body.Close()
+		stop()
+		r.onDisconnect(err)
 		return nil, err
 	}
+	r.onConnect()
+
+	// track last value for diffs
+	var delta *deltaTracker
+	if r.watchDiffs {
+		delta = new(deltaTracker)
+	}
 
 	events := make(chan *Event, r.watchBufLen)
 	go func() {
-		defer res.Body.Close()
+		var closeErr error
+		var received bool
+		defer func() {
+			if r.watchPoll != nil && ctxt.Err() == nil {
+				r.watchPoll.done(received)
+			}
+			res.Body.Close()
+			stop()
+			// notify before closing, so callbacks complete before the
+			// consumer sees the end of the watch
+			r.onDisconnect(closeErr)
+			close(events)
+		}()
+
+		// emit sends an event per the overflow policy, notifying callbacks
+		sender := &eventSender{r: r, events: events}
+		emit := func(ev *Event) {
+			r.onEvent(ev)
+			sender.send(ev)
+		}
+
+		// watchdog
+		var body io.Reader = res.Body
+		var sr *staleReader
+		if r.watchStale > 0 {
+			sr = newStaleReader(res.Body, r.watchStale)
+			body = sr
+		}
+
+		// fail emits a synthesized terminal event, ending the watch
+		fail := func(ev *Event) {
+			if sr != nil && sr.isStale() {
+				ev = sr.staleEvent()
+			}
+			if ev.Err == nil {
+				ev.Err = &Error{
+					Err: fmt.Sprintf("%s: %s", ev.Type, string(ev.Data)),
+				}
+			}
+			ev.StatusCode, ev.Header = res.StatusCode, res.Header
+			closeErr = ev.Err
+			emit(ev)
+		}
+
+		// a proxy that does not support event streams may rewrite the
+		// response
+		if typ := res.Header.Get("Content-Type"); r.watchPoll != nil && !strings.HasPrefix(typ, "text/event-stream") {
+			err := errUnexpectedContentType(typ)
+			fail(&Event{
+				Type: EventTypeError,
+				Data: []byte(err.Error()),
+				Err:  err,
+			})
+			return
+		}
 
 		// create reader
-		rdr := bufio.NewReader(res.Body)
+		rdr := bufio.NewReader(body)
 
 		var errEvent *Event
 		var typ, data []byte
 
+		skipInitial := r.watchChangesOnly
+
 		for {
 			select {
 			default:
 				// read line "event: <event>"
 				typ, errEvent = readLine(rdr, watchEventPrefix, EventTypeMalformedEventError)
 				if errEvent != nil {
-					events <- errEvent
-					close(events)
+					fail(errEvent)
 					return
 				}
 
 				// read line "data: <data>"
 				data, errEvent = readLine(rdr, watchDataPrefix, EventTypeMalformedDataError)
 				if errEvent != nil {
-					events <- errEvent
-					close(events)
+					fail(errEvent)
 					return
 				}
 
-				// emit event
-				events <- &Event{
-					Type: EventType(typ),
-					Data: data,
+				// create event
+				ev := &Event{
+					Type:      EventType(typ),
+					Data:      data,
+					unmarshal: r.jsonUnmarshal,
+				}
+				received = true
+
+				// compute diff
+				if delta != nil && (ev.Type == EventTypePut || ev.Type == EventTypePatch) {
+					diff, err := delta.diff(ev)
+					if err != nil {
+						fail(&Event{
+							Type: EventTypeMalformedDataError,
+							Data: []byte(err.Error()),
+						})
+						return
+					}
+					ev.Diff = diff
+				}
+
+				// emit event, suppressing the initial snapshot when only
+				// changes are wanted
+				switch {
+				case skipInitial && ev.Type == EventTypePut:
+					skipInitial = false
+				case skipInitial && ev.Type == EventTypeKeepAlive:
+					emit(ev)
+				default:
+					skipInitial = false
+					emit(ev)
 				}
 
 				// consume empty line
 				_, errEvent = readLine(rdr, "", EventTypeUnknownError)
 				if errEvent != nil {
-					events <- errEvent
-					close(events)
+					fail(errEvent)
 					return
 				}
 
 			// context finished
 			case <-ctxt.Done():
-				close(events)
+				closeErr = ctxt.Err()
 				return
 			}
 		}
@@ -152,35 +282,194 @@ func Watch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *E
 // Firebase connection closes, or the auth token is revoked, then Listen will
 // continue to reattempt connecting to the Firebase ref.
 //
+// Include EventTypeConnected, EventTypeDisconnected, and/or
+// EventTypeReconnecting in eventTypes to receive synthesized connection state
+// events, such as for displaying the connection state or logging outages.
+//
 // NOTE: the Log option will not work with Watch/Listen.
 // events from the server.
 func Listen(r *DatabaseRef, ctxt context.Context, eventTypes []EventType, opts ...QueryOption) <-chan *Event {
 	events := make(chan *Event, r.watchBufLen)
 
 	go func() {
+		var attempt int
 		for {
-		watchLoop:
 			select {
 			default:
 				// setup watch
-				ev, err := Watch(r, ctxt, opts...)
-				if err != nil {
+				ev, ok := listenWatch(r, ctxt, events, eventTypes, attempt, opts...)
+				if !ok {
 					close(events)
 					return
 				}
 
 				// consume events
+				var last *Event
 				for e := range ev {
-					if e == nil {
-						break watchLoop
-					}
+					sendListen(r, events, eventTypes, e)
+					last = e
+				}
+
+				listenDisconnected(ctxt, events, eventTypes, last)
+				attempt++
 
-					// filter
-					for _, typ := range eventTypes {
-						if typ == e.Type {
-							events <- e
+			case <-ctxt.Done():
+				close(events)
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// listenWatch starts a watch for Listen, emitting the reconnecting (when
+// attempt is greater than 0), and the connected or disconnected connection
+// state events. Returns false if the watch could not be started.
+func listenWatch(r *DatabaseRef, ctxt context.Context, events chan<- *Event, eventTypes []EventType, attempt int, opts ...QueryOption) (<-chan *Event, bool) {
+	if attempt > 0 {
+		r.onReconnect(attempt)
+		sendFiltered(events, eventTypes, &Event{
+			Type:    EventTypeReconnecting,
+			Attempt: attempt,
+		})
+	}
+
+	ev, err := Watch(r, ctxt, opts...)
+	if err != nil {
+		if ctxt.Err() == nil {
+			sendFiltered(events, eventTypes, &Event{
+				Type: EventTypeDisconnected,
+				Data: []byte(err.Error()),
+				Err:  err,
+			})
+		}
+		return nil, false
+	}
+
+	sendFiltered(events, eventTypes, &Event{
+		Type: EventTypeConnected,
+	})
+
+	return ev, true
+}
+
+// listenDisconnected emits the disconnected connection state event for
+// Listen, using the last event received by the watch, unless the context is
+// done.
+func listenDisconnected(ctxt context.Context, events chan<- *Event, eventTypes []EventType, last *Event) {
+	if ctxt.Err() != nil {
+		return
+	}
+
+	ev := &Event{
+		Type: EventTypeDisconnected,
+	}
+	if last != nil && last.Err != nil {
+		ev.Data = []byte(last.Err.Error())
+		ev.Err, ev.StatusCode, ev.Header = last.Err, last.StatusCode, last.Header
+	}
+
+	sendFiltered(events, eventTypes, ev)
+}
+
+// sendListen sends an event received by Listen on events when its type is
+// one of eventTypes, or sends a heartbeat event in place of an excluded
+// keep-alive event when the ListenHeartbeats option has been set on the ref.
+func sendListen(r *DatabaseRef, events chan<- *Event, eventTypes []EventType, ev *Event) {
+	if ev.Type == EventTypeDropped {
+		events <- ev
+		return
+	}
+	if r.listenHeartbeats && ev.Type == EventTypeKeepAlive {
+		for _, typ := range eventTypes {
+			if typ == EventTypeKeepAlive {
+				events <- ev
+				return
+			}
+		}
+		events <- &Event{
+			Type: EventTypeHeartbeat,
+		}
+		return
+	}
+
+	sendFiltered(events, eventTypes, ev)
+}
+
+// sendFiltered sends the event on events when its type is one of eventTypes.
+func sendFiltered(events chan<- *Event, eventTypes []EventType, ev *Event) {
+	for _, typ := range eventTypes {
+		if typ == ev.Type {
+			events <- ev
+			return
+		}
+	}
+}
+
+// ListenFrom listens on a Firebase ref for any of the specified eventTypes,
+// emitting them on the returned channel, resuming from a previously known
+// state identified by stateHash (as computed by ContentHash).
+//
+// When connecting, Firebase sends an initial put event containing the
+// complete value of the ref. ListenFrom compares the content hash of the
+// initial value against stateHash, and only emits the initial put event when
+// the state differs. Likewise, when reconnecting, the initial put event is
+// only emitted if the state differs from the last state seen prior to the
+// reconnect. All subsequent events (including connection state events) are
+// emitted as with Listen.
+//
+// NOTE: the Log option will not work with Watch/Listen.
+func ListenFrom(r *DatabaseRef, ctxt context.Context, stateHash string, eventTypes []EventType, opts ...QueryOption) <-chan *Event {
+	events := make(chan *Event, r.watchBufLen)
+
+	go func() {
+		hash := stateHash
+		var root interface{}
+		var attempt int
+
+		for {
+			select {
+			default:
+				// setup watch
+				ev, ok := listenWatch(r, ctxt, events, eventTypes, attempt, opts...)
+				if !ok {
+					close(events)
+					return
+				}
+
+				// consume events
+				var last *Event
+				initial := true
+				for e := range ev {
+					// track state
+					if e.Type == EventTypePut || e.Type == EventTypePatch {
+						var env eventData
+						if err := json.Unmarshal(e.Data, &env); err == nil {
+							if data, err := decodeJSON(env.Data); err == nil {
+								root = treeApply(root, e.Type, env.Path, data)
+							}
+						}
+
+						// skip initial put when state is unchanged
+						if initial && e.Type == EventTypePut {
+							initial = false
+							if h, err := HashValue(root); err == nil && h == hash {
+								continue
+							}
 						}
 					}
+
+					sendListen(r, events, eventTypes, e)
+					last = e
+				}
+
+				listenDisconnected(ctxt, events, eventTypes, last)
+				attempt++
+
+				// save state hash for reconnect
+				if h, err := HashValue(root); err == nil {
+					hash = h
 				}
 
 			case <-ctxt.Done():
@@ -0,0 +1,239 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchTerminalEvent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Test", "1")
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n"))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	events, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var last *Event
+	for ev := range events {
+		last = ev
+	}
+	if last == nil || last.Type != EventTypeClosed {
+		t.Fatalf("expected closed event, got: %v", last)
+	}
+	if last.Err == nil || last.StatusCode != http.StatusOK || last.Header.Get("X-Test") != "1" {
+		t.Errorf("expected error, status, and headers, got: %v %d %v", last.Err, last.StatusCode, last.Header)
+	}
+}
+
+func TestListenConnectionState(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n"))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := r.Listen(ctxt, []EventType{
+		EventTypePut,
+		EventTypeConnected,
+		EventTypeDisconnected,
+		EventTypeReconnecting,
+	})
+
+	exp := []EventType{
+		EventTypeConnected,
+		EventTypePut,
+		EventTypeDisconnected,
+		EventTypeReconnecting,
+		EventTypeConnected,
+	}
+	for i, typ := range exp {
+		ev := <-events
+		if ev.Type != typ {
+			t.Fatalf("event %d expected %s, got: %s", i, typ, ev.Type)
+		}
+		switch ev.Type {
+		case EventTypeDisconnected:
+			if ev.Err == nil {
+				t.Errorf("event %d expected error", i)
+			}
+		case EventTypeReconnecting:
+			if ev.Attempt != 1 {
+				t.Errorf("event %d expected attempt 1, got: %d", i, ev.Attempt)
+			}
+		}
+	}
+}
+
+func TestWatchPollFallback(t *testing.T) {
+	var mu sync.Mutex
+	var streams int
+	value, etag := `{"a":1}`, "e1"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// proxy rewriting event streams
+		if req.Header.Get("Accept") == "text/event-stream" {
+			streams++
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("buffered"))
+			return
+		}
+
+		if req.Header.Get("X-Firebase-ETag") != "true" {
+			t.Errorf("expected X-Firebase-ETag header")
+		}
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(value))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), WatchPollFallback(2, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if typ := r.WatchTransport(); typ != WatchTransportStream {
+		t.Errorf("expected transport %s, got: %s", WatchTransportStream, typ)
+	}
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := r.Listen(ctxt, []EventType{EventTypePut, EventTypeKeepAlive})
+
+	// initial value
+	ev := <-events
+	if ev.Type != EventTypePut || string(ev.Data) != `{"path":"/","data":{"a":1}}` {
+		t.Fatalf("expected initial put, got: %v", ev)
+	}
+	for ev = <-events; ev.Type != EventTypeKeepAlive; ev = <-events {
+	}
+
+	// change
+	mu.Lock()
+	value, etag = `{"a":2}`, "e2"
+	mu.Unlock()
+	for ev = <-events; ev.Type != EventTypePut; ev = <-events {
+	}
+	if string(ev.Data) != `{"path":"/","data":{"a":2}}` {
+		t.Errorf("expected changed put, got: %v", ev)
+	}
+
+	if typ := r.WatchTransport(); typ != WatchTransportPoll {
+		t.Errorf("expected transport %s, got: %s", WatchTransportPoll, typ)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if streams != 2 {
+		t.Errorf("expected 2 stream attempts before polling, got: %d", streams)
+	}
+}
+
+func TestListenHeartbeats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\nevent: keep-alive\ndata: null\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := []struct {
+		eventTypes []EventType
+		exp        EventType
+	}{
+		{[]EventType{EventTypePut}, EventTypeHeartbeat},
+		{[]EventType{EventTypePut, EventTypeKeepAlive}, EventTypeKeepAlive},
+	}
+	for i, test := range tests {
+		r, err := NewDatabaseRef(URL(ts.URL+"/"), ListenHeartbeats(true))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		events := r.Listen(ctxt, test.eventTypes)
+		if ev := <-events; ev.Type != EventTypePut {
+			t.Fatalf("test %d expected put, got: %s", i, ev.Type)
+		}
+		if ev := <-events; ev.Type != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, ev.Type)
+		}
+	}
+}
+
+func TestWatchChangesOnly(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("event: keep-alive\ndata: null\n\n"))
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":{\"a\":1,\"b\":1}}\n\n"))
+		w.Write([]byte("event: put\ndata: {\"path\":\"/b\",\"data\":2}\n\n"))
+		w.Write([]byte("event: patch\ndata: {\"path\":\"/\",\"data\":{\"a\":1,\"c\":3}}\n\n"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		changesOnly bool
+		exp         []string
+	}{
+		{false, []string{
+			"keep-alive null",
+			`put {"data":{"a":1,"b":1},"path":"/"}`,
+			`put {"data":2,"path":"/b"}`,
+			`patch {"data":{"c":3},"path":"/"}`,
+		}},
+		{true, []string{
+			"keep-alive null",
+			`put {"data":2,"path":"/b"}`,
+			`patch {"data":{"c":3},"path":"/"}`,
+		}},
+	}
+	for i, test := range tests {
+		r, err := NewDatabaseRef(URL(ts.URL+"/"), WatchChangesOnly(test.changesOnly), WatchDiffs(true))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		events, err := r.Watch(context.Background())
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		var s []string
+		for ev := range events {
+			switch ev.Type {
+			case EventTypeClosed:
+			case EventTypeKeepAlive:
+				s = append(s, string(ev.Type)+" "+string(ev.Data))
+			default:
+				s = append(s, string(ev.Type)+" "+string(ev.Diff))
+			}
+		}
+		if strings.Join(s, "\n") != strings.Join(test.exp, "\n") {
+			t.Errorf("test %d expected:\n%s\ngot:\n%s", i, strings.Join(test.exp, "\n"), strings.Join(s, "\n"))
+		}
+	}
+}
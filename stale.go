@@ -0,0 +1,80 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// WatchStaleTimeout is an option that sets a watchdog on streams opened by
+// Watch and Listen, ending a stream with a synthesized EventTypeStale event
+// when no data (including keep-alive events) has been received from the
+// Firebase server within the timeout, such as when the underlying connection
+// has silently died. Listen then reconnects as with any other ended stream.
+//
+// The Firebase server sends keep-alive events roughly every 30 seconds, and
+// as such the timeout should be comfortably larger (ie, 90 seconds). Time
+// spent waiting for the consumer to receive events is not counted against
+// the timeout. A timeout of 0 disables the watchdog (the default).
+func WatchStaleTimeout(d time.Duration) Option {
+	return func(r *DatabaseRef) error {
+		if d < 0 {
+			return errors.New("watch stale timeout cannot be negative")
+		}
+		r.watchStale = d
+		return nil
+	}
+}
+
+// staleReader is a reader that closes the underlying stream when a read does
+// not complete within the stale timeout.
+type staleReader struct {
+	rc      io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stale   int32
+}
+
+// newStaleReader creates a stale reader for the stream.
+func newStaleReader(rc io.ReadCloser, timeout time.Duration) *staleReader {
+	sr := &staleReader{
+		rc:      rc,
+		timeout: timeout,
+	}
+	sr.timer = time.AfterFunc(timeout, sr.expire)
+	sr.timer.Stop()
+	return sr
+}
+
+// expire marks the stream stale, closing it.
+func (sr *staleReader) expire() {
+	atomic.StoreInt32(&sr.stale, 1)
+	sr.rc.Close()
+}
+
+// Read satisfies the io.Reader interface.
+func (sr *staleReader) Read(buf []byte) (int, error) {
+	sr.timer.Reset(sr.timeout)
+	n, err := sr.rc.Read(buf)
+	sr.timer.Stop()
+	return n, err
+}
+
+// isStale determines if the stream was closed by the watchdog.
+func (sr *staleReader) isStale() bool {
+	return atomic.LoadInt32(&sr.stale) == 1
+}
+
+// staleEvent returns the terminal event for the stale stream.
+func (sr *staleReader) staleEvent() *Event {
+	err := &Error{
+		Err: fmt.Sprintf("no data received within %v", sr.timeout),
+	}
+	return &Event{
+		Type: EventTypeStale,
+		Data: []byte(err.Err),
+		Err:  err,
+	}
+}
@@ -0,0 +1,68 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchStaleTimeout(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: keep-alive\ndata: null\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer ts.Close()
+
+	if _, err := NewDatabaseRef(WatchStaleTimeout(-1)); err == nil {
+		t.Errorf("expected error")
+	}
+
+	r, err := NewDatabaseRef(URL(ts.URL+"/"), WatchStaleTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// watch
+	events, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var types []EventType
+	var last *Event
+	for ev := range events {
+		types = append(types, ev.Type)
+		last = ev
+	}
+	if len(types) != 2 || types[0] != EventTypeKeepAlive || types[1] != EventTypeStale {
+		t.Errorf("expected keep-alive and stale events, got: %v", types)
+	}
+	if last == nil || last.Err == nil {
+		t.Errorf("expected stale error")
+	}
+
+	// listen reconnects
+	atomic.StoreInt32(&requests, 0)
+	ctxt, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var n int
+	for ev := range r.Listen(ctxt, []EventType{EventTypeDisconnected, EventTypeReconnecting}) {
+		if ev.Type == EventTypeDisconnected && ev.Err == nil {
+			t.Errorf("expected disconnected error")
+		}
+		if ev.Type == EventTypeReconnecting {
+			if n++; n == 2 {
+				cancel()
+			}
+		}
+	}
+	if n != 2 || atomic.LoadInt32(&requests) < 2 {
+		t.Errorf("expected reconnects, got: %d (%d requests)", n, atomic.LoadInt32(&requests))
+	}
+}
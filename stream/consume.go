@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/knq/firebase"
+)
+
+// ConsumeEvents processes the events received on ch using a bounded pool of
+// workers, calling fn for each event, and blocking until ch is closed or fn
+// returns an error.
+//
+// Events are routed to workers by the first component of their path, such
+// that events for the same path (and its descendants) are processed serially
+// in the order received, while events for different paths are processed in
+// parallel. Events at the root path, or without a path (ie, keep-alive or
+// error events), act as a barrier: they are processed only after all prior
+// events have been processed, and before any subsequent event.
+//
+// After fn returns an error, no further events are processed, and the first
+// error is returned. The remaining events on ch are not drained.
+func ConsumeEvents(ch <-chan *firebase.Event, workers int, fn func(*firebase.Event) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	failed := make(chan struct{})
+
+	// process calls fn, recording the first error
+	process := func(ev *firebase.Event) {
+		select {
+		case <-failed:
+			return
+		default:
+		}
+		if err := fn(ev); err != nil {
+			once.Do(func() {
+				firstErr = err
+				close(failed)
+			})
+		}
+	}
+
+	// start workers
+	queues := make([]chan *firebase.Event, workers)
+	for i := range queues {
+		queues[i] = make(chan *firebase.Event, cap(ch))
+		go func(q <-chan *firebase.Event) {
+			for ev := range q {
+				process(ev)
+				wg.Done()
+			}
+		}(queues[i])
+	}
+
+	// dispatch
+loop:
+	for ev := range ch {
+		select {
+		case <-failed:
+			break loop
+		default:
+		}
+
+		key := routeKey(ev)
+		if key == "" {
+			wg.Wait()
+			process(ev)
+			continue
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		wg.Add(1)
+		queues[h.Sum32()%uint32(workers)] <- ev
+	}
+
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// routeKey returns the first component of the event's path, or the empty
+// string when the event is at the root path or has no path.
+func routeKey(ev *firebase.Event) string {
+	path := strings.Trim(ev.Path(), "/")
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		path = path[:i]
+	}
+	return path
+}
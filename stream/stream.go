@@ -0,0 +1,209 @@
+// Package stream provides composable transformations over Firebase event
+// channels, as returned by Watch and Listen.
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+// Map emits the result of applying fn to each event received on in. Events
+// for which fn returns nil are dropped.
+//
+// The returned channel is closed when in is closed or the context is done.
+func Map(ctxt context.Context, in <-chan *firebase.Event, fn func(*firebase.Event) *firebase.Event) <-chan *firebase.Event {
+	out := make(chan *firebase.Event, cap(in))
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					return
+				}
+				if ev = fn(ev); ev == nil {
+					continue
+				}
+				select {
+				case out <- ev:
+				case <-ctxt.Done():
+					return
+				}
+
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Filter emits the events received on in for which fn returns true.
+//
+// The returned channel is closed when in is closed or the context is done.
+func Filter(ctxt context.Context, in <-chan *firebase.Event, fn func(*firebase.Event) bool) <-chan *firebase.Event {
+	return Map(ctxt, in, func(ev *firebase.Event) *firebase.Event {
+		if !fn(ev) {
+			return nil
+		}
+		return ev
+	})
+}
+
+// Types emits the events received on in that have any of the specified
+// eventTypes.
+//
+// The returned channel is closed when in is closed or the context is done.
+func Types(ctxt context.Context, in <-chan *firebase.Event, eventTypes ...firebase.EventType) <-chan *firebase.Event {
+	return Filter(ctxt, in, func(ev *firebase.Event) bool {
+		for _, typ := range eventTypes {
+			if ev.Type == typ {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Batch groups the events received on in into batches of up to n events. A
+// partial batch is emitted when maxWait has elapsed since the first event of
+// the batch was received. If maxWait is 0, then only full batches are
+// emitted until in is closed.
+//
+// Any pending partial batch is emitted when in is closed. The returned channel
+// is closed when in is closed or the context is done.
+func Batch(ctxt context.Context, in <-chan *firebase.Event, n int, maxWait time.Duration) <-chan []*firebase.Event {
+	if n < 1 {
+		n = 1
+	}
+
+	out := make(chan []*firebase.Event)
+
+	go func() {
+		defer close(out)
+
+		var batch []*firebase.Event
+		var timer *time.Timer
+		var timeout <-chan time.Time
+
+		// flush emits the current batch
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+
+			select {
+			case out <- batch:
+				batch = nil
+				return true
+			case <-ctxt.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+
+				batch = append(batch, ev)
+
+				// start timer on first event
+				if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					timeout = timer.C
+				}
+
+				if len(batch) >= n && !flush() {
+					return
+				}
+
+			case <-timeout:
+				if !flush() {
+					return
+				}
+
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Debounce emits the most recent event received on in, after no other event
+// has been received for the duration d. Intermediate events received during
+// a burst are dropped.
+//
+// Any pending event is emitted when in is closed. The returned channel is
+// closed when in is closed or the context is done.
+func Debounce(ctxt context.Context, in <-chan *firebase.Event, d time.Duration) <-chan *firebase.Event {
+	out := make(chan *firebase.Event)
+
+	go func() {
+		defer close(out)
+
+		var pending *firebase.Event
+		timer := time.NewTimer(d)
+		timer.Stop()
+		defer timer.Stop()
+
+		// emit sends the pending event
+		emit := func() bool {
+			if pending == nil {
+				return true
+			}
+
+			select {
+			case out <- pending:
+				pending = nil
+				return true
+			case <-ctxt.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+
+				// reset timer
+				pending = ev
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(d)
+
+			case <-timer.C:
+				if !emit() {
+					return
+				}
+
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,93 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+func events(n int) <-chan *firebase.Event {
+	ch := make(chan *firebase.Event, n)
+	for i := 0; i < n; i++ {
+		ch <- &firebase.Event{Type: firebase.EventTypePut, Data: []byte{byte('a' + i)}}
+	}
+	close(ch)
+	return ch
+}
+
+func TestBatch(t *testing.T) {
+	var sizes []int
+	for b := range Batch(context.Background(), events(7), 3, 0) {
+		sizes = append(sizes, len(b))
+	}
+
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Errorf("expected batch sizes [3 3 1], got: %v", sizes)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	var got []*firebase.Event
+	for ev := range Debounce(context.Background(), events(5), time.Second) {
+		got = append(got, ev)
+	}
+
+	if len(got) != 1 || string(got[0].Data) != "e" {
+		t.Errorf("expected only last event, got: %v", got)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var n int
+	for range Filter(context.Background(), events(5), func(ev *firebase.Event) bool {
+		return ev.Data[0]%2 == 0
+	}) {
+		n++
+	}
+
+	if n != 2 {
+		t.Errorf("expected 2 events, got: %d", n)
+	}
+}
+
+func TestConsumeEvents(t *testing.T) {
+	ch := make(chan *firebase.Event, 100)
+	for i := 0; i < 100; i++ {
+		ch <- &firebase.Event{
+			Type: firebase.EventTypePatch,
+			Data: []byte(fmt.Sprintf(`{"path":"/%c/x","data":{"n":%d}}`, 'a'+i%4, i)),
+		}
+	}
+	close(ch)
+
+	var mu sync.Mutex
+	last := make(map[byte]int)
+	err := ConsumeEvents(ch, 3, func(ev *firebase.Event) error {
+		var env struct {
+			Path string
+			Data struct{ N int }
+		}
+		if err := json.Unmarshal(ev.Data, &env); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if n, ok := last[env.Path[1]]; ok && n > env.Data.N {
+			return fmt.Errorf("path %s: event %d processed after %d", env.Path, env.Data.N, n)
+		}
+		last[env.Path[1]] = env.Data.N
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(last) != 4 {
+		t.Errorf("expected 4 paths, got: %d", len(last))
+	}
+}
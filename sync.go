@@ -0,0 +1,322 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MergeFunc is a conflict resolution callback for a Syncer, returning the
+// merged value of the record at path given its value in databases A and B.
+// A nil value indicates the record does not exist.
+type MergeFunc func(path string, a, b interface{}) (interface{}, error)
+
+// SyncConfig is the configuration for a Syncer.
+type SyncConfig struct {
+	// A and B are the database refs to keep in sync.
+	A, B *DatabaseRef
+
+	// Collections are the paths (relative to A and B) of the collections to
+	// sync. Each child of a collection is a record, which is the unit of
+	// conflict resolution.
+	Collections []string
+
+	// TimestampField is the record field holding the last write's server
+	// timestamp (ie, written with ServerTimestamp) used for last-writer-wins
+	// conflict resolution. Records without the field lose to records with
+	// the field.
+	TimestampField string
+
+	// Merge is an optional custom conflict resolution callback, used instead
+	// of last-writer-wins.
+	Merge MergeFunc
+}
+
+// SyncStats are the metrics of a Syncer.
+type SyncStats struct {
+	// Conflicts is the number of records that differed between A and B.
+	Conflicts int64 `json:"conflicts"`
+
+	// WritesA and WritesB are the number of records written to A and B,
+	// respectively.
+	WritesA int64 `json:"writesA"`
+	WritesB int64 `json:"writesB"`
+
+	// Errors is the number of errors encountered.
+	Errors int64 `json:"errors"`
+
+	// LastError is the last error encountered, if any.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Syncer keeps collections in two databases in sync, for bridging a legacy
+// database and a new one during phased migrations.
+//
+// Syncer maintains an in-memory copy of the collections in both databases.
+// Once the initial values of both databases have been received, all records
+// are reconciled, and thereafter each changed record is compared and, when
+// different, resolved using the Merge callback (if set) or by
+// last-writer-wins on the TimestampField, with the result written to the
+// database(s) that differ.
+//
+// Records removed by an event are removed from the other database. During
+// reconciliation, however, a record missing from one database is copied from
+// the other. Use soft deletes (tombstones) where removals must survive
+// reconciliation.
+type Syncer struct {
+	mu sync.Mutex
+
+	cfg   SyncConfig
+	stats SyncStats
+
+	a, b   interface{}
+	aReady bool
+	bReady bool
+}
+
+// NewSyncer creates a new two-way syncer.
+func NewSyncer(cfg *SyncConfig) (*Syncer, error) {
+	switch {
+	case cfg.A == nil || cfg.B == nil:
+		return nil, &Error{
+			Err: "sync databases A and B must be specified",
+		}
+	case len(cfg.Collections) == 0:
+		return nil, &Error{
+			Err: "sync collections must be specified",
+		}
+	case cfg.TimestampField == "" && cfg.Merge == nil:
+		return nil, &Error{
+			Err: "sync timestamp field or merge func must be specified",
+		}
+	}
+
+	s := &Syncer{
+		cfg: *cfg,
+	}
+	s.cfg.Collections = make([]string, len(cfg.Collections))
+	for i, c := range cfg.Collections {
+		s.cfg.Collections[i] = joinPath(c, "")
+	}
+
+	return s, nil
+}
+
+// Run syncs the databases until the context is done, or an error is
+// encountered.
+func (s *Syncer) Run(ctxt context.Context, opts ...QueryOption) error {
+	types := []EventType{EventTypePut, EventTypePatch}
+	aEvents := Listen(s.cfg.A, ctxt, types, opts...)
+	bEvents := Listen(s.cfg.B, ctxt, types, opts...)
+
+	for aEvents != nil || bEvents != nil {
+		var ev *Event
+		var fromA, ok bool
+		select {
+		case ev, ok = <-aEvents:
+			if !ok {
+				aEvents = nil
+				continue
+			}
+			fromA = true
+		case ev, ok = <-bEvents:
+			if !ok {
+				bEvents = nil
+				continue
+			}
+		}
+
+		if err := s.apply(ctxt, ev, fromA); err != nil {
+			s.update(func(st *SyncStats) {
+				st.Errors++
+				st.LastError = err.Error()
+			})
+			return err
+		}
+	}
+
+	if err := ctxt.Err(); err != nil {
+		return err
+	}
+	return &Error{
+		Err: "sync listen closed",
+	}
+}
+
+// apply applies the event to the in-memory copy of its database, and
+// resolves the affected records.
+func (s *Syncer) apply(ctxt context.Context, ev *Event, fromA bool) error {
+	var env eventData
+	err := json.Unmarshal(ev.Data, &env)
+	if err != nil {
+		return err
+	}
+	data, err := decodeJSON(env.Data)
+	if err != nil {
+		return err
+	}
+
+	changes, err := ev.Patch()
+	if err != nil {
+		return err
+	}
+
+	// apply
+	wasReady := s.aReady && s.bReady
+	if fromA {
+		s.a = treeApply(s.a, ev.Type, env.Path, data)
+		s.aReady = s.aReady || ev.Type == EventTypePut
+	} else {
+		s.b = treeApply(s.b, ev.Type, env.Path, data)
+		s.bReady = s.bReady || ev.Type == EventTypePut
+	}
+	if !s.aReady || !s.bReady {
+		return nil
+	}
+
+	// reconcile all records once both databases are ready
+	if !wasReady {
+		return s.resolve(ctxt, s.records("/"), nil)
+	}
+
+	// resolve affected records, propagating explicit removals
+	var records []string
+	removed := make(map[string]bool)
+	for path, raw := range changes {
+		recs := s.records(path)
+		records = append(records, recs...)
+		if len(recs) == 1 && recs[0] == path && string(raw) == "null" {
+			removed[path] = true
+		}
+	}
+
+	return s.resolve(ctxt, records, func(path string) bool {
+		return removed[path]
+	})
+}
+
+// records returns the record paths affected by a change at path.
+func (s *Syncer) records(path string) []string {
+	keys := make(map[string]bool)
+	for _, c := range s.cfg.Collections {
+		switch {
+		case pathHasPrefix(path, c) && path != c:
+			// change within a record
+			keys[joinPath(c, splitPath(strings.TrimPrefix(path, c))[0])] = true
+
+		case pathHasPrefix(c, path):
+			// change at or above a collection
+			parts := splitPath(c)
+			for _, tree := range []interface{}{s.a, s.b} {
+				m, _ := treeGet(tree, parts).(map[string]interface{})
+				for k := range m {
+					keys[joinPath(c, k)] = true
+				}
+			}
+		}
+	}
+
+	records := make([]string, 0, len(keys))
+	for k := range keys {
+		records = append(records, k)
+	}
+	sort.Strings(records)
+
+	return records
+}
+
+// resolve resolves differences in the records between the databases.
+func (s *Syncer) resolve(ctxt context.Context, records []string, removed func(string) bool) error {
+	for _, path := range records {
+		parts := splitPath(path)
+		a, b := treeGet(s.a, parts), treeGet(s.b, parts)
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		s.update(func(st *SyncStats) {
+			st.Conflicts++
+		})
+
+		// determine value
+		var v interface{}
+		switch {
+		case removed != nil && removed(path):
+			v = nil
+		case s.cfg.Merge != nil:
+			var err error
+			v, err = s.cfg.Merge(path, a, b)
+			if err != nil {
+				return err
+			}
+		case s.timestamp(a) >= s.timestamp(b):
+			v = a
+		default:
+			v = b
+		}
+
+		// write
+		if !reflect.DeepEqual(v, a) {
+			if err := s.write(ctxt, s.cfg.A, path, v); err != nil {
+				return err
+			}
+			s.update(func(st *SyncStats) {
+				st.WritesA++
+			})
+		}
+		if !reflect.DeepEqual(v, b) {
+			if err := s.write(ctxt, s.cfg.B, path, v); err != nil {
+				return err
+			}
+			s.update(func(st *SyncStats) {
+				st.WritesB++
+			})
+		}
+	}
+
+	return nil
+}
+
+// timestamp returns the value of the record's timestamp field, or -1 when
+// the record does not have the field.
+func (s *Syncer) timestamp(v interface{}) float64 {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return -1
+	}
+	n, ok := m[s.cfg.TimestampField].(json.Number)
+	if !ok {
+		return -1
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return -1
+	}
+	return f
+}
+
+// write writes the record value to the database.
+func (s *Syncer) write(ctxt context.Context, r *DatabaseRef, path string, v interface{}) error {
+	if v == nil {
+		return r.Ref(path).RemoveContext(ctxt)
+	}
+	return r.Ref(path).SetContext(ctxt, v)
+}
+
+// update updates the stats.
+func (s *Syncer) update(f func(*SyncStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f(&s.stats)
+}
+
+// Stats returns a copy of the syncer's metrics.
+func (s *Syncer) Stats() SyncStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
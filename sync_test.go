@@ -0,0 +1,32 @@
+package firebase
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSyncerRecords(t *testing.T) {
+	s := &Syncer{
+		cfg: SyncConfig{
+			Collections: []string{"/users"},
+		},
+	}
+	s.a, _ = decodeJSON([]byte(`{"users":{"alice":{"n":1}}}`))
+	s.b, _ = decodeJSON([]byte(`{"users":{"bob":{"n":2}}}`))
+
+	tests := []struct {
+		path string
+		exp  []string
+	}{
+		{"/", []string{"/users/alice", "/users/bob"}},
+		{"/users", []string{"/users/alice", "/users/bob"}},
+		{"/users/alice/n", []string{"/users/alice"}},
+		{"/other", []string{}},
+	}
+
+	for i, test := range tests {
+		if recs := s.records(test.path); !reflect.DeepEqual(recs, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, recs)
+		}
+	}
+}
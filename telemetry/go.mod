@@ -0,0 +1,24 @@
+module github.com/knq/firebase/telemetry
+
+go 1.25.0
+
+require (
+	github.com/knq/firebase v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.44.0
+	go.opentelemetry.io/otel/metric v1.44.0
+	go.opentelemetry.io/otel/trace v1.44.0
+)
+
+require (
+	cloud.google.com/go v0.28.0 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/knq/jwt v0.0.0-20180925223530-fc44a4704737 // indirect
+	github.com/knq/pemutil v0.0.0-20180607233853-a6a7785bc45a // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	golang.org/x/net v0.0.0-20180926154720-4dfa2610cdf3 // indirect
+	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be // indirect
+	golang.org/x/time v0.10.0 // indirect
+)
+
+replace github.com/knq/firebase => ../
@@ -0,0 +1,242 @@
+// Package telemetry provides OpenTelemetry tracing and metrics
+// instrumentation for Firebase database refs.
+//
+// The instrumentation is attached to a database ref using the
+// Instrumentation option:
+//
+//	db, err := firebase.NewDatabaseRef(
+//	    firebase.GoogleServiceAccountCredentialsFile("credentials.json"),
+//	    telemetry.Instrumentation(tp, mp),
+//	)
+//
+// A span is recorded for each REST request (including each retry attempt),
+// with the database path, operation type, and status code as attributes,
+// parented to the span in the context passed to the operation (ie,
+// GetContext). As spans are recorded after the request completes, trace
+// headers are not propagated to the Firebase server.
+//
+// The package is a separate module, so that the OpenTelemetry dependencies
+// are only required by applications using the instrumentation.
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/knq/firebase"
+)
+
+// ScopeName is the instrumentation scope name used for the tracer and
+// meter.
+const ScopeName = "github.com/knq/firebase/telemetry"
+
+// Attribute keys.
+const (
+	// PathKey is the attribute key for the database path.
+	PathKey = attribute.Key("firebase.path")
+
+	// OpKey is the attribute key for the operation type (ie, get, set).
+	OpKey = attribute.Key("firebase.op")
+
+	// AttemptKey is the attribute key for the attempt number of a request.
+	AttemptKey = attribute.Key("firebase.attempt")
+
+	// StatusCodeKey is the attribute key for the HTTP status code.
+	StatusCodeKey = attribute.Key("http.response.status_code")
+
+	// ErrorTypeKey is the attribute key for the class of a failed request's
+	// error (ie, 4xx, 5xx, or network).
+	ErrorTypeKey = attribute.Key("error.type")
+)
+
+// opNames are the operation names for the operation types.
+var opNames = map[firebase.OpType]string{
+	firebase.OpTypeGet:    "get",
+	firebase.OpTypePush:   "push",
+	firebase.OpTypeSet:    "set",
+	firebase.OpTypeUpdate: "update",
+	firebase.OpTypeRemove: "remove",
+}
+
+// instruments are the tracer and metric instruments.
+type instruments struct {
+	tracer trace.Tracer
+
+	duration    metric.Float64Histogram
+	retries     metric.Int64Counter
+	reconnects  metric.Int64Counter
+	disconnects metric.Int64Counter
+	dropped     metric.Int64Counter
+}
+
+// Instrumentation is an option that records spans and metrics for requests
+// made against the database ref (and its children) using the tracer and
+// meter providers. If a provider is nil, then the global provider is used.
+//
+// The following metrics are recorded:
+//
+//	firebase.client.request.duration  histogram of request latency (seconds)
+//	firebase.client.request.retries   count of retried request attempts
+//	firebase.client.watch.reconnects  count of Listen reconnection attempts
+//	firebase.client.watch.disconnects count of ended or failed streams
+//	firebase.client.watch.dropped     count of dropped events
+//
+// Events are only dropped when the WatchOverflow option has been set on the
+// ref with a drop policy.
+func Instrumentation(tp trace.TracerProvider, mp metric.MeterProvider) firebase.Option {
+	return func(r *firebase.DatabaseRef) error {
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+		if mp == nil {
+			mp = otel.GetMeterProvider()
+		}
+
+		inst, err := newInstruments(tp.Tracer(ScopeName), mp.Meter(ScopeName))
+		if err != nil {
+			return err
+		}
+
+		if err = firebase.Observe(inst.observe)(r); err != nil {
+			return err
+		}
+		return firebase.WatchHooks(firebase.WatchCallbacks{
+			OnDisconnect: inst.onDisconnect,
+			OnReconnect:  inst.onReconnect,
+			OnDrop:       inst.onDrop,
+		})(r)
+	}
+}
+
+// newInstruments creates the instruments.
+func newInstruments(tracer trace.Tracer, meter metric.Meter) (*instruments, error) {
+	var err error
+	inst := &instruments{
+		tracer: tracer,
+	}
+
+	inst.duration, err = meter.Float64Histogram(
+		"firebase.client.request.duration",
+		metric.WithDescription("Duration of Firebase REST requests."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inst.retries, err = meter.Int64Counter(
+		"firebase.client.request.retries",
+		metric.WithDescription("Number of retried Firebase REST request attempts."),
+		metric.WithUnit("{attempt}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inst.reconnects, err = meter.Int64Counter(
+		"firebase.client.watch.reconnects",
+		metric.WithDescription("Number of Listen reconnection attempts."),
+		metric.WithUnit("{reconnect}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inst.disconnects, err = meter.Int64Counter(
+		"firebase.client.watch.disconnects",
+		metric.WithDescription("Number of ended or failed Watch streams."),
+		metric.WithUnit("{disconnect}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inst.dropped, err = meter.Int64Counter(
+		"firebase.client.watch.dropped",
+		metric.WithDescription("Number of Watch events dropped due to a full event buffer."),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// observe records a span and metrics for the completed request.
+func (inst *instruments) observe(info *firebase.OperationInfo) {
+	ctxt := info.Context
+	if ctxt == nil {
+		ctxt = context.Background()
+	}
+
+	op := opNames[info.Op]
+	if op == "" {
+		op = strings.ToLower(string(info.Op))
+	}
+	attrs := []attribute.KeyValue{OpKey.String(op)}
+	if info.StatusCode != 0 {
+		attrs = append(attrs, StatusCodeKey.Int(info.StatusCode))
+	}
+	if info.Err != nil {
+		attrs = append(attrs, ErrorTypeKey.String(errorType(info)))
+	}
+
+	// span
+	_, span := inst.tracer.Start(ctxt, "firebase "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(info.Start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(PathKey.String(info.Path), AttemptKey.Int(info.Attempt)),
+	)
+	if info.Err != nil {
+		span.RecordError(info.Err)
+		span.SetStatus(codes.Error, info.Err.Error())
+	}
+	span.End(trace.WithTimestamp(info.Start.Add(info.Duration)))
+
+	// metrics
+	set := metric.WithAttributes(attrs...)
+	inst.duration.Record(ctxt, info.Duration.Seconds(), set)
+	if info.Attempt > 1 {
+		inst.retries.Add(ctxt, 1, metric.WithAttributes(OpKey.String(op)))
+	}
+}
+
+// onReconnect records a Listen reconnection attempt.
+func (inst *instruments) onReconnect(*firebase.DatabaseRef, int) {
+	inst.reconnects.Add(context.Background(), 1)
+}
+
+// onDrop records dropped events.
+func (inst *instruments) onDrop(_ *firebase.DatabaseRef, n int) {
+	inst.dropped.Add(context.Background(), int64(n))
+}
+
+// onDisconnect records an ended or failed stream, unless the stream ended
+// because its context was done.
+func (inst *instruments) onDisconnect(_ *firebase.DatabaseRef, err error) {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return
+	}
+	inst.disconnects.Add(context.Background(), 1)
+}
+
+// errorType returns the class of the failed request's error.
+func errorType(info *firebase.OperationInfo) string {
+	switch {
+	case info.StatusCode >= 500:
+		return "5xx"
+	case info.StatusCode >= 400:
+		return "4xx"
+	case info.StatusCode == 0:
+		return "network"
+	}
+	return "other"
+}
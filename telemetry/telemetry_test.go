@@ -0,0 +1,133 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/knq/firebase"
+)
+
+func TestInstrumentation(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer ts.Close()
+
+	tp, mp := new(testTracerProvider), new(testMeterProvider)
+	r, err := firebase.NewDatabaseRef(
+		firebase.URL(ts.URL+"/"),
+		firebase.Retry(firebase.RetryPolicy{Backoff: time.Millisecond}),
+		Instrumentation(tp, mp),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var s string
+	if err = r.Ref("a/b").Get(&s); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(tp.spans) != 2 {
+		t.Fatalf("expected 2 spans, got: %d", len(tp.spans))
+	}
+	if s := tp.spans[0]; s.name != "firebase get" || !s.failed {
+		t.Errorf("expected failed firebase get span, got: %s (%t)", s.name, s.failed)
+	}
+	if s := tp.spans[1]; s.name != "firebase get" || s.failed {
+		t.Errorf("expected firebase get span, got: %s (%t)", s.name, s.failed)
+	}
+	if mp.counts["firebase.client.request.retries"] != 1 {
+		t.Errorf("expected 1 retry, got: %d", mp.counts["firebase.client.request.retries"])
+	}
+	if mp.records != 2 {
+		t.Errorf("expected 2 duration records, got: %d", mp.records)
+	}
+}
+
+type testTracerProvider struct {
+	tracenoop.TracerProvider
+	spans []*testSpan
+}
+
+func (tp *testTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &testTracer{tp: tp}
+}
+
+type testTracer struct {
+	tracenoop.Tracer
+	tp *testTracerProvider
+}
+
+func (tr *testTracer) Start(ctxt context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &testSpan{name: name}
+	tr.tp.spans = append(tr.tp.spans, s)
+	return ctxt, s
+}
+
+type testSpan struct {
+	tracenoop.Span
+	name   string
+	failed bool
+}
+
+func (s *testSpan) RecordError(error, ...trace.EventOption) {
+	s.failed = true
+}
+
+type testMeterProvider struct {
+	metricnoop.MeterProvider
+	counts  map[string]int64
+	records int
+}
+
+func (mp *testMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	mp.counts = make(map[string]int64)
+	return &testMeter{mp: mp}
+}
+
+type testMeter struct {
+	metricnoop.Meter
+	mp *testMeterProvider
+}
+
+func (m *testMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &testCounter{name: name, mp: m.mp}, nil
+}
+
+func (m *testMeter) Float64Histogram(string, ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return &testHistogram{mp: m.mp}, nil
+}
+
+type testCounter struct {
+	metricnoop.Int64Counter
+	name string
+	mp   *testMeterProvider
+}
+
+func (c *testCounter) Add(_ context.Context, n int64, _ ...metric.AddOption) {
+	c.mp.counts[c.name] += n
+}
+
+type testHistogram struct {
+	metricnoop.Float64Histogram
+	mp *testMeterProvider
+}
+
+func (h *testHistogram) Record(context.Context, float64, ...metric.RecordOption) {
+	h.mp.records++
+}
@@ -1,6 +1,8 @@
 package firebase
 
 import (
+	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 )
@@ -109,9 +111,38 @@ func (t Time) String() string {
 // Error is a general Firebase error.
 type Error struct {
 	Err string `json:"error"`
+
+	// StatusCode is the HTTP status code of the server response, when the
+	// error was returned by the server.
+	StatusCode int `json:"-"`
+
+	// Attempts is the number of attempts made, when the operation was
+	// retried per the ref's RetryPolicy.
+	Attempts int `json:"-"`
+
+	// RateLimitWait is the time the request would have waited for the ref's
+	// rate limiter (see RateLimiter), when the context was done before the
+	// wait elapsed.
+	RateLimitWait time.Duration `json:"-"`
+
+	// network indicates the request failed before a response was received.
+	network bool
+
+	// body and header are the body and headers of the server response.
+	body   []byte
+	header http.Header
 }
 
 // Error satisfies the error interface.
 func (e *Error) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("firebase: %s (after %d attempts)", e.Err, e.Attempts)
+	}
 	return "firebase: " + e.Err
 }
+
+// Network returns true when the request failed before a response was
+// received from the server (ie, a connection or DNS error).
+func (e *Error) Network() bool {
+	return e.network
+}
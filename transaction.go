@@ -0,0 +1,168 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+)
+
+const (
+	// DefaultTransactionRetries is the default maximum number of times a
+	// transaction will be retried after a conflicting write.
+	DefaultTransactionRetries = 25
+)
+
+// TransactionFunc is a transaction mutation func, that is passed the current
+// JSON encoded value of a ref (which is "null" if there is no value), and
+// returns the new value to store at the ref.
+//
+// A TransactionFunc may be called multiple times, and should not have side
+// effects. Returning an error aborts the transaction.
+type TransactionFunc func(current json.RawMessage) (interface{}, error)
+
+// Transaction atomically modifies the value stored at Firebase database ref r
+// using the mutation func fn.
+//
+// Transaction reads the current value and its ETag, applies fn, and then
+// conditionally writes the result using the ETag. If the value was modified
+// by another client before the write, the write is rejected and fn is
+// reapplied to the new value, up to the ref's maximum transaction retries
+// (see the TransactionRetries option).
+//
+// The current value is decoded using the ref's path and field codecs (see
+// PathCodec and EncryptFields). As the tagged fields are determined by the
+// type of the value returned by fn, when the ref has field codecs, fn is
+// first applied to the current value with only the path codecs applied, and
+// then reapplied to the current value decoded for the type of the returned
+// value.
+func Transaction(r *DatabaseRef, fn TransactionFunc, opts ...QueryOption) error {
+	return TransactionContext(r, context.Background(), fn, opts...)
+}
+
+// TransactionContext atomically modifies the value stored at Firebase
+// database ref r using the mutation func fn, canceling the transaction when
+// the passed context is done. See Transaction for more information.
+func TransactionContext(r *DatabaseRef, ctxt context.Context, fn TransactionFunc, opts ...QueryOption) error {
+	// read current value and etag
+	current, etag, err := getWithETag(r, ctxt, opts...)
+	if err != nil {
+		return err
+	}
+
+	var hint interface{}
+	for i := 0; ; i++ {
+		// apply mutation
+		v, err := r.applyTransaction(fn, current, &hint)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			v = json.RawMessage("null")
+		}
+		body, err := r.encodeBody(OpTypeSet, v)
+		if err != nil {
+			return err
+		}
+
+		// conditionally write
+		res, err := r.do(ctxt, string(OpTypeSet), body, http.Header{
+			"If-Match": []string{etag},
+		}, opts...)
+		e, ok := err.(*Error)
+		if !ok || e.StatusCode != http.StatusPreconditionFailed {
+			if err != nil {
+				return err
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			return res.Body.Close()
+		}
+
+		// conflict
+		if i >= r.transactionRetries {
+			return &Error{
+				Err:        fmt.Sprintf("transaction aborted after %d retries", i),
+				StatusCode: e.StatusCode,
+			}
+		}
+
+		// server responds with current value and etag
+		current, etag = json.RawMessage(e.body), e.header.Get("ETag")
+		if etag == "" || len(current) == 0 {
+			if current, etag, err = getWithETag(r, ctxt, opts...); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// applyTransaction applies the mutation func to the current value, decoded
+// using the ref's path and field codecs.
+//
+// As the tagged fields of the value are not known until the mutation func
+// returns a value, the current value is decoded using the type of the value
+// previously returned (hint), and the mutation func is reapplied when the
+// returned value's type differs from hint.
+func (r *DatabaseRef) applyTransaction(fn TransactionFunc, current json.RawMessage, hint *interface{}) (interface{}, error) {
+	for i := 0; ; i++ {
+		buf, err := r.decodeValues(*hint, current)
+		if err != nil {
+			return nil, err
+		}
+		v, err := fn(buf)
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 || len(r.fieldCodecs) == 0 || v == nil || reflect.TypeOf(v) == reflect.TypeOf(*hint) {
+			return v, nil
+		}
+		*hint = v
+	}
+}
+
+// getWithETag retrieves the JSON encoded value and ETag for Firebase database
+// ref r.
+func getWithETag(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (json.RawMessage, string, error) {
+	return getIfNoneMatch(r, ctxt, "", opts...)
+}
+
+// getIfNoneMatch retrieves the raw value and ETag of the ref, unless the
+// ETag matches etag, in which case no value and the passed etag are returned.
+func getIfNoneMatch(r *DatabaseRef, ctxt context.Context, etag string, opts ...QueryOption) (json.RawMessage, string, error) {
+	h := http.Header{
+		"X-Firebase-ETag": []string{"true"},
+	}
+	if etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	res, err := r.do(ctxt, string(OpTypeGet), nil, h, opts...)
+	if e, ok := err.(*Error); ok && etag != "" && e.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err == ErrResponseTooLarge {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", &Error{
+			Err: fmt.Sprintf("could not read response: %v", err),
+		}
+	}
+
+	etag = res.Header.Get("ETag")
+	if etag == "" {
+		return nil, "", &Error{
+			Err: "server did not return an etag",
+		}
+	}
+
+	return json.RawMessage(buf), etag, nil
+}
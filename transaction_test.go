@@ -0,0 +1,159 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// counterServer is a test server holding a counter, that simulates
+// conflicting writes by another client for the first conflicts conditional
+// writes.
+type counterServer struct {
+	sync.Mutex
+	value     int
+	version   int
+	conflicts int
+	writes    int
+}
+
+func (s *counterServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch req.Method {
+	case "GET":
+		w.Header().Set("ETag", fmt.Sprintf("v%d", s.version))
+		fmt.Fprintf(w, "%d", s.value)
+	case "PUT":
+		s.writes++
+		if s.conflicts > 0 {
+			s.conflicts--
+			s.value, s.version = s.value+1, s.version+1
+		}
+		if req.Header.Get("If-Match") != fmt.Sprintf("v%d", s.version) {
+			w.Header().Set("ETag", fmt.Sprintf("v%d", s.version))
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprintf(w, "%d", s.value)
+			return
+		}
+		buf, _ := ioutil.ReadAll(req.Body)
+		s.value, _ = strconv.Atoi(string(buf))
+		s.version++
+		w.Write(buf)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	tests := []struct {
+		retries   int
+		conflicts int
+		exp       int
+		calls     int
+		err       bool
+	}{
+		{DefaultTransactionRetries, 0, 1, 1, false},
+		{DefaultTransactionRetries, 3, 4, 4, false},
+		{2, 2, 3, 3, false},
+		{2, 3, 3, 3, true},
+		{0, 1, 1, 1, true},
+	}
+
+	for i, test := range tests {
+		s := &counterServer{conflicts: test.conflicts}
+		ts := httptest.NewServer(s)
+
+		r, err := NewDatabaseRef(URL(ts.URL+"/"), TransactionRetries(test.retries))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		var calls int
+		err = r.Transaction(func(current json.RawMessage) (interface{}, error) {
+			calls++
+			var n int
+			if err := json.Unmarshal(current, &n); err != nil {
+				return nil, err
+			}
+			return n + 1, nil
+		})
+		ts.Close()
+
+		switch e, ok := err.(*Error); {
+		case test.err && (!ok || e.StatusCode != http.StatusPreconditionFailed):
+			t.Errorf("test %d expected precondition failed error, got: %v", i, err)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		}
+		if s.value != test.exp {
+			t.Errorf("test %d expected value %d, got: %d", i, test.exp, s.value)
+		}
+		if calls != test.calls {
+			t.Errorf("test %d expected %d calls, got: %d", i, test.calls, calls)
+		}
+	}
+}
+
+func TestTransactionAbort(t *testing.T) {
+	s := &counterServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	abort := errors.New("abort")
+	err = r.Transaction(func(json.RawMessage) (interface{}, error) {
+		return nil, abort
+	})
+	if err != abort {
+		t.Errorf("expected abort error, got: %v", err)
+	}
+	if s.writes != 0 {
+		t.Errorf("expected no writes, got: %d", s.writes)
+	}
+}
+
+func TestTransactionCancel(t *testing.T) {
+	s := &counterServer{conflicts: 100}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// cancel after the first conflict
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	err = r.TransactionContext(ctxt, func(json.RawMessage) (interface{}, error) {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return calls, nil
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusPreconditionFailed {
+		t.Errorf("expected context error, got: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got: %d", calls)
+	}
+	if s.writes != 1 {
+		t.Errorf("expected 1 write, got: %d", s.writes)
+	}
+}
@@ -0,0 +1,128 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// eventData is the JSON envelope of put and patch event data sent by
+// Firebase.
+type eventData struct {
+	Path string          `json:"path"`
+	Data json.RawMessage `json:"data"`
+}
+
+// decodeJSON decodes buf into an interface{} tree, preserving numbers as
+// json.Number.
+func decodeJSON(buf []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// splitPath splits a database path into its components.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// joinPath joins the database path and relative child path, returning an
+// absolute, normalized path.
+func joinPath(path, child string) string {
+	return "/" + strings.Join(append(splitPath(path), splitPath(child)...), "/")
+}
+
+// treeGet returns the value at the path components in the tree.
+func treeGet(root interface{}, parts []string) interface{} {
+	v := root
+	for _, p := range parts {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[p]
+	}
+	return v
+}
+
+// treeSet sets the value at the path components in the tree, returning the
+// new root. Setting a nil value removes the value, and prunes any empty
+// parents, following Firebase semantics.
+func treeSet(root interface{}, parts []string, v interface{}) interface{} {
+	if len(parts) == 0 {
+		return v
+	}
+
+	m, ok := root.(map[string]interface{})
+	if !ok {
+		if v == nil {
+			return root
+		}
+		m = make(map[string]interface{})
+	}
+
+	child := treeSet(m[parts[0]], parts[1:], v)
+	if child == nil {
+		delete(m, parts[0])
+	} else {
+		m[parts[0]] = child
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// treeApply applies a put or patch event's decoded data at path to the tree,
+// returning the new root.
+func treeApply(root interface{}, typ EventType, path string, data interface{}) interface{} {
+	parts := splitPath(path)
+	switch typ {
+	case EventTypePut:
+		return treeSet(root, parts, data)
+
+	case EventTypePatch:
+		m, _ := data.(map[string]interface{})
+		for k, v := range m {
+			root = treeSet(root, append(parts[:len(parts):len(parts)], splitPath(k)...), v)
+		}
+	}
+	return root
+}
+
+// mergeDiff computes a JSON merge patch (RFC 7386) that transforms a into b,
+// returning false if a and b are equivalent.
+func mergeDiff(a, b interface{}) (interface{}, bool) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if reflect.DeepEqual(a, b) {
+			return nil, false
+		}
+		return b, true
+	}
+
+	diff := make(map[string]interface{})
+	for k, bv := range bm {
+		if d, changed := mergeDiff(am[k], bv); changed {
+			diff[k] = d
+		}
+	}
+	for k := range am {
+		if _, ok := bm[k]; !ok {
+			diff[k] = nil
+		}
+	}
+
+	return diff, len(diff) != 0
+}
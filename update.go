@@ -0,0 +1,57 @@
+package firebase
+
+import (
+	"context"
+)
+
+// deleteValue is the type of the Delete sentinel.
+type deleteValue struct{}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (deleteValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// Delete is a sentinel value that removes the data at a path when used as a
+// value in an Update (or UpdateFields or UpdateMulti) map, and is encoded as
+// JSON null. For example:
+//
+//	err := r.Update(map[string]interface{}{
+//		"name":     "alice",
+//		"nickname": firebase.Delete,
+//	})
+//
+// Delete cannot be used as the value of Set, Push, or Update itself; use
+// Remove instead.
+var Delete interface{} = deleteValue{}
+
+// errDeleteValue is the error returned when Delete is used as the value of
+// an operation.
+var errDeleteValue = &Error{
+	Err: "Delete can only be used as a value in an update map; use Remove",
+}
+
+// UpdateFields updates the fields of Firebase database ref r in a single
+// request, where fields are keyed by their paths relative to r (ie, "name",
+// "profile/age"), and fields with the value Delete (or nil) are removed.
+//
+// Unlike Update, UpdateFields validates that the paths are not empty, and do
+// not overlap (ie, "profile" and "profile/age"), as Firebase rejects such
+// updates.
+func UpdateFields(r *DatabaseRef, fields map[string]interface{}, opts ...QueryOption) error {
+	return UpdateFieldsContext(r, context.Background(), fields, opts...)
+}
+
+// UpdateFieldsContext updates the fields of Firebase database ref r in a
+// single request, canceling the operation when the passed context is done.
+// See UpdateFields.
+func UpdateFieldsContext(r *DatabaseRef, ctxt context.Context, fields map[string]interface{}, opts ...QueryOption) error {
+	m, err := multiPathUpdate(fields)
+	if err != nil {
+		return err
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return UpdateContext(r, ctxt, m, opts...)
+}
@@ -0,0 +1,46 @@
+package firebase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdateFields(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PATCH" || req.URL.Path != "/users/a.json" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		buf, _ := ioutil.ReadAll(req.Body)
+		body = string(buf)
+		w.Write(buf)
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	u := r.Ref("users/a")
+
+	err = u.UpdateFields(map[string]interface{}{
+		"/name":       "alice",
+		"profile/age": 30,
+		"nickname":    Delete,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := `{"name":"alice","nickname":null,"profile/age":30}`; body != exp {
+		t.Errorf("expected %s, got: %s", exp, body)
+	}
+
+	if err = u.UpdateFields(map[string]interface{}{"profile": nil, "profile/age": 1}); err == nil {
+		t.Errorf("expected error for overlapping fields")
+	}
+	if err = u.Set(Delete); err != errDeleteValue {
+		t.Errorf("expected delete value error, got: %v", err)
+	}
+}
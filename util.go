@@ -15,12 +15,15 @@ func checkServerError(res *http.Response) error {
 		buf, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return &Error{
-				Err: fmt.Sprintf("unable to read server error: %v", err),
+				Err:        fmt.Sprintf("unable to read server error: %v", err),
+				StatusCode: res.StatusCode,
 			}
 		}
 		if len(buf) < 1 {
 			return &Error{
-				Err: fmt.Sprintf("empty server error: %s (%d)", res.Status, res.StatusCode),
+				Err:        fmt.Sprintf("empty server error: %s (%d)", res.Status, res.StatusCode),
+				StatusCode: res.StatusCode,
+				header:     res.Header,
 			}
 		}
 
@@ -28,9 +31,13 @@ func checkServerError(res *http.Response) error {
 		err = json.Unmarshal(buf, &e)
 		if err != nil {
 			return &Error{
-				Err: fmt.Sprintf("unknown server error: %s (%d)", string(buf), res.StatusCode),
+				Err:        fmt.Sprintf("unknown server error: %s (%d)", string(buf), res.StatusCode),
+				StatusCode: res.StatusCode,
+				body:       buf,
+				header:     res.Header,
 			}
 		}
+		e.StatusCode, e.body, e.header = res.StatusCode, buf, res.Header
 
 		return &e
 	}
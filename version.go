@@ -0,0 +1,118 @@
+package firebase
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// ModulePath is the module path of the firebase package.
+const ModulePath = "github.com/knq/firebase"
+
+// Build is the version and build information of the firebase package, as
+// compiled into the running binary.
+type Build struct {
+	// Version is the module version (ie, "v1.2.3"), or "(devel)" when the
+	// package was built from a local checkout.
+	Version string `json:"version"`
+
+	// Sum is the module checksum, if known.
+	Sum string `json:"sum,omitempty"`
+
+	// GoVersion is the Go version used to build the binary.
+	GoVersion string `json:"go_version"`
+
+	// Revision is the VCS revision, when the binary was built from a checkout
+	// of the module.
+	Revision string `json:"revision,omitempty"`
+
+	// Time is the VCS commit time (RFC3339), when the binary was built from a
+	// checkout of the module.
+	Time string `json:"time,omitempty"`
+
+	// Modified indicates the checkout had uncommitted changes.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// String satisfies the stringer interface.
+func (b Build) String() string {
+	s := b.Version + " (" + b.GoVersion
+	if b.Revision != "" {
+		s += ", rev " + b.Revision
+		if b.Modified {
+			s += "+modified"
+		}
+	}
+	if b.Time != "" {
+		s += ", " + b.Time
+	}
+	return s + ")"
+}
+
+var (
+	buildOnce sync.Once
+	build     Build
+)
+
+// BuildInfo returns the version and build information of the firebase
+// package, as read from the build information of the running binary.
+func BuildInfo() Build {
+	buildOnce.Do(func() {
+		build = Build{
+			Version:   "(devel)",
+			GoVersion: runtime.Version(),
+		}
+
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		// dependency of the main module
+		for _, m := range bi.Deps {
+			if m.Path != ModulePath {
+				continue
+			}
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			if m.Version != "" {
+				build.Version, build.Sum = m.Version, m.Sum
+			}
+			return
+		}
+
+		// main module (ie, the command line tools)
+		if bi.Main.Path != ModulePath {
+			return
+		}
+		if bi.Main.Version != "" {
+			build.Version, build.Sum = bi.Main.Version, bi.Main.Sum
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				build.Revision = s.Value
+			case "vcs.time":
+				build.Time = s.Value
+			case "vcs.modified":
+				build.Modified = s.Value == "true"
+			}
+		}
+	})
+	return build
+}
+
+// Version returns the module version of the firebase package (ie, "v1.2.3"),
+// or "(devel)" when unknown. See BuildInfo.
+func Version() string {
+	return BuildInfo().Version
+}
+
+// UserAgent returns the default User-Agent sent with requests made by a
+// Firebase database ref (ie, "knq-firebase/v1.2.3 go1.22.0").
+func UserAgent() string {
+	b := BuildInfo()
+	return "knq-firebase/" + strings.Trim(b.Version, "()") + " " + b.GoVersion
+}
@@ -0,0 +1,38 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	b := BuildInfo()
+	if b.Version == "" || b.GoVersion != runtime.Version() {
+		t.Errorf("expected version and go version, got: %+v", b)
+	}
+	if Version() != b.Version {
+		t.Errorf("expected version %q, got: %q", b.Version, Version())
+	}
+
+	var ua string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ua = req.Header.Get("User-Agent")
+		w.Write([]byte(`null`))
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var v interface{}
+	if err = r.Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ua != UserAgent() || !strings.HasPrefix(ua, "knq-firebase/") || strings.ContainsAny(ua, "()") {
+		t.Errorf("expected user agent %q, got: %q", UserAgent(), ua)
+	}
+}
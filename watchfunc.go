@@ -0,0 +1,48 @@
+package firebase
+
+import (
+	"context"
+)
+
+// WatchHandler handles an event emitted by WatchFunc. Returning an error
+// ends the watch.
+type WatchHandler func(ev *Event) error
+
+// WatchFunc watches a Firebase ref for events, calling f with each event
+// sent by the server, as an alternative to consuming the channel returned by
+// Watch.
+//
+// Events are handled synchronously, making backpressure explicit: when f is
+// slower than the rate of events, reading from the connection pauses once the
+// ref's event buffer (see WatchBufferLen) is full. WatchFunc blocks
+// until the watch ends, returning the error returned by f, the context's
+// error when the context is done, or the error that ended the watch (see the
+// terminal events of Watch), which is not passed to f.
+func WatchFunc(r *DatabaseRef, ctxt context.Context, f WatchHandler, opts ...QueryOption) error {
+	ctxt, cancel := context.WithCancel(ctxt)
+	defer cancel()
+
+	events, err := Watch(r, ctxt, opts...)
+	if err != nil {
+		return err
+	}
+
+	for ev := range events {
+		if ev.Err != nil {
+			err = ev.Err
+			continue
+		}
+		if err = f(ev); err != nil {
+			// stop watch and drain remaining events
+			cancel()
+			for range events {
+			}
+			return err
+		}
+	}
+
+	if ctxt.Err() != nil {
+		return ctxt.Err()
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWatchFunc(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\nevent: put\ndata: {\"path\":\"/\",\"data\":2}\n\n"))
+		if req.URL.Path == "/block.json" {
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewDatabaseRef(URL(ts.URL + "/"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// stream closed by server
+	var n int
+	err = r.WatchFunc(context.Background(), func(ev *Event) error {
+		if ev.Type != EventTypePut {
+			t.Errorf("expected put event, got: %s", ev.Type)
+		}
+		n++
+		return nil
+	})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	if n != 2 {
+		t.Errorf("expected 2 events, got: %d", n)
+	}
+
+	// handler error
+	errStop := errors.New("stop")
+	n = 0
+	err = r.Ref("block").WatchFunc(context.Background(), func(ev *Event) error {
+		n++
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("expected handler error, got: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 event, got: %d", n)
+	}
+
+	// context done
+	ctxt, cancel := context.WithCancel(context.Background())
+	err = r.Ref("block").WatchFunc(ctxt, func(ev *Event) error {
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context canceled, got: %v", err)
+	}
+}
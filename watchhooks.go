@@ -0,0 +1,75 @@
+package firebase
+
+// WatchCallbacks are lifecycle callbacks invoked by Watch and Listen, for use
+// in monitoring and alerting on stream health.
+//
+// Callbacks are invoked synchronously from the goroutine reading the stream,
+// and should not block.
+type WatchCallbacks struct {
+	// OnConnect is called when a stream has been successfully established
+	// with the Firebase server.
+	OnConnect func(r *DatabaseRef)
+
+	// OnDisconnect is called when an established stream ends, or when a
+	// connection attempt fails. The passed err is the reason the stream ended,
+	// and is the context's error when the stream ended because the context
+	// was done.
+	OnDisconnect func(r *DatabaseRef, err error)
+
+	// OnEvent is called for each event (including synthesized events) prior
+	// to the event being emitted.
+	OnEvent func(r *DatabaseRef, ev *Event)
+
+	// OnReconnect is called by Listen prior to reconnecting to the Firebase
+	// server, with the reconnection attempt (starting at 1).
+	OnReconnect func(r *DatabaseRef, attempt int)
+
+	// OnDrop is called when events are dropped due to a full event buffer,
+	// with the number of events dropped. See WatchOverflow.
+	OnDrop func(r *DatabaseRef, n int)
+}
+
+// onConnect invokes the OnConnect callbacks for the ref.
+func (r *DatabaseRef) onConnect() {
+	for _, cb := range r.watchCallbacks {
+		if cb.OnConnect != nil {
+			cb.OnConnect(r)
+		}
+	}
+}
+
+// onDisconnect invokes the OnDisconnect callbacks for the ref.
+func (r *DatabaseRef) onDisconnect(err error) {
+	for _, cb := range r.watchCallbacks {
+		if cb.OnDisconnect != nil {
+			cb.OnDisconnect(r, err)
+		}
+	}
+}
+
+// onReconnect invokes the OnReconnect callbacks for the ref.
+func (r *DatabaseRef) onReconnect(attempt int) {
+	for _, cb := range r.watchCallbacks {
+		if cb.OnReconnect != nil {
+			cb.OnReconnect(r, attempt)
+		}
+	}
+}
+
+// onEvent invokes the OnEvent callbacks for the ref.
+func (r *DatabaseRef) onEvent(ev *Event) {
+	for _, cb := range r.watchCallbacks {
+		if cb.OnEvent != nil {
+			cb.OnEvent(r, ev)
+		}
+	}
+}
+
+// onDrop invokes the OnDrop callbacks for the ref.
+func (r *DatabaseRef) onDrop(n int) {
+	for _, cb := range r.watchCallbacks {
+		if cb.OnDrop != nil {
+			cb.OnDrop(r, n)
+		}
+	}
+}
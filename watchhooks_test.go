@@ -0,0 +1,81 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWatchHooks(t *testing.T) {
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/denied.json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Permission denied"}`))
+			return
+		}
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n"))
+	}))
+	defer ts.Close()
+
+	var calls []string
+	record := func(format string, v ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, fmt.Sprintf(format, v...))
+	}
+	r, err := NewDatabaseRef(
+		URL(ts.URL+"/"),
+		WatchHooks(WatchCallbacks{
+			OnConnect: func(r *DatabaseRef) {
+				record("connect %s", r.URL().Path)
+			},
+			OnDisconnect: func(_ *DatabaseRef, err error) {
+				record("disconnect %t", err != nil)
+			},
+			OnEvent: func(_ *DatabaseRef, ev *Event) {
+				record("event %s", ev.Type)
+			},
+			OnReconnect: func(_ *DatabaseRef, attempt int) {
+				record("reconnect %d", attempt)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := r.Ref("/a").Listen(ctxt, []EventType{EventTypePut})
+	for i := 0; i < 2; i++ {
+		if ev := <-events; ev == nil || ev.Type != EventTypePut {
+			t.Fatalf("expected put event, got: %v", ev)
+		}
+	}
+	cancel()
+	for range events {
+	}
+
+	mu.Lock()
+	exp := "connect /a,event put,event closed,disconnect true,reconnect 1,connect /a,event put"
+	if s := strings.Join(calls, ","); !strings.HasPrefix(s, exp) {
+		t.Errorf("expected calls to start with %q, got: %q", exp, s)
+	}
+	calls = nil
+	mu.Unlock()
+
+	// failed connection
+	if _, err = r.Ref("/denied").Watch(context.Background()); err == nil {
+		t.Fatalf("expected error")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if s := strings.Join(calls, ","); s != "disconnect true" {
+		t.Errorf("expected disconnect, got: %q", s)
+	}
+}